@@ -68,10 +68,10 @@ func main() {
 		ctx,
 		dependencies.WithDB(conf),
 		dependencies.WithRiverQueue(conf),
-		dependencies.WithEventProvider(conf),
+		dependencies.WithTicketProviders(conf),
 		dependencies.WithPaymentGateway(conf),
 		dependencies.WithAuthenticationService(),
-		dependencies.WithResetService(conf),
+		dependencies.WithResetService(),
 	)
 	if err != nil {
 		slog.Error("unable to get dependencies", "error", err)
@@ -88,17 +88,27 @@ func main() {
 
 	river.AddWorker(
 		deps.RiverWorkers,
-		portriver.NewSelectSeatsWorker(queries, deps.DB, deps.RiverClient, deps.EventProvider),
+		portriver.NewSelectSeatsWorker(queries, deps.DB, deps.TicketProviders),
 	)
 
 	river.AddWorker(
 		deps.RiverWorkers,
-		portriver.NewConfirmOrderWorker(queries, deps.DB, deps.EventProvider),
+		portriver.NewConfirmOrderWorker(queries, deps.DB, deps.TicketProviders),
 	)
 
 	river.AddWorker(
 		deps.RiverWorkers,
-		portriver.NewCancelBookingWorker(queries, deps.DB, deps.RiverClient, deps.EventProvider),
+		portriver.NewCancelBookingWorker(queries, deps.DB, deps.TicketProviders),
+	)
+
+	river.AddWorker(
+		deps.RiverWorkers,
+		portriver.NewFailBookingWorker(queries, deps.DB, deps.TicketProviders),
+	)
+
+	river.AddWorker(
+		deps.RiverWorkers,
+		portriver.NewExpireBookingWorker(queries, deps.DB),
 	)
 
 	river.AddWorker(
@@ -106,7 +116,71 @@ func main() {
 		portriver.NewRefundPaymentWorker(queries, deps.DB, deps.PaymentGateway, conf),
 	)
 
-	if err := deps.InitRiverClient(conf.River.MaxWorkers); err != nil {
+	river.AddWorker(
+		deps.RiverWorkers,
+		portriver.NewRefundSeatWorker(queries, deps.DB, deps.PaymentGateway, conf),
+	)
+
+	river.AddWorker(
+		deps.RiverWorkers,
+		portriver.NewCapturePaymentWorker(queries, deps.PaymentGateway, conf),
+	)
+
+	river.AddWorker(
+		deps.RiverWorkers,
+		portriver.NewOfferWaitlistSeatsWorker(queries, deps.DB, deps.TicketProviders, conf.Waitlist.OfferTTL),
+	)
+
+	river.AddWorker(
+		deps.RiverWorkers,
+		portriver.NewExpireWaitlistOfferWorker(queries, deps.DB),
+	)
+
+	river.AddWorker(
+		deps.RiverWorkers,
+		portriver.NewCleanupIdempotencyKeysWorker(queries, conf.Idempotency.TTL),
+	)
+
+	river.AddWorker(
+		deps.RiverWorkers,
+		portriver.NewReconcilePaymentsWorker(queries, deps.DB, deps.PaymentGateway, conf),
+	)
+
+	river.AddWorker(
+		deps.RiverWorkers,
+		portriver.NewReconcilePaymentWorker(queries, deps.DB, deps.PaymentGateway, conf),
+	)
+
+	river.AddWorker(
+		deps.RiverWorkers,
+		portriver.NewSyncInventoryWorker(queries, deps.DB, deps.TicketProviders, conf),
+	)
+
+	periodicJobs := []*river.PeriodicJob{
+		river.NewPeriodicJob(
+			river.PeriodicInterval(conf.Idempotency.CleanupInterval),
+			func() (river.JobArgs, *river.InsertOpts) {
+				return portriver.CleanupIdempotencyKeysArgs{}, nil
+			},
+			nil,
+		),
+		river.NewPeriodicJob(
+			river.PeriodicInterval(conf.PaymentReconciliation.Interval),
+			func() (river.JobArgs, *river.InsertOpts) {
+				return portriver.ReconcilePaymentsArgs{}, nil
+			},
+			nil,
+		),
+		river.NewPeriodicJob(
+			river.PeriodicInterval(conf.InventorySync.Interval),
+			func() (river.JobArgs, *river.InsertOpts) {
+				return portriver.SyncInventoryArgs{}, nil
+			},
+			nil,
+		),
+	}
+
+	if err := deps.InitRiverClient(conf.River.MaxWorkers, periodicJobs...); err != nil {
 		slog.Error("unable to init river client", "error", err)
 		return
 	}
@@ -122,6 +196,7 @@ func main() {
 			queries,
 			deps.DB,
 			deps.RiverClient,
+			deps.TicketProviders,
 			deps.PaymentGateway,
 			deps.ResetService,
 			conf,
@@ -143,13 +218,33 @@ func main() {
 		})
 	})
 
+	// Idempotency-Key for retried bookings, seat selection and payments
+	idempotentRoutes := map[string]bool{
+		http.MethodPost + " /api/bookings":                  true,
+		http.MethodPatch + " /api/seats/select":             true,
+		http.MethodPatch + " /api/seats/select/bulk":        true,
+		http.MethodPatch + " /api/seats/select/best":        true,
+		http.MethodPatch + " /api/bookings/initiatePayment": true,
+	}
+	router.Use(func(next http.Handler) http.Handler {
+		idempotent := middleware.IdempotencyMiddleware(queries, conf.Idempotency.TTL, conf.Idempotency.InProgressTTL)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if idempotentRoutes[r.Method+" "+r.URL.Path] {
+				idempotent.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+
 	//////////////
 
 	// Setup server
 
 	server := &http.Server{
 		Handler: handlers.CORS(
-			handlers.AllowedHeaders([]string{"Authorization", "Content-Type", "X-Requested-With", "Accept", "Origin"}),
+			handlers.AllowedHeaders([]string{"Authorization", "Content-Type", "X-Requested-With", "Accept", "Origin", middleware.IdempotencyKeyHeader}),
 			handlers.AllowedOrigins([]string{"*"}),
 			handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "OPTIONS", "PATCH", "DELETE"}),
 		)(router),
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"hackload/pkg/paymentgateway/fakegateway"
+)
+
+// Локальный платежный шлюз для разработки: те же эндпоинты, что у настоящего,
+// платежи хранятся в памяти и пропадают при перезапуске.
+// По умолчанию мерчант берется из тех же переменных окружения, что читает API.
+func main() {
+	var (
+		port       string
+		baseURL    string
+		teamSlug   string
+		password   string
+		paymentTTL time.Duration
+	)
+
+	flag.StringVar(&port, "port", "8090", "Port to listen on")
+	flag.StringVar(&baseURL, "base-url", "", "Public address of the gateway for payment URLs (default http://<request host>)")
+	flag.StringVar(&teamSlug, "team", os.Getenv("PAYMENT_PROVIDER_MERCHANT_ID"), "Merchant team slug")
+	flag.StringVar(&password, "password", os.Getenv("PAYMENT_PROVIDER_MERCHANT_PASSWORD"), "Merchant password")
+	flag.DurationVar(&paymentTTL, "payment-ttl", 15*time.Minute, "How long a payment waits for the form")
+	flag.Parse()
+
+	if teamSlug == "" || password == "" {
+		slog.Error("team and password are required, set -team/-password or PAYMENT_PROVIDER_MERCHANT_ID/PAYMENT_PROVIDER_MERCHANT_PASSWORD")
+		return
+	}
+
+	gateway := fakegateway.New(
+		fakegateway.WithTeam(teamSlug, password),
+		fakegateway.WithBaseURL(baseURL),
+		fakegateway.WithPaymentTTL(paymentTTL),
+	)
+
+	server := &http.Server{
+		Handler: gateway,
+		Addr:    fmt.Sprintf(":%s", port),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("failed to shutdown fake payment gateway", "error", err)
+		}
+	}()
+
+	slog.Info("starting fake payment gateway", "address", server.Addr, "team", teamSlug)
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("fake payment gateway failed", "error", err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"hackload/pkg/eventprovider/fakeprovider"
+)
+
+// Локальный провайдер билетов для разработки: те же эндпоинты, что у настоящего,
+// заказы и места хранятся в памяти и пропадают при перезапуске.
+func main() {
+	var (
+		port      string
+		places    int
+		rowSize   int
+		seed      uint64
+		latency   time.Duration
+		jitter    time.Duration
+		errorRate float64
+	)
+
+	flag.StringVar(&port, "port", "8091", "Port to listen on")
+	flag.IntVar(&places, "places", 100000, "Number of places to seed")
+	flag.IntVar(&rowSize, "row-size", 1000, "Seats per row when seeding")
+	flag.Uint64Var(&seed, "seed", 0, "Random seed for reproducible ids, 0 for random ids")
+	flag.DurationVar(&latency, "latency", 0, "Delay added to every request")
+	flag.DurationVar(&jitter, "jitter", 0, "Random delay added on top of latency, up to this value")
+	flag.Float64Var(&errorRate, "error-rate", 0, "Share of requests (0..1) answered with 500")
+	flag.Parse()
+
+	opts := []fakeprovider.Option{
+		fakeprovider.WithRowSize(rowSize),
+		fakeprovider.WithLatency(latency, jitter),
+		fakeprovider.WithErrorRate(errorRate),
+	}
+	if seed != 0 {
+		opts = append(opts, fakeprovider.WithRandSeed(seed))
+	}
+	opts = append(opts, fakeprovider.WithPlaces(places))
+
+	server := &http.Server{
+		Handler: fakeprovider.New(opts...),
+		Addr:    fmt.Sprintf(":%s", port),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Error("failed to shutdown fake event provider", "error", err)
+		}
+	}()
+
+	slog.Info("starting fake event provider", "address", server.Addr, "places", places)
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("fake event provider failed", "error", err)
+	}
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
 )
@@ -26,24 +29,111 @@ type Config struct {
 		Addr string `env:"ADDR"`
 	} `env:", prefix=API_"`
 
+	// Бронирования
+	Booking struct {
+		// Сколько держать места за бронью в статусе CREATED
+		CreatedTTL time.Duration `env:"CREATED_TTL, default=10m"`
+		// Сколько ждать оплату брони в статусе PAYMENT_INITIATED
+		PaymentTTL time.Duration `env:"PAYMENT_TTL, default=15m"`
+	} `env:", prefix=BOOKING_"`
+
+	// Лист ожидания
+	Waitlist struct {
+		// Сколько держать предложенные из листа ожидания места
+		OfferTTL time.Duration `env:"OFFER_TTL, default=10m"`
+	} `env:", prefix=WAITLIST_"`
+
+	// Идемпотентность (заголовок Idempotency-Key)
+	Idempotency struct {
+		// Сколько хранить первый ответ для повторов с тем же ключом
+		TTL time.Duration `env:"TTL, default=24h"`
+		// Сколько ключ остается занятым запросом без ответа. Запрос, оборванный падением процесса,
+		// иначе держал бы ключ весь TTL. Должно быть дольше самого медленного запроса
+		InProgressTTL time.Duration `env:"IN_PROGRESS_TTL, default=1m"`
+		// Как часто удалять просроченные ключи
+		CleanupInterval time.Duration `env:"CLEANUP_INTERVAL, default=1h"`
+	} `env:", prefix=IDEMPOTENCY_"`
+
+	// Сверка незавершенных платежей с платежным шлюзом
+	PaymentReconciliation struct {
+		// Как часто сверять платежи
+		Interval time.Duration `env:"INTERVAL, default=5m"`
+		// Через сколько после создания платеж в INIT считается потерянным.
+		// Должно быть больше BOOKING_PAYMENT_TTL, чтобы не мешать пользователю оплатить
+		StaleAfter time.Duration `env:"STALE_AFTER, default=30m"`
+		// Сколько платежей сверять за один запуск
+		BatchSize int64 `env:"BATCH_SIZE, default=100"`
+	} `env:", prefix=PAYMENT_RECONCILIATION_"`
+
+	// Сверка мест с провайдером билетов. Брони не меняются, поэтому сверку можно запускать в проде
+	InventorySync struct {
+		// Как часто сверять места
+		Interval time.Duration `env:"INTERVAL, default=1h"`
+		// Сколько мест запрашивать у провайдера за раз
+		PageSize int `env:"PAGE_SIZE, default=1000"`
+		// Сколько расхождений перечислять в отчете, остальные только считаются
+		ReportLimit int `env:"REPORT_LIMIT, default=100"`
+	} `env:", prefix=INVENTORY_SYNC_"`
+
 	// Провайдер билетов (Event Provider)
 	EventProvider struct {
 		Addr string `env:"ADDR"`
+		// Заказ у провайдера начинается при создании брони, а места выбираются в нем
+		// сразу при добавлении в бронь: место, занятое другим дистрибьютором, отклоняется до оплаты
+		ReserveOnSelect bool `env:"RESERVE_ON_SELECT, default=false"`
+		// Событие, в которое сброс (POST /api/reset и preloader) загружает места провайдера
+		EventID int64 `env:"EVENT_ID, default=1"`
 	} `env:", prefix=EVENT_PROVIDER_"`
 
+	// Провайдеры билетов по значению events_archive.provider, ключи через запятую: EVENT_PROVIDERS=TICKETRU,SHOWTIME.
+	// Настройки провайдера читаются из EVENT_PROVIDERS_<КЛЮЧ>_*. События других провайдеров и без провайдера
+	// обслуживает EVENT_PROVIDER_ADDR
+	EventProviderKeys []string `env:"EVENT_PROVIDERS"`
+	EventProviders    []EventProviderConfig
+
 	// API Платежного шлюза
 	PaymentProvider struct {
 		Addr             string `env:"ADDR"`
 		MerchantPassword string `env:"MERCHANT_PASSWORD"`
 		MerchantID       string `env:"MERCHANT_ID"`
+		// Двухстадийная оплата: при оплате деньги блокируются, а списываются
+		// только после подтверждения заказа провайдером билетов
+		TwoStage bool `env:"TWO_STAGE, default=false"`
 	} `env:", prefix=PAYMENT_PROVIDER_"`
 }
 
+// EventProviderConfig is read from EVENT_PROVIDERS_<KEY>_* for each key of EVENT_PROVIDERS.
+type EventProviderConfig struct {
+	// Значение events_archive.provider, например 'Билеттер'
+	Name string `env:"NAME, required"`
+	// API провайдера, пока поддерживается только eventprovider
+	Adapter string `env:"ADAPTER, default=eventprovider"`
+	Addr    string `env:"ADDR, required"`
+	// См. EVENT_PROVIDER_RESERVE_ON_SELECT
+	ReserveOnSelect bool `env:"RESERVE_ON_SELECT, default=false"`
+	// См. EVENT_PROVIDER_EVENT_ID. Без него сброс не загружает места провайдера
+	EventID int64 `env:"EVENT_ID"`
+}
+
 func GetConfig(ctx context.Context) (*Config, error) {
 	var c Config
 	if err := envconfig.Process(ctx, &c); err != nil {
 		return nil, err
 	}
 
+	for _, key := range c.EventProviderKeys {
+		prefix := "EVENT_PROVIDERS_" + strings.ToUpper(strings.TrimSpace(key)) + "_"
+
+		var provider EventProviderConfig
+		if err := envconfig.ProcessWith(ctx, &envconfig.Config{
+			Target:   &provider,
+			Lookuper: envconfig.PrefixLookuper(prefix, envconfig.OsLookuper()),
+		}); err != nil {
+			return nil, fmt.Errorf("event provider %s: %w", key, err)
+		}
+
+		c.EventProviders = append(c.EventProviders, provider)
+	}
+
 	return &c, nil
 }
@@ -3,12 +3,13 @@ package dependencies
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log/slog"
 
 	"hackload/internal/config"
 	"hackload/internal/service"
 	"hackload/internal/sqlc"
-	"hackload/pkg/eventprovider"
+	"hackload/internal/ticketing"
 	"hackload/pkg/paymentgateway"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -25,7 +26,7 @@ type Dependencies struct {
 	RiverDriver           *riversqlite.Driver
 	RiverWorkers          *river.Workers
 	RiverClient           *river.Client[*sql.Tx]
-	EventProvider         eventprovider.ClientInterface
+	TicketProviders       *ticketing.Registry
 	PaymentGateway        paymentgateway.ClientInterface
 	AuthenticationService service.AuthenticationService
 	ResetService          service.ResetService
@@ -157,12 +158,13 @@ func WithRiverQueue(conf *config.Config) Option {
 	}
 }
 
-func (d *Dependencies) InitRiverClient(maxWorkers int) error {
+func (d *Dependencies) InitRiverClient(maxWorkers int, periodicJobs ...*river.PeriodicJob) error {
 	riverClient, err := river.NewClient(d.RiverDriver, &river.Config{
 		Queues: map[string]river.QueueConfig{
 			river.QueueDefault: {MaxWorkers: maxWorkers},
 		},
-		Workers: d.RiverWorkers,
+		Workers:      d.RiverWorkers,
+		PeriodicJobs: periodicJobs,
 	})
 	if err != nil {
 		return err
@@ -180,32 +182,31 @@ func WithAuthenticationService() Option {
 	}
 }
 
-func WithResetService(conf *config.Config) Option {
+// WithResetService reloads the places through the ticket providers, so it goes after WithTicketProviders.
+func WithResetService() Option {
 	return func(ctx context.Context, d *Dependencies) error {
-		queries := sqlc.New(d.DB)
-
-		// Create EventProvider client with responses
-		epClient, err := eventprovider.NewClientWithResponses(conf.EventProvider.Addr)
-		if err != nil {
-			return err
+		if d.TicketProviders == nil {
+			return errors.New("reset service requires ticket providers")
 		}
 
+		queries := sqlc.New(d.DB)
 		d.ResetService = service.NewResetService(
 			queries,
 			d.DB,
-			epClient,
+			d.TicketProviders,
 		)
 		return nil
 	}
 }
 
-func WithEventProvider(conf *config.Config) Option {
+// WithTicketProviders routes the events to their providers: EVENT_PROVIDERS and EVENT_PROVIDER_ADDR for the rest.
+func WithTicketProviders(conf *config.Config) Option {
 	return func(ctx context.Context, d *Dependencies) error {
-		client, err := eventprovider.NewClient(conf.EventProvider.Addr)
+		registry, err := ticketing.NewRegistryFromConfig(sqlc.New(d.DB), conf)
 		if err != nil {
 			return err
 		}
-		d.EventProvider = client
+		d.TicketProviders = registry
 		return nil
 	}
 }
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// BookingStatus статус брони (bookings.status)
+type BookingStatus string
+
+const (
+	BookingStatusCreated          BookingStatus = "CREATED"
+	BookingStatusPaymentInitiated BookingStatus = "PAYMENT_INITIATED"
+	BookingStatusConfirmed        BookingStatus = "CONFIRMED"
+	BookingStatusCancelled        BookingStatus = "CANCELLED"
+	// Оплаченная бронь, места для которой не удалось получить у провайдера: заказ
+	// отменен, места освобождены, деньги возвращаются. Причина - в booking_status_history
+	BookingStatusFailed BookingStatus = "FAILED"
+)
+
+// Инициаторы перехода статуса брони (booking_status_history.actor)
+const (
+	ActorPaymentGateway = "payment_gateway"
+	ActorSystem         = "system"
+)
+
+// UserActor returns the booking_status_history.actor value for a user.
+func UserActor(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+var ErrInvalidBookingTransition = errors.New("invalid booking status transition")
+
+// bookingTransitions lists the statuses each status may move to.
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	BookingStatusCreated:          {BookingStatusPaymentInitiated, BookingStatusCancelled},
+	BookingStatusPaymentInitiated: {BookingStatusConfirmed, BookingStatusCreated, BookingStatusCancelled},
+	BookingStatusConfirmed:        {BookingStatusCancelled, BookingStatusFailed},
+	BookingStatusCancelled:        {},
+	BookingStatusFailed:           {},
+}
+
+func (s BookingStatus) CanTransitionTo(to BookingStatus) bool {
+	return slices.Contains(bookingTransitions[s], to)
+}
+
+// ValidateTransition returns ErrInvalidBookingTransition if the booking may not move from s to to.
+func (s BookingStatus) ValidateTransition(to BookingStatus) error {
+	if !s.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidBookingTransition, s, to)
+	}
+	return nil
+}
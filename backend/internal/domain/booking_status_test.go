@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBookingStatusTransitions(t *testing.T) {
+	statuses := []BookingStatus{
+		BookingStatusCreated,
+		BookingStatusPaymentInitiated,
+		BookingStatusConfirmed,
+		BookingStatusCancelled,
+		BookingStatusFailed,
+	}
+
+	allowed := map[[2]BookingStatus]bool{
+		{BookingStatusCreated, BookingStatusPaymentInitiated}:   true,
+		{BookingStatusCreated, BookingStatusCancelled}:          true,
+		{BookingStatusPaymentInitiated, BookingStatusConfirmed}: true,
+		// Брошенная или неуспешная попытка оплаты, пока места удерживаются
+		{BookingStatusPaymentInitiated, BookingStatusCreated}:   true,
+		{BookingStatusPaymentInitiated, BookingStatusCancelled}: true,
+		{BookingStatusConfirmed, BookingStatusCancelled}:        true,
+		{BookingStatusConfirmed, BookingStatusFailed}:           true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]BookingStatus{from, to}]
+
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s -> %s: CanTransitionTo() = %v, want %v", from, to, got, want)
+			}
+
+			err := from.ValidateTransition(to)
+			if want && err != nil {
+				t.Errorf("%s -> %s: ValidateTransition() = %v, want nil", from, to, err)
+			}
+			if !want && !errors.Is(err, ErrInvalidBookingTransition) {
+				t.Errorf("%s -> %s: ValidateTransition() = %v, want ErrInvalidBookingTransition", from, to, err)
+			}
+		}
+	}
+}
+
+func TestBookingStatusUnknown(t *testing.T) {
+	unknown := BookingStatus("PAID")
+
+	if unknown.CanTransitionTo(BookingStatusConfirmed) {
+		t.Error("unknown status may move to CONFIRMED")
+	}
+	if BookingStatusCreated.CanTransitionTo(unknown) {
+		t.Error("CREATED may move to an unknown status")
+	}
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency валюта цен, для которых она не указана
+const DefaultCurrency = "KZT"
+
+// Money сумма в минимальных единицах валюты (тиын, цент): 40000.00 хранится как 4000000.
+// Суммы складываются как целые числа, без округлений float.
+type Money int64
+
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// ParseMoney parses a decimal amount with at most two fraction digits, e.g. "40000", "40000.5", "40000.00".
+func ParseMoney(s string) (Money, error) {
+	whole, frac, hasFrac := strings.Cut(strings.TrimSpace(s), ".")
+	if !isDigits(whole) || len(frac) > 2 || (hasFrac && !isDigits(frac)) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/100-1 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+
+	var minor int64
+	if hasFrac {
+		minor, _ = strconv.ParseInt((frac + "0")[:2], 10, 64)
+	}
+
+	return Money(units*100 + minor), nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the amount the way prices are shown in the API, e.g. 120000.00
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
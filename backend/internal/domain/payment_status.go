@@ -0,0 +1,56 @@
+package domain
+
+import "strings"
+
+// Статусы платежа (booking_payments.status)
+const (
+	PaymentStatusInit = "INIT"
+	// Двухстадийный платеж: деньги заблокированы, но еще не списаны
+	PaymentStatusAuthorized = "AUTHORIZED"
+	PaymentStatusSuccess    = "SUCCESS"
+	PaymentStatusFail       = "FAIL"
+	PaymentStatusRefunded   = "REFUNDED"
+	// Блокировка денег по двухстадийному платежу снята без списания
+	PaymentStatusVoided = "VOIDED"
+	// Попытка оплаты брошена: пользователь начал новую, старая отменена в платежном шлюзе
+	PaymentStatusAbandoned = "ABANDONED"
+)
+
+// Статусы возврата (booking_refunds.status)
+const (
+	RefundStatusPending = "PENDING"
+	// Место освобождено в EventProvider, деньги еще не возвращены
+	RefundStatusPlaceReleased = "PLACE_RELEASED"
+	RefundStatusRefunded      = "REFUNDED"
+)
+
+// GatewayPaymentOutcome maps a payment gateway status onto booking_payments.status.
+// Intermediate statuses (NEW, FORM_SHOWED, AUTHORIZING...) map to "".
+func GatewayPaymentOutcome(gatewayStatus string) string {
+	switch strings.ToUpper(gatewayStatus) {
+	case "AUTHORIZED":
+		return PaymentStatusAuthorized
+	case "CONFIRMED", "COMPLETED":
+		return PaymentStatusSuccess
+	case "REJECTED", "CANCELLED", "CANCELED", "DEADLINE_EXPIRED", "EXPIRED", "FAILED", "AUTH_FAIL":
+		return PaymentStatusFail
+	case "REFUNDED", "PARTIAL_REFUNDED", "REVERSED":
+		return PaymentStatusRefunded
+	default:
+		return ""
+	}
+}
+
+// IsPaymentSettled reports whether the payment went through, whatever happened to the money
+// afterwards, and must not be failed or confirmed again.
+func IsPaymentSettled(status *string) bool {
+	if status == nil {
+		return false
+	}
+	switch *status {
+	case PaymentStatusAuthorized, PaymentStatusSuccess, PaymentStatusRefunded, PaymentStatusVoided:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,12 @@
+package domain
+
+// WaitlistStatus статус заявки в листе ожидания (waitlist_entries.status)
+type WaitlistStatus string
+
+const (
+	WaitlistStatusWaiting   WaitlistStatus = "WAITING"
+	WaitlistStatusOffered   WaitlistStatus = "OFFERED"
+	WaitlistStatusFulfilled WaitlistStatus = "FULFILLED"
+	WaitlistStatusExpired   WaitlistStatus = "EXPIRED"
+	WaitlistStatusCancelled WaitlistStatus = "CANCELLED"
+)
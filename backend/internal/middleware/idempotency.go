@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"hackload/internal/sqlc"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyMiddleware stores the first response per user and Idempotency-Key
+// and replays it for retries within ttl. Requests without the header pass through.
+// A key claimed by a request that has not answered within inProgressTTL is claimed again.
+// It must run after AuthenticationMiddleware.
+func IdempotencyMiddleware(queries *sqlc.Queries, ttl time.Duration, inProgressTTL time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > 255 {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			session, ok := GetUserFromContext(r.Context())
+			if !ok {
+				fmt.Println("ERROR: middleware.GetUserFromContext: false")
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// Same key must not be reused for a different request
+			hasher := sha256.New()
+			hasher.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+			hasher.Write(body)
+			requestHash := hex.EncodeToString(hasher.Sum(nil))
+
+			now := time.Now().UTC()
+
+			// 1. Free the key if its window has passed, or if the request that claimed it
+			// has not answered in time and will not: its process died before releasing the key
+			if err := queries.DeleteExpiredIdempotencyKey(r.Context(), sqlc.DeleteExpiredIdempotencyKeyParams{
+				UserID:                  session.UserID,
+				Key:                     key,
+				ExpiredBefore:           now.Add(-ttl),
+				InProgressExpiredBefore: now.Add(-inProgressTTL),
+			}); err != nil {
+				fmt.Println("ERROR: queries.DeleteExpiredIdempotencyKey:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			// 2. Claim the key, only one request wins
+			claimed, err := queries.ClaimIdempotencyKey(r.Context(), sqlc.ClaimIdempotencyKeyParams{
+				UserID:      session.UserID,
+				Key:         key,
+				RequestHash: requestHash,
+				CreatedAt:   now,
+			})
+			if err != nil {
+				fmt.Println("ERROR: queries.ClaimIdempotencyKey:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			// 3. Key already used - replay the stored response
+			if claimed == 0 {
+				stored, err := queries.GetIdempotencyKey(r.Context(), sqlc.GetIdempotencyKeyParams{
+					UserID: session.UserID,
+					Key:    key,
+				})
+				if err != nil {
+					if err == sql.ErrNoRows {
+						// First request failed and released the key in between
+						http.Error(w, "Request with this Idempotency-Key failed, retry", http.StatusConflict)
+						return
+					}
+					fmt.Println("ERROR: queries.GetIdempotencyKey:", err)
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+
+				if stored.RequestHash != requestHash {
+					http.Error(w, "Idempotency-Key is already used for a different request", http.StatusUnprocessableEntity)
+					return
+				}
+
+				if stored.StatusCode == nil {
+					http.Error(w, "Request with this Idempotency-Key is in progress", http.StatusConflict)
+					return
+				}
+
+				if stored.ContentType != nil {
+					w.Header().Set("Content-Type", *stored.ContentType)
+				}
+				if stored.Location != nil {
+					w.Header().Set("Location", *stored.Location)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(int(*stored.StatusCode))
+				w.Write(stored.Body)
+				return
+			}
+
+			// 4. Run the handler and store its response.
+			// Server errors are not stored, the key is released so the client can retry.
+			rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			saved := false
+			defer func() {
+				if saved {
+					return
+				}
+				if err := queries.DeleteIdempotencyKey(context.WithoutCancel(r.Context()), sqlc.DeleteIdempotencyKeyParams{
+					UserID: session.UserID,
+					Key:    key,
+				}); err != nil {
+					fmt.Println("ERROR: queries.DeleteIdempotencyKey:", err)
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+
+			if rec.statusCode >= http.StatusInternalServerError {
+				return
+			}
+
+			statusCode := int64(rec.statusCode)
+			err = queries.SaveIdempotencyKeyResponse(context.WithoutCancel(r.Context()), sqlc.SaveIdempotencyKeyResponseParams{
+				StatusCode:  &statusCode,
+				ContentType: headerPtr(w.Header(), "Content-Type"),
+				Location:    headerPtr(w.Header(), "Location"),
+				Body:        rec.body.Bytes(),
+				UserID:      session.UserID,
+				Key:         key,
+			})
+			if err != nil {
+				fmt.Println("ERROR: queries.SaveIdempotencyKeyResponse:", err)
+				return
+			}
+			saved = true
+		})
+	}
+}
+
+// responseRecorder passes the response through and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if !r.wroteHeader {
+		r.statusCode = statusCode
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func headerPtr(h http.Header, name string) *string {
+	v := h.Get(name)
+	if v == "" {
+		return nil
+	}
+	return &v
+}
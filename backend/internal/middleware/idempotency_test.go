@@ -0,0 +1,191 @@
+//go:build sqlite_fts5
+
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hackload/internal/middleware"
+	"hackload/internal/service"
+	"hackload/internal/sqlc"
+	"hackload/internal/testenv"
+)
+
+const (
+	idempotencyTTL = 24 * time.Hour
+	inProgressTTL  = time.Minute
+)
+
+// idempotencyEnv serves user 1 a handler behind IdempotencyMiddleware.
+type idempotencyEnv struct {
+	queries *sqlc.Queries
+	handler http.Handler
+	// Сколько раз запрос дошел до обработчика
+	calls atomic.Int64
+}
+
+func newIdempotencyEnv(t *testing.T, next http.HandlerFunc) *idempotencyEnv {
+	t.Helper()
+
+	deps := testenv.New(t)
+	env := &idempotencyEnv{queries: sqlc.New(deps.DB)}
+	if _, err := deps.DB.Exec(`INSERT INTO users (user_id, email, password_hash, first_name, surname, registered_at, is_active, last_logged_in)
+		VALUES (1, 'a@example.com', 'x', 'A', 'A', '2025-01-01', 1, '2025-01-01')`); err != nil {
+		t.Fatal(err)
+	}
+
+	env.handler = middleware.IdempotencyMiddleware(env.queries, idempotencyTTL, inProgressTTL)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			env.calls.Add(1)
+			next(w, r)
+		}),
+	)
+
+	return env
+}
+
+// request sends body with the Idempotency-Key on behalf of user 1.
+func (e *idempotencyEnv) request(key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/bookings", strings.NewReader(body))
+	r.Header.Set(middleware.IdempotencyKeyHeader, key)
+	r = r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, &service.GetSessionResponse{UserID: 1}))
+
+	w := httptest.NewRecorder()
+	e.handler.ServeHTTP(w, r)
+	return w
+}
+
+func created(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/bookings/1")
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(`{"id":1}`))
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	env := newIdempotencyEnv(t, created)
+
+	first := env.request("key", `{"event_id":1}`)
+	replay := env.request("key", `{"event_id":1}`)
+
+	if env.calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", env.calls.Load())
+	}
+	if replay.Code != http.StatusCreated {
+		t.Errorf("replay status is %d, want 201", replay.Code)
+	}
+	if replay.Body.String() != first.Body.String() {
+		t.Errorf("replay body is %q, want %q", replay.Body.String(), first.Body.String())
+	}
+	if location := replay.Header().Get("Location"); location != "/api/bookings/1" {
+		t.Errorf("replay Location is %q, want /api/bookings/1", location)
+	}
+	if contentType := replay.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("replay Content-Type is %q, want application/json", contentType)
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replay is not marked Idempotent-Replayed")
+	}
+}
+
+func TestIdempotencyRejectsDifferentRequest(t *testing.T) {
+	env := newIdempotencyEnv(t, created)
+
+	env.request("key", `{"event_id":1}`)
+	w := env.request("key", `{"event_id":2}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status is %d, want 422", w.Code)
+	}
+	if env.calls.Load() != 1 {
+		t.Errorf("handler called %d times, want 1", env.calls.Load())
+	}
+}
+
+func TestIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	var failed atomic.Bool
+	env := newIdempotencyEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		if failed.CompareAndSwap(false, true) {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		created(w, r)
+	})
+
+	if w := env.request("key", `{"event_id":1}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("first status is %d, want 500", w.Code)
+	}
+
+	// Ответ 5xx не сохраняется: повтор выполняется заново
+	w := env.request("key", `{"event_id":1}`)
+	if w.Code != http.StatusCreated {
+		t.Errorf("retry status is %d, want 201", w.Code)
+	}
+	if w.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("retry is marked Idempotent-Replayed")
+	}
+	if env.calls.Load() != 2 {
+		t.Errorf("handler called %d times, want 2", env.calls.Load())
+	}
+}
+
+func TestIdempotencyRejectsRequestWhileInProgress(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	env := newIdempotencyEnv(t, func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		created(w, r)
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- env.request("key", `{"event_id":1}`)
+	}()
+	<-entered
+
+	if w := env.request("key", `{"event_id":1}`); w.Code != http.StatusConflict {
+		t.Errorf("concurrent status is %d, want 409", w.Code)
+	}
+
+	close(release)
+	if w := <-done; w.Code != http.StatusCreated {
+		t.Fatalf("first status is %d, want 201", w.Code)
+	}
+
+	// Когда первый запрос ответил, его ответ повторяется
+	if w := env.request("key", `{"event_id":1}`); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("replay status is %d, replayed %q, want 201 replayed", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+	if env.calls.Load() != 1 {
+		t.Errorf("handler called %d times, want 1", env.calls.Load())
+	}
+}
+
+func TestIdempotencyReclaimsAbandonedKey(t *testing.T) {
+	env := newIdempotencyEnv(t, created)
+
+	// Ключ занят запросом, процесс которого упал до ответа
+	claimed, err := env.queries.ClaimIdempotencyKey(context.Background(), sqlc.ClaimIdempotencyKeyParams{
+		UserID:      1,
+		Key:         "key",
+		RequestHash: "abandoned",
+		CreatedAt:   time.Now().UTC().Add(-inProgressTTL - time.Second),
+	})
+	if err != nil || claimed != 1 {
+		t.Fatalf("claim: %d, %v", claimed, err)
+	}
+
+	if w := env.request("key", `{"event_id":1}`); w.Code != http.StatusCreated {
+		t.Errorf("status is %d, want 201", w.Code)
+	}
+	if env.calls.Load() != 1 {
+		t.Errorf("handler called %d times, want 1", env.calls.Load())
+	}
+}
@@ -1,23 +1,139 @@
 package paymenttoken
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
+	"sort"
+	"strings"
 )
 
-// GenerateToken generates a payment token using the 5 required parameters
-// Parameters in alphabetical order: Amount → Currency → OrderId → Password → TeamSlug
-func GenerateToken(amount int64, currency, orderID, password, teamSlug string) string {
-	tokenParams := fmt.Sprintf("%s%s%s%s%s",
-		strconv.FormatInt(amount, 10), // Amount in cents
-		currency,                      // Currency
-		orderID,                      // OrderId
-		password,                     // Password
-		teamSlug,                     // TeamSlug
-	)
-	
-	hash := sha256.Sum256([]byte(tokenParams))
+// Ключи, которые участвуют в подписи на особых правах
+const (
+	tokenKey    = "token"
+	passwordKey = "password"
+)
+
+var ErrMissingToken = errors.New("request has no token")
+
+// Signer builds payment gateway tokens.
+//
+// The token covers the root-level scalar fields of a request: nested objects, arrays
+// and nulls are skipped, the Password is added as one more field, the values are
+// concatenated in key order and hashed with SHA-256.
+// Example for Init: Amount → Currency → OrderId → Password → TeamSlug.
+type Signer struct {
+	password string
+}
+
+func NewSigner(password string) *Signer {
+	return &Signer{password: password}
+}
+
+// Sign returns the token for a request DTO (or any value that marshals to a JSON object).
+// The token field itself is never signed, so it may already be set.
+func (s *Signer) Sign(request any) (string, error) {
+	params, err := rootParams(request)
+	if err != nil {
+		return "", err
+	}
+	return s.sign(params), nil
+}
+
+// Verify reports whether token is valid for the request. Tokens are compared in constant time.
+func (s *Signer) Verify(request any, token string) bool {
+	expected, err := s.Sign(request)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.ToLower(token)), []byte(expected)) == 1
+}
+
+// VerifyJSON verifies a signed JSON object, e.g. a gateway notification, against the token it carries.
+// Fields unknown to our DTOs are signed too, so the raw body is verified rather than a decoded struct.
+func (s *Signer) VerifyJSON(body []byte) (bool, error) {
+	params, err := rootParams(json.RawMessage(body))
+	if err != nil {
+		return false, err
+	}
+
+	var token string
+	for key, value := range params {
+		if strings.EqualFold(key, tokenKey) {
+			token = value
+		}
+	}
+	if token == "" {
+		return false, ErrMissingToken
+	}
+
+	expected := s.sign(params)
+	return subtle.ConstantTimeCompare([]byte(strings.ToLower(token)), []byte(expected)) == 1, nil
+}
+
+func (s *Signer) sign(params map[string]string) string {
+	keys := make([]string, 0, len(params)+1)
+	for key := range params {
+		if strings.EqualFold(key, tokenKey) || strings.EqualFold(key, passwordKey) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	keys = append(keys, passwordKey)
+
+	// Ключи сравниваются без учета регистра: amount и Amount занимают одно место
+	sort.Slice(keys, func(i, j int) bool {
+		return strings.ToLower(keys[i]) < strings.ToLower(keys[j])
+	})
+
+	var canonical strings.Builder
+	for _, key := range keys {
+		if key == passwordKey {
+			canonical.WriteString(s.password)
+			continue
+		}
+		canonical.WriteString(params[key])
+	}
+
+	hash := sha256.Sum256([]byte(canonical.String()))
 	return hex.EncodeToString(hash[:])
-}
\ No newline at end of file
+}
+
+// rootParams returns the root-level scalar fields of request as they appear in JSON.
+// Numbers keep their JSON text, so 19200 is signed as "19200" and not "19200.00".
+func rootParams(request any) (map[string]string, error) {
+	raw, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("request is not a JSON object: %w", err)
+	}
+
+	params := make(map[string]string, len(fields))
+	for key, value := range fields {
+		switch v := value.(type) {
+		case string:
+			params[key] = v
+		case json.Number:
+			params[key] = v.String()
+		case bool:
+			if v {
+				params[key] = "true"
+			} else {
+				params[key] = "false"
+			}
+		}
+	}
+
+	return params, nil
+}
@@ -0,0 +1,178 @@
+package paymenttoken
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Примеры из спецификации шлюза и из запросов, которые отправляет сервис
+var vectors = []struct {
+	name      string
+	request   string
+	password  string
+	token     string
+	canonical string // значения до хеширования, чтобы разобрать несовпадение
+}{
+	{
+		// Пример из спецификации: вложенные DATA и Receipt в подписи не участвуют
+		name:      "spec Init",
+		request:   `{"TerminalKey":"MerchantTerminalKey","Amount":19200,"OrderId":"21090","Description":"Подарочная карта на 1000 рублей","DATA":{"Phone":"+71234567890","Email":"a@test.com"},"Receipt":{"Email":"a@test.ru","Phone":"+79031234567","Taxation":"osn","Items":[{"Name":"Наименование товара 1","Price":10000,"Quantity":1,"Amount":10000,"Tax":"vat10"}]}}`,
+		password:  "usaf8fw8fsw21g",
+		token:     "0024a00af7c350a3a67ca168ce06502aa72772456662e38696d48b56ee9c97d9",
+		canonical: "19200Подарочная карта на 1000 рублей21090usaf8fw8fsw21gMerchantTerminalKey",
+	},
+	{
+		name:      "Init",
+		request:   `{"amount":8000050,"currency":"KZT","orderId":"1792215376624055172","teamSlug":"team"}`,
+		password:  "pw",
+		token:     "98f8c43eedc5aa9d1a4cca5c703a55ca1f36fe25d95cb5ba55060cffe6695828",
+		canonical: "8000050KZT1792215376624055172pwteam",
+	},
+	{
+		// null и объекты пропускаются, bool подписывается как true/false
+		name:      "Check",
+		request:   `{"paymentId":"pay_123","orderId":"42","teamSlug":"team","includeTransactions":true,"correlationId":null,"data":{"source":"api"}}`,
+		password:  "pw",
+		token:     "2d12485b4122d4f4700872551fb14c4ca2eff3411d8d48f71f79dc4d77c71f9a",
+		canonical: "true42pwpay_123team",
+	},
+	{
+		// Частичная отмена: сумма подписывается, позиции (items) нет
+		name:      "Cancel with amount",
+		request:   `{"paymentId":"pay_123","teamSlug":"team","amount":4000000,"reason":"seat removed from booking","items":[{"itemId":"1","amount":4000000,"quantity":1}]}`,
+		password:  "pw",
+		token:     "ca277eaaab3353d1161f0ea3b51acc275b92617a449ba22a3b16e0109d882258",
+		canonical: "4000000pwpay_123seat removed from bookingteam",
+	},
+}
+
+func TestSignerSign(t *testing.T) {
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			token, err := NewSigner(v.password).Sign(json.RawMessage(v.request))
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			if token != v.token {
+				t.Errorf("got token %s, want %s (canonical %q)", token, v.token, v.canonical)
+			}
+		})
+	}
+}
+
+func TestSignerSignIgnoresToken(t *testing.T) {
+	signer := NewSigner("pw")
+
+	unsigned, err := signer.Sign(map[string]any{"orderId": "42", "teamSlug": "team"})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	signed, err := signer.Sign(map[string]any{"orderId": "42", "teamSlug": "team", "Token": "stale"})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	if signed != unsigned {
+		t.Errorf("token field changed the token: %s != %s", signed, unsigned)
+	}
+}
+
+func TestSignerSignRejectsNonObject(t *testing.T) {
+	if _, err := NewSigner("pw").Sign([]string{"a"}); err == nil {
+		t.Error("Sign of an array succeeded")
+	}
+}
+
+func TestSignerVerify(t *testing.T) {
+	request := json.RawMessage(`{"amount":8000050,"currency":"KZT","orderId":"1792215376624055172","teamSlug":"team"}`)
+	tampered := json.RawMessage(`{"amount":1,"currency":"KZT","orderId":"1792215376624055172","teamSlug":"team"}`)
+	token := "98f8c43eedc5aa9d1a4cca5c703a55ca1f36fe25d95cb5ba55060cffe6695828"
+
+	tests := []struct {
+		name     string
+		password string
+		request  json.RawMessage
+		token    string
+		want     bool
+	}{
+		{name: "valid", password: "pw", request: request, token: token, want: true},
+		{name: "upper case", password: "pw", request: request, token: strings.ToUpper(token), want: true},
+		{name: "tampered request", password: "pw", request: tampered, token: token, want: false},
+		{name: "tampered token", password: "pw", request: request, token: "0" + token[1:], want: false},
+		{name: "short token", password: "pw", request: request, token: token[:32], want: false},
+		{name: "long token", password: "pw", request: request, token: token + "00", want: false},
+		{name: "empty token", password: "pw", request: request, token: "", want: false},
+		{name: "wrong password", password: "other", request: request, token: token, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewSigner(tt.password).Verify(tt.request, tt.token); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignerVerifyJSON(t *testing.T) {
+	token := "98f8c43eedc5aa9d1a4cca5c703a55ca1f36fe25d95cb5ba55060cffe6695828"
+
+	tests := []struct {
+		name    string
+		body    string
+		want    bool
+		wantErr error
+	}{
+		{
+			name: "valid",
+			body: `{"amount":8000050,"currency":"KZT","orderId":"1792215376624055172","teamSlug":"team","token":"` + token + `"}`,
+			want: true,
+		},
+		{
+			name: "token key in any case",
+			body: `{"amount":8000050,"currency":"KZT","orderId":"1792215376624055172","teamSlug":"team","Token":"` + strings.ToUpper(token) + `"}`,
+			want: true,
+		},
+		{
+			// Неизвестное нашим DTO поле тоже подписано
+			name: "unknown field added",
+			body: `{"amount":8000050,"currency":"KZT","orderId":"1792215376624055172","teamSlug":"team","status":"CONFIRMED","token":"` + token + `"}`,
+			want: false,
+		},
+		{
+			name: "tampered amount",
+			body: `{"amount":1,"currency":"KZT","orderId":"1792215376624055172","teamSlug":"team","token":"` + token + `"}`,
+			want: false,
+		},
+		{
+			name: "short token",
+			body: `{"amount":8000050,"currency":"KZT","orderId":"1792215376624055172","teamSlug":"team","token":"` + token[:10] + `"}`,
+			want: false,
+		},
+		{
+			name:    "missing token",
+			body:    `{"amount":8000050,"teamSlug":"team"}`,
+			wantErr: ErrMissingToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewSigner("pw").VerifyJSON([]byte(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyJSON() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignerVerifyJSONRejectsNonObject(t *testing.T) {
+	if _, err := NewSigner("pw").VerifyJSON([]byte(`not json`)); err == nil {
+		t.Error("VerifyJSON of invalid JSON succeeded")
+	}
+}
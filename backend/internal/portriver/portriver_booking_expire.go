@@ -0,0 +1,161 @@
+package portriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"hackload/internal/domain"
+	"hackload/internal/service"
+	"hackload/internal/sqlc"
+
+	"github.com/riverqueue/river"
+)
+
+// ExpireBookingArgs is scheduled at bookings.expires_at and cancels the booking
+// if it is still in StatusEq by then.
+type ExpireBookingArgs struct {
+	BookingID int64
+	StatusEq  domain.BookingStatus
+}
+
+func (ExpireBookingArgs) Kind() string { return "booking.expire" }
+
+// Причина отмены, по которой повтор задачи узнает свою отмену
+const holdExpiredReason = "hold expired"
+
+type ExpireBookingWorker struct {
+	river.WorkerDefaults[ExpireBookingArgs]
+
+	queries *sqlc.Queries
+	db      *sql.DB
+}
+
+func NewExpireBookingWorker(queries *sqlc.Queries, db *sql.DB) river.Worker[ExpireBookingArgs] {
+	return &ExpireBookingWorker{
+		queries: queries,
+		db:      db,
+	}
+}
+
+func (w *ExpireBookingWorker) Work(ctx context.Context, job *river.Job[ExpireBookingArgs]) error {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for booking %d: %w", job.Args.BookingID, err)
+	}
+	defer tx.Rollback()
+
+	qtx := w.queries.WithTx(tx)
+
+	// 1. GetBooking to verify it exists
+	booking, err := qtx.GetBooking(ctx, job.Args.BookingID)
+	if err != nil {
+		return fmt.Errorf("failed to get booking %d: %w", job.Args.BookingID, err)
+	}
+
+	// Повтор после сбоя постановки задач: бронь уже отменена этой задачей.
+	// Брони, отмененные другим путем, убирает тот, кто их отменил
+	if job.Attempt > 1 && booking.Status == domain.BookingStatusCancelled {
+		expired, err := cancelledOnExpiry(ctx, qtx, booking.ID, job.Args.StatusEq)
+		if err != nil {
+			return err
+		}
+		if !expired {
+			return nil
+		}
+		return w.queueCleanup(ctx, booking)
+	}
+
+	// Booking moved on (paid, cancelled or hold extended) - nothing to do
+	if booking.Status != job.Args.StatusEq {
+		return nil
+	}
+	if booking.ExpiresAt != nil && booking.ExpiresAt.After(time.Now().UTC()) {
+		return nil
+	}
+
+	// 2. Cancel the booking
+	err = service.TransitionBooking(ctx, qtx, booking, domain.BookingStatusCancelled, domain.ActorSystem, holdExpiredReason)
+	if err != nil {
+		if errors.Is(err, service.ErrBookingStatusChanged) {
+			return nil
+		}
+		return fmt.Errorf("failed to cancel booking %d: %w", booking.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction for booking %d: %w", job.Args.BookingID, err)
+	}
+
+	return w.queueCleanup(ctx, booking)
+}
+
+// cancelledOnExpiry reports whether the latest transition of the booking is the cancel
+// ExpireBookingWorker records when the hold in status from expires.
+func cancelledOnExpiry(ctx context.Context, qtx *sqlc.Queries, bookingID int64, from domain.BookingStatus) (bool, error) {
+	transition, err := qtx.GetLatestBookingStatusHistory(ctx, bookingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get latest status history of booking %d: %w", bookingID, err)
+	}
+
+	return transition.ToStatus == domain.BookingStatusCancelled &&
+		transition.FromStatus != nil && *transition.FromStatus == from &&
+		transition.Actor == domain.ActorSystem &&
+		transition.Reason != nil && *transition.Reason == holdExpiredReason, nil
+}
+
+// queueCleanup queues the jobs that undo what the cancelled booking held:
+// its seats, its unfinished payment and its EventProvider order.
+func (w *ExpireBookingWorker) queueCleanup(ctx context.Context, booking sqlc.Booking) error {
+	// 3. Cancel the EventProvider order holding the places of the booking,
+	// CancelBookingWorker releases the seats once the provider has released the places
+	orderCancelled, err := queueOrderCancel(ctx, w.queries, booking.ID)
+	if err != nil {
+		return err
+	}
+
+	// Release the seats through ReleaseSeatsWorker, it also offers them to the waitlist
+	if !orderCancelled {
+		cancelled := domain.BookingStatusCancelled
+		if _, err := river.ClientFromContext[*sql.Tx](ctx).Insert(ctx, ReleaseSeatsArgs{
+			BookingID: booking.ID,
+			StatusEq:  &cancelled,
+		}, nil); err != nil {
+			return fmt.Errorf("failed to queue ReleaseSeatsWorker for booking %d: %w", booking.ID, err)
+		}
+	}
+
+	// 4. Void the payment the user did not complete, so it can no longer be paid.
+	// Not returned as an error: a payment left in INIT is settled by the periodic reconciliation
+	if err := queuePaymentVoid(ctx, w.queries, booking.ID); err != nil {
+		slog.Error("failed to queue payment void", "booking_id", booking.ID, "error", err)
+	}
+
+	return nil
+}
+
+// queuePaymentVoid settles the latest payment attempt of the booking if it is still unfinished.
+func queuePaymentVoid(ctx context.Context, queries *sqlc.Queries, bookingID int64) error {
+	payment, err := queries.GetLatestBookingPayment(ctx, bookingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get latest booking payment: %w", err)
+	}
+
+	if payment.Status == nil || *payment.Status != domain.PaymentStatusInit {
+		return nil
+	}
+
+	_, err = river.ClientFromContext[*sql.Tx](ctx).Insert(ctx, ReconcilePaymentArgs{
+		OrderID: payment.OrderID,
+	}, nil)
+	return err
+}
@@ -0,0 +1,250 @@
+package portriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"hackload/internal/domain"
+	"hackload/internal/service"
+	"hackload/internal/sqlc"
+	"hackload/internal/ticketing"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// FailBookingArgs compensates a paid booking whose seats could not be obtained from
+// the ticket provider: the saga job failed for the last time and River discards it.
+type FailBookingArgs struct {
+	BookingID int64
+	// Заказ, начатый последней попыткой SelectSeatsWorker. Его может не быть в booking_orders:
+	// попытка упала, не успев его сохранить
+	OrderID *string `json:",omitempty"`
+	Reason  string
+}
+
+func (FailBookingArgs) Kind() string { return "booking.fail" }
+
+type FailBookingWorker struct {
+	river.WorkerDefaults[FailBookingArgs]
+
+	queries         *sqlc.Queries
+	db              *sql.DB
+	TicketProviders *ticketing.Registry
+}
+
+func NewFailBookingWorker(queries *sqlc.Queries, db *sql.DB, ticketProviders *ticketing.Registry) river.Worker[FailBookingArgs] {
+	return &FailBookingWorker{
+		queries:         queries,
+		db:              db,
+		TicketProviders: ticketProviders,
+	}
+}
+
+func (w *FailBookingWorker) Work(ctx context.Context, job *river.Job[FailBookingArgs]) error {
+	// 1. Only a paid booking waiting for its seats is compensated
+	booking, err := w.queries.GetBooking(ctx, job.Args.BookingID)
+	if err != nil {
+		return fmt.Errorf("failed to get booking: %w", err)
+	}
+	if booking.Status != domain.BookingStatusConfirmed {
+		return nil
+	}
+
+	orderIDs, err := w.bookingOrderIDs(ctx, booking.ID, job.Args.OrderID)
+	if err != nil {
+		return err
+	}
+
+	provider, err := w.TicketProviders.ForEvent(ctx, booking.EventID)
+	if err != nil {
+		return err
+	}
+
+	// 2. Cancel the provider orders, which frees their places at the provider.
+	// Places are not released one by one: a rejected place may be selected by another order
+	for _, orderID := range orderIDs {
+		confirmed, err := cancelOrder(ctx, provider, orderID)
+		if err != nil {
+			return err
+		}
+		if confirmed {
+			// Провайдер подтвердил заказ, сломалась только наша сторона - места получены
+			return w.completeBooking(ctx, booking)
+		}
+	}
+
+	// 3. Fail the booking and free its seats
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := w.queries.WithTx(tx)
+
+	err = service.TransitionBooking(
+		ctx,
+		qtx,
+		booking,
+		domain.BookingStatusFailed,
+		domain.ActorSystem,
+		job.Args.Reason,
+	)
+	if err != nil {
+		if errors.Is(err, service.ErrBookingStatusChanged) {
+			return nil
+		}
+		return fmt.Errorf("failed to fail booking %d: %w", booking.ID, err)
+	}
+
+	err = qtx.UpdateBookingOrderStatus(ctx, sqlc.UpdateBookingOrderStatusParams{
+		Status:    stringPtr("CANCELLED"),
+		BookingID: booking.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update booking order status to CANCELLED: %w", err)
+	}
+
+	if _, err := service.ReleaseBookingSeats(ctx, qtx, booking.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// 4. Refund the payment, or void it if it was only authorized
+	if _, err := river.ClientFromContext[*sql.Tx](ctx).Insert(ctx, RefundPaymentArgs{
+		BookingID: booking.ID,
+	}, nil); err != nil {
+		return fmt.Errorf("failed to queue RefundPaymentWorker: %w", err)
+	}
+
+	return queueWaitlistOffer(ctx, w.queries, booking.EventID)
+}
+
+// bookingOrderIDs returns the saved provider order of the booking and the one from the job, if different.
+func (w *FailBookingWorker) bookingOrderIDs(ctx context.Context, bookingID int64, jobOrderID *string) ([]string, error) {
+	var orderIDs []string
+
+	bookingOrder, err := w.queries.GetBookingOrder(ctx, bookingID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get booking order: %w", err)
+	}
+	if err == nil {
+		orderIDs = append(orderIDs, bookingOrder.OrderID)
+	}
+
+	if jobOrderID != nil && (len(orderIDs) == 0 || orderIDs[0] != *jobOrderID) {
+		orderIDs = append(orderIDs, *jobOrderID)
+	}
+
+	return orderIDs, nil
+}
+
+// cancelOrder cancels the provider order unless it is already cancelled or unknown.
+// It reports whether the order turned out to be confirmed, which cannot be cancelled.
+func cancelOrder(ctx context.Context, provider ticketing.Provider, orderID string) (bool, error) {
+	order, err := provider.GetOrder(ctx, orderID)
+	if errors.Is(err, ticketing.ErrOrderNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch order.Status {
+	case ticketing.OrderConfirmed:
+		return true, nil
+	case ticketing.OrderCancelled:
+		return false, nil
+	}
+
+	if err := provider.CancelOrder(ctx, orderID); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// completeBooking finishes what ConfirmOrderWorker did not: the order is confirmed by the provider,
+// so the seats are sold and the payment captured.
+func (w *FailBookingWorker) completeBooking(ctx context.Context, booking sqlc.Booking) error {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := w.queries.WithTx(tx)
+
+	err = qtx.UpdateBookingOrderStatus(ctx, sqlc.UpdateBookingOrderStatusParams{
+		Status:    stringPtr("CONFIRMED"),
+		BookingID: booking.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update booking order status to CONFIRMED: %w", err)
+	}
+
+	seatIDs, err := qtx.GetBookingSeats(ctx, booking.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get booking seats: %w", err)
+	}
+
+	// Места, переданные в выделенные брони, входят в тот же заказ
+	splitBookings, err := qtx.GetSplitBookings(ctx, &booking.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get split bookings of booking %d: %w", booking.ID, err)
+	}
+	for _, splitBooking := range splitBookings {
+		if splitBooking.Status != domain.BookingStatusConfirmed {
+			continue
+		}
+
+		splitSeatIDs, err := qtx.GetBookingSeats(ctx, splitBooking.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get booking seats of split booking %d: %w", splitBooking.ID, err)
+		}
+		seatIDs = append(seatIDs, splitSeatIDs...)
+	}
+
+	if len(seatIDs) > 0 {
+		err = qtx.UpdateSeatsStatusByIDs(ctx, sqlc.UpdateSeatsStatusByIDsParams{
+			Status:  "SOLD",
+			SeatIds: seatIDs,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update seats status: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return queuePaymentCapture(ctx, w.queries, booking.ID)
+}
+
+// queueFailBookingOnLastAttempt hands the booking over to FailBookingWorker when a saga job
+// failed for the last time, or was cancelled, and River is about to give up on it.
+// The job error is returned as is, so River still records it.
+func queueFailBookingOnLastAttempt(ctx context.Context, job *rivertype.JobRow, args FailBookingArgs, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var cancelErr *rivertype.JobCancelError
+	if job.Attempt < job.MaxAttempts && !errors.As(err, &cancelErr) {
+		return err
+	}
+
+	args.Reason = fmt.Sprintf("%s gave up after %d attempts: %v", job.Kind, job.Attempt, err)
+	if _, insertErr := river.ClientFromContext[*sql.Tx](ctx).Insert(ctx, args, nil); insertErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to queue FailBookingWorker: %w", insertErr))
+	}
+
+	return err
+}
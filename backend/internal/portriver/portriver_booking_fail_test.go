@@ -0,0 +1,133 @@
+//go:build sqlite_fts5
+
+package portriver_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"hackload/internal/domain"
+	"hackload/internal/portriver"
+	"hackload/internal/testenv"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// startFailBooking starts River with FailBookingWorker and SelectSeatsWorker.
+// The jobs they queue are left undone, so that they can be inspected.
+func startFailBooking(t *testing.T, env *sagaEnv) *river.Client[*sql.Tx] {
+	t.Helper()
+
+	river.AddWorker(env.deps.RiverWorkers, portriver.NewFailBookingWorker(env.queries, env.deps.DB, env.registry))
+	river.AddWorker(env.deps.RiverWorkers, portriver.NewSelectSeatsWorker(env.queries, env.deps.DB, env.registry))
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.ConfirmOrderArgs]{})
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.RefundPaymentArgs]{})
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.CapturePaymentArgs]{})
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.OfferWaitlistSeatsArgs]{})
+
+	return testenv.StartRiver(t, env.deps)
+}
+
+// splitBooking creates a CONFIRMED booking of user 2 split from parentBookingID with the given seat,
+// as a seat transfer does.
+func (e *sagaEnv) splitBooking(t *testing.T, parentBookingID, seatID int64) int64 {
+	t.Helper()
+
+	bookingID := e.createBooking(t, domain.BookingStatusConfirmed, seatID)
+	e.exec(t, `UPDATE bookings SET user_id = 2, parent_booking_id = ? WHERE id = ?`, parentBookingID, bookingID)
+	e.exec(t, `UPDATE booking_seats SET user_id = 2 WHERE booking_id = ?`, bookingID)
+
+	return bookingID
+}
+
+func TestFailBookingCompletesConfirmedOrder(t *testing.T) {
+	env := newSagaEnv(t)
+	riverClient := startFailBooking(t, env)
+
+	bookingID := env.createBooking(t, domain.BookingStatusConfirmed, 1)
+	childID := env.splitBooking(t, bookingID, 2)
+	orderID := env.startOrder(t, bookingID, 1, 2)
+
+	// Провайдер подтвердил заказ, но ConfirmOrderWorker этого не увидел
+	if err := env.provider.Provider.SubmitOrder(orderID); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.provider.Provider.ConfirmOrder(orderID); err != nil {
+		t.Fatal(err)
+	}
+
+	job := testenv.WorkJob(t, riverClient, portriver.FailBookingArgs{BookingID: bookingID, Reason: "confirm timed out"})
+	if job.State != rivertype.JobStateCompleted {
+		t.Fatalf("job is %s: %v", job.State, job.Errors)
+	}
+
+	for _, id := range []int64{bookingID, childID} {
+		if status := env.bookingStatus(t, id); status != domain.BookingStatusConfirmed {
+			t.Errorf("booking %d is %s, want CONFIRMED", id, status)
+		}
+	}
+	for _, seatID := range []int64{1, 2} {
+		if status := env.seatStatus(t, seatID); status != "SOLD" {
+			t.Errorf("seat %d is %s, want SOLD", seatID, status)
+		}
+	}
+	if jobs := testenv.Jobs(t, riverClient, portriver.RefundPaymentArgs{}.Kind()); len(jobs) != 0 {
+		t.Errorf("%d refund jobs queued, want none", len(jobs))
+	}
+}
+
+func TestFailBookingIgnoresUnconfirmedBooking(t *testing.T) {
+	env := newSagaEnv(t)
+	riverClient := startFailBooking(t, env)
+
+	bookingID := env.createBooking(t, domain.BookingStatusCancelled)
+
+	job := testenv.WorkJob(t, riverClient, portriver.FailBookingArgs{BookingID: bookingID, Reason: "late"})
+	if job.State != rivertype.JobStateCompleted {
+		t.Fatalf("job is %s: %v", job.State, job.Errors)
+	}
+
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusCancelled {
+		t.Errorf("booking is %s, want CANCELLED", status)
+	}
+	if jobs := testenv.Jobs(t, riverClient, portriver.RefundPaymentArgs{}.Kind()); len(jobs) != 0 {
+		t.Errorf("%d refund jobs queued, want none", len(jobs))
+	}
+}
+
+func TestSelectSeatsFailsBookingOnLastAttempt(t *testing.T) {
+	env := newSagaEnv(t)
+	riverClient := startFailBooking(t, env)
+
+	bookingID := env.createBooking(t, domain.BookingStatusConfirmed, 1)
+
+	// Место уже продается в чужом заказе
+	other := env.provider.Provider.StartOrder()
+	if err := env.provider.Provider.SelectPlace(env.places[0].Id, other.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	job := testenv.WorkJob(t, riverClient, portriver.SelectSeatsArgs{BookingID: bookingID})
+	if job.State != rivertype.JobStateDiscarded {
+		t.Fatalf("job is %s, want discarded", job.State)
+	}
+
+	jobs := testenv.Jobs(t, riverClient, portriver.FailBookingArgs{}.Kind())
+	if len(jobs) != 1 {
+		t.Fatalf("%d fail booking jobs queued, want 1", len(jobs))
+	}
+
+	var args portriver.FailBookingArgs
+	if err := json.Unmarshal(jobs[0].EncodedArgs, &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.BookingID != bookingID {
+		t.Errorf("fail booking job is for booking %d, want %d", args.BookingID, bookingID)
+	}
+	// Заказ последней попытки передается, чтобы FailBookingWorker его отменил
+	if args.OrderID == nil {
+		t.Error("fail booking job has no order")
+	}
+}
@@ -3,12 +3,12 @@ package portriver
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"hackload/internal/sqlc"
-	"hackload/pkg/eventprovider"
+	"hackload/internal/ticketing"
 
-	"github.com/google/uuid"
 	"github.com/riverqueue/river"
 )
 
@@ -21,18 +21,16 @@ func (CancelBookingArgs) Kind() string { return "booking.cancel" }
 type CancelBookingWorker struct {
 	river.WorkerDefaults[CancelBookingArgs]
 
-	queries       *sqlc.Queries
-	db            *sql.DB
-	riverClient   *river.Client[*sql.Tx]
-	EventProvider eventprovider.ClientInterface
+	queries         *sqlc.Queries
+	db              *sql.DB
+	TicketProviders *ticketing.Registry
 }
 
-func NewCancelBookingWorker(queries *sqlc.Queries, db *sql.DB, riverClient *river.Client[*sql.Tx], eventProvider eventprovider.ClientInterface) river.Worker[CancelBookingArgs] {
+func NewCancelBookingWorker(queries *sqlc.Queries, db *sql.DB, ticketProviders *ticketing.Registry) river.Worker[CancelBookingArgs] {
 	return &CancelBookingWorker{
-		queries:       queries,
-		db:            db,
-		riverClient:   riverClient,
-		EventProvider: eventProvider,
+		queries:         queries,
+		db:              db,
+		TicketProviders: ticketProviders,
 	}
 }
 
@@ -57,63 +55,63 @@ func (w *CancelBookingWorker) Work(ctx context.Context, job *river.Job[CancelBoo
 		return fmt.Errorf("failed to get booking seats: %w", err)
 	}
 
-	if len(seatIDs) == 0 {
-		// No seats to cancel, just return success
-		return tx.Commit()
-	}
-
-	// 3. Get existing booking order if it exists
-	var orderID *uuid.UUID
+	// 3. Get existing booking order if it exists.
+	// Отмена начатого заказа сама освобождает выбранные в нем места, а не выбранные еще места
+	// провайдер не отпустит, поэтому по одному места освобождаются только без такого заказа
+	var orderID *string
+	releasePlaces := true
 	bookingOrder, err := qtx.GetBookingOrder(ctx, booking.ID)
 	if err != nil && err != sql.ErrNoRows {
 		return fmt.Errorf("failed to get booking order: %w", err)
 	}
 	if err == nil {
-		parsed, err := uuid.Parse(bookingOrder.OrderID)
-		if err != nil {
-			return fmt.Errorf("failed to parse order_id: %w", err)
+		switch {
+		case bookingOrder.Status != nil && *bookingOrder.Status == "CANCELLED":
+			releasePlaces = false
+		case bookingOrder.Status != nil && *bookingOrder.Status == "STARTED":
+			orderID = &bookingOrder.OrderID
+			releasePlaces = false
+		default:
+			orderID = &bookingOrder.OrderID
 		}
-		orderID = &parsed
 	}
 
-	// 4. For each seat, release it from EventProvider
-	for _, seatID := range seatIDs {
-		// Get seat details efficiently by ID
-		targetSeat, err := qtx.GetSeatByID(ctx, seatID)
-		if err != nil {
-			return fmt.Errorf("failed to get seat %d: %w", seatID, err)
-		}
-
-		// Skip seats without external_id
-		if targetSeat.ExternalID == nil {
-			continue
-		}
-
-		placeID, err := uuid.Parse(*targetSeat.ExternalID)
-		if err != nil {
-			return fmt.Errorf("failed to parse external_id: %w", err)
-		}
+	// Места брони уже освобождены, а заказ у провайдера отменен
+	if len(seatIDs) == 0 && orderID == nil {
+		// No seats to cancel, just return success
+		return tx.Commit()
+	}
 
-		// Release place in EventProvider
-		releaseResp, err := w.EventProvider.ReleasePlace(ctx, placeID)
-		if err != nil {
-			return fmt.Errorf("failed to release place: %w", err)
-		}
+	provider, err := w.TicketProviders.ForEvent(ctx, booking.EventID)
+	if err != nil {
+		return err
+	}
 
-		if releaseResp.StatusCode > 299 {
-			return fmt.Errorf("failed to release place, status: %d", releaseResp.StatusCode)
+	// 4. For each seat, release it at the provider
+	if releasePlaces {
+		for _, seatID := range seatIDs {
+			// Get seat details efficiently by ID
+			targetSeat, err := qtx.GetSeatByID(ctx, seatID)
+			if err != nil {
+				return fmt.Errorf("failed to get seat %d: %w", seatID, err)
+			}
+
+			// Skip seats without external_id
+			if targetSeat.ExternalID == nil {
+				continue
+			}
+
+			// Release place at the provider
+			if err := provider.ReleasePlace(ctx, *targetSeat.ExternalID); err != nil {
+				return err
+			}
 		}
 	}
 
-	// 5. Cancel order in EventProvider if it exists
+	// 5. Cancel order at the provider if it exists
 	if orderID != nil {
-		cancelResp, err := w.EventProvider.CancelOrder(ctx, *orderID)
-		if err != nil {
-			return fmt.Errorf("failed to cancel order: %w", err)
-		}
-
-		if cancelResp.StatusCode > 299 {
-			return fmt.Errorf("failed to cancel order, status: %d", cancelResp.StatusCode)
+		if err := provider.CancelOrder(ctx, *orderID); err != nil {
+			return err
 		}
 
 		// Update booking order status to CANCELLED
@@ -132,7 +130,7 @@ func (w *CancelBookingWorker) Work(ctx context.Context, job *river.Job[CancelBoo
 	}
 
 	// 6. Queue ReleaseSeatsWorker to update seat statuses to FREE
-	_, err = w.riverClient.Insert(ctx, ReleaseSeatsArgs{
+	_, err = river.ClientFromContext[*sql.Tx](ctx).Insert(ctx, ReleaseSeatsArgs{
 		BookingID: booking.ID,
 	}, nil)
 	if err != nil {
@@ -145,3 +143,29 @@ func (w *CancelBookingWorker) Work(ctx context.Context, job *river.Job[CancelBoo
 func stringPtr(s string) *string {
 	return &s
 }
+
+// queueOrderCancel cancels the provider order of a booking cancelled before payment.
+// Such an order exists only with RESERVE_ON_SELECT and holds the places selected for the booking.
+// CancelBookingWorker frees the seats once the order is cancelled, so it reports whether the order
+// cancel was queued and the caller must not free the seats itself.
+func queueOrderCancel(ctx context.Context, queries *sqlc.Queries, bookingID int64) (bool, error) {
+	bookingOrder, err := queries.GetBookingOrder(ctx, bookingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get booking order of booking %d: %w", bookingID, err)
+	}
+
+	if bookingOrder.Status == nil || *bookingOrder.Status != "STARTED" {
+		return false, nil
+	}
+
+	if _, err = river.ClientFromContext[*sql.Tx](ctx).Insert(ctx, CancelBookingArgs{
+		BookingID: bookingID,
+	}, nil); err != nil {
+		return false, fmt.Errorf("failed to queue CancelBookingWorker: %w", err)
+	}
+
+	return true, nil
+}
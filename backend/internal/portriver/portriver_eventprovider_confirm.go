@@ -3,20 +3,19 @@ package portriver
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"slices"
 
+	"hackload/internal/domain"
+	"hackload/internal/service"
 	"hackload/internal/sqlc"
-	"hackload/pkg/eventprovider"
+	"hackload/internal/ticketing"
 
-	"github.com/google/uuid"
 	"github.com/riverqueue/river"
 )
 
 type ConfirmOrderArgs struct {
 	BookingID      int64
-	OrderID        uuid.UUID
+	OrderID        string
 	ExpectedPlaces int
 }
 
@@ -25,20 +24,28 @@ func (ConfirmOrderArgs) Kind() string { return "booking.confirm_order" }
 type ConfirmOrderWorker struct {
 	river.WorkerDefaults[ConfirmOrderArgs]
 
-	queries       *sqlc.Queries
-	db            *sql.DB
-	EventProvider eventprovider.ClientInterface
+	queries         *sqlc.Queries
+	db              *sql.DB
+	TicketProviders *ticketing.Registry
 }
 
-func NewConfirmOrderWorker(queries *sqlc.Queries, db *sql.DB, eventProvider eventprovider.ClientInterface) river.Worker[ConfirmOrderArgs] {
+func NewConfirmOrderWorker(queries *sqlc.Queries, db *sql.DB, ticketProviders *ticketing.Registry) river.Worker[ConfirmOrderArgs] {
 	return &ConfirmOrderWorker{
-		queries:       queries,
-		db:            db,
-		EventProvider: eventProvider,
+		queries:         queries,
+		db:              db,
+		TicketProviders: ticketProviders,
 	}
 }
 
 func (w *ConfirmOrderWorker) Work(ctx context.Context, job *river.Job[ConfirmOrderArgs]) error {
+	// Последняя попытка: заказ отменяется, бронь переводится в FAILED, деньги возвращаются
+	return queueFailBookingOnLastAttempt(ctx, job.JobRow, FailBookingArgs{
+		BookingID: job.Args.BookingID,
+		OrderID:   &job.Args.OrderID,
+	}, w.work(ctx, job))
+}
+
+func (w *ConfirmOrderWorker) work(ctx context.Context, job *river.Job[ConfirmOrderArgs]) error {
 	tx, err := w.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -53,15 +60,15 @@ func (w *ConfirmOrderWorker) Work(ctx context.Context, job *river.Job[ConfirmOrd
 		return fmt.Errorf("failed to get booking: %w", err)
 	}
 
-	// 2. Get order details and validate expected number of places
-	getOrderResp, err := w.EventProvider.GetOrder(ctx, job.Args.OrderID)
+	provider, err := w.TicketProviders.ForEvent(ctx, booking.EventID)
 	if err != nil {
-		return fmt.Errorf("failed to get order: %w", err)
+		return err
 	}
 
-	var order eventprovider.Order
-	if err := json.NewDecoder(getOrderResp.Body).Decode(&order); err != nil {
-		return fmt.Errorf("failed to decode getOrder response: %w", err)
+	// 2. Get order details and validate expected number of places
+	order, err := provider.GetOrder(ctx, job.Args.OrderID)
+	if err != nil {
+		return err
 	}
 
 	fmt.Printf("getOrder: %#v\n", order)
@@ -71,24 +78,19 @@ func (w *ConfirmOrderWorker) Work(ctx context.Context, job *river.Job[ConfirmOrd
 		return fmt.Errorf("order validation failed: expected %d places, got %d", job.Args.ExpectedPlaces, order.PlacesCount)
 	}
 
-	if slices.Contains([]eventprovider.OrderStatus{
-		eventprovider.CANCELLED,
-		eventprovider.CONFIRMED,
-	}, order.Status) {
-		return nil
+	switch order.Status {
+	case ticketing.OrderConfirmed:
+		// Заказ подтвержден предыдущей попыткой - осталось списать деньги
+		return queuePaymentCapture(ctx, w.queries, booking.ID)
+	case ticketing.OrderCancelled:
+		// Провайдер отменил заказ - места не получены, деньги нужно вернуть
+		return w.cancelBooking(ctx, tx, qtx, booking)
 	}
 
 	// 3. Submit order
-	if order.Status == eventprovider.STARTED {
-		submitResp, err := w.EventProvider.SubmitOrder(ctx, job.Args.OrderID)
-		if err != nil {
-			return fmt.Errorf("failed to submit order: %w", err)
-		}
-
-		fmt.Printf("submitResp: %v\n", submitResp.StatusCode)
-
-		if submitResp.StatusCode > 299 {
-			return fmt.Errorf("failed to submit order, status: %d", submitResp.StatusCode)
+	if order.Status == ticketing.OrderStarted {
+		if err := provider.SubmitOrder(ctx, job.Args.OrderID); err != nil {
+			return err
 		}
 
 		// Update booking order status to SUBMITTED
@@ -102,13 +104,8 @@ func (w *ConfirmOrderWorker) Work(ctx context.Context, job *river.Job[ConfirmOrd
 	}
 
 	// 4. Confirm order
-	confirmResp, err := w.EventProvider.ConfirmOrder(ctx, job.Args.OrderID)
-	if err != nil {
-		return fmt.Errorf("failed to confirm order: %w", err)
-	}
-
-	if confirmResp.StatusCode > 299 {
-		return fmt.Errorf("failed to confirm order, status: %d", confirmResp.StatusCode)
+	if err := provider.ConfirmOrder(ctx, job.Args.OrderID); err != nil {
+		return err
 	}
 
 	// Update booking order status to CONFIRMED
@@ -136,5 +133,55 @@ func (w *ConfirmOrderWorker) Work(ctx context.Context, job *river.Job[ConfirmOrd
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// 6. Двухстадийный платеж списывается только теперь, когда места получены
+	return queuePaymentCapture(ctx, w.queries, booking.ID)
+}
+
+// cancelBooking cancels a confirmed booking whose provider order was cancelled,
+// releases its seats and refunds the payment, or voids it if it was only authorized.
+func (w *ConfirmOrderWorker) cancelBooking(ctx context.Context, tx *sql.Tx, qtx *sqlc.Queries, booking sqlc.Booking) error {
+	// Бронь уже отменена пользователем или по неуспешной оплате
+	if booking.Status != domain.BookingStatusConfirmed {
+		return tx.Commit()
+	}
+
+	err := service.TransitionBooking(
+		ctx,
+		qtx,
+		booking,
+		domain.BookingStatusCancelled,
+		domain.ActorSystem,
+		"EventProvider order cancelled",
+	)
+	if err != nil {
+		return fmt.Errorf("failed to cancel booking %d: %w", booking.ID, err)
+	}
+
+	err = qtx.UpdateBookingOrderStatus(ctx, sqlc.UpdateBookingOrderStatusParams{
+		Status:    stringPtr("CANCELLED"),
+		BookingID: booking.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update booking order status to CANCELLED: %w", err)
+	}
+
+	if _, err := service.ReleaseBookingSeats(ctx, qtx, booking.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if _, err := river.ClientFromContext[*sql.Tx](ctx).Insert(ctx, RefundPaymentArgs{
+		BookingID: booking.ID,
+	}, nil); err != nil {
+		return fmt.Errorf("failed to queue RefundPaymentWorker: %w", err)
+	}
+
+	return queueWaitlistOffer(ctx, w.queries, booking.EventID)
 }
@@ -3,13 +3,13 @@ package portriver
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"hackload/internal/sqlc"
-	"hackload/pkg/eventprovider"
+	"hackload/internal/ticketing"
 
-	"github.com/google/uuid"
 	"github.com/riverqueue/river"
 )
 
@@ -22,123 +22,231 @@ func (SelectSeatsArgs) Kind() string { return "booking.select_seats" }
 type SelectSeatsWorker struct {
 	river.WorkerDefaults[SelectSeatsArgs]
 
-	queries       *sqlc.Queries
-	db            *sql.DB
-	riverClient   *river.Client[*sql.Tx]
-	EventProvider eventprovider.ClientInterface
+	queries         *sqlc.Queries
+	db              *sql.DB
+	TicketProviders *ticketing.Registry
 }
 
-func NewSelectSeatsWorker(queries *sqlc.Queries, db *sql.DB, riverClient *river.Client[*sql.Tx], eventProvider eventprovider.ClientInterface) river.Worker[SelectSeatsArgs] {
+func NewSelectSeatsWorker(queries *sqlc.Queries, db *sql.DB, ticketProviders *ticketing.Registry) river.Worker[SelectSeatsArgs] {
 	return &SelectSeatsWorker{
-		queries:       queries,
-		db:            db,
-		riverClient:   riverClient,
-		EventProvider: eventProvider,
+		queries:         queries,
+		db:              db,
+		TicketProviders: ticketProviders,
 	}
 }
 
 func (w *SelectSeatsWorker) Work(ctx context.Context, job *river.Job[SelectSeatsArgs]) error {
-	tx, err := w.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
+	orderID, err := w.work(ctx, job)
+
+	// Последняя попытка: заказ отменяется, бронь переводится в FAILED, деньги возвращаются
+	args := FailBookingArgs{BookingID: job.Args.BookingID}
+	if orderID != "" {
+		args.OrderID = &orderID
 	}
-	defer tx.Rollback()
+	return queueFailBookingOnLastAttempt(ctx, job.JobRow, args, err)
+}
 
-	qtx := w.queries.WithTx(tx)
+// work selects the seats and returns the provider order it uses, even on failure.
+// Места выбираются без транзакции: каждое выбранное место сразу отмечается в booking_seats,
+// и повторная попытка продолжает с того же заказа и первого невыбранного места.
+// С RESERVE_ON_SELECT у провайдера события места выбраны еще при добавлении в бронь,
+// и остается только передать заказ на подтверждение.
+func (w *SelectSeatsWorker) work(ctx context.Context, job *river.Job[SelectSeatsArgs]) (string, error) {
+	// 1. Get the booking and the provider of its event
+	booking, err := w.queries.GetBooking(ctx, job.Args.BookingID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get booking: %w", err)
+	}
 
-	// 1. Get the booking
-	booking, err := qtx.GetBooking(ctx, job.Args.BookingID)
+	provider, err := w.TicketProviders.ForEvent(ctx, booking.EventID)
 	if err != nil {
-		return fmt.Errorf("failed to get booking: %w", err)
+		return "", err
 	}
 
 	// 2. Get all seats for this booking
-	seatIDs, err := qtx.GetBookingSeats(ctx, booking.ID)
+	seats, err := w.queries.GetBookingSeatPlaces(ctx, booking.ID)
 	if err != nil {
-		return fmt.Errorf("failed to get booking seats: %w", err)
+		return "", fmt.Errorf("failed to get booking seats: %w", err)
 	}
 
-	if len(seatIDs) == 0 {
-		return fmt.Errorf("no seats found for booking %d", booking.ID)
+	if len(seats) == 0 {
+		return "", fmt.Errorf("no seats found for booking %d", booking.ID)
 	}
 
-	// 3. Start order with EventProvider
-	orderResp, err := w.EventProvider.StartOrder(ctx)
+	// 3. Reuse the order of a previous attempt or start a new one
+	order, err := w.bookingOrder(ctx, provider, booking.ID)
 	if err != nil {
-		return fmt.Errorf("failed to start order: %w", err)
+		return order.ID, err
 	}
+	orderID := order.ID
 
-	if orderResp.StatusCode != 201 {
-		return fmt.Errorf("failed to start order, status: %d", orderResp.StatusCode)
+	// Места уже переданы на подтверждение - ConfirmOrderWorker разберется с заказом
+	if order.Status != ticketing.OrderStarted {
+		return orderID, w.queueConfirmOrder(ctx, booking.ID, orderID, order.PlacesCount)
 	}
 
-	// Parse order creation response
-	var orderCreated eventprovider.OrderCreatedResponse
-	if err := json.NewDecoder(orderResp.Body).Decode(&orderCreated); err != nil {
-		return fmt.Errorf("failed to decode order response: %w", err)
+	// Число мест в заказе расходится с отмеченными: предыдущая попытка выбрала место, но не успела
+	// это записать, или отмеченное место пропало из заказа. Провайдер не сообщает, какие места
+	// в заказе, поэтому выбор повторяется для всех мест, в которых нет уверенности, а выбранные
+	// места заказа остаются за бронью
+	markedSeats := 0
+	for _, seat := range seats {
+		if seat.ExternalID != nil && seat.PlaceSelectedAt != nil {
+			markedSeats++
+		}
 	}
+	recheckMarked := order.PlacesCount < markedSeats
 
-	orderID := orderCreated.OrderId
-	fmt.Printf("orderCreated: %v\n", orderCreated)
+	// 4. Select every place that is not selected yet
+	selectedSeats := 0
+	var takenSeats []sqlc.GetBookingSeatPlacesRow
+	for _, seat := range seats {
+		// Skip seats without external_id
+		if seat.ExternalID == nil {
+			continue
+		}
 
-	// 4. Save order_id into booking_orders
-	err = qtx.InsertBookingOrder(ctx, sqlc.InsertBookingOrderParams{
-		BookingID: booking.ID,
-		OrderID:   orderID.String(),
-		Status:    stringPtr("STARTED"),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to insert booking order: %w", err)
-	}
+		if seat.PlaceSelectedAt != nil && !recheckMarked {
+			selectedSeats++
+			continue
+		}
 
-	// 5. For each seat, get seat info and select it
-	selectedSeats := 0
-	for _, seatID := range seatIDs {
-		// Get seat details efficiently by ID
-		targetSeat, err := qtx.GetSeatByID(ctx, seatID)
+		err := provider.SelectPlace(ctx, orderID, *seat.ExternalID)
+		if errors.Is(err, ticketing.ErrPlaceTaken) {
+			// Место занято этим же заказом или чужим - различить можно только по числу мест в заказе
+			takenSeats = append(takenSeats, seat)
+			continue
+		}
 		if err != nil {
-			return fmt.Errorf("failed to get seat %d: %w", seatID, err)
+			return orderID, err
 		}
 
-		fmt.Printf("targetSeat: %#v\n", targetSeat)
+		if err := w.markSelected(ctx, booking.ID, seat); err != nil {
+			return orderID, err
+		}
 
-		// Skip seats without external_id
-		if targetSeat.ExternalID == nil {
-			continue
+		selectedSeats++
+	}
+
+	// Занятые места принадлежат заказу, только если в нем ровно все остальные места брони
+	if len(takenSeats) > 0 {
+		order, err = provider.GetOrder(ctx, orderID)
+		if err != nil {
+			return orderID, err
+		}
+		if order.PlacesCount != selectedSeats+len(takenSeats) {
+			return orderID, fmt.Errorf("%w: order %s holds %d of %d places", ticketing.ErrPlaceTaken,
+				orderID, order.PlacesCount, selectedSeats+len(takenSeats))
+		}
+
+		for _, seat := range takenSeats {
+			if err := w.markSelected(ctx, booking.ID, seat); err != nil {
+				return orderID, err
+			}
+			selectedSeats++
 		}
+	}
+
+	// 5. Enqueue ConfirmOrder worker to handle order validation, submission and confirmation
+	return orderID, w.queueConfirmOrder(ctx, booking.ID, orderID, selectedSeats)
+}
 
-		placeID, err := uuid.Parse(*targetSeat.ExternalID)
+// bookingOrder returns the provider order of the booking. An order the provider
+// no longer knows or has cancelled is replaced by a new one, and the seats are selected again.
+func (w *SelectSeatsWorker) bookingOrder(ctx context.Context, provider ticketing.Provider, bookingID int64) (ticketing.Order, error) {
+	bookingOrder, err := w.queries.GetBookingOrder(ctx, bookingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		orderID, err := provider.StartOrder(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to parse external_id: %w", err)
+			return ticketing.Order{}, err
 		}
 
-		// Select place for the order
-		selectResp, err := w.EventProvider.SelectPlace(ctx, placeID, eventprovider.SelectPlaceRequest{
-			OrderId: orderID,
+		err = w.queries.InsertBookingOrder(ctx, sqlc.InsertBookingOrderParams{
+			BookingID: bookingID,
+			OrderID:   orderID,
+			Status:    stringPtr("STARTED"),
 		})
 		if err != nil {
-			return fmt.Errorf("failed to select place: %w", err)
+			return ticketing.Order{ID: orderID}, fmt.Errorf("failed to insert booking order: %w", err)
 		}
 
-		fmt.Printf("selectResp: %v\n", selectResp.StatusCode)
+		return ticketing.Order{ID: orderID, Status: ticketing.OrderStarted}, nil
+	}
+	if err != nil {
+		return ticketing.Order{}, fmt.Errorf("failed to get booking order: %w", err)
+	}
 
-		if selectResp.StatusCode > 299 {
-			return fmt.Errorf("failed to select place, status: %d", selectResp.StatusCode)
-		}
+	order, err := provider.GetOrder(ctx, bookingOrder.OrderID)
+	if err != nil && !errors.Is(err, ticketing.ErrOrderNotFound) {
+		return ticketing.Order{ID: bookingOrder.OrderID}, err
+	}
+	if err == nil && order.Status != ticketing.OrderCancelled {
+		return order, nil
+	}
 
-		selectedSeats++
+	// Заказ потерян провайдером или отменен - начинаем новый
+	return w.restartOrder(ctx, provider, bookingID)
+}
+
+// restartOrder replaces the order of the booking with a new one, in which no seat is selected yet.
+func (w *SelectSeatsWorker) restartOrder(ctx context.Context, provider ticketing.Provider, bookingID int64) (ticketing.Order, error) {
+	orderID, err := provider.StartOrder(ctx)
+	if err != nil {
+		return ticketing.Order{}, err
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ticketing.Order{ID: orderID}, err
+	}
+	defer tx.Rollback()
+
+	qtx := w.queries.WithTx(tx)
+
+	err = qtx.UpdateBookingOrder(ctx, sqlc.UpdateBookingOrderParams{
+		OrderID:   orderID,
+		Status:    stringPtr("STARTED"),
+		BookingID: bookingID,
+	})
+	if err != nil {
+		return ticketing.Order{ID: orderID}, fmt.Errorf("failed to update booking order: %w", err)
+	}
+
+	if err := qtx.ResetBookingSeatPlacesSelected(ctx, bookingID); err != nil {
+		return ticketing.Order{ID: orderID}, fmt.Errorf("failed to reset selected seats: %w", err)
 	}
 
-	// Commit the transaction first to ensure seat selections are persisted
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit seat selection transaction: %w", err)
+		return ticketing.Order{ID: orderID}, fmt.Errorf("failed to commit booking order: %w", err)
 	}
 
-	// 6. Enqueue ConfirmOrder worker to handle order validation, submission and confirmation
-	_, err = w.riverClient.Insert(ctx, ConfirmOrderArgs{
-		BookingID:      booking.ID,
+	return ticketing.Order{ID: orderID, Status: ticketing.OrderStarted}, nil
+}
+
+// markSelected records that the place of the seat is selected in the booking order, unless it is already recorded.
+func (w *SelectSeatsWorker) markSelected(ctx context.Context, bookingID int64, seat sqlc.GetBookingSeatPlacesRow) error {
+	if seat.PlaceSelectedAt != nil {
+		return nil
+	}
+
+	selectedAt := time.Now().UTC()
+	err := w.queries.MarkBookingSeatPlaceSelected(ctx, sqlc.MarkBookingSeatPlaceSelectedParams{
+		PlaceSelectedAt: &selectedAt,
+		BookingID:       bookingID,
+		SeatID:          seat.SeatID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark seat %d selected: %w", seat.SeatID, err)
+	}
+
+	return nil
+}
+
+func (w *SelectSeatsWorker) queueConfirmOrder(ctx context.Context, bookingID int64, orderID string, expectedPlaces int) error {
+	_, err := river.ClientFromContext[*sql.Tx](ctx).Insert(ctx, ConfirmOrderArgs{
+		BookingID:      bookingID,
 		OrderID:        orderID,
-		ExpectedPlaces: selectedSeats,
+		ExpectedPlaces: expectedPlaces,
 	}, nil)
 	if err != nil {
 		return fmt.Errorf("failed to enqueue confirm order job: %w", err)
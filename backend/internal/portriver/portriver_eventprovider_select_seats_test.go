@@ -0,0 +1,153 @@
+//go:build sqlite_fts5
+
+package portriver_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"hackload/internal/domain"
+	"hackload/internal/portriver"
+	"hackload/internal/testenv"
+	"hackload/pkg/eventprovider/fakeprovider"
+
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+func startSelectSeats(t *testing.T, env *sagaEnv) *river.Client[*sql.Tx] {
+	t.Helper()
+
+	river.AddWorker(env.deps.RiverWorkers, portriver.NewSelectSeatsWorker(env.queries, env.deps.DB, env.registry))
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.ConfirmOrderArgs]{})
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.FailBookingArgs]{})
+
+	return testenv.StartRiver(t, env.deps)
+}
+
+// markSelected records the places of the seats as selected in the booking order.
+func (e *sagaEnv) markSelected(t *testing.T, bookingID int64, seatIDs ...int64) {
+	t.Helper()
+
+	for _, seatID := range seatIDs {
+		e.exec(t, `UPDATE booking_seats SET place_selected_at = CURRENT_TIMESTAMP WHERE booking_id = ? AND seat_id = ?`, bookingID, seatID)
+	}
+}
+
+// assertOrderHoldsBooking checks that the booking order holds exactly the places of the seats,
+// all of them recorded as selected, and that ConfirmOrderWorker is queued for it.
+func (e *sagaEnv) assertOrderHoldsBooking(t *testing.T, riverClient *river.Client[*sql.Tx], bookingID int64, orderID uuid.UUID, seatIDs ...int64) {
+	t.Helper()
+
+	bookingOrder, err := e.queries.GetBookingOrder(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bookingOrder.OrderID != orderID.String() {
+		t.Errorf("booking order is %s, want %s", bookingOrder.OrderID, orderID)
+	}
+
+	order, err := e.provider.Provider.GetOrder(orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.PlacesCount != len(seatIDs) {
+		t.Errorf("provider order holds %d places, want %d", order.PlacesCount, len(seatIDs))
+	}
+	for _, seatID := range seatIDs {
+		place, err := e.provider.Provider.GetPlace(e.places[seatID-1].Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if place.IsFree {
+			t.Errorf("provider place %d is free", seatID)
+		}
+	}
+
+	seats, err := e.queries.GetBookingSeatPlaces(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, seat := range seats {
+		if seat.PlaceSelectedAt == nil {
+			t.Errorf("seat %d is not recorded as selected", seat.SeatID)
+		}
+	}
+
+	jobs := testenv.Jobs(t, riverClient, portriver.ConfirmOrderArgs{}.Kind())
+	if len(jobs) != 1 {
+		t.Fatalf("%d confirm order jobs queued, want 1", len(jobs))
+	}
+	var args portriver.ConfirmOrderArgs
+	if err := json.Unmarshal(jobs[0].EncodedArgs, &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.OrderID != orderID.String() || args.ExpectedPlaces != len(seatIDs) {
+		t.Errorf("confirm order job expects %d places of order %s, want %d of %s", args.ExpectedPlaces, args.OrderID, len(seatIDs), orderID)
+	}
+}
+
+func TestSelectSeatsResumesAfterLostSelect(t *testing.T) {
+	env := newSagaEnv(t)
+	riverClient := startSelectSeats(t, env)
+
+	bookingID := env.createBooking(t, domain.BookingStatusConfirmed, 1, 2, 3)
+	orderID := env.startOrder(t, bookingID)
+
+	// Место 2 выбрано, но ответ потерян: работа обрывается посреди цикла, место не отмечено
+	env.provider.Provider.Fail(fakeprovider.Failure{
+		Op:                   fakeprovider.OpSelectPlace,
+		ID:                   env.places[1].Id.String(),
+		AfterwardsStatusCode: http.StatusInternalServerError,
+	})
+
+	// Повторная попытка той же задачи продолжает тот же заказ
+	if _, err := riverClient.Insert(context.Background(), portriver.SelectSeatsArgs{BookingID: bookingID}, &river.InsertOpts{MaxAttempts: 2}); err != nil {
+		t.Fatal(err)
+	}
+	jobs := testenv.WaitJobs(t, riverClient, portriver.SelectSeatsArgs{}.Kind())
+	if len(jobs) != 1 || jobs[0].State != rivertype.JobStateCompleted || len(jobs[0].Errors) != 1 {
+		t.Fatalf("select seats jobs: %+v", jobs)
+	}
+
+	env.assertOrderHoldsBooking(t, riverClient, bookingID, orderID, 1, 2, 3)
+	if jobs := testenv.Jobs(t, riverClient, portriver.FailBookingArgs{}.Kind()); len(jobs) != 0 {
+		t.Errorf("%d fail booking jobs queued, want none", len(jobs))
+	}
+}
+
+func TestSelectSeatsKeepsPlacesHeldByOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		held   []int64
+		marked []int64
+	}{
+		// Место выбрано в заказе, но отметка о выборе не записана
+		{name: "unmarked place in order", held: []int64{1, 2, 3}, marked: []int64{1, 2}},
+		// Отмеченное место пропало из заказа
+		{name: "marked place missing from order", held: []int64{1}, marked: []int64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newSagaEnv(t)
+			riverClient := startSelectSeats(t, env)
+
+			bookingID := env.createBooking(t, domain.BookingStatusConfirmed, 1, 2, 3)
+			orderID := env.startOrder(t, bookingID, tt.held...)
+			env.markSelected(t, bookingID, tt.marked...)
+
+			job := testenv.WorkJob(t, riverClient, portriver.SelectSeatsArgs{BookingID: bookingID})
+			if job.State != rivertype.JobStateCompleted {
+				t.Fatalf("job is %s: %v", job.State, job.Errors)
+			}
+
+			// Заказ не отменяется: места, выбранные с RESERVE_ON_SELECT, остаются за бронью
+			env.assertOrderHoldsBooking(t, riverClient, bookingID, orderID, 1, 2, 3)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package portriver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"hackload/internal/sqlc"
+
+	"github.com/riverqueue/river"
+)
+
+// CleanupIdempotencyKeysArgs runs periodically and removes idempotency keys
+// whose replay window has passed.
+type CleanupIdempotencyKeysArgs struct{}
+
+func (CleanupIdempotencyKeysArgs) Kind() string { return "idempotency.cleanup" }
+
+type CleanupIdempotencyKeysWorker struct {
+	river.WorkerDefaults[CleanupIdempotencyKeysArgs]
+
+	queries *sqlc.Queries
+	ttl     time.Duration
+}
+
+func NewCleanupIdempotencyKeysWorker(queries *sqlc.Queries, ttl time.Duration) river.Worker[CleanupIdempotencyKeysArgs] {
+	return &CleanupIdempotencyKeysWorker{
+		queries: queries,
+		ttl:     ttl,
+	}
+}
+
+func (w *CleanupIdempotencyKeysWorker) Work(ctx context.Context, job *river.Job[CleanupIdempotencyKeysArgs]) error {
+	deleted, err := w.queries.DeleteExpiredIdempotencyKeys(ctx, time.Now().UTC().Add(-w.ttl))
+	if err != nil {
+		return fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	if deleted > 0 {
+		slog.Info("expired idempotency keys deleted", "count", deleted)
+	}
+
+	return nil
+}
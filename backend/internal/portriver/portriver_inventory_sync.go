@@ -0,0 +1,280 @@
+package portriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"hackload/internal/config"
+	"hackload/internal/sqlc"
+	"hackload/internal/ticketing"
+
+	"github.com/riverqueue/river"
+)
+
+// SyncInventoryArgs runs periodically and compares the seats with the places of every ticket provider.
+// Unlike ResetService.Reset it never touches bookings: only seats nobody booked are updated.
+type SyncInventoryArgs struct{}
+
+func (SyncInventoryArgs) Kind() string { return "inventory.sync" }
+
+// Вид расхождения (InventoryDrift.Kind)
+const (
+	// Место занято у провайдера, а у нас свободно: его держит другой дистрибьютор.
+	// Место становится недоступным
+	DriftHeldElsewhere = "held_elsewhere"
+	// Место освобождено у провайдера, а у нас недоступно без брони. Место снова свободно
+	DriftReleasedElsewhere = "released_elsewhere"
+	// Место продано у нас, но свободно у провайдера. Только в отчете: место принадлежит брони
+	DriftSoldButFree = "sold_but_free"
+	// Места провайдера нет у нас или оно относится к событию другого провайдера. Только в отчете
+	DriftUnknownPlace = "unknown_place"
+)
+
+// InventoryDrift is a seat whose status disagrees with the provider place.
+type InventoryDrift struct {
+	Provider     string `json:"provider,omitempty"`
+	ExternalID   string `json:"external_id"`
+	SeatID       int64  `json:"seat_id,omitempty"`
+	BookingID    *int64 `json:"booking_id,omitempty"`
+	LocalStatus  string `json:"local_status,omitempty"`
+	ProviderFree bool   `json:"provider_free"`
+	Kind         string `json:"kind"`
+	Fixed        bool   `json:"fixed"`
+}
+
+// InventorySyncReport is recorded as the job output and logged after every run.
+type InventorySyncReport struct {
+	Pages  int              `json:"pages"`
+	Places int              `json:"places"`
+	Drifts map[string]int   `json:"drifts"`
+	Fixed  int              `json:"fixed"`
+	Seats  []InventoryDrift `json:"seats"`
+}
+
+type SyncInventoryWorker struct {
+	river.WorkerDefaults[SyncInventoryArgs]
+
+	queries         *sqlc.Queries
+	db              *sql.DB
+	TicketProviders *ticketing.Registry
+	config          *config.Config
+}
+
+func NewSyncInventoryWorker(queries *sqlc.Queries, db *sql.DB, ticketProviders *ticketing.Registry, config *config.Config) river.Worker[SyncInventoryArgs] {
+	return &SyncInventoryWorker{
+		queries:         queries,
+		db:              db,
+		TicketProviders: ticketProviders,
+		config:          config,
+	}
+}
+
+// inventorySync is the state of one run.
+type inventorySync struct {
+	report      InventorySyncReport
+	freedEvents map[int64]bool
+	// Провайдер события, чтобы не искать его для каждого места
+	eventRoutes map[int64]*ticketing.Route
+}
+
+// Timeout allows paging through all the places, the default minute is not enough for a large venue.
+func (w *SyncInventoryWorker) Timeout(*river.Job[SyncInventoryArgs]) time.Duration {
+	return 15 * time.Minute
+}
+
+func (w *SyncInventoryWorker) Work(ctx context.Context, job *river.Job[SyncInventoryArgs]) error {
+	run := &inventorySync{
+		report: InventorySyncReport{
+			Drifts: map[string]int{},
+			Seats:  make([]InventoryDrift, 0),
+		},
+		freedEvents: map[int64]bool{},
+		eventRoutes: map[int64]*ticketing.Route{},
+	}
+	report := &run.report
+
+	// 1. Page through the places of every provider and sync each page on its own.
+	// Исправления идемпотентны, поэтому повтор после ошибки просто продолжит сверку
+	pageSize := w.config.InventorySync.PageSize
+	for _, route := range w.TicketProviders.Routes() {
+		for page := 1; ; page++ {
+			places, err := route.ListPlaces(ctx, page, pageSize)
+			if err != nil {
+				return err
+			}
+
+			report.Pages++
+			report.Places += len(places)
+
+			if err := w.syncPage(ctx, route, places, run); err != nil {
+				return err
+			}
+
+			if len(places) < pageSize {
+				break
+			}
+		}
+	}
+
+	// 2. Offer the seats that became FREE to the waitlist
+	for eventID := range run.freedEvents {
+		if err := queueWaitlistOffer(ctx, w.queries, eventID); err != nil {
+			slog.Error("failed to queue waitlist offer", "event_id", eventID, "error", err)
+		}
+	}
+
+	// 3. Report
+	if err := river.RecordOutput(ctx, report); err != nil {
+		return fmt.Errorf("failed to record inventory sync report: %w", err)
+	}
+
+	slog.Info("inventory synced",
+		"pages", report.Pages,
+		"places", report.Places,
+		"drifts", report.Drifts,
+		"fixed", report.Fixed,
+	)
+	for _, drift := range report.Seats {
+		if !drift.Fixed {
+			slog.Warn("inventory drift",
+				"provider", drift.Provider,
+				"external_id", drift.ExternalID,
+				"seat_id", drift.SeatID,
+				"local_status", drift.LocalStatus,
+				"provider_free", drift.ProviderFree,
+				"kind", drift.Kind,
+			)
+		}
+	}
+
+	return nil
+}
+
+// syncPage diffs one page of the route's places against the seats and fixes the seats nobody booked.
+func (w *SyncInventoryWorker) syncPage(
+	ctx context.Context,
+	route *ticketing.Route,
+	places []ticketing.Place,
+	run *inventorySync,
+) error {
+	if len(places) == 0 {
+		return nil
+	}
+
+	report := &run.report
+
+	externalIDs := make([]*string, 0, len(places))
+	for _, place := range places {
+		externalIDs = append(externalIDs, stringPtr(place.ID))
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := w.queries.WithTx(tx)
+
+	seats, err := qtx.GetSeatsByExternalIDs(ctx, externalIDs)
+	if err != nil {
+		return fmt.Errorf("failed to get seats by external ids: %w", err)
+	}
+
+	seatsByExternalID := make(map[string]sqlc.GetSeatsByExternalIDsRow, len(seats))
+	for _, seat := range seats {
+		// Место события другого провайдера не сверяется с этим
+		seatRoute, err := w.eventRoute(ctx, run, seat.EventID)
+		if err != nil {
+			return err
+		}
+		if seatRoute != route {
+			continue
+		}
+
+		seatsByExternalID[*seat.ExternalID] = seat
+	}
+
+	for _, place := range places {
+		drift := InventoryDrift{
+			Provider:     route.Name,
+			ExternalID:   place.ID,
+			ProviderFree: place.IsFree,
+		}
+
+		seat, ok := seatsByExternalID[drift.ExternalID]
+		if ok {
+			drift.SeatID = seat.ID
+			drift.BookingID = seat.BookingID
+			drift.LocalStatus = seat.Status
+		}
+
+		// Места с бронью не меняются: за них отвечают воркеры брони
+		var statusEq, status string
+		switch {
+		case !ok:
+			drift.Kind = DriftUnknownPlace
+		case !place.IsFree && seat.Status == "FREE" && seat.BookingID == nil:
+			drift.Kind = DriftHeldElsewhere
+			statusEq, status = "FREE", "RESERVED"
+		case place.IsFree && seat.Status == "RESERVED" && seat.BookingID == nil:
+			drift.Kind = DriftReleasedElsewhere
+			statusEq, status = "RESERVED", "FREE"
+		case place.IsFree && seat.Status == "SOLD":
+			drift.Kind = DriftSoldButFree
+		default:
+			continue
+		}
+
+		if status != "" {
+			// Условное обновление: место могли выбрать, пока шла сверка
+			updated, err := qtx.UpdateUnbookedSeatStatus(ctx, sqlc.UpdateUnbookedSeatStatusParams{
+				Status:   status,
+				SeatID:   seat.ID,
+				StatusEq: statusEq,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to update seat %d status: %w", seat.ID, err)
+			}
+			if updated == 0 {
+				continue
+			}
+
+			drift.Fixed = true
+			report.Fixed++
+			if status == "FREE" {
+				run.freedEvents[seat.EventID] = true
+			}
+		}
+
+		report.Drifts[drift.Kind]++
+		if len(report.Seats) < w.config.InventorySync.ReportLimit {
+			report.Seats = append(report.Seats, drift)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit inventory sync: %w", err)
+	}
+
+	return nil
+}
+
+// eventRoute returns the route of the event, nil if no provider serves it.
+func (w *SyncInventoryWorker) eventRoute(ctx context.Context, run *inventorySync, eventID int64) (*ticketing.Route, error) {
+	if route, ok := run.eventRoutes[eventID]; ok {
+		return route, nil
+	}
+
+	route, err := w.TicketProviders.ForEvent(ctx, eventID)
+	if err != nil && !errors.Is(err, ticketing.ErrUnknownProvider) {
+		return nil, err
+	}
+
+	run.eventRoutes[eventID] = route
+	return route, nil
+}
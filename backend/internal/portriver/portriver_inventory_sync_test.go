@@ -0,0 +1,110 @@
+//go:build sqlite_fts5
+
+package portriver_test
+
+import (
+	"encoding/json"
+	"maps"
+	"testing"
+
+	"hackload/internal/domain"
+	"hackload/internal/portriver"
+	"hackload/internal/testenv"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+func TestSyncInventoryFixesUnbookedSeats(t *testing.T) {
+	env := newSagaEnv(t)
+	env.conf.InventorySync.PageSize = 2
+	env.conf.InventorySync.ReportLimit = 100
+
+	// Места 4-7 добавляются к трем местам окружения, у места 7 нет места в сервисе
+	env.provider.Provider.Seed(4)
+	places, err := env.provider.Provider.ListPlaces(1, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.places = places
+	for i := 3; i < 6; i++ {
+		env.exec(t, `INSERT INTO seats (id, event_id, external_id, row, number, price, status) VALUES (?, 1, ?, 1, ?, 10000, 'FREE')`,
+			i+1, places[i].Id.String(), i+1)
+	}
+
+	river.AddWorker(env.deps.RiverWorkers, portriver.NewSyncInventoryWorker(env.queries, env.deps.DB, env.registry, env.conf))
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.OfferWaitlistSeatsArgs]{})
+	riverClient := testenv.StartRiver(t, env.deps)
+
+	// Места 1 и 5 держит другой дистрибьютор
+	other := env.provider.Provider.StartOrder()
+	for _, i := range []int{0, 4} {
+		if err := env.provider.Provider.SelectPlace(places[i].Id, other.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 1: свободно у нас и занято у провайдера
+	// 2: недоступно без брони, а у провайдера свободно
+	env.exec(t, `UPDATE seats SET status = 'RESERVED' WHERE id = 2`)
+	// 3 и 5: места брони, которые расходятся с провайдером в обе стороны
+	created := env.createBooking(t, domain.BookingStatusCreated, 3, 5)
+	env.exec(t, `UPDATE seats SET status = 'FREE' WHERE id = 5`)
+	// 4: продано у нас, а у провайдера свободно
+	confirmed := env.createBooking(t, domain.BookingStatusConfirmed, 4)
+	env.exec(t, `UPDATE seats SET status = 'SOLD' WHERE id = 4`)
+	// 6: совпадает с провайдером
+
+	env.exec(t, `INSERT INTO waitlist_entries (event_id, user_id, seats_count, status, created_at) VALUES (1, 2, 1, 'WAITING', CURRENT_TIMESTAMP)`)
+
+	job := testenv.WorkJob(t, riverClient, portriver.SyncInventoryArgs{})
+	if job.State != rivertype.JobStateCompleted {
+		t.Fatalf("job is %s: %v", job.State, job.Errors)
+	}
+
+	var report portriver.InventorySyncReport
+	if err := json.Unmarshal(job.Output(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Pages != 4 || report.Places != 7 {
+		t.Errorf("report has %d pages of %d places, want 4 pages of 7", report.Pages, report.Places)
+	}
+	wantDrifts := map[string]int{
+		portriver.DriftHeldElsewhere:     1,
+		portriver.DriftReleasedElsewhere: 1,
+		portriver.DriftSoldButFree:       1,
+		portriver.DriftUnknownPlace:      1,
+	}
+	if !maps.Equal(report.Drifts, wantDrifts) || report.Fixed != 2 {
+		t.Errorf("report has drifts %v with %d fixed, want %v with 2", report.Drifts, report.Fixed, wantDrifts)
+	}
+
+	drifts := map[string]portriver.InventoryDrift{}
+	for _, drift := range report.Seats {
+		drifts[drift.ExternalID] = drift
+	}
+	if drift := drifts[places[3].Id.String()]; drift.Kind != portriver.DriftSoldButFree || drift.Fixed || drift.BookingID == nil || *drift.BookingID != confirmed {
+		t.Errorf("seat 4 drift: %+v", drift)
+	}
+	if drift := drifts[places[6].Id.String()]; drift.Kind != portriver.DriftUnknownPlace || drift.Fixed {
+		t.Errorf("place 7 drift: %+v", drift)
+	}
+
+	// Исправляются только места без брони
+	wantStatuses := map[int64]string{1: "RESERVED", 2: "FREE", 3: "RESERVED", 4: "SOLD", 5: "FREE", 6: "FREE"}
+	for seatID, want := range wantStatuses {
+		if status := env.seatStatus(t, seatID); status != want {
+			t.Errorf("seat %d is %s, want %s", seatID, status, want)
+		}
+	}
+	for _, bookingID := range []int64{created, confirmed} {
+		if history := env.statusHistory(t, bookingID); len(history) != 0 {
+			t.Errorf("booking %d moved: %v", bookingID, history)
+		}
+	}
+
+	// Освободившееся место предлагается листу ожидания
+	if jobs := testenv.Jobs(t, riverClient, portriver.OfferWaitlistSeatsArgs{}.Kind()); len(jobs) != 1 {
+		t.Errorf("%d waitlist offer jobs queued, want 1", len(jobs))
+	}
+}
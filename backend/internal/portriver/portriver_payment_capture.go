@@ -0,0 +1,104 @@
+package portriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"hackload/internal/config"
+	"hackload/internal/domain"
+	"hackload/internal/service"
+	"hackload/internal/sqlc"
+	"hackload/pkg/paymentgateway"
+
+	"github.com/riverqueue/river"
+)
+
+// CapturePaymentArgs charges an authorized two-stage payment once the EventProvider
+// has confirmed the booking's order.
+type CapturePaymentArgs struct {
+	BookingID int64
+}
+
+func (CapturePaymentArgs) Kind() string { return "payment.capture" }
+
+type CapturePaymentWorker struct {
+	river.WorkerDefaults[CapturePaymentArgs]
+
+	queries        *sqlc.Queries
+	paymentGateway paymentgateway.ClientInterface
+	config         *config.Config
+}
+
+func NewCapturePaymentWorker(queries *sqlc.Queries, paymentGateway paymentgateway.ClientInterface, config *config.Config) river.Worker[CapturePaymentArgs] {
+	return &CapturePaymentWorker{
+		queries:        queries,
+		paymentGateway: paymentGateway,
+		config:         config,
+	}
+}
+
+func (w *CapturePaymentWorker) Work(ctx context.Context, job *river.Job[CapturePaymentArgs]) error {
+	// 1. Бронь могла быть отменена после подтверждения заказа - тогда блокировку снимает RefundPaymentWorker
+	booking, err := w.queries.GetBooking(ctx, job.Args.BookingID)
+	if err != nil {
+		return fmt.Errorf("failed to get booking: %w", err)
+	}
+
+	if booking.Status != domain.BookingStatusConfirmed {
+		return nil
+	}
+
+	// 2. Only authorized two-stage payments are captured
+	payment, err := w.queries.GetPaidBookingPayment(ctx, booking.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get booking payment: %w", err)
+	}
+
+	if !payment.TwoStage || payment.Status == nil || *payment.Status != domain.PaymentStatusAuthorized {
+		return nil
+	}
+
+	// 3. Capture at the gateway
+	if err := service.CapturePayment(ctx, w.paymentGateway, payment, w.config.PaymentProvider.MerchantPassword); err != nil {
+		return err
+	}
+
+	// 4. Update payment status to SUCCESS
+	err = w.queries.UpdateBookingPaymentStatus(ctx, sqlc.UpdateBookingPaymentStatusParams{
+		Status:  stringPtr(domain.PaymentStatusSuccess),
+		OrderID: payment.OrderID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update payment status to SUCCESS: %w", err)
+	}
+
+	return nil
+}
+
+// queuePaymentCapture enqueues CapturePaymentArgs if the booking is paid by an authorized two-stage payment.
+func queuePaymentCapture(ctx context.Context, queries *sqlc.Queries, bookingID int64) error {
+	payment, err := queries.GetPaidBookingPayment(ctx, bookingID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get booking payment for booking %d: %w", bookingID, err)
+	}
+
+	if !payment.TwoStage || payment.Status == nil || *payment.Status != domain.PaymentStatusAuthorized {
+		return nil
+	}
+
+	if _, err := river.ClientFromContext[*sql.Tx](ctx).Insert(ctx, CapturePaymentArgs{
+		BookingID: bookingID,
+	}, nil); err != nil {
+		return fmt.Errorf("failed to queue CapturePaymentWorker: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package portriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"hackload/internal/config"
+	"hackload/internal/domain"
+	"hackload/internal/service"
+	"hackload/internal/sqlc"
+	"hackload/pkg/paymentgateway"
+
+	"github.com/riverqueue/river"
+)
+
+// ReconcilePaymentsArgs runs periodically and settles payments stuck in INIT
+// because the success/fail redirect never reached us.
+type ReconcilePaymentsArgs struct{}
+
+func (ReconcilePaymentsArgs) Kind() string { return "payment.reconcile" }
+
+// Итог сверки платежа (PaymentReconciliationResult.Outcome)
+const (
+	ReconciliationConfirmed   = "confirmed"
+	ReconciliationFailed      = "failed"
+	ReconciliationCancelled   = "cancelled"
+	ReconciliationRefunded    = "refunded"
+	ReconciliationNeedsReview = "needs_review"
+	ReconciliationError       = "error"
+)
+
+// PaymentReconciliationResult is the outcome of reconciling one payment.
+type PaymentReconciliationResult struct {
+	OrderID       string `json:"order_id"`
+	BookingID     int64  `json:"booking_id"`
+	GatewayStatus string `json:"gateway_status,omitempty"`
+	Outcome       string `json:"outcome"`
+	Error         string `json:"error,omitempty"`
+}
+
+// PaymentReconciliationReport is recorded as the job output and logged after every run.
+type PaymentReconciliationReport struct {
+	Checked  int                           `json:"checked"`
+	Outcomes map[string]int                `json:"outcomes"`
+	Payments []PaymentReconciliationResult `json:"payments"`
+}
+
+type ReconcilePaymentsWorker struct {
+	river.WorkerDefaults[ReconcilePaymentsArgs]
+
+	queries        *sqlc.Queries
+	paymentGateway paymentgateway.ClientInterface
+	paymentSaga    *PaymentSaga
+	config         *config.Config
+}
+
+func NewReconcilePaymentsWorker(queries *sqlc.Queries, db *sql.DB, paymentGateway paymentgateway.ClientInterface, config *config.Config) river.Worker[ReconcilePaymentsArgs] {
+	return &ReconcilePaymentsWorker{
+		queries:        queries,
+		paymentGateway: paymentGateway,
+		paymentSaga:    NewPaymentSaga(queries, db, paymentGateway, config),
+		config:         config,
+	}
+}
+
+func (w *ReconcilePaymentsWorker) Work(ctx context.Context, job *river.Job[ReconcilePaymentsArgs]) error {
+	createdBefore := time.Now().UTC().Add(-w.config.PaymentReconciliation.StaleAfter)
+
+	// 1. Payments still in INIT long after they were created
+	payments, err := w.queries.GetStaleInitPayments(ctx, sqlc.GetStaleInitPaymentsParams{
+		CreatedBefore: &createdBefore,
+		Limit:         w.config.PaymentReconciliation.BatchSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get stale payments: %w", err)
+	}
+
+	// 2. Settle each payment by what the gateway reports
+	riverClient := river.ClientFromContext[*sql.Tx](ctx)
+	report := PaymentReconciliationReport{
+		Outcomes: map[string]int{},
+		Payments: make([]PaymentReconciliationResult, 0, len(payments)),
+	}
+	for _, payment := range payments {
+		result := w.reconcile(ctx, riverClient, payment)
+		report.Checked++
+		report.Outcomes[result.Outcome]++
+		report.Payments = append(report.Payments, result)
+	}
+
+	// 3. Report
+	if err := river.RecordOutput(ctx, report); err != nil {
+		return fmt.Errorf("failed to record reconciliation report: %w", err)
+	}
+
+	if report.Checked > 0 {
+		slog.Info("payments reconciled", "checked", report.Checked, "outcomes", report.Outcomes)
+	}
+	for _, result := range report.Payments {
+		if result.Outcome == ReconciliationError || result.Outcome == ReconciliationNeedsReview {
+			slog.Warn("payment reconciliation",
+				"order_id", result.OrderID,
+				"booking_id", result.BookingID,
+				"gateway_status", result.GatewayStatus,
+				"outcome", result.Outcome,
+				"error", result.Error,
+			)
+		}
+	}
+
+	return nil
+}
+
+// ReconcilePaymentArgs settles one unfinished payment by what the gateway reports.
+// ExpireBookingWorker queues it for the payment of an expired booking: a payment that was
+// never completed is cancelled at the gateway, a completed one is refunded by the saga.
+type ReconcilePaymentArgs struct {
+	OrderID string
+}
+
+func (ReconcilePaymentArgs) Kind() string { return "payment.reconcile_one" }
+
+type ReconcilePaymentWorker struct {
+	river.WorkerDefaults[ReconcilePaymentArgs]
+
+	reconciler *ReconcilePaymentsWorker
+}
+
+func NewReconcilePaymentWorker(queries *sqlc.Queries, db *sql.DB, paymentGateway paymentgateway.ClientInterface, config *config.Config) river.Worker[ReconcilePaymentArgs] {
+	return &ReconcilePaymentWorker{
+		reconciler: &ReconcilePaymentsWorker{
+			queries:        queries,
+			paymentGateway: paymentGateway,
+			paymentSaga:    NewPaymentSaga(queries, db, paymentGateway, config),
+			config:         config,
+		},
+	}
+}
+
+func (w *ReconcilePaymentWorker) Work(ctx context.Context, job *river.Job[ReconcilePaymentArgs]) error {
+	payment, err := w.reconciler.queries.GetBookingPaymentByOrderID(ctx, job.Args.OrderID)
+	if err != nil {
+		return fmt.Errorf("failed to get payment %s: %w", job.Args.OrderID, err)
+	}
+
+	// Платеж уже завершен уведомлением или периодической сверкой
+	if payment.Status == nil || *payment.Status != domain.PaymentStatusInit {
+		return nil
+	}
+
+	result := w.reconciler.reconcile(ctx, river.ClientFromContext[*sql.Tx](ctx), payment)
+	switch result.Outcome {
+	case ReconciliationError:
+		return fmt.Errorf("failed to reconcile payment %s: %s", payment.OrderID, result.Error)
+	case ReconciliationNeedsReview:
+		slog.Warn("payment reconciliation",
+			"order_id", result.OrderID,
+			"booking_id", result.BookingID,
+			"gateway_status", result.GatewayStatus,
+			"outcome", result.Outcome,
+			"error", result.Error,
+		)
+	}
+
+	return nil
+}
+
+func (w *ReconcilePaymentsWorker) reconcile(
+	ctx context.Context,
+	riverClient *river.Client[*sql.Tx],
+	payment sqlc.BookingPayment,
+) PaymentReconciliationResult {
+	result := PaymentReconciliationResult{
+		OrderID:   payment.OrderID,
+		BookingID: payment.BookingID,
+	}
+	fail := func(err error) PaymentReconciliationResult {
+		result.Outcome = ReconciliationError
+		result.Error = err.Error()
+		return result
+	}
+
+	check, err := service.CheckPayment(ctx, w.paymentGateway, payment, w.config.PaymentProvider.MerchantPassword)
+	if errors.Is(err, service.ErrPaymentNotFoundAtGateway) {
+		// Платеж так и не был создан в платежном шлюзе
+		if err := w.paymentSaga.Fail(ctx, riverClient, payment.OrderID); err != nil {
+			return fail(err)
+		}
+		result.Outcome = ReconciliationFailed
+		return result
+	}
+	if err != nil {
+		return fail(err)
+	}
+	result.GatewayStatus = check.Status
+
+	outcome := domain.GatewayPaymentOutcome(check.Status)
+	if outcome == domain.PaymentStatusAuthorized && payment.TwoStage {
+		// Деньги по двухстадийному платежу заблокированы - платеж завершен
+		outcome = domain.PaymentStatusSuccess
+	}
+
+	switch outcome {
+	case domain.PaymentStatusSuccess:
+		err = w.paymentSaga.confirm(ctx, riverClient, payment, check)
+		switch {
+		case err == nil:
+			result.Outcome = ReconciliationConfirmed
+		case errors.Is(err, ErrBookingNotConfirmed):
+			// Бронь уже отменена, платеж возвращается
+			result.Outcome = ReconciliationRefunded
+		case errors.Is(err, ErrPaymentNeedsReview):
+			result.Outcome = ReconciliationNeedsReview
+			result.Error = err.Error()
+		default:
+			return fail(err)
+		}
+
+	case domain.PaymentStatusFail:
+		if err := w.paymentSaga.Fail(ctx, riverClient, payment.OrderID); err != nil {
+			return fail(err)
+		}
+		result.Outcome = ReconciliationFailed
+
+	case domain.PaymentStatusRefunded:
+		// Возврат без подтверждения брони: сверять нечего, только зафиксировать статус
+		if err := w.queries.UpdateBookingPaymentStatus(ctx, sqlc.UpdateBookingPaymentStatusParams{
+			Status:  stringPtr(domain.PaymentStatusRefunded),
+			OrderID: payment.OrderID,
+		}); err != nil {
+			return fail(err)
+		}
+		result.Outcome = ReconciliationRefunded
+
+	default:
+		// Платеж так и не был завершен - отменить его в платежном шлюзе, чтобы его нельзя было оплатить позже
+		if err := service.CancelPayment(ctx, w.paymentGateway, payment, w.config.PaymentProvider.MerchantPassword); err != nil {
+			return fail(err)
+		}
+		if err := w.paymentSaga.Fail(ctx, riverClient, payment.OrderID); err != nil {
+			return fail(err)
+		}
+		result.Outcome = ReconciliationCancelled
+	}
+
+	return result
+}
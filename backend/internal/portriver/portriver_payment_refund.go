@@ -5,8 +5,10 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"hackload/internal/config"
+	"hackload/internal/domain"
 	"hackload/internal/paymenttoken"
 	"hackload/internal/sqlc"
 	"hackload/pkg/paymentgateway"
@@ -14,8 +16,11 @@ import (
 	"github.com/riverqueue/river"
 )
 
+// RefundPaymentArgs refunds the paid attempt of the booking.
+// OrderID picks a specific attempt, e.g. a second payment for an already confirmed booking.
 type RefundPaymentArgs struct {
 	BookingID int64
+	OrderID   string `json:",omitempty"`
 }
 
 func (RefundPaymentArgs) Kind() string { return "payment.refund" }
@@ -53,8 +58,13 @@ func (w *RefundPaymentWorker) Work(ctx context.Context, job *river.Job[RefundPay
 		return fmt.Errorf("failed to get booking: %w", err)
 	}
 
-	// 2. Get payment record for this booking
-	payment, err := qtx.GetBookingPaymentByBookingID(ctx, booking.ID)
+	// 2. Get the paid attempt of this booking
+	var payment sqlc.BookingPayment
+	if job.Args.OrderID != "" {
+		payment, err = qtx.GetBookingPaymentByOrderID(ctx, job.Args.OrderID)
+	} else {
+		payment, err = qtx.GetPaidBookingPayment(ctx, booking.ID)
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// No payment record found, nothing to refund
@@ -63,27 +73,24 @@ func (w *RefundPaymentWorker) Work(ctx context.Context, job *river.Job[RefundPay
 		return fmt.Errorf("failed to get booking payment: %w", err)
 	}
 
-	// 3. Check if payment was successful and can be refunded
-	if payment.Status == nil || *payment.Status != "SUCCESS" {
+	// 3. Check if payment was successful and can be refunded.
+	// Незавершенный двухстадийный платеж не списан - PaymentCancel снимает блокировку
+	if payment.Status == nil || (*payment.Status != domain.PaymentStatusSuccess && *payment.Status != domain.PaymentStatusAuthorized) {
 		// Payment wasn't successful, nothing to refund
 		return tx.Commit()
 	}
 
-	// Generate token for cancel operation (same parameters as init)
-	token := paymenttoken.GenerateToken(
-		payment.Amount,
-		payment.Currency,
-		payment.OrderID, // Use the original OrderID
-		w.config.PaymentProvider.MerchantPassword,
-		payment.TeamSlug,
-	)
-
 	// 4. Call payment gateway to cancel/refund the payment
-	cancelResp, err := w.paymentGateway.PostApiV1PaymentCancelCancel(ctx, paymentgateway.PaymentCancelRequestDto{
+	cancelReq := paymentgateway.PaymentCancelRequestDto{
 		PaymentId: payment.PaymentID, // Use the actual PaymentID from gateway
 		TeamSlug:  payment.TeamSlug,  // Use the saved TeamSlug
-		Token:     token,             // Generate token using saved parameters
-	})
+	}
+	cancelReq.Token, err = paymenttoken.NewSigner(w.config.PaymentProvider.MerchantPassword).Sign(cancelReq)
+	if err != nil {
+		return fmt.Errorf("failed to sign cancel request: %w", err)
+	}
+
+	cancelResp, err := w.paymentGateway.PostApiV1PaymentCancelCancel(ctx, cancelReq)
 	if err != nil {
 		return fmt.Errorf("failed to cancel payment: %w", err)
 	}
@@ -98,14 +105,41 @@ func (w *RefundPaymentWorker) Work(ctx context.Context, job *river.Job[RefundPay
 		return fmt.Errorf("failed to decode cancel response: %w", err)
 	}
 
-	// 5. Update payment status to REFUNDED
-	refundedStatus := "REFUNDED"
+	// 5. Update payment status to REFUNDED, or VOIDED if the money was never captured
+	refundedStatus := domain.PaymentStatusRefunded
+	if *payment.Status == domain.PaymentStatusAuthorized {
+		refundedStatus = domain.PaymentStatusVoided
+	}
 	err = qtx.UpdateBookingPaymentStatus(ctx, sqlc.UpdateBookingPaymentStatusParams{
 		Status:  &refundedStatus,
 		OrderID: payment.OrderID,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to update payment status to REFUNDED: %w", err)
+		return fmt.Errorf("failed to update payment status to %s: %w", refundedStatus, err)
+	}
+
+	// 6. Record the refund of whatever was not returned seat by seat
+	if refundedStatus == domain.PaymentStatusRefunded {
+		refunded, err := qtx.GetBookingPaymentRefundedAmount(ctx, payment.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get refunded amount: %w", err)
+		}
+
+		if remaining := payment.Amount - refunded; remaining > 0 {
+			now := time.Now()
+			_, err = qtx.InsertBookingRefund(ctx, sqlc.InsertBookingRefundParams{
+				BookingPaymentID: payment.ID,
+				BookingID:        booking.ID,
+				Amount:           remaining,
+				Currency:         payment.Currency,
+				Status:           domain.RefundStatusRefunded,
+				CreatedAt:        now,
+				RefundedAt:       &now,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to record booking refund: %w", err)
+			}
+		}
 	}
 
 	return tx.Commit()
@@ -0,0 +1,140 @@
+package portriver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"hackload/internal/config"
+	"hackload/internal/domain"
+	"hackload/internal/service"
+	"hackload/internal/sqlc"
+	"hackload/pkg/paymentgateway"
+
+	"github.com/riverqueue/river"
+)
+
+// RefundSeatArgs returns the money for a seat removed from a confirmed booking.
+type RefundSeatArgs struct {
+	RefundID int64
+}
+
+func (RefundSeatArgs) Kind() string { return "payment.refund_seat" }
+
+type RefundSeatWorker struct {
+	river.WorkerDefaults[RefundSeatArgs]
+
+	queries        *sqlc.Queries
+	db             *sql.DB
+	paymentGateway paymentgateway.ClientInterface
+	config         *config.Config
+}
+
+func NewRefundSeatWorker(
+	queries *sqlc.Queries,
+	db *sql.DB,
+	paymentGateway paymentgateway.ClientInterface,
+	config *config.Config,
+) river.Worker[RefundSeatArgs] {
+	return &RefundSeatWorker{
+		queries:        queries,
+		db:             db,
+		paymentGateway: paymentGateway,
+		config:         config,
+	}
+}
+
+func (w *RefundSeatWorker) Work(ctx context.Context, job *river.Job[RefundSeatArgs]) error {
+	// 1. Get the refund
+	refund, err := w.queries.GetBookingRefund(ctx, job.Args.RefundID)
+	if err != nil {
+		return fmt.Errorf("failed to get booking refund %d: %w", job.Args.RefundID, err)
+	}
+
+	if refund.Status == domain.RefundStatusRefunded {
+		return nil
+	}
+
+	// 2. Give the seat back to the sale
+	if refund.Status == domain.RefundStatusPending {
+		if err := w.freeSeat(ctx, refund); err != nil {
+			return err
+		}
+		refund.Status = domain.RefundStatusPlaceReleased
+	}
+
+	// 3. Return the seat price to the payer
+	payment, err := w.queries.GetBookingPaymentByID(ctx, refund.BookingPaymentID)
+	if err != nil {
+		return fmt.Errorf("failed to get booking payment %d: %w", refund.BookingPaymentID, err)
+	}
+
+	// Если бронь успели отменить, полный возврат уже вернул и эти деньги
+	if payment.Status != nil && *payment.Status == domain.PaymentStatusSuccess {
+		if err := service.RefundSeat(ctx, w.paymentGateway, payment, refund, w.config.PaymentProvider.MerchantPassword); err != nil {
+			return err
+		}
+	}
+
+	// 4. Mark the refund as done
+	refundedAt := time.Now()
+	err = w.queries.UpdateBookingRefundStatus(ctx, sqlc.UpdateBookingRefundStatusParams{
+		Status:     domain.RefundStatusRefunded,
+		RefundedAt: &refundedAt,
+		RefundID:   refund.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update booking refund %d status: %w", refund.ID, err)
+	}
+
+	return nil
+}
+
+// freeSeat returns the seat of the refund to the sale. Only seats the provider has not sold
+// are refunded: it releases places of started orders only, a confirmed order keeps them.
+func (w *RefundSeatWorker) freeSeat(ctx context.Context, refund sqlc.BookingRefund) error {
+	if refund.SeatID == nil {
+		return fmt.Errorf("booking refund %d has no seat", refund.ID)
+	}
+
+	seat, err := w.queries.GetSeatByID(ctx, *refund.SeatID)
+	if err != nil {
+		return fmt.Errorf("failed to get seat %d: %w", *refund.SeatID, err)
+	}
+
+	if seat.ExternalID != nil {
+		return river.JobCancel(fmt.Errorf("seat %d of booking refund %d is sold by the event provider", seat.ID, refund.ID))
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := w.queries.WithTx(tx)
+
+	err = qtx.UpdateBookingRefundStatus(ctx, sqlc.UpdateBookingRefundStatusParams{
+		Status:   domain.RefundStatusPlaceReleased,
+		RefundID: refund.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update booking refund %d status: %w", refund.ID, err)
+	}
+
+	err = qtx.UpdateSeatStatus(ctx, sqlc.UpdateSeatStatusParams{
+		Status: "FREE",
+		SeatID: seat.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to free seat %d: %w", seat.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction for booking refund %d: %w", refund.ID, err)
+	}
+
+	// Offer the seat to the waitlist
+	return queueWaitlistOffer(ctx, w.queries, seat.EventID)
+}
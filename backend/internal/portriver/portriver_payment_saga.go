@@ -0,0 +1,283 @@
+package portriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"hackload/internal/config"
+	"hackload/internal/domain"
+	"hackload/internal/service"
+	"hackload/internal/sqlc"
+	"hackload/pkg/paymentgateway"
+
+	"github.com/riverqueue/river"
+)
+
+var (
+	ErrPaymentNotFound     = errors.New("payment not found")
+	ErrPaymentCheckFailed  = errors.New("failed to check payment with payment gateway")
+	ErrPaymentNotCompleted = errors.New("payment is not completed at payment gateway")
+	ErrPaymentNeedsReview  = errors.New("payment needs review")
+	ErrBookingNotConfirmed = errors.New("booking cannot be confirmed, payment is refunded")
+)
+
+// PaymentSaga applies payment outcomes to bookings.
+// The success/fail redirects, gateway notifications and payment reconciliation all go through it.
+type PaymentSaga struct {
+	queries        *sqlc.Queries
+	db             *sql.DB
+	paymentGateway paymentgateway.ClientInterface
+	config         *config.Config
+}
+
+func NewPaymentSaga(queries *sqlc.Queries, db *sql.DB, paymentGateway paymentgateway.ClientInterface, config *config.Config) *PaymentSaga {
+	return &PaymentSaga{
+		queries:        queries,
+		db:             db,
+		paymentGateway: paymentGateway,
+		config:         config,
+	}
+}
+
+// Complete verifies the payment orderID with the gateway, confirms the booking
+// and starts the EventProvider saga.
+func (p *PaymentSaga) Complete(ctx context.Context, riverClient *river.Client[*sql.Tx], orderID string) error {
+	// 1. Verify the payment with the gateway: knowing the orderID proves nothing
+	payment, err := p.queries.GetBookingPaymentByOrderID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: order %s", ErrPaymentNotFound, orderID)
+		}
+		return fmt.Errorf("failed to get booking payment: %w", err)
+	}
+
+	// Повторное уведомление - платеж уже обработан
+	if domain.IsPaymentSettled(payment.Status) {
+		return nil
+	}
+
+	check, err := service.CheckPayment(ctx, p.paymentGateway, payment, p.config.PaymentProvider.MerchantPassword)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPaymentCheckFailed, err)
+	}
+
+	return p.confirm(ctx, riverClient, payment, check)
+}
+
+// confirm applies a successful gateway check to the payment and its booking.
+func (p *PaymentSaga) confirm(
+	ctx context.Context,
+	riverClient *river.Client[*sql.Tx],
+	payment sqlc.BookingPayment,
+	check service.PaymentCheck,
+) error {
+	orderID := payment.OrderID
+
+	// Двухстадийный платеж завершен, когда деньги заблокированы: списание после подтверждения заказа
+	outcome := domain.GatewayPaymentOutcome(check.Status)
+	if outcome != domain.PaymentStatusSuccess && !(payment.TwoStage && outcome == domain.PaymentStatusAuthorized) {
+		return fmt.Errorf("%w: order %s is %s", ErrPaymentNotCompleted, orderID, check.Status)
+	}
+
+	if check.Amount != payment.Amount || !strings.EqualFold(check.Currency, payment.Currency) {
+		reason := fmt.Sprintf(
+			"gateway reports %s %s, expected %s %s",
+			domain.Money(check.Amount), check.Currency, domain.Money(payment.Amount), payment.Currency,
+		)
+		if err := p.queries.FlagBookingPaymentForReview(ctx, sqlc.FlagBookingPaymentForReviewParams{
+			ReviewReason: &reason,
+			OrderID:      orderID,
+		}); err != nil {
+			return fmt.Errorf("failed to flag payment for review: %w", err)
+		}
+		return fmt.Errorf("%w: order %s: %s", ErrPaymentNeedsReview, orderID, reason)
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := p.queries.WithTx(tx)
+
+	// 2. Get booking by payment order ID
+	booking, err := qtx.GetBookingByPaymentOrderID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: order %s", ErrPaymentNotFound, orderID)
+		}
+		return fmt.Errorf("failed to get booking by payment order ID: %w", err)
+	}
+
+	// Платеж мог быть обработан параллельно, пока шел запрос в платежный шлюз
+	payment, err = qtx.GetBookingPaymentByOrderID(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to get booking payment: %w", err)
+	}
+	if domain.IsPaymentSettled(payment.Status) {
+		return nil
+	}
+
+	// 3. Update booking_payments status to SUCCESS or AUTHORIZED
+	err = qtx.UpdateBookingPaymentStatus(ctx, sqlc.UpdateBookingPaymentStatusParams{
+		Status:  stringPtr(outcome),
+		OrderID: orderID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update payment status: %w", err)
+	}
+
+	// 4. Update booking status to CONFIRMED
+	reason := "payment succeeded, order " + orderID
+	if outcome == domain.PaymentStatusAuthorized {
+		reason = "payment authorized, order " + orderID
+	}
+	err = service.TransitionBooking(
+		ctx,
+		qtx,
+		booking,
+		domain.BookingStatusConfirmed,
+		domain.ActorPaymentGateway,
+		reason,
+	)
+	if errors.Is(err, domain.ErrInvalidBookingTransition) {
+		// Деньги списаны, но бронь уже отменена (например, истек срок оплаты)
+		// или оплачена другой попыткой - вернуть этот платеж
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+
+		if _, err = riverClient.Insert(ctx, RefundPaymentArgs{
+			BookingID: booking.ID,
+			OrderID:   orderID,
+		}, nil); err != nil {
+			slog.Error("failed to queue RefundPaymentWorker", "booking_id", booking.ID, "error", err)
+		}
+
+		return fmt.Errorf("%w: booking %d is %s", ErrBookingNotConfirmed, booking.ID, booking.Status)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update booking status: %w", err)
+	}
+
+	// Commit database changes first
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// 5. Trigger SelectSeatsWorker to handle EventProvider confirmation and seat updates
+	if _, err = riverClient.Insert(ctx, SelectSeatsArgs{
+		BookingID: booking.ID,
+	}, nil); err != nil {
+		// Don't fail - payment was already processed successfully
+		slog.Error("failed to queue SelectSeatsWorker", "booking_id", booking.ID, "error", err)
+	}
+
+	return nil
+}
+
+// Fail records the failed payment attempt orderID. While the seats are still held the booking
+// goes back to CREATED for a new attempt, otherwise it is cancelled and its seats are released.
+// A payment that already succeeded is left untouched.
+func (p *PaymentSaga) Fail(ctx context.Context, riverClient *river.Client[*sql.Tx], orderID string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	qtx := p.queries.WithTx(tx)
+
+	// 1. Get booking by payment order ID
+	booking, err := qtx.GetBookingByPaymentOrderID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: order %s", ErrPaymentNotFound, orderID)
+		}
+		return fmt.Errorf("failed to get booking by payment order ID: %w", err)
+	}
+
+	// Запоздавшее уведомление о неуспехе не отменяет уже проведенный платеж
+	payment, err := qtx.GetBookingPaymentByOrderID(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to get booking payment: %w", err)
+	}
+	if domain.IsPaymentSettled(payment.Status) {
+		slog.Warn("payment failure ignored", "order_id", orderID, "payment_status", *payment.Status)
+		return nil
+	}
+	// Брошенная попытка уже отменена в платежном шлюзе, бронь ждет новую
+	if payment.Status != nil && *payment.Status == domain.PaymentStatusAbandoned {
+		return nil
+	}
+
+	// 2. Only the latest attempt of a booking waiting for payment decides its fate.
+	// Бронь могла быть уже отменена, оплачена или возвращена к выбору мест другой попыткой
+	latest, err := qtx.GetLatestBookingPayment(ctx, booking.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get latest booking payment: %w", err)
+	}
+
+	var next domain.BookingStatus
+	if booking.Status == domain.BookingStatusPaymentInitiated && latest.ID == payment.ID {
+		// Пока места удерживаются, бронь возвращается в CREATED для новой попытки оплаты
+		next = domain.BookingStatusCancelled
+		if booking.ExpiresAt != nil && booking.ExpiresAt.After(time.Now().UTC()) {
+			next = domain.BookingStatusCreated
+		}
+
+		err = service.TransitionBooking(
+			ctx,
+			qtx,
+			booking,
+			next,
+			domain.ActorPaymentGateway,
+			"payment failed, order "+orderID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to move booking %d to %s: %w", booking.ID, next, err)
+		}
+	}
+
+	// 3. Update booking_payments status to FAIL
+	err = qtx.UpdateBookingPaymentStatus(ctx, sqlc.UpdateBookingPaymentStatusParams{
+		Status:  stringPtr(domain.PaymentStatusFail),
+		OrderID: orderID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update payment status: %w", err)
+	}
+
+	// Commit database changes first
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	switch next {
+	case domain.BookingStatusCreated:
+		// 4. Cancel the booking if no new attempt is made while the seats are held
+		if _, err = riverClient.Insert(ctx, ExpireBookingArgs{
+			BookingID: booking.ID,
+			StatusEq:  domain.BookingStatusCreated,
+		}, &river.InsertOpts{ScheduledAt: *booking.ExpiresAt}); err != nil {
+			slog.Error("failed to queue ExpireBookingWorker", "booking_id", booking.ID, "error", err)
+		}
+
+	case domain.BookingStatusCancelled:
+		// 4. Queue CancelBookingWorker to handle EventProvider cancellation and seat release
+		if _, err = riverClient.Insert(ctx, CancelBookingArgs{
+			BookingID: booking.ID,
+		}, nil); err != nil {
+			// Don't fail - payment failure was already processed successfully
+			slog.Error("failed to queue CancelBookingWorker", "booking_id", booking.ID, "error", err)
+		}
+	}
+
+	return nil
+}
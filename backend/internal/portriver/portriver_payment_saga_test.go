@@ -0,0 +1,202 @@
+//go:build sqlite_fts5
+
+package portriver_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"hackload/internal/domain"
+	"hackload/internal/portriver"
+	"hackload/internal/testenv"
+
+	"github.com/riverqueue/river"
+)
+
+// startSaga starts River with the jobs the saga queues left undone, so that they can be inspected.
+func startSaga(t *testing.T, env *sagaEnv) (*portriver.PaymentSaga, *river.Client[*sql.Tx]) {
+	t.Helper()
+
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.SelectSeatsArgs]{})
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.RefundPaymentArgs]{})
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.ExpireBookingArgs]{})
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.CancelBookingArgs]{})
+
+	saga := portriver.NewPaymentSaga(env.queries, env.deps.DB, env.gateway, env.conf)
+	return saga, testenv.StartRiver(t, env.deps)
+}
+
+func TestPaymentSagaCompleteConfirmsPaidBooking(t *testing.T) {
+	env := newSagaEnv(t)
+	saga, riverClient := startSaga(t, env)
+	ctx := context.Background()
+
+	bookingID := env.createBooking(t, domain.BookingStatusPaymentInitiated, 1)
+	paymentID := env.initPayment(t, bookingID, "order-1", 10000)
+	env.pay(paymentID, 10000)
+
+	if err := saga.Complete(ctx, riverClient, "order-1"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	// Повторное уведомление ничего не меняет
+	if err := saga.Complete(ctx, riverClient, "order-1"); err != nil {
+		t.Fatalf("repeated Complete: %v", err)
+	}
+
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusConfirmed {
+		t.Errorf("booking is %s, want CONFIRMED", status)
+	}
+	if status := env.paymentStatus(t, "order-1"); status != domain.PaymentStatusSuccess {
+		t.Errorf("payment is %s, want SUCCESS", status)
+	}
+	if jobs := testenv.Jobs(t, riverClient, portriver.SelectSeatsArgs{}.Kind()); len(jobs) != 1 {
+		t.Errorf("%d select seats jobs queued, want 1", len(jobs))
+	}
+}
+
+func TestPaymentSagaCompleteRejectsUnpaidPayment(t *testing.T) {
+	env := newSagaEnv(t)
+	saga, riverClient := startSaga(t, env)
+
+	bookingID := env.createBooking(t, domain.BookingStatusPaymentInitiated, 1)
+	env.initPayment(t, bookingID, "order-1", 10000)
+
+	// Знать orderID недостаточно: шлюз не видит оплаты
+	err := saga.Complete(context.Background(), riverClient, "order-1")
+	if !errors.Is(err, portriver.ErrPaymentNotCompleted) {
+		t.Fatalf("Complete() = %v, want ErrPaymentNotCompleted", err)
+	}
+
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusPaymentInitiated {
+		t.Errorf("booking is %s, want PAYMENT_INITIATED", status)
+	}
+	if status := env.paymentStatus(t, "order-1"); status != domain.PaymentStatusInit {
+		t.Errorf("payment is %s, want INIT", status)
+	}
+}
+
+func TestPaymentSagaCompleteFlagsAmountMismatch(t *testing.T) {
+	env := newSagaEnv(t)
+	saga, riverClient := startSaga(t, env)
+
+	bookingID := env.createBooking(t, domain.BookingStatusPaymentInitiated, 1)
+	paymentID := env.initPayment(t, bookingID, "order-1", 10000)
+	env.pay(paymentID, 10000)
+	env.exec(t, `UPDATE booking_payments SET amount = 20000 WHERE order_id = 'order-1'`)
+
+	err := saga.Complete(context.Background(), riverClient, "order-1")
+	if !errors.Is(err, portriver.ErrPaymentNeedsReview) {
+		t.Fatalf("Complete() = %v, want ErrPaymentNeedsReview", err)
+	}
+
+	payment, err := env.queries.GetBookingPaymentByOrderID(context.Background(), "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.ReviewReason == nil {
+		t.Error("payment is not flagged for review")
+	}
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusPaymentInitiated {
+		t.Errorf("booking is %s, want PAYMENT_INITIATED", status)
+	}
+	if jobs := testenv.Jobs(t, riverClient, portriver.SelectSeatsArgs{}.Kind()); len(jobs) != 0 {
+		t.Errorf("%d select seats jobs queued, want none", len(jobs))
+	}
+}
+
+func TestPaymentSagaCompleteRefundsCancelledBooking(t *testing.T) {
+	env := newSagaEnv(t)
+	saga, riverClient := startSaga(t, env)
+
+	bookingID := env.createBooking(t, domain.BookingStatusCancelled)
+	paymentID := env.initPayment(t, bookingID, "order-1", 10000)
+	env.pay(paymentID, 10000)
+
+	err := saga.Complete(context.Background(), riverClient, "order-1")
+	if !errors.Is(err, portriver.ErrBookingNotConfirmed) {
+		t.Fatalf("Complete() = %v, want ErrBookingNotConfirmed", err)
+	}
+
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusCancelled {
+		t.Errorf("booking is %s, want CANCELLED", status)
+	}
+	if jobs := testenv.Jobs(t, riverClient, portriver.RefundPaymentArgs{}.Kind()); len(jobs) != 1 {
+		t.Errorf("%d refund jobs queued, want 1", len(jobs))
+	}
+}
+
+func TestPaymentSagaFail(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		want      domain.BookingStatus
+		wantJob   string
+	}{
+		{
+			// Пока места удерживаются, можно начать новую попытку
+			name:      "seats still held",
+			expiresAt: time.Now().UTC().Add(time.Hour),
+			want:      domain.BookingStatusCreated,
+			wantJob:   portriver.ExpireBookingArgs{}.Kind(),
+		},
+		{
+			name:      "hold expired",
+			expiresAt: time.Now().UTC().Add(-time.Minute),
+			want:      domain.BookingStatusCancelled,
+			wantJob:   portriver.CancelBookingArgs{}.Kind(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newSagaEnv(t)
+			saga, riverClient := startSaga(t, env)
+
+			bookingID := env.createBooking(t, domain.BookingStatusPaymentInitiated, 1)
+			env.exec(t, `UPDATE bookings SET expires_at = ? WHERE id = ?`, tt.expiresAt, bookingID)
+			env.initPayment(t, bookingID, "order-1", 10000)
+
+			if err := saga.Fail(context.Background(), riverClient, "order-1"); err != nil {
+				t.Fatalf("Fail: %v", err)
+			}
+
+			if status := env.bookingStatus(t, bookingID); status != tt.want {
+				t.Errorf("booking is %s, want %s", status, tt.want)
+			}
+			if status := env.paymentStatus(t, "order-1"); status != domain.PaymentStatusFail {
+				t.Errorf("payment is %s, want FAIL", status)
+			}
+			if jobs := testenv.Jobs(t, riverClient, tt.wantJob); len(jobs) != 1 {
+				t.Errorf("%d %s jobs queued, want 1", len(jobs), tt.wantJob)
+			}
+		})
+	}
+}
+
+func TestPaymentSagaFailIgnoresSettledPayment(t *testing.T) {
+	env := newSagaEnv(t)
+	saga, riverClient := startSaga(t, env)
+	ctx := context.Background()
+
+	bookingID := env.createBooking(t, domain.BookingStatusPaymentInitiated, 1)
+	paymentID := env.initPayment(t, bookingID, "order-1", 10000)
+	env.pay(paymentID, 10000)
+	if err := saga.Complete(ctx, riverClient, "order-1"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	// Запоздавшее уведомление о неуспехе
+	if err := saga.Fail(ctx, riverClient, "order-1"); err != nil {
+		t.Fatalf("Fail: %v", err)
+	}
+
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusConfirmed {
+		t.Errorf("booking is %s, want CONFIRMED", status)
+	}
+	if status := env.paymentStatus(t, "order-1"); status != domain.PaymentStatusSuccess {
+		t.Errorf("payment is %s, want SUCCESS", status)
+	}
+}
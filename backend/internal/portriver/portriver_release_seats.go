@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 
+	"hackload/internal/domain"
+	"hackload/internal/service"
 	"hackload/internal/sqlc"
 
 	"github.com/riverqueue/river"
@@ -12,7 +14,7 @@ import (
 
 type ReleaseSeatsArgs struct {
 	BookingID int64
-	StatusEq  *string
+	StatusEq  *domain.BookingStatus
 }
 
 func (ReleaseSeatsArgs) Kind() string { return "booking.release_seats" }
@@ -50,31 +52,20 @@ func (w *ReleaseSeatsWorker) Work(ctx context.Context, job *river.Job[ReleaseSea
 		return nil
 	}
 
-	// 2. Get all seat IDs for this booking
-	seatIDs, err := qtx.GetBookingSeats(ctx, booking.ID)
-	if err != nil {
-		return fmt.Errorf("failed to get booking seats for booking %d: %w", booking.ID, err)
+	// 2. Delete booking_seats records and update seats status to FREE
+	if _, err := service.ReleaseBookingSeats(ctx, qtx, booking.ID); err != nil {
+		return err
 	}
 
-	// 3. Delete booking_seats records
-	rowsAffected, err := qtx.DeleteBookingSeats(ctx, booking.ID)
-	if err != nil {
-		return fmt.Errorf("failed to delete booking seats for booking %d: %w", booking.ID, err)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction for booking %d: %w", booking.ID, err)
 	}
 
-	// 4. Update seats status to FREE (only if we actually deleted booking seats)
-	if rowsAffected > 0 && len(seatIDs) > 0 {
-		err = qtx.UpdateSeatsStatusByIDs(ctx, sqlc.UpdateSeatsStatusByIDsParams{
-			Status:  "FREE",
-			SeatIds: seatIDs,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to update seats status to FREE for booking %d: %w", booking.ID, err)
-		}
+	// 3. Offer the seats to the waitlist.
+	// Checked on every run so that a retry after a failed insert queues it again.
+	if err := queueWaitlistOffer(ctx, w.queries, booking.EventID); err != nil {
+		return err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction for booking %d: %w", booking.ID, err)
-	}
 	return nil
 }
@@ -0,0 +1,192 @@
+//go:build sqlite_fts5
+
+package portriver_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"hackload/internal/config"
+	"hackload/internal/dependencies"
+	"hackload/internal/domain"
+	"hackload/internal/sqlc"
+	"hackload/internal/testenv"
+	"hackload/internal/ticketing"
+	"hackload/pkg/eventprovider"
+	"hackload/pkg/eventprovider/fakeprovider"
+
+	"github.com/google/uuid"
+)
+
+const (
+	teamSlug         = "team"
+	merchantPassword = "secret"
+)
+
+// sagaEnv is a booking service database with a fake ticket provider and a stub payment gateway.
+// Seats 1-3 of event 1 are the provider places 1-3, all FREE.
+type sagaEnv struct {
+	deps    *dependencies.Dependencies
+	queries *sqlc.Queries
+	conf    *config.Config
+
+	provider *fakeprovider.Server
+	places   []eventprovider.Place
+	registry *ticketing.Registry
+	gateway  *testenv.Gateway
+}
+
+func newSagaEnv(t *testing.T) *sagaEnv {
+	t.Helper()
+
+	deps := testenv.New(t)
+	env := &sagaEnv{
+		deps:    deps,
+		queries: sqlc.New(deps.DB),
+		conf:    &config.Config{},
+		gateway: testenv.NewGateway(),
+	}
+	env.conf.PaymentProvider.MerchantID = teamSlug
+	env.conf.PaymentProvider.MerchantPassword = merchantPassword
+
+	env.provider = fakeprovider.NewServer(fakeprovider.WithPlaces(3))
+	t.Cleanup(env.provider.Close)
+	providerClient, err := env.provider.ProviderClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.places, err = env.provider.Provider.ListPlaces(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.registry = ticketing.NewRegistry(env.queries, &ticketing.Route{Provider: ticketing.NewEventProvider(providerClient)})
+
+	env.exec(t, `INSERT INTO users (user_id, email, password_hash, first_name, surname, registered_at, is_active, last_logged_in)
+		VALUES (1, 'a@example.com', 'x', 'A', 'A', '2025-01-01', 1, '2025-01-01'),
+		       (2, 'b@example.com', 'x', 'B', 'B', '2025-01-01', 1, '2025-01-01')`)
+	env.exec(t, `INSERT INTO events_archive (id, title, datetime_start, provider) VALUES (1, 'Concert', '2025-01-01T20:00:00', 'Билеттер')`)
+	for i, place := range env.places {
+		env.exec(t, `INSERT INTO seats (id, event_id, external_id, row, number, price, status) VALUES (?, 1, ?, 1, ?, 10000, 'FREE')`,
+			i+1, place.Id.String(), i+1)
+	}
+
+	return env
+}
+
+func (e *sagaEnv) exec(t *testing.T, query string, args ...any) {
+	t.Helper()
+	if _, err := e.deps.DB.Exec(query, args...); err != nil {
+		t.Fatalf("exec %q: %v", query, err)
+	}
+}
+
+// createBooking creates a booking of user 1 in status holding seats, which are RESERVED for it.
+func (e *sagaEnv) createBooking(t *testing.T, status domain.BookingStatus, seatIDs ...int64) int64 {
+	t.Helper()
+
+	bookingID, err := e.queries.CreateBooking(context.Background(), sqlc.CreateBookingParams{UserID: 1, EventID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.exec(t, `UPDATE bookings SET status = ? WHERE id = ?`, status, bookingID)
+
+	for _, seatID := range seatIDs {
+		e.exec(t, `INSERT INTO booking_seats (booking_id, seat_id, user_id) VALUES (?, ?, 1)`, bookingID, seatID)
+		e.exec(t, `UPDATE seats SET status = 'RESERVED' WHERE id = ?`, seatID)
+	}
+
+	return bookingID
+}
+
+// initPayment records the INIT payment attempt of the booking, which the gateway knows as not yet paid.
+func (e *sagaEnv) initPayment(t *testing.T, bookingID int64, orderID string, amount int64) string {
+	t.Helper()
+
+	paymentID := "payment-" + orderID
+	e.exec(t, `INSERT INTO booking_payments (booking_id, order_id, payment_id, status, amount, currency, team_slug, created_at)
+		VALUES (?, ?, ?, 'INIT', ?, 'KZT', ?, CURRENT_TIMESTAMP)`,
+		bookingID, orderID, paymentID, amount, teamSlug)
+	e.gateway.SetPayment(paymentID, testenv.GatewayPayment{Status: "NEW", Amount: amount, Currency: "KZT"})
+
+	return paymentID
+}
+
+// pay marks the payment paid at the gateway.
+func (e *sagaEnv) pay(paymentID string, amount int64) {
+	e.gateway.SetPayment(paymentID, testenv.GatewayPayment{Status: "CONFIRMED", Amount: amount, Currency: "KZT"})
+}
+
+// startOrder starts a provider order with the places of the seats selected and records it for the booking.
+func (e *sagaEnv) startOrder(t *testing.T, bookingID int64, seatIDs ...int64) uuid.UUID {
+	t.Helper()
+
+	order := e.provider.Provider.StartOrder()
+	for _, seatID := range seatIDs {
+		if err := e.provider.Provider.SelectPlace(e.places[seatID-1].Id, order.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.exec(t, `INSERT INTO booking_orders (booking_id, order_id, status) VALUES (?, ?, 'STARTED')`, bookingID, order.Id.String())
+
+	return order.Id
+}
+
+func (e *sagaEnv) bookingStatus(t *testing.T, bookingID int64) domain.BookingStatus {
+	t.Helper()
+
+	booking, err := e.queries.GetBooking(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return booking.Status
+}
+
+func (e *sagaEnv) seatStatus(t *testing.T, seatID int64) string {
+	t.Helper()
+
+	seat, err := e.queries.GetSeatByID(context.Background(), seatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seat.Status
+}
+
+func (e *sagaEnv) paymentStatus(t *testing.T, orderID string) string {
+	t.Helper()
+
+	payment, err := e.queries.GetBookingPaymentByOrderID(context.Background(), orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status == nil {
+		return ""
+	}
+	return *payment.Status
+}
+
+// statusHistory returns the statuses the booking moved to with their reasons, oldest first.
+func (e *sagaEnv) statusHistory(t *testing.T, bookingID int64) []string {
+	t.Helper()
+
+	rows, err := e.deps.DB.Query(`SELECT to_status, coalesce(reason, '') FROM booking_status_history WHERE booking_id = ? ORDER BY id`, bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var history []string
+	for rows.Next() {
+		var status, reason string
+		if err := rows.Scan(&status, &reason); err != nil {
+			t.Fatal(err)
+		}
+		history = append(history, status+": "+reason)
+	}
+	if err := rows.Err(); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		t.Fatal(err)
+	}
+
+	return history
+}
@@ -0,0 +1,367 @@
+package portriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"hackload/internal/domain"
+	"hackload/internal/sqlc"
+	"hackload/internal/ticketing"
+
+	"github.com/riverqueue/river"
+)
+
+// OfferWaitlistSeatsArgs is queued when seats of an event become FREE.
+// Waiting users get the seats in line order as a CREATED booking held until the offer expires.
+// With RESERVE_ON_SELECT the places are selected in a provider order of the booking, as when seats are added by the user.
+type OfferWaitlistSeatsArgs struct {
+	EventID int64
+}
+
+func (OfferWaitlistSeatsArgs) Kind() string { return "waitlist.offer" }
+
+type OfferWaitlistSeatsWorker struct {
+	river.WorkerDefaults[OfferWaitlistSeatsArgs]
+
+	queries         *sqlc.Queries
+	db              *sql.DB
+	ticketProviders *ticketing.Registry
+	offerTTL        time.Duration
+}
+
+func NewOfferWaitlistSeatsWorker(queries *sqlc.Queries, db *sql.DB, ticketProviders *ticketing.Registry, offerTTL time.Duration) river.Worker[OfferWaitlistSeatsArgs] {
+	return &OfferWaitlistSeatsWorker{
+		queries:         queries,
+		db:              db,
+		ticketProviders: ticketProviders,
+		offerTTL:        offerTTL,
+	}
+}
+
+func (w *OfferWaitlistSeatsWorker) Work(ctx context.Context, job *river.Job[OfferWaitlistSeatsArgs]) error {
+	route, err := w.reservingRoute(ctx, job.Args.EventID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for event %d: %w", job.Args.EventID, err)
+	}
+	defer tx.Rollback()
+
+	qtx := w.queries.WithTx(tx)
+
+	// Места, выбранные у провайдера для несохраненных предложений, нужно вернуть
+	var held []*offerPlaces
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		for _, places := range held {
+			places.release(ctx)
+		}
+	}()
+
+	for {
+		// 1. Next user in line
+		entry, err := qtx.GetNextWaitingWaitlistEntry(ctx, job.Args.EventID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				break
+			}
+			return fmt.Errorf("failed to get next waitlist entry for event %d: %w", job.Args.EventID, err)
+		}
+
+		// 2. Pick seats, users further in line wait until the first one is served
+		seatIDs, err := pickWaitlistSeats(ctx, qtx, entry)
+		if err != nil {
+			return err
+		}
+		if int64(len(seatIDs)) < entry.SeatsCount {
+			break
+		}
+
+		// 3. Select the places at the provider, a place taken there makes its seat unavailable and the seats are picked again
+		places, err := w.selectPlaces(ctx, qtx, route, entry, seatIDs)
+		if errors.Is(err, ticketing.ErrPlaceTaken) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if places != nil {
+			held = append(held, places)
+		}
+
+		// 4. Hold the seats with a booking for the user
+		bookingID, expiresAt, err := w.holdSeats(ctx, qtx, entry, seatIDs, places)
+		if err != nil {
+			return err
+		}
+
+		// 5. Return the seats to the pool if the offer is not taken in time.
+		// Queued before commit: if the commit fails the job sees a non-offered entry and skips it.
+		if _, err = river.ClientFromContext[*sql.Tx](ctx).Insert(ctx, ExpireWaitlistOfferArgs{
+			EntryID: entry.ID,
+		}, &river.InsertOpts{ScheduledAt: expiresAt}); err != nil {
+			return fmt.Errorf("failed to queue ExpireWaitlistOfferWorker: %w", err)
+		}
+
+		slog.Info("waitlist offer created",
+			"event_id", entry.EventID,
+			"entry_id", entry.ID,
+			"user_id", entry.UserID,
+			"booking_id", bookingID,
+			"seats", len(seatIDs),
+		)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction for event %d: %w", job.Args.EventID, err)
+	}
+	committed = true
+
+	return nil
+}
+
+// reservingRoute returns the provider of the event if it selects places as they are added to a booking, nil otherwise.
+func (w *OfferWaitlistSeatsWorker) reservingRoute(ctx context.Context, eventID int64) (*ticketing.Route, error) {
+	if !w.ticketProviders.ReserveOnSelect() {
+		return nil, nil
+	}
+
+	route, err := w.ticketProviders.ForEvent(ctx, eventID)
+	if errors.Is(err, ticketing.ErrUnknownProvider) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !route.ReserveOnSelect {
+		return nil, nil
+	}
+
+	return route, nil
+}
+
+// offerPlaces are the places selected in the provider order of an offer.
+type offerPlaces struct {
+	route   *ticketing.Route
+	orderID string
+	// Место провайдера по seat_id
+	places map[int64]string
+}
+
+// release frees the selected places at the provider.
+func (p *offerPlaces) release(ctx context.Context) {
+	// Задачу могли остановить, а места все равно нужно вернуть
+	ctx = context.WithoutCancel(ctx)
+	for _, placeID := range p.places {
+		err := p.route.ReleasePlace(ctx, placeID)
+		if err != nil && !errors.Is(err, ticketing.ErrPlaceNotFound) && !errors.Is(err, ticketing.ErrPlaceNotSelected) {
+			slog.Error("failed to release place of waitlist offer", "order_id", p.orderID, "place_id", placeID, "error", err)
+		}
+	}
+}
+
+// selectPlaces selects the places of the seats in a new provider order, nil without a reserving route.
+// A place taken by another distributor marks its seat RESERVED until the inventory sync sees it free,
+// the places selected so far are released and ErrPlaceTaken is returned.
+func (w *OfferWaitlistSeatsWorker) selectPlaces(ctx context.Context, qtx *sqlc.Queries, route *ticketing.Route, entry sqlc.WaitlistEntry, seatIDs []int64) (*offerPlaces, error) {
+	if route == nil {
+		return nil, nil
+	}
+
+	seats, err := qtx.GetSeatsByIDs(ctx, seatIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get seats for waitlist entry %d: %w", entry.ID, err)
+	}
+
+	orderID, err := route.StartOrder(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start order for waitlist entry %d: %w", entry.ID, err)
+	}
+
+	places := &offerPlaces{route: route, orderID: orderID, places: make(map[int64]string, len(seats))}
+	for _, seat := range seats {
+		if seat.ExternalID == nil {
+			continue
+		}
+
+		err := route.SelectPlace(ctx, orderID, *seat.ExternalID)
+		if err == nil {
+			places.places[seat.ID] = *seat.ExternalID
+			continue
+		}
+
+		if errors.Is(err, ticketing.ErrPlaceTaken) {
+			places.release(ctx)
+
+			if _, err := qtx.UpdateUnbookedSeatStatus(ctx, sqlc.UpdateUnbookedSeatStatusParams{
+				Status:   "RESERVED",
+				SeatID:   seat.ID,
+				StatusEq: "FREE",
+			}); err != nil {
+				return nil, fmt.Errorf("failed to mark seat %d taken at the provider: %w", seat.ID, err)
+			}
+
+			return nil, fmt.Errorf("seat %d for waitlist entry %d: %w", seat.ID, entry.ID, ticketing.ErrPlaceTaken)
+		}
+
+		// Ответ мог потеряться уже после выбора места - его тоже нужно освободить
+		places.places[seat.ID] = *seat.ExternalID
+		places.release(ctx)
+
+		return nil, fmt.Errorf("failed to select place of seat %d for waitlist entry %d: %w", seat.ID, entry.ID, err)
+	}
+
+	return places, nil
+}
+
+func (w *OfferWaitlistSeatsWorker) holdSeats(ctx context.Context, qtx *sqlc.Queries, entry sqlc.WaitlistEntry, seatIDs []int64, places *offerPlaces) (int64, time.Time, error) {
+	reserved, err := qtx.ReserveFreeSeatsByIDs(ctx, seatIDs)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to reserve seats for waitlist entry %d: %w", entry.ID, err)
+	}
+	if reserved != int64(len(seatIDs)) {
+		return 0, time.Time{}, fmt.Errorf("seats for waitlist entry %d were taken concurrently", entry.ID)
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(w.offerTTL)
+
+	bookingID, err := qtx.CreateBooking(ctx, sqlc.CreateBookingParams{
+		UserID:    entry.UserID,
+		EventID:   entry.EventID,
+		ExpiresAt: &expiresAt,
+	})
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to create booking for waitlist entry %d: %w", entry.ID, err)
+	}
+
+	reason := fmt.Sprintf("waitlist offer, entry %d", entry.ID)
+	if err = qtx.InsertBookingStatusHistory(ctx, sqlc.InsertBookingStatusHistoryParams{
+		BookingID: bookingID,
+		ToStatus:  domain.BookingStatusCreated,
+		Actor:     domain.ActorSystem,
+		Reason:    &reason,
+		CreatedAt: now,
+	}); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to insert booking %d status history: %w", bookingID, err)
+	}
+
+	for _, seatID := range seatIDs {
+		if err = qtx.InsertBookingSeat(ctx, sqlc.InsertBookingSeatParams{
+			UserID:    entry.UserID,
+			BookingID: bookingID,
+			SeatID:    seatID,
+		}); err != nil {
+			return 0, time.Time{}, fmt.Errorf("failed to insert booking seat %d for booking %d: %w", seatID, bookingID, err)
+		}
+	}
+
+	// Бронь держит места в заказе провайдера, как бронь, созданная пользователем
+	if places != nil {
+		if err = qtx.InsertBookingOrder(ctx, sqlc.InsertBookingOrderParams{
+			BookingID: bookingID,
+			OrderID:   places.orderID,
+			Status:    stringPtr("STARTED"),
+		}); err != nil {
+			return 0, time.Time{}, fmt.Errorf("failed to insert booking order for booking %d: %w", bookingID, err)
+		}
+
+		for seatID := range places.places {
+			if err = qtx.MarkBookingSeatPlaceSelected(ctx, sqlc.MarkBookingSeatPlaceSelectedParams{
+				PlaceSelectedAt: &now,
+				BookingID:       bookingID,
+				SeatID:          seatID,
+			}); err != nil {
+				return 0, time.Time{}, fmt.Errorf("failed to mark seat %d selected for booking %d: %w", seatID, bookingID, err)
+			}
+		}
+	}
+
+	offered, err := qtx.OfferWaitlistEntry(ctx, sqlc.OfferWaitlistEntryParams{
+		BookingID:      &bookingID,
+		OfferExpiresAt: &expiresAt,
+		EntryID:        entry.ID,
+	})
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to offer waitlist entry %d: %w", entry.ID, err)
+	}
+	if offered == 0 {
+		return 0, time.Time{}, fmt.Errorf("waitlist entry %d changed concurrently", entry.ID)
+	}
+
+	return bookingID, expiresAt, nil
+}
+
+// pickWaitlistSeats prefers adjacent seats in one row and falls back to any FREE seats of the event.
+// Only seats priced in the event currency are offered, so the booking is charged in one currency.
+func pickWaitlistSeats(ctx context.Context, qtx *sqlc.Queries, entry sqlc.WaitlistEntry) ([]int64, error) {
+	event, err := qtx.GetEvent(ctx, entry.EventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event %d for waitlist entry %d: %w", entry.EventID, entry.ID, err)
+	}
+
+	adjacent, err := qtx.FindBestAvailableSeats(ctx, sqlc.FindBestAvailableSeatsParams{
+		EventID:  entry.EventID,
+		Count:    entry.SeatsCount,
+		Currency: event.Currency,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find adjacent seats for waitlist entry %d: %w", entry.ID, err)
+	}
+
+	seatIDs := make([]int64, 0, entry.SeatsCount)
+	if int64(len(adjacent)) == entry.SeatsCount {
+		for _, seat := range adjacent {
+			seatIDs = append(seatIDs, seat.ID)
+		}
+		return seatIDs, nil
+	}
+
+	free := "FREE"
+	seats, err := qtx.GetSeats(ctx, sqlc.GetSeatsParams{
+		EventID:  entry.EventID,
+		Status:   &free,
+		Currency: &event.Currency,
+		Limit:    entry.SeatsCount,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get free seats for waitlist entry %d: %w", entry.ID, err)
+	}
+
+	for _, seat := range seats {
+		seatIDs = append(seatIDs, seat.ID)
+	}
+	return seatIDs, nil
+}
+
+// queueWaitlistOffer queues OfferWaitlistSeatsWorker for the event if anyone is waiting.
+// Called after the transaction that freed the seats has been committed.
+func queueWaitlistOffer(ctx context.Context, queries *sqlc.Queries, eventID int64) error {
+	waiting, err := queries.CountWaitingWaitlistEntries(ctx, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to count waitlist entries for event %d: %w", eventID, err)
+	}
+
+	if waiting == 0 {
+		return nil
+	}
+
+	if _, err = river.ClientFromContext[*sql.Tx](ctx).Insert(ctx, OfferWaitlistSeatsArgs{
+		EventID: eventID,
+	}, nil); err != nil {
+		return fmt.Errorf("failed to queue OfferWaitlistSeatsWorker: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package portriver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"hackload/internal/domain"
+	"hackload/internal/service"
+	"hackload/internal/sqlc"
+
+	"github.com/riverqueue/river"
+)
+
+// ExpireWaitlistOfferArgs is scheduled at waitlist_entries.offer_expires_at.
+// An offer whose booking is still CREATED is cancelled and its seats go back to the pool,
+// which in turn offers them to the next user in line.
+type ExpireWaitlistOfferArgs struct {
+	EntryID int64
+}
+
+func (ExpireWaitlistOfferArgs) Kind() string { return "waitlist.offer_expire" }
+
+type ExpireWaitlistOfferWorker struct {
+	river.WorkerDefaults[ExpireWaitlistOfferArgs]
+
+	queries *sqlc.Queries
+	db      *sql.DB
+}
+
+func NewExpireWaitlistOfferWorker(queries *sqlc.Queries, db *sql.DB) river.Worker[ExpireWaitlistOfferArgs] {
+	return &ExpireWaitlistOfferWorker{
+		queries: queries,
+		db:      db,
+	}
+}
+
+func (w *ExpireWaitlistOfferWorker) Work(ctx context.Context, job *river.Job[ExpireWaitlistOfferArgs]) error {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for waitlist entry %d: %w", job.Args.EntryID, err)
+	}
+	defer tx.Rollback()
+
+	qtx := w.queries.WithTx(tx)
+
+	// 1. Offer already accepted, declined or expired - nothing to do
+	entry, err := qtx.GetWaitlistEntry(ctx, job.Args.EntryID)
+	if err != nil {
+		return fmt.Errorf("failed to get waitlist entry %d: %w", job.Args.EntryID, err)
+	}
+
+	if entry.Status != domain.WaitlistStatusOffered || entry.BookingID == nil {
+		return nil
+	}
+
+	booking, err := qtx.GetBooking(ctx, *entry.BookingID)
+	if err != nil {
+		return fmt.Errorf("failed to get booking %d: %w", *entry.BookingID, err)
+	}
+
+	// 2. Close the entry depending on what the user did with the booking
+	var status domain.WaitlistStatus
+	switch booking.Status {
+	case domain.BookingStatusPaymentInitiated, domain.BookingStatusConfirmed:
+		status = domain.WaitlistStatusFulfilled
+	default:
+		status = domain.WaitlistStatusExpired
+	}
+
+	if _, err = qtx.UpdateWaitlistEntryStatus(ctx, sqlc.UpdateWaitlistEntryStatusParams{
+		Status:   status,
+		EntryID:  entry.ID,
+		StatusEq: domain.WaitlistStatusOffered,
+	}); err != nil {
+		return fmt.Errorf("failed to update waitlist entry %d status: %w", entry.ID, err)
+	}
+
+	// 3. Booking was never taken to payment - cancel it and free the seats in the same transaction.
+	// Места, выбранные в заказе провайдера, освобождаются только после отмены заказа
+	orderStarted := false
+	if booking.Status == domain.BookingStatusCreated {
+		err = service.TransitionBooking(ctx, qtx, booking, domain.BookingStatusCancelled, domain.ActorSystem, "waitlist offer expired")
+		if err != nil {
+			if errors.Is(err, service.ErrBookingStatusChanged) {
+				return fmt.Errorf("booking %d changed concurrently, retrying: %w", booking.ID, err)
+			}
+			return fmt.Errorf("failed to cancel booking %d: %w", booking.ID, err)
+		}
+
+		bookingOrder, err := qtx.GetBookingOrder(ctx, booking.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to get booking order of booking %d: %w", booking.ID, err)
+		}
+		orderStarted = err == nil && bookingOrder.Status != nil && *bookingOrder.Status == "STARTED"
+
+		if !orderStarted {
+			if _, err := service.ReleaseBookingSeats(ctx, qtx, booking.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction for waitlist entry %d: %w", entry.ID, err)
+	}
+
+	// 4. Offer the seats to the next user in line, after the order cancel frees them if there is one.
+	// Not returned as an error: a retry would find the entry closed and stop before this step.
+	if orderStarted {
+		if _, err := queueOrderCancel(ctx, w.queries, booking.ID); err != nil {
+			slog.Error("failed to queue order cancel", "entry_id", entry.ID, "booking_id", booking.ID, "error", err)
+		}
+	} else if booking.Status == domain.BookingStatusCreated {
+		if err := queueWaitlistOffer(ctx, w.queries, booking.EventID); err != nil {
+			slog.Error("failed to queue waitlist offer", "entry_id", entry.ID, "error", err)
+		}
+	}
+
+	return nil
+}
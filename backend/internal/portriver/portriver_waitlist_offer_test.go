@@ -0,0 +1,293 @@
+//go:build sqlite_fts5
+
+package portriver_test
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"hackload/internal/domain"
+	"hackload/internal/portriver"
+	"hackload/internal/sqlc"
+	"hackload/internal/testenv"
+	"hackload/internal/ticketing"
+
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+const offerTTL = time.Hour
+
+// startWaitlist starts the offer and expiry workers of the waitlist. Seats freed by
+// an expired offer held at the provider are freed by the real cancel and release workers.
+func startWaitlist(t *testing.T, env *sagaEnv) *river.Client[*sql.Tx] {
+	t.Helper()
+
+	river.AddWorker(env.deps.RiverWorkers, portriver.NewOfferWaitlistSeatsWorker(env.queries, env.deps.DB, env.registry, offerTTL))
+	river.AddWorker(env.deps.RiverWorkers, portriver.NewExpireWaitlistOfferWorker(env.queries, env.deps.DB))
+	river.AddWorker(env.deps.RiverWorkers, portriver.NewCancelBookingWorker(env.queries, env.deps.DB, env.registry))
+	river.AddWorker(env.deps.RiverWorkers, portriver.NewReleaseSeatsWorker(env.queries, env.deps.DB))
+
+	return testenv.StartRiver(t, env.deps)
+}
+
+// reserveOnSelect makes the provider of event 1 select places as they are added to a booking.
+func (e *sagaEnv) reserveOnSelect(t *testing.T) {
+	t.Helper()
+
+	providerClient, err := e.provider.ProviderClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.registry = ticketing.NewRegistry(e.queries, &ticketing.Route{Provider: ticketing.NewEventProvider(providerClient), ReserveOnSelect: true})
+}
+
+// joinWaitlist puts the user in line for seats of event 1.
+func (e *sagaEnv) joinWaitlist(t *testing.T, userID, seatsCount int64) int64 {
+	t.Helper()
+
+	entryID, err := e.queries.InsertWaitlistEntry(context.Background(), sqlc.InsertWaitlistEntryParams{
+		EventID:    1,
+		UserID:     userID,
+		SeatsCount: seatsCount,
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entryID
+}
+
+func (e *sagaEnv) waitlistEntry(t *testing.T, entryID int64) sqlc.WaitlistEntry {
+	t.Helper()
+
+	entry, err := e.queries.GetWaitlistEntry(context.Background(), entryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+// offeredSeats checks that the entry is offered with a CREATED booking of its user and returns the booking seats.
+func (e *sagaEnv) offeredSeats(t *testing.T, entryID int64) (int64, []int64) {
+	t.Helper()
+
+	entry := e.waitlistEntry(t, entryID)
+	if entry.Status != domain.WaitlistStatusOffered || entry.BookingID == nil || entry.OfferExpiresAt == nil {
+		t.Fatalf("entry %d is %s with booking %v, want an OFFERED booking", entryID, entry.Status, entry.BookingID)
+	}
+
+	booking, err := e.queries.GetBooking(context.Background(), *entry.BookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.Status != domain.BookingStatusCreated || booking.UserID != entry.UserID {
+		t.Errorf("booking of entry %d is %s of user %d, want CREATED of user %d", entryID, booking.Status, booking.UserID, entry.UserID)
+	}
+
+	seatIDs, err := e.queries.GetBookingSeats(context.Background(), booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(seatIDs)
+	for _, seatID := range seatIDs {
+		if status := e.seatStatus(t, seatID); status != "RESERVED" {
+			t.Errorf("offered seat %d is %s, want RESERVED", seatID, status)
+		}
+	}
+
+	return booking.ID, seatIDs
+}
+
+func TestOfferWaitlistSeatsServesLineInOrder(t *testing.T) {
+	env := newSagaEnv(t)
+	riverClient := startWaitlist(t, env)
+
+	// Место 3 уже продано, свободны два места
+	env.exec(t, `INSERT INTO users (user_id, email, password_hash, first_name, surname, registered_at, is_active, last_logged_in)
+		VALUES (3, 'c@example.com', 'x', 'C', 'C', '2025-01-01', 1, '2025-01-01')`)
+	env.createBooking(t, domain.BookingStatusConfirmed, 3)
+	first := env.joinWaitlist(t, 1, 1)
+	second := env.joinWaitlist(t, 2, 1)
+	third := env.joinWaitlist(t, 3, 1)
+
+	job := testenv.WorkJob(t, riverClient, portriver.OfferWaitlistSeatsArgs{EventID: 1})
+	if job.State != rivertype.JobStateCompleted {
+		t.Fatalf("job is %s: %v", job.State, job.Errors)
+	}
+
+	_, firstSeats := env.offeredSeats(t, first)
+	_, secondSeats := env.offeredSeats(t, second)
+	if seats := slices.Concat(firstSeats, secondSeats); !slices.Equal(seats, []int64{1, 2}) {
+		t.Errorf("offered seats are %v, want [1 2]", seats)
+	}
+	if entry := env.waitlistEntry(t, third); entry.Status != domain.WaitlistStatusWaiting {
+		t.Errorf("third entry is %s, want WAITING", entry.Status)
+	}
+
+	// Каждое предложение истекает в свой срок
+	expiring := testenv.Jobs(t, riverClient, portriver.ExpireWaitlistOfferArgs{}.Kind())
+	if len(expiring) != 2 {
+		t.Fatalf("%d offer expiry jobs queued, want 2", len(expiring))
+	}
+	for _, job := range expiring {
+		if job.State != rivertype.JobStateScheduled {
+			t.Errorf("offer expiry job is %s, want scheduled", job.State)
+		}
+	}
+}
+
+func TestOfferWaitlistSeatsWaitsForHeadOfLine(t *testing.T) {
+	env := newSagaEnv(t)
+	riverClient := startWaitlist(t, env)
+
+	// Первому в очереди нужно больше мест, чем свободно, и следующий его не обгоняет
+	env.createBooking(t, domain.BookingStatusConfirmed, 3)
+	first := env.joinWaitlist(t, 1, 3)
+	second := env.joinWaitlist(t, 2, 1)
+
+	job := testenv.WorkJob(t, riverClient, portriver.OfferWaitlistSeatsArgs{EventID: 1})
+	if job.State != rivertype.JobStateCompleted {
+		t.Fatalf("job is %s: %v", job.State, job.Errors)
+	}
+
+	for _, entryID := range []int64{first, second} {
+		if entry := env.waitlistEntry(t, entryID); entry.Status != domain.WaitlistStatusWaiting || entry.BookingID != nil {
+			t.Errorf("entry %d is %s with booking %v, want WAITING", entryID, entry.Status, entry.BookingID)
+		}
+	}
+	for _, seatID := range []int64{1, 2} {
+		if status := env.seatStatus(t, seatID); status != "FREE" {
+			t.Errorf("seat %d is %s, want FREE", seatID, status)
+		}
+	}
+	if jobs := testenv.Jobs(t, riverClient, portriver.ExpireWaitlistOfferArgs{}.Kind()); len(jobs) != 0 {
+		t.Errorf("%d offer expiry jobs queued, want none", len(jobs))
+	}
+}
+
+func TestExpireWaitlistOfferMovesSeatsToNextEntry(t *testing.T) {
+	env := newSagaEnv(t)
+	riverClient := startWaitlist(t, env)
+
+	env.createBooking(t, domain.BookingStatusConfirmed, 3)
+	first := env.joinWaitlist(t, 1, 2)
+	second := env.joinWaitlist(t, 2, 2)
+
+	testenv.WorkJob(t, riverClient, portriver.OfferWaitlistSeatsArgs{EventID: 1})
+	bookingID, _ := env.offeredSeats(t, first)
+
+	// Предложение не принято: бронь отменяется, а места уходят следующему в очереди
+	job := testenv.WorkJob(t, riverClient, portriver.ExpireWaitlistOfferArgs{EntryID: first})
+	if job.State != rivertype.JobStateCompleted {
+		t.Fatalf("job is %s: %v", job.State, job.Errors)
+	}
+	testenv.WaitJobs(t, riverClient, portriver.OfferWaitlistSeatsArgs{}.Kind())
+
+	if entry := env.waitlistEntry(t, first); entry.Status != domain.WaitlistStatusExpired {
+		t.Errorf("first entry is %s, want EXPIRED", entry.Status)
+	}
+	if history := env.statusHistory(t, bookingID); !slices.Equal(history, []string{
+		"CREATED: waitlist offer, entry 1",
+		"CANCELLED: waitlist offer expired",
+	}) {
+		t.Errorf("first booking history: %v", history)
+	}
+	if seats, err := env.queries.GetBookingSeats(context.Background(), bookingID); err != nil || len(seats) != 0 {
+		t.Errorf("first booking still holds seats %v: %v", seats, err)
+	}
+
+	if _, seats := env.offeredSeats(t, second); !slices.Equal(seats, []int64{1, 2}) {
+		t.Errorf("second entry is offered seats %v, want [1 2]", seats)
+	}
+}
+
+func TestExpireWaitlistOfferKeepsPaidBooking(t *testing.T) {
+	env := newSagaEnv(t)
+	riverClient := startWaitlist(t, env)
+
+	entryID := env.joinWaitlist(t, 1, 1)
+	testenv.WorkJob(t, riverClient, portriver.OfferWaitlistSeatsArgs{EventID: 1})
+	bookingID, seats := env.offeredSeats(t, entryID)
+	env.exec(t, `UPDATE bookings SET status = ? WHERE id = ?`, domain.BookingStatusPaymentInitiated, bookingID)
+
+	testenv.WorkJob(t, riverClient, portriver.ExpireWaitlistOfferArgs{EntryID: entryID})
+
+	if entry := env.waitlistEntry(t, entryID); entry.Status != domain.WaitlistStatusFulfilled {
+		t.Errorf("entry is %s, want FULFILLED", entry.Status)
+	}
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusPaymentInitiated {
+		t.Errorf("booking is %s, want PAYMENT_INITIATED", status)
+	}
+	if status := env.seatStatus(t, seats[0]); status != "RESERVED" {
+		t.Errorf("seat %d is %s, want RESERVED", seats[0], status)
+	}
+}
+
+func TestWaitlistOfferHoldsPlacesAtProvider(t *testing.T) {
+	env := newSagaEnv(t)
+	env.reserveOnSelect(t)
+	riverClient := startWaitlist(t, env)
+
+	// Место 1 держит другой дистрибьютор, предложение получает места 2 и 3
+	other := env.provider.Provider.StartOrder()
+	if err := env.provider.Provider.SelectPlace(env.places[0].Id, other.Id); err != nil {
+		t.Fatal(err)
+	}
+	entryID := env.joinWaitlist(t, 1, 2)
+
+	job := testenv.WorkJob(t, riverClient, portriver.OfferWaitlistSeatsArgs{EventID: 1})
+	if job.State != rivertype.JobStateCompleted {
+		t.Fatalf("job is %s: %v", job.State, job.Errors)
+	}
+
+	bookingID, seats := env.offeredSeats(t, entryID)
+	if !slices.Equal(seats, []int64{2, 3}) {
+		t.Fatalf("offered seats are %v, want [2 3]", seats)
+	}
+	if status := env.seatStatus(t, 1); status != "RESERVED" {
+		t.Errorf("seat 1 is %s, want RESERVED", status)
+	}
+
+	bookingOrder, err := env.queries.GetBookingOrder(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderID := uuid.MustParse(bookingOrder.OrderID)
+	if order, err := env.provider.Provider.GetOrder(orderID); err != nil || order.PlacesCount != 2 {
+		t.Fatalf("provider order holds %d places, want 2: %v", order.PlacesCount, err)
+	}
+	places, err := env.queries.GetBookingSeatPlaces(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, place := range places {
+		if place.PlaceSelectedAt == nil {
+			t.Errorf("seat %d is not recorded as selected", place.SeatID)
+		}
+	}
+
+	// Истекшее предложение отменяет заказ, и только после этого места свободны
+	testenv.WorkJob(t, riverClient, portriver.ExpireWaitlistOfferArgs{EntryID: entryID})
+	testenv.WaitJobs(t, riverClient, portriver.CancelBookingArgs{}.Kind())
+	releases := testenv.WaitJobs(t, riverClient, portriver.ReleaseSeatsArgs{}.Kind())
+	if len(releases) != 1 || releases[0].State != rivertype.JobStateCompleted {
+		t.Fatalf("release jobs: %+v", releases)
+	}
+
+	if order, _ := env.provider.Provider.GetOrder(orderID); order.Status != "CANCELLED" {
+		t.Errorf("provider order is %s, want CANCELLED", order.Status)
+	}
+	for _, seatID := range seats {
+		if status := env.seatStatus(t, seatID); status != "FREE" {
+			t.Errorf("seat %d is %s, want FREE", seatID, status)
+		}
+		if place, _ := env.provider.Provider.GetPlace(env.places[seatID-1].Id); !place.IsFree {
+			t.Errorf("provider place %d is not free", seatID)
+		}
+	}
+}
@@ -29,13 +29,18 @@ const (
 
 // AnalyticsResponse defines model for AnalyticsResponse.
 type AnalyticsResponse struct {
-	BookingsCount int32  `json:"bookings_count"`
-	EventId       int64  `json:"event_id"`
-	FreeSeats     int32  `json:"free_seats"`
-	ReservedSeats int32  `json:"reserved_seats"`
-	SoldSeats     int32  `json:"sold_seats"`
-	TotalRevenue  string `json:"total_revenue"`
-	TotalSeats    int32  `json:"total_seats"`
+	BookingsCount int32 `json:"bookings_count"`
+	EventId       int64 `json:"event_id"`
+	FreeSeats     int32 `json:"free_seats"`
+	ReservedSeats int32 `json:"reserved_seats"`
+
+	// RevenueByCurrency Выручка по валютам проданных мест, пример: {"KZT": "120000.00"}
+	RevenueByCurrency map[string]string `json:"revenue_by_currency"`
+	SoldSeats         int32             `json:"sold_seats"`
+
+	// TotalRevenue Выручка в валюте события
+	TotalRevenue string `json:"total_revenue"`
+	TotalSeats   int32  `json:"total_seats"`
 }
 
 // CancelBookingRequest defines model for CancelBookingRequest.
@@ -50,7 +55,54 @@ type CreateBookingRequest struct {
 
 // CreateBookingResponse defines model for CreateBookingResponse.
 type CreateBookingResponse struct {
-	Id int64 `json:"id"`
+	// ExpiresAt Время, после которого неоплаченная бронь будет отменена
+	ExpiresAt time.Time `json:"expires_at"`
+	Id        int64     `json:"id"`
+}
+
+// GetBookingResponse defines model for GetBookingResponse.
+type GetBookingResponse struct {
+	// Currency Валюта суммы; пустая, пока в брони нет мест
+	Currency string `json:"currency"`
+	EventId  int64  `json:"event_id"`
+
+	// ExpiresAt Время, после которого неоплаченная бронь будет отменена
+	ExpiresAt *time.Time                 `json:"expires_at,omitempty"`
+	Id        int64                      `json:"id"`
+	Order     *GetBookingResponseOrder   `json:"order,omitempty"`
+	Payment   *GetBookingResponsePayment `json:"payment,omitempty"`
+	Seats     []GetBookingResponseSeat   `json:"seats"`
+
+	// Status Статус брони: CREATED, PAYMENT_INITIATED, CONFIRMED, CANCELLED, FAILED (места не получены у провайдера, деньги возвращаются)
+	Status string `json:"status"`
+
+	// Total Сумма за все места брони, пример: 120000.00
+	Total string `json:"total"`
+}
+
+// GetBookingResponseOrder defines model for GetBookingResponseOrder.
+type GetBookingResponseOrder struct {
+	// Status Статус заказа в Event Provider: STARTED, SUBMITTED, CONFIRMED, CANCELLED
+	Status string `json:"status"`
+}
+
+// GetBookingResponsePayment defines model for GetBookingResponsePayment.
+type GetBookingResponsePayment struct {
+	OrderId    string  `json:"order_id"`
+	PaymentUrl *string `json:"payment_url,omitempty"`
+
+	// Status Статус платежа: INIT, SUCCESS, FAIL, REFUNDED
+	Status string `json:"status"`
+}
+
+// GetBookingResponseSeat defines model for GetBookingResponseSeat.
+type GetBookingResponseSeat struct {
+	// Currency Валюта цены, пример: KZT
+	Currency string `json:"currency"`
+	Id       int64  `json:"id"`
+	Number   int64  `json:"number"`
+	Price    string `json:"price"`
+	Row      int64  `json:"row"`
 }
 
 // InitiatePaymentRequest defines model for InitiatePaymentRequest.
@@ -58,14 +110,38 @@ type InitiatePaymentRequest struct {
 	BookingId int64 `json:"booking_id"`
 }
 
+// JoinWaitlistRequest defines model for JoinWaitlistRequest.
+type JoinWaitlistRequest struct {
+	EventId int64 `json:"event_id"`
+
+	// SeatsCount Сколько мест нужно, от 1 до 10
+	SeatsCount int64 `json:"seats_count"`
+}
+
+// JoinWaitlistResponse defines model for JoinWaitlistResponse.
+type JoinWaitlistResponse struct {
+	Id int64 `json:"id"`
+
+	// Position Место в очереди на событие
+	Position int64 `json:"position"`
+}
+
+// LeaveWaitlistRequest defines model for LeaveWaitlistRequest.
+type LeaveWaitlistRequest struct {
+	Id int64 `json:"id"`
+}
+
 // ListBookingsResponse defines model for ListBookingsResponse.
 type ListBookingsResponse = []ListBookingsResponseItem
 
 // ListBookingsResponseItem defines model for ListBookingsResponseItem.
 type ListBookingsResponseItem struct {
-	EventId int64                         `json:"event_id"`
-	Id      int64                         `json:"id"`
-	Seats   *[]ListEventsResponseItemSeat `json:"seats,omitempty"`
+	EventId int64 `json:"event_id"`
+
+	// ExpiresAt Время, после которого неоплаченная бронь будет отменена
+	ExpiresAt *time.Time                    `json:"expires_at,omitempty"`
+	Id        int64                         `json:"id"`
+	Seats     *[]ListEventsResponseItemSeat `json:"seats,omitempty"`
 }
 
 // ListEventsResponse defines model for ListEventsResponse.
@@ -87,23 +163,55 @@ type ListSeatsResponse = []ListSeatsResponseItem
 
 // ListSeatsResponseItem defines model for ListSeatsResponseItem.
 type ListSeatsResponseItem struct {
-	Id     int64                       `json:"id"`
-	Number int64                       `json:"number"`
-	Price  string                      `json:"price"`
-	Row    int64                       `json:"row"`
-	Status ListSeatsResponseItemStatus `json:"status"`
+	// Currency Валюта цены, пример: KZT
+	Currency string                      `json:"currency"`
+	Id       int64                       `json:"id"`
+	Number   int64                       `json:"number"`
+	Price    string                      `json:"price"`
+	Row      int64                       `json:"row"`
+	Status   ListSeatsResponseItemStatus `json:"status"`
 }
 
 // ListSeatsResponseItemStatus defines model for ListSeatsResponseItem.Status.
 type ListSeatsResponseItemStatus string
 
+// ListWaitlistResponse defines model for ListWaitlistResponse.
+type ListWaitlistResponse = []ListWaitlistResponseItem
+
+// ListWaitlistResponseItem defines model for ListWaitlistResponseItem.
+type ListWaitlistResponseItem struct {
+	// BookingId Бронь с предложенными местами, только после OFFERED
+	BookingId *int64 `json:"booking_id,omitempty"`
+	EventId   int64  `json:"event_id"`
+	Id        int64  `json:"id"`
+
+	// OfferExpiresAt Время, до которого нужно перейти к оплате предложенной брони
+	OfferExpiresAt *time.Time `json:"offer_expires_at,omitempty"`
+
+	// Position Место в очереди, только для WAITING
+	Position   *int64 `json:"position,omitempty"`
+	SeatsCount int64  `json:"seats_count"`
+
+	// Status Статус заявки: WAITING, OFFERED, FULFILLED, EXPIRED, CANCELLED
+	Status string `json:"status"`
+}
+
 // PaymentNotificationPayload defines model for PaymentNotificationPayload.
 type PaymentNotificationPayload struct {
+	// Amount Сумма в минимальных единицах валюты
+	Amount    *int64                             `json:"amount,omitempty"`
+	Currency  *string                            `json:"currency,omitempty"`
 	Data      *map[string]map[string]interface{} `json:"data,omitempty"`
+	OrderId   *string                            `json:"orderId,omitempty"`
 	PaymentId *string                            `json:"paymentId,omitempty"`
-	Status    *string                            `json:"status,omitempty"`
-	TeamSlug  *string                            `json:"teamSlug,omitempty"`
-	Timestamp *time.Time                         `json:"timestamp,omitempty"`
+
+	// Status Статус платежа в платежном шлюзе, например CONFIRMED, REJECTED, REFUNDED
+	Status    *string    `json:"status,omitempty"`
+	TeamSlug  *string    `json:"teamSlug,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+
+	// Token Подпись уведомления, рассчитывается как при создании платежа
+	Token *string `json:"token,omitempty"`
 }
 
 // ReleaseSeatRequest defines model for ReleaseSeatRequest.
@@ -111,12 +219,75 @@ type ReleaseSeatRequest struct {
 	SeatId int64 `json:"seat_id"`
 }
 
+// ReleaseSeatsRequest defines model for ReleaseSeatsRequest.
+type ReleaseSeatsRequest struct {
+	BookingId int64   `json:"booking_id"`
+	SeatIds   []int64 `json:"seat_ids"`
+}
+
+// SeatConflict defines model for SeatConflict.
+type SeatConflict struct {
+	// Reason Причина: NOT_FOUND, OTHER_EVENT, NOT_AVAILABLE, NOT_IN_BOOKING, OTHER_CURRENCY
+	Reason string `json:"reason"`
+	SeatId int64  `json:"seat_id"`
+}
+
+// SeatsBulkResponse defines model for SeatsBulkResponse.
+type SeatsBulkResponse struct {
+	Conflicts []SeatConflict `json:"conflicts"`
+}
+
+// SelectBestSeatsRequest defines model for SelectBestSeatsRequest.
+type SelectBestSeatsRequest struct {
+	BookingId int64 `json:"booking_id"`
+
+	// Count Сколько мест подряд нужно, от 1 до 10
+	Count int64 `json:"count"`
+
+	// Price Ценовая категория, пример: 40000.00
+	Price *string `json:"price,omitempty"`
+
+	// RowFrom Первый допустимый ряд
+	RowFrom *int64 `json:"row_from,omitempty"`
+
+	// RowTo Последний допустимый ряд
+	RowTo *int64 `json:"row_to,omitempty"`
+}
+
+// SelectBestSeatsResponse defines model for SelectBestSeatsResponse.
+type SelectBestSeatsResponse struct {
+	Seats []GetBookingResponseSeat `json:"seats"`
+}
+
 // SelectSeatRequest defines model for SelectSeatRequest.
 type SelectSeatRequest struct {
 	BookingId int64 `json:"booking_id"`
 	SeatId    int64 `json:"seat_id"`
 }
 
+// SelectSeatsRequest defines model for SelectSeatsRequest.
+type SelectSeatsRequest struct {
+	BookingId int64   `json:"booking_id"`
+	SeatIds   []int64 `json:"seat_ids"`
+}
+
+// TransferBookingRequest defines model for TransferBookingRequest.
+type TransferBookingRequest struct {
+	BookingId int64 `json:"booking_id"`
+
+	// Email Email получателя
+	Email string `json:"email"`
+
+	// SeatIds Места для передачи. Если не указаны, передается вся бронь
+	SeatIds *[]int64 `json:"seat_ids,omitempty"`
+}
+
+// TransferBookingResponse defines model for TransferBookingResponse.
+type TransferBookingResponse struct {
+	// BookingId Бронь получателя
+	BookingId int64 `json:"booking_id"`
+}
+
 // GetEventAnalyticsParams defines parameters for GetEventAnalytics.
 type GetEventAnalyticsParams struct {
 	// Id ID события для получения аналитики
@@ -165,15 +336,33 @@ type CancelBookingJSONRequestBody = CancelBookingRequest
 // InitiatePaymentJSONRequestBody defines body for InitiatePayment for application/json ContentType.
 type InitiatePaymentJSONRequestBody = InitiatePaymentRequest
 
+// TransferBookingJSONRequestBody defines body for TransferBooking for application/json ContentType.
+type TransferBookingJSONRequestBody = TransferBookingRequest
+
 // OnPaymentUpdatesJSONRequestBody defines body for OnPaymentUpdates for application/json ContentType.
 type OnPaymentUpdatesJSONRequestBody = PaymentNotificationPayload
 
 // ReleaseSeatJSONRequestBody defines body for ReleaseSeat for application/json ContentType.
 type ReleaseSeatJSONRequestBody = ReleaseSeatRequest
 
+// ReleaseSeatsJSONRequestBody defines body for ReleaseSeats for application/json ContentType.
+type ReleaseSeatsJSONRequestBody = ReleaseSeatsRequest
+
 // SelectSeatJSONRequestBody defines body for SelectSeat for application/json ContentType.
 type SelectSeatJSONRequestBody = SelectSeatRequest
 
+// SelectBestSeatsJSONRequestBody defines body for SelectBestSeats for application/json ContentType.
+type SelectBestSeatsJSONRequestBody = SelectBestSeatsRequest
+
+// SelectSeatsJSONRequestBody defines body for SelectSeats for application/json ContentType.
+type SelectSeatsJSONRequestBody = SelectSeatsRequest
+
+// JoinWaitlistJSONRequestBody defines body for JoinWaitlist for application/json ContentType.
+type JoinWaitlistJSONRequestBody = JoinWaitlistRequest
+
+// LeaveWaitlistJSONRequestBody defines body for LeaveWaitlist for application/json ContentType.
+type LeaveWaitlistJSONRequestBody = LeaveWaitlistRequest
+
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
 	// Получить аналитику продаж для события
@@ -191,6 +380,12 @@ type ServerInterface interface {
 	// Инициировать платеж для бронирования
 	// (PATCH /api/bookings/initiatePayment)
 	InitiatePayment(w http.ResponseWriter, r *http.Request)
+	// Передать подтвержденное бронирование другому пользователю
+	// (PATCH /api/bookings/transfer)
+	TransferBooking(w http.ResponseWriter, r *http.Request)
+	// Получить бронирование
+	// (GET /api/bookings/{id})
+	GetBooking(w http.ResponseWriter, r *http.Request, id int64)
 	// Получить список событий
 	// (GET /api/events)
 	ListEvents(w http.ResponseWriter, r *http.Request, params ListEventsParams)
@@ -212,9 +407,27 @@ type ServerInterface interface {
 	// Убрать место из брони
 	// (PATCH /api/seats/release)
 	ReleaseSeat(w http.ResponseWriter, r *http.Request)
+	// Убрать несколько мест из брони
+	// (PATCH /api/seats/release/bulk)
+	ReleaseSeats(w http.ResponseWriter, r *http.Request)
 	// Выбрать место для брони
 	// (PATCH /api/seats/select)
 	SelectSeat(w http.ResponseWriter, r *http.Request)
+	// Подобрать лучшие места подряд
+	// (PATCH /api/seats/select/best)
+	SelectBestSeats(w http.ResponseWriter, r *http.Request)
+	// Выбрать несколько мест для брони
+	// (PATCH /api/seats/select/bulk)
+	SelectSeats(w http.ResponseWriter, r *http.Request)
+	// Получить заявки пользователя в листе ожидания
+	// (GET /api/waitlist)
+	ListWaitlist(w http.ResponseWriter, r *http.Request)
+	// Встать в лист ожидания на событие
+	// (POST /api/waitlist)
+	JoinWaitlist(w http.ResponseWriter, r *http.Request)
+	// Покинуть лист ожидания
+	// (PATCH /api/waitlist/cancel)
+	LeaveWaitlist(w http.ResponseWriter, r *http.Request)
 }
 
 // ServerInterfaceWrapper converts contexts to parameters.
@@ -316,6 +529,45 @@ func (siw *ServerInterfaceWrapper) InitiatePayment(w http.ResponseWriter, r *htt
 	handler.ServeHTTP(w, r)
 }
 
+// TransferBooking operation middleware
+func (siw *ServerInterfaceWrapper) TransferBooking(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.TransferBooking(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// GetBooking operation middleware
+func (siw *ServerInterfaceWrapper) GetBooking(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+
+	// ------------- Path parameter "id" -------------
+	var id int64
+
+	err = runtime.BindStyledParameterWithOptions("simple", "id", mux.Vars(r)["id"], &id, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "id", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.GetBooking(w, r, id)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 // ListEvents operation middleware
 func (siw *ServerInterfaceWrapper) ListEvents(w http.ResponseWriter, r *http.Request) {
 
@@ -543,6 +795,20 @@ func (siw *ServerInterfaceWrapper) ReleaseSeat(w http.ResponseWriter, r *http.Re
 	handler.ServeHTTP(w, r)
 }
 
+// ReleaseSeats operation middleware
+func (siw *ServerInterfaceWrapper) ReleaseSeats(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ReleaseSeats(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 // SelectSeat operation middleware
 func (siw *ServerInterfaceWrapper) SelectSeat(w http.ResponseWriter, r *http.Request) {
 
@@ -557,6 +823,76 @@ func (siw *ServerInterfaceWrapper) SelectSeat(w http.ResponseWriter, r *http.Req
 	handler.ServeHTTP(w, r)
 }
 
+// SelectBestSeats operation middleware
+func (siw *ServerInterfaceWrapper) SelectBestSeats(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.SelectBestSeats(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// SelectSeats operation middleware
+func (siw *ServerInterfaceWrapper) SelectSeats(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.SelectSeats(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// ListWaitlist operation middleware
+func (siw *ServerInterfaceWrapper) ListWaitlist(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListWaitlist(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// JoinWaitlist operation middleware
+func (siw *ServerInterfaceWrapper) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.JoinWaitlist(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// LeaveWaitlist operation middleware
+func (siw *ServerInterfaceWrapper) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.LeaveWaitlist(w, r)
+	}))
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		handler = middleware(handler)
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
 type UnescapedCookieParamError struct {
 	ParamName string
 	Err       error
@@ -680,6 +1016,10 @@ func HandlerWithOptions(si ServerInterface, options GorillaServerOptions) http.H
 
 	r.HandleFunc(options.BaseURL+"/api/bookings/initiatePayment", wrapper.InitiatePayment).Methods("PATCH")
 
+	r.HandleFunc(options.BaseURL+"/api/bookings/transfer", wrapper.TransferBooking).Methods("PATCH")
+
+	r.HandleFunc(options.BaseURL+"/api/bookings/{id}", wrapper.GetBooking).Methods("GET")
+
 	r.HandleFunc(options.BaseURL+"/api/events", wrapper.ListEvents).Methods("GET")
 
 	r.HandleFunc(options.BaseURL+"/api/payments/fail", wrapper.NotifyPaymentFailed).Methods("GET")
@@ -694,7 +1034,19 @@ func HandlerWithOptions(si ServerInterface, options GorillaServerOptions) http.H
 
 	r.HandleFunc(options.BaseURL+"/api/seats/release", wrapper.ReleaseSeat).Methods("PATCH")
 
+	r.HandleFunc(options.BaseURL+"/api/seats/release/bulk", wrapper.ReleaseSeats).Methods("PATCH")
+
 	r.HandleFunc(options.BaseURL+"/api/seats/select", wrapper.SelectSeat).Methods("PATCH")
 
+	r.HandleFunc(options.BaseURL+"/api/seats/select/best", wrapper.SelectBestSeats).Methods("PATCH")
+
+	r.HandleFunc(options.BaseURL+"/api/seats/select/bulk", wrapper.SelectSeats).Methods("PATCH")
+
+	r.HandleFunc(options.BaseURL+"/api/waitlist", wrapper.ListWaitlist).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/api/waitlist", wrapper.JoinWaitlist).Methods("POST")
+
+	r.HandleFunc(options.BaseURL+"/api/waitlist/cancel", wrapper.LeaveWaitlist).Methods("PATCH")
+
 	return r
 }
@@ -2,8 +2,10 @@ package ports
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -12,43 +14,54 @@ import (
 	"time"
 
 	"hackload/internal/config"
+	"hackload/internal/domain"
 	"hackload/internal/middleware"
 	"hackload/internal/paymenttoken"
 	"hackload/internal/portriver"
 	"hackload/internal/service"
 	"hackload/internal/sqlc"
+	"hackload/internal/ticketing"
 	"hackload/pkg/paymentgateway"
 	"hackload/pkg/telemetry"
 
+	"github.com/mattn/go-sqlite3"
 	"github.com/riverqueue/river"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// maxBestSeats limits how many adjacent seats SelectBestSeats looks for
+const maxBestSeats = 10
+
 type HttpServer struct {
-	queries        *sqlc.Queries
-	db             *sql.DB
-	riverClient    *river.Client[*sql.Tx]
-	paymentGateway paymentgateway.ClientInterface
-	resetService   service.ResetService
-	config         *config.Config
+	queries         *sqlc.Queries
+	db              *sql.DB
+	riverClient     *river.Client[*sql.Tx]
+	ticketProviders *ticketing.Registry
+	paymentGateway  paymentgateway.ClientInterface
+	resetService    service.ResetService
+	paymentSaga     *portriver.PaymentSaga
+	config          *config.Config
 }
 
 func NewHttpServer(
 	queries *sqlc.Queries,
 	db *sql.DB,
 	riverClient *river.Client[*sql.Tx],
+	ticketProviders *ticketing.Registry,
 	paymentGateway paymentgateway.ClientInterface,
 	resetService service.ResetService,
 	config *config.Config,
 ) ServerInterface {
 	return &HttpServer{
-		queries:        queries,
-		db:             db,
-		riverClient:    riverClient,
-		paymentGateway: paymentGateway,
-		resetService:   resetService,
-		config:         config,
+		queries:         queries,
+		db:              db,
+		riverClient:     riverClient,
+		ticketProviders: ticketProviders,
+		paymentGateway:  paymentGateway,
+		resetService:    resetService,
+		paymentSaga:     portriver.NewPaymentSaga(queries, db, paymentGateway, config),
+		config:          config,
 	}
 }
 
@@ -93,9 +106,10 @@ func (s *HttpServer) ListBookings(w http.ResponseWriter, r *http.Request) {
 		}
 
 		item := ListBookingsResponseItem{
-			Id:      booking.ID,
-			EventId: booking.EventID,
-			Seats:   &seats,
+			Id:        booking.ID,
+			EventId:   booking.EventID,
+			ExpiresAt: booking.ExpiresAt,
+			Seats:     &seats,
 		}
 		response = append(response, item)
 	}
@@ -125,9 +139,41 @@ func (s *HttpServer) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bookingID, err := s.queries.CreateBooking(r.Context(), sqlc.CreateBookingParams{
-		UserID:  session.UserID,
-		EventID: req.EventId,
+	// Места брони будут выбираться в заказе провайдера события сразу при добавлении.
+	// Пустой заказ, оставшийся после неудачной транзакции, ничего не удерживает
+	route, err := s.reservingRoute(r.Context(), req.EventId)
+	if err != nil {
+		fmt.Println("ERROR: s.reservingRoute:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var orderID string
+	if route != nil {
+		orderID, err = route.StartOrder(r.Context())
+		if err != nil {
+			fmt.Println("ERROR: route.StartOrder:", err)
+			http.Error(w, "Event provider is unavailable", http.StatusBadGateway)
+			return
+		}
+	}
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := s.queries.WithTx(tx)
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(s.config.Booking.CreatedTTL)
+
+	bookingID, err := qtx.CreateBooking(r.Context(), sqlc.CreateBookingParams{
+		UserID:    session.UserID,
+		EventID:   req.EventId,
+		ExpiresAt: &expiresAt,
 	})
 	if err != nil {
 		fmt.Println("ERROR: s.queries.CreateBooking:", err)
@@ -135,23 +181,51 @@ func (s *HttpServer) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// statusEq := "CREATED"
+	if err = qtx.InsertBookingStatusHistory(r.Context(), sqlc.InsertBookingStatusHistoryParams{
+		BookingID: bookingID,
+		ToStatus:  domain.BookingStatusCreated,
+		Actor:     domain.UserActor(session.UserID),
+		CreatedAt: now,
+	}); err != nil {
+		fmt.Println("ERROR: s.queries.InsertBookingStatusHistory:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if orderID != "" {
+		if err = qtx.InsertBookingOrder(r.Context(), sqlc.InsertBookingOrderParams{
+			BookingID: bookingID,
+			OrderID:   orderID,
+			Status:    stringPtr("STARTED"),
+		}); err != nil {
+			fmt.Println("ERROR: s.queries.InsertBookingOrder:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Отменить бронь и освободить места, если она не дошла до оплаты вовремя
+	if _, err = s.riverClient.Insert(
+		r.Context(),
+		portriver.ExpireBookingArgs{
+			BookingID: bookingID,
+			StatusEq:  domain.BookingStatusCreated,
+		},
+		&river.InsertOpts{ScheduledAt: expiresAt},
+	); err != nil {
+		fmt.Println("ERROR: s.riverClient.Insert:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
-	// if _, err = s.riverClient.Insert(
-	// 	r.Context(),
-	// 	portriver.ReleaseSeatsArgs{
-	// 		BookingID: bookingID,
-	// 		StatusEq:  &statusEq,
-	// 	},
-	// 	&river.InsertOpts{ScheduledAt: time.Now().UTC().Add(10 * time.Minute)},
-	// ); err != nil {
-	// 	fmt.Println("ERROR: s.riverClient.Insert:", err)
-	// 	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-	// 	return
-	// }
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Could not commit transaction", http.StatusInternalServerError)
+		return
+	}
 
 	response := CreateBookingResponse{
-		Id: bookingID,
+		Id:        bookingID,
+		ExpiresAt: expiresAt,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -191,6 +265,12 @@ func (s *HttpServer) CancelBooking(w http.ResponseWriter, r *http.Request) {
 	// 1. GetBooking to verify it exists
 	booking, err := qtx.GetBooking(r.Context(), req.BookingId)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		fmt.Println("ERROR: s.queries.GetBooking:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
@@ -199,20 +279,56 @@ func (s *HttpServer) CancelBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if booking.Status == "PAYMENT_INITIATED" || booking.Status == "CANCELLED" {
+	// Повторная отмена ничего не меняет
+	if booking.Status == domain.BookingStatusCancelled {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	if _, err = qtx.CancelBooking(r.Context(), sqlc.CancelBookingParams{
-		BookingID: req.BookingId,
-		UserID:    session.UserID,
-	}); err != nil {
-		fmt.Println("ERROR: s.queries.CancelBooking:", err)
+	// Пока идет оплата, бронь отменяется только платежным шлюзом или по истечению срока
+	if booking.Status == domain.BookingStatusPaymentInitiated {
+		http.Error(w, "Booking payment is in progress", http.StatusConflict)
+		return
+	}
+
+	// Заказ у провайдера и платеж общие для брони и отделенных от нее при передаче,
+	// поэтому такие брони пока не отменяются
+	if booking.Status == domain.BookingStatusConfirmed {
+		if booking.ParentBookingID != nil {
+			http.Error(w, "Booking was split from another booking", http.StatusConflict)
+			return
+		}
+		splitCount, err := qtx.CountSplitBookings(r.Context(), &booking.ID)
+		if err != nil {
+			fmt.Println("ERROR: qtx.CountSplitBookings:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if splitCount > 0 {
+			http.Error(w, "Booking was partially transferred", http.StatusConflict)
+			return
+		}
+	}
+
+	err = service.TransitionBooking(
+		r.Context(),
+		qtx,
+		booking,
+		domain.BookingStatusCancelled,
+		domain.UserActor(session.UserID),
+		"cancelled by user",
+	)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidBookingTransition) || errors.Is(err, service.ErrBookingStatusChanged) {
+			http.Error(w, "Booking cannot be cancelled", http.StatusConflict)
+			return
+		}
+		fmt.Println("ERROR: service.TransitionBooking:", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	if booking.Status == "CONFIRMED" {
+	if booking.Status == domain.BookingStatusConfirmed {
 		// 1. Если CONFIRMED -> Отменить в TicketProvider -> Освободить места
 		if _, err := s.riverClient.Insert(
 			r.Context(),
@@ -240,20 +356,26 @@ func (s *HttpServer) CancelBooking(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Если CREATED -> Освободить места
-	if booking.Status == "CREATED" {
-		if _, err := s.riverClient.Insert(
-			r.Context(),
-			&portriver.ReleaseSeatsArgs{
-				BookingID: req.BookingId,
-				StatusEq:  &booking.Status,
-			},
-			nil,
-		); err != nil {
-			fmt.Println("ERROR: s.riverClient.Insert:", err)
+	// Если CREATED -> Освободить места в той же транзакции.
+	// Места, выбранные в заказе провайдера, освобождает CancelBookingWorker после отмены заказа,
+	// иначе их успеют выбрать другие, пока провайдер их еще держит
+	orderStarted := false
+	if booking.Status == domain.BookingStatusCreated {
+		bookingOrder, err := qtx.GetBookingOrder(r.Context(), booking.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			fmt.Println("ERROR: qtx.GetBookingOrder:", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
+		orderStarted = err == nil && bookingOrder.Status != nil && *bookingOrder.Status == "STARTED"
+
+		if !orderStarted {
+			if _, err := service.ReleaseBookingSeats(r.Context(), qtx, booking.ID); err != nil {
+				fmt.Println("ERROR: service.ReleaseBookingSeats:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -261,12 +383,21 @@ func (s *HttpServer) CancelBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Отменить заказ, в котором были выбраны места, или сразу предложить их листу ожидания
+	if booking.Status == domain.BookingStatusCreated {
+		if orderStarted {
+			s.queueOrderCancel(r, booking.ID)
+		} else {
+			s.queueWaitlistOffer(r, booking.EventID)
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
-// Инициировать платеж для бронирования
-// (PATCH /api/bookings/initiatePayment)
-func (s *HttpServer) InitiatePayment(w http.ResponseWriter, r *http.Request) {
+// Передать подтвержденное бронирование другому пользователю
+// (PATCH /api/bookings/transfer)
+func (s *HttpServer) TransferBooking(w http.ResponseWriter, r *http.Request) {
 	session, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
 		fmt.Println("ERROR: middleware.GetUserFromContext: false")
@@ -274,13 +405,22 @@ func (s *HttpServer) InitiatePayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req InitiatePaymentRequest
+	var req TransferBookingRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		fmt.Println("ERROR: json.NewDecoder:", err)
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 
+	var seatIDs []int64
+	if req.SeatIds != nil {
+		if status, message := validateSeatIDs(*req.SeatIds); status != http.StatusOK {
+			http.Error(w, message, status)
+			return
+		}
+		seatIDs = *req.SeatIds
+	}
+
 	tx, err := s.db.BeginTx(r.Context(), nil)
 	if err != nil {
 		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
@@ -290,8 +430,110 @@ func (s *HttpServer) InitiatePayment(w http.ResponseWriter, r *http.Request) {
 
 	qtx := s.queries.WithTx(tx)
 
-	// 1. Get and validate the booking
+	// 1. Проверить бронь
 	booking, err := qtx.GetBooking(r.Context(), req.BookingId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		fmt.Println("ERROR: qtx.GetBooking:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if booking.UserID != session.UserID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if booking.Status != domain.BookingStatusConfirmed {
+		http.Error(w, "Only confirmed bookings can be transferred", http.StatusConflict)
+		return
+	}
+
+	// 2. Найти получателя
+	recipient, err := qtx.GetUser(r.Context(), req.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Recipient not found", http.StatusNotFound)
+			return
+		}
+		fmt.Println("ERROR: qtx.GetUser:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if !recipient.IsActive {
+		http.Error(w, "Recipient not found", http.StatusNotFound)
+		return
+	}
+
+	if recipient.UserID == session.UserID {
+		http.Error(w, "Cannot transfer booking to yourself", http.StatusBadRequest)
+		return
+	}
+
+	// 3. Передать бронь или выделить места в новую бронь получателя
+	recipientBookingID, err := service.TransferBooking(r.Context(), qtx, booking, recipient.UserID, seatIDs)
+	if err != nil {
+		if errors.Is(err, service.ErrSeatsNotInBooking) {
+			http.Error(w, "Seats do not belong to booking", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrBookingNotIssued) {
+			http.Error(w, "Tickets are not issued by the event provider yet", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, service.ErrRefundPending) {
+			http.Error(w, "Seat refund is in progress", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidBookingTransition) || errors.Is(err, service.ErrBookingStatusChanged) {
+			http.Error(w, "Booking cannot be transferred", http.StatusConflict)
+			return
+		}
+		fmt.Println("ERROR: service.TransferBooking:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Could not commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	response := TransferBookingResponse{
+		BookingId: recipientBookingID,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Инициировать платеж для бронирования
+// (PATCH /api/bookings/initiatePayment)
+func (s *HttpServer) InitiatePayment(w http.ResponseWriter, r *http.Request) {
+	session, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		fmt.Println("ERROR: middleware.GetUserFromContext: false")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var req InitiatePaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fmt.Println("ERROR: json.NewDecoder:", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	// 1. Get and validate the booking
+	booking, err := s.queries.GetBooking(r.Context(), req.BookingId)
 	if err != nil {
 		fmt.Printf("ERROR: failed to get booking: %v\n", err)
 		http.Error(w, "Booking not found", http.StatusNotFound)
@@ -304,32 +546,59 @@ func (s *HttpServer) InitiatePayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A new attempt replaces an unfinished one while the seats are still held.
+	// Попытка бросается до транзакции, чтобы запросы к шлюзу не держали блокировку записи
+	if booking.Status == domain.BookingStatusPaymentInitiated {
+		if status, message := s.abandonPayment(r.Context(), booking, session.UserID); status != http.StatusOK {
+			http.Error(w, message, status)
+			return
+		}
+	}
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := s.queries.WithTx(tx)
+
+	// Бронь перечитывается: ее статус мог измениться, пока бросалась прежняя попытка
+	booking, err = qtx.GetBooking(r.Context(), req.BookingId)
+	if err != nil {
+		fmt.Printf("ERROR: failed to get booking: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	// Check if booking is in correct status
-	if booking.Status != "CREATED" {
+	if !booking.Status.CanTransitionTo(domain.BookingStatusPaymentInitiated) {
 		http.Error(w, "Booking is not in valid state for payment", http.StatusBadRequest)
 		return
 	}
 
-	// 2. Get booking total in cents
-	totalInterface, err := qtx.GetBookingTotal(r.Context(), req.BookingId)
-	if err != nil {
-		fmt.Printf("ERROR: failed to get booking total: %v\n", err)
-		http.Error(w, "Failed to calculate booking total", http.StatusInternalServerError)
+	// Every attempt gets its own booking_payments row; after the first one the seat hold is not extended
+	_, err = qtx.GetLatestBookingPayment(r.Context(), booking.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		fmt.Printf("ERROR: failed to get latest booking payment: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	firstAttempt := errors.Is(err, sql.ErrNoRows)
 
-	// Convert total to int64 (cents)
-	var totalCents int64
-	switch v := totalInterface.(type) {
-	case float64:
-		totalCents = int64(v)
-	case int64:
-		totalCents = v
-	default:
-		fmt.Printf("ERROR: unexpected total type: %T\n", totalInterface)
+	// 2. Get booking total in minor units of the booking currency
+	total, currency, err := bookingTotal(r.Context(), qtx, req.BookingId)
+	if errors.Is(err, errMixedCurrencies) {
+		http.Error(w, "Booking seats are priced in different currencies", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		fmt.Printf("ERROR: failed to get booking total: %v\n", err)
 		http.Error(w, "Failed to calculate booking total", http.StatusInternalServerError)
 		return
 	}
+	totalCents := int64(total)
 
 	if totalCents <= 0 {
 		http.Error(w, "Booking has no items or invalid total", http.StatusBadRequest)
@@ -339,27 +608,30 @@ func (s *HttpServer) InitiatePayment(w http.ResponseWriter, r *http.Request) {
 	// Generate unique order ID for payment - using timestamp to ensure uniqueness
 	orderID := time.Now().UnixNano()
 	orderIDStr := strconv.FormatInt(orderID, 10)
-	currency := "KZT"
-
-	// Generate token using shared token generation function
-	token := paymenttoken.GenerateToken(
-		totalCents,
-		currency,
-		orderIDStr,
-		s.config.PaymentProvider.MerchantPassword,
-		s.config.PaymentProvider.MerchantID,
-	)
 
 	// 3. Create payment in PaymentGateway
 	paymentReq := paymentgateway.PaymentInitRequestDto{
-		Amount:      float64(totalCents),
-		OrderId:     orderIDStr,
-		TeamSlug:    s.config.PaymentProvider.MerchantID,
-		Token:       token,
-		SuccessURL:  stringPtr(s.config.API.Addr + "/api/payments/success?orderId=" + orderIDStr),
-		FailURL:     stringPtr(s.config.API.Addr + "/api/payments/fail?orderId=" + orderIDStr),
-		Currency:    stringPtr(currency),
-		Description: stringPtr("Payment for booking " + strconv.FormatInt(req.BookingId, 10)),
+		Amount:          float64(totalCents),
+		OrderId:         orderIDStr,
+		TeamSlug:        s.config.PaymentProvider.MerchantID,
+		SuccessURL:      stringPtr(s.config.API.Addr + "/api/payments/success?orderId=" + orderIDStr),
+		FailURL:         stringPtr(s.config.API.Addr + "/api/payments/fail?orderId=" + orderIDStr),
+		NotificationURL: stringPtr(s.config.API.Addr + "/api/payments/notifications"),
+		Currency:        stringPtr(currency),
+		Description:     stringPtr("Payment for booking " + strconv.FormatInt(req.BookingId, 10)),
+	}
+
+	// Двухстадийный платеж только блокирует деньги, списание после подтверждения заказа провайдером
+	if s.config.PaymentProvider.TwoStage {
+		paymentReq.PayType = stringPtr("T")
+	}
+
+	// Все скалярные поля запроса подписываются, поэтому токен считается последним
+	paymentReq.Token, err = paymenttoken.NewSigner(s.config.PaymentProvider.MerchantPassword).Sign(paymentReq)
+	if err != nil {
+		fmt.Printf("ERROR: failed to sign payment init: %v\n", err)
+		http.Error(w, "Failed to initialize payment", http.StatusInternalServerError)
+		return
 	}
 
 	pr, _ := json.Marshal(paymentReq)
@@ -399,14 +671,18 @@ func (s *HttpServer) InitiatePayment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 4. Create booking_payments record
+	createdAt := time.Now().UTC()
 	err = qtx.InsertBookingPayment(r.Context(), sqlc.InsertBookingPaymentParams{
-		BookingID: req.BookingId,
-		OrderID:   orderIDStr,
-		PaymentID: *paymentResp.PaymentId, // Save the PaymentID from gateway response
-		Status:    stringPtr("INIT"),
-		Amount:    totalCents,                          // Save amount for token generation
-		Currency:  currency,                            // Save currency for token generation
-		TeamSlug:  s.config.PaymentProvider.MerchantID, // Save team slug for token generation
+		BookingID:  req.BookingId,
+		OrderID:    orderIDStr,
+		PaymentID:  *paymentResp.PaymentId, // Save the PaymentID from gateway response
+		Status:     stringPtr("INIT"),
+		Amount:     totalCents,                          // Save amount for token generation
+		Currency:   currency,                            // Save currency for token generation
+		TeamSlug:   s.config.PaymentProvider.MerchantID, // Save team slug for token generation
+		PaymentUrl: paymentResp.PaymentURL,
+		CreatedAt:  &createdAt,
+		TwoStage:   s.config.PaymentProvider.TwoStage,
 	})
 	if err != nil {
 		fmt.Printf("ERROR: failed to insert booking payment: %v\n", err)
@@ -415,12 +691,31 @@ func (s *HttpServer) InitiatePayment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 5. Update booking status to PAYMENT_INITIATED
-	err = qtx.UpdateBookingStatus(r.Context(), sqlc.UpdateBookingStatusParams{
-		Status:    "PAYMENT_INITIATED",
+	err = service.TransitionBooking(
+		r.Context(),
+		qtx,
+		booking,
+		domain.BookingStatusPaymentInitiated,
+		domain.UserActor(session.UserID),
+		"payment initiated, order "+orderIDStr,
+	)
+	if err != nil {
+		fmt.Printf("ERROR: failed to update booking status: %v\n", err)
+		http.Error(w, "Failed to update booking status", http.StatusInternalServerError)
+		return
+	}
+
+	// 6. Give the user PaymentTTL to complete the payment
+	expiresAt := time.Now().UTC().Add(s.config.Booking.PaymentTTL)
+	if !firstAttempt && booking.ExpiresAt != nil {
+		expiresAt = *booking.ExpiresAt
+	}
+	err = qtx.UpdateBookingExpiresAt(r.Context(), sqlc.UpdateBookingExpiresAtParams{
+		ExpiresAt: &expiresAt,
 		BookingID: req.BookingId,
 	})
 	if err != nil {
-		fmt.Printf("ERROR: failed to update booking status: %v\n", err)
+		fmt.Printf("ERROR: failed to update booking expires_at: %v\n", err)
 		http.Error(w, "Failed to update booking status", http.StatusInternalServerError)
 		return
 	}
@@ -431,123 +726,268 @@ func (s *HttpServer) InitiatePayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 6. Return 302 redirect with payment URL
+	// 7. Cancel the booking if the payment is not completed in time
+	if _, err = s.riverClient.Insert(r.Context(), portriver.ExpireBookingArgs{
+		BookingID: req.BookingId,
+		StatusEq:  domain.BookingStatusPaymentInitiated,
+	}, &river.InsertOpts{ScheduledAt: expiresAt}); err != nil {
+		fmt.Printf("ERROR: failed to queue ExpireBookingWorker: %v\n", err)
+		// Don't fail the request - payment was already initiated
+	}
+
+	// 8. Return 302 redirect with payment URL
 	w.Header().Set("Location", *paymentResp.PaymentURL)
 	w.WriteHeader(http.StatusFound)
 }
 
-// Получить список событий
-// (GET /api/events)
-func (s *HttpServer) ListEvents(w http.ResponseWriter, r *http.Request, params ListEventsParams) {
-	ctx, tr := telemetry.Global().T().Start(r.Context(), "ListEvents")
-	defer tr.End()
+// abandonPayment gives up the unfinished payment attempt of a PAYMENT_INITIATED booking
+// and moves the booking back to CREATED. The attempt is cancelled at the gateway,
+// so it can no longer be paid. The gateway is called outside of any transaction,
+// the outcome is then recorded in a short one if the attempt is still the latest and unfinished.
+func (s *HttpServer) abandonPayment(ctx context.Context, booking sqlc.Booking, userID int64) (int, string) {
+	if booking.ExpiresAt == nil || !booking.ExpiresAt.After(time.Now().UTC()) {
+		return http.StatusConflict, "Seat hold expired"
+	}
 
-	tr.AddEvent("ListEvents called", trace.WithAttributes(
-		attribute.String("http.method", r.Method),
-		attribute.String("http.url", r.URL.String()),
-	))
+	payment, err := s.queries.GetLatestBookingPayment(ctx, booking.ID)
+	if err != nil {
+		fmt.Println("ERROR: s.queries.GetLatestBookingPayment:", err)
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
 
-	page := int64(1)
-	pageSize := int64(10)
+	if domain.IsPaymentSettled(payment.Status) {
+		return http.StatusConflict, "Payment is already completed"
+	}
 
-	if params.Page != nil && *params.Page > 0 {
-		page = int64(*params.Page)
+	// Пользователь мог успеть оплатить - такую попытку нельзя бросать
+	status := domain.PaymentStatusAbandoned
+	check, err := service.CheckPayment(ctx, s.paymentGateway, payment, s.config.PaymentProvider.MerchantPassword)
+	switch {
+	case errors.Is(err, service.ErrPaymentNotFoundAtGateway):
+		status = domain.PaymentStatusFail
+	case err != nil:
+		fmt.Println("ERROR: service.CheckPayment:", err)
+		return http.StatusBadGateway, "Failed to check payment with payment gateway"
+	default:
+		switch domain.GatewayPaymentOutcome(check.Status) {
+		case domain.PaymentStatusSuccess, domain.PaymentStatusAuthorized:
+			return http.StatusConflict, "Payment is already completed"
+		case domain.PaymentStatusFail:
+			status = domain.PaymentStatusFail
+		default:
+			if err := service.CancelPayment(ctx, s.paymentGateway, payment, s.config.PaymentProvider.MerchantPassword); err != nil {
+				fmt.Println("ERROR: service.CancelPayment:", err)
+				return http.StatusBadGateway, "Failed to cancel previous payment"
+			}
+		}
 	}
-	if params.PageSize != nil && *params.PageSize > 0 && *params.PageSize <= 20 {
-		pageSize = int64(*params.PageSize)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return http.StatusInternalServerError, "Could not start transaction"
 	}
+	defer tx.Rollback()
 
-	offset := (page - 1) * pageSize
+	qtx := s.queries.WithTx(tx)
 
-	var dateStr *string
-	if params.Date != nil {
-		dateString := params.Date.String()
-		dateStr = &dateString
+	// Условный переход первым берет блокировку записи. Бронь могли оплатить или отменить, пока шли запросы к шлюзу
+	err = service.TransitionBooking(
+		ctx,
+		qtx,
+		booking,
+		domain.BookingStatusCreated,
+		domain.UserActor(userID),
+		"payment abandoned, order "+payment.OrderID,
+	)
+	if err != nil {
+		fmt.Println("ERROR: service.TransitionBooking:", err)
+		return http.StatusConflict, "Booking status changed"
 	}
 
-	events, err := s.queries.GetEventsList(ctx, sqlc.GetEventsListParams{
-		Query:  params.Query,
-		Date:   dateStr,
-		Offset: offset,
-		Limit:  pageSize,
-	})
+	// Попытку могли завершить уведомлением или сверкой, а пользователь мог начать новую
+	latest, err := qtx.GetLatestBookingPayment(ctx, booking.ID)
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		fmt.Println("ERROR: qtx.GetLatestBookingPayment:", err)
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
+	if latest.ID != payment.ID || domain.IsPaymentSettled(latest.Status) {
+		return http.StatusConflict, "Payment status changed"
 	}
 
-	response := make(ListEventsResponse, 0, len(events))
-	for _, event := range events {
-		eventItem := ListEventsResponseItem{
-			Id: event.ID,
-		}
-		if event.Title != nil {
-			eventItem.Title = *event.Title
-		}
-		response = append(response, eventItem)
+	err = qtx.UpdateBookingPaymentStatus(ctx, sqlc.UpdateBookingPaymentStatusParams{
+		Status:  &status,
+		OrderID: payment.OrderID,
+	})
+	if err != nil {
+		fmt.Println("ERROR: qtx.UpdateBookingPaymentStatus:", err)
+		return http.StatusInternalServerError, "Internal Server Error"
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err = tx.Commit(); err != nil {
+		return http.StatusInternalServerError, "Could not commit transaction"
+	}
+
+	// Задача истечения, поставленная при оплате, ждет PAYMENT_INITIATED.
+	// Бронь снова в CREATED: если новая попытка не начнется, места освободятся в срок
+	if _, err = s.riverClient.Insert(ctx, portriver.ExpireBookingArgs{
+		BookingID: booking.ID,
+		StatusEq:  domain.BookingStatusCreated,
+	}, &river.InsertOpts{ScheduledAt: *booking.ExpiresAt}); err != nil {
+		fmt.Printf("ERROR: failed to queue ExpireBookingWorker: %v\n", err)
+	}
+
+	return http.StatusOK, ""
+}
+
+// Получить бронирование
+// (GET /api/bookings/{id})
+func (s *HttpServer) GetBooking(w http.ResponseWriter, r *http.Request, id int64) {
+	session, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		fmt.Println("ERROR: middleware.GetUserFromContext: false")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-}
 
-// Уведомить сервис, что платеж неуспешно проведен
-// (GET /api/payments/fail)
-func (s *HttpServer) NotifyPaymentFailed(w http.ResponseWriter, r *http.Request, params NotifyPaymentFailedParams) {
-	tx, err := s.db.BeginTx(r.Context(), nil)
+	// 1. Booking with its latest payment and EventProvider order
+	booking, err := s.queries.GetBookingDetails(r.Context(), sqlc.GetBookingDetailsParams{
+		BookingID: id,
+		UserID:    session.UserID,
+	})
 	if err != nil {
-		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Booking not found", http.StatusNotFound)
+			return
+		}
+		fmt.Println("ERROR: s.queries.GetBookingDetails:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	defer tx.Rollback()
-
-	qtx := s.queries.WithTx(tx)
 
-	// 1. Update booking_payments status to FAIL
-	err = qtx.UpdateBookingPaymentStatus(r.Context(), sqlc.UpdateBookingPaymentStatusParams{
-		Status:  stringPtr("FAIL"),
-		OrderID: fmt.Sprintf("%d", params.OrderId),
-	})
+	// 2. Seats with row, number and price
+	seats, err := s.queries.GetBookingDetailsSeats(r.Context(), booking.ID)
 	if err != nil {
-		fmt.Printf("ERROR: failed to update payment status: %v\n", err)
-		http.Error(w, "Failed to update payment status", http.StatusInternalServerError)
+		fmt.Println("ERROR: s.queries.GetBookingDetailsSeats:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	// 2. Get booking by payment order ID
-	booking, err := qtx.GetBookingByPaymentOrderID(r.Context(), fmt.Sprintf("%d", params.OrderId))
+	// 3. Total the same way InitiatePayment charges it
+	total, currency, err := bookingTotal(r.Context(), s.queries, booking.ID)
 	if err != nil {
-		fmt.Printf("ERROR: failed to get booking by payment order ID: %v\n", err)
-		http.Error(w, "Booking not found", http.StatusNotFound)
+		fmt.Println("ERROR: bookingTotal:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	// 3. Update booking status to CANCELLED
-	err = qtx.UpdateBookingStatus(r.Context(), sqlc.UpdateBookingStatusParams{
-		Status:    "CANCELLED",
-		BookingID: booking.ID,
+	response := GetBookingResponse{
+		Id:        booking.ID,
+		EventId:   booking.EventID,
+		Status:    string(booking.Status),
+		ExpiresAt: booking.ExpiresAt,
+		Seats:     make([]GetBookingResponseSeat, 0, len(seats)),
+		Total:     total.String(),
+		Currency:  currency,
+	}
+	for _, seat := range seats {
+		response.Seats = append(response.Seats, GetBookingResponseSeat{
+			Id:       seat.ID,
+			Row:      seat.Row,
+			Number:   seat.Number,
+			Price:    seat.Price.String(),
+			Currency: seat.Currency,
+		})
+	}
+	if booking.PaymentOrderID != nil {
+		payment := &GetBookingResponsePayment{
+			OrderId:    *booking.PaymentOrderID,
+			PaymentUrl: booking.PaymentUrl,
+		}
+		if booking.PaymentStatus != nil {
+			payment.Status = *booking.PaymentStatus
+		}
+		response.Payment = payment
+	}
+	if booking.OrderStatus != nil {
+		response.Order = &GetBookingResponseOrder{
+			Status: *booking.OrderStatus,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Получить список событий
+// (GET /api/events)
+func (s *HttpServer) ListEvents(w http.ResponseWriter, r *http.Request, params ListEventsParams) {
+	ctx, tr := telemetry.Global().T().Start(r.Context(), "ListEvents")
+	defer tr.End()
+
+	tr.AddEvent("ListEvents called", trace.WithAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.url", r.URL.String()),
+	))
+
+	page := int64(1)
+	pageSize := int64(10)
+
+	if params.Page != nil && *params.Page > 0 {
+		page = int64(*params.Page)
+	}
+	if params.PageSize != nil && *params.PageSize > 0 && *params.PageSize <= 20 {
+		pageSize = int64(*params.PageSize)
+	}
+
+	offset := (page - 1) * pageSize
+
+	var dateStr *string
+	if params.Date != nil {
+		dateString := params.Date.String()
+		dateStr = &dateString
+	}
+
+	events, err := s.queries.GetEventsList(ctx, sqlc.GetEventsListParams{
+		Query:  params.Query,
+		Date:   dateStr,
+		Offset: offset,
+		Limit:  pageSize,
 	})
 	if err != nil {
-		fmt.Printf("ERROR: failed to update booking status: %v\n", err)
-		http.Error(w, "Failed to update booking status", http.StatusInternalServerError)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	// Commit database changes first
-	if err = tx.Commit(); err != nil {
-		http.Error(w, "Could not commit transaction", http.StatusInternalServerError)
+	response := make(ListEventsResponse, 0, len(events))
+	for _, event := range events {
+		eventItem := ListEventsResponseItem{
+			Id: event.ID,
+		}
+		if event.Title != nil {
+			eventItem.Title = *event.Title
+		}
+		response = append(response, eventItem)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+}
 
-	// 4. Queue CancelBookingProvider to handle EventProvider cancellation and seat release
-	if _, err = s.riverClient.Insert(r.Context(), portriver.CancelBookingArgs{
-		BookingID: booking.ID,
-	}, nil); err != nil {
-		fmt.Printf("ERROR: failed to queue CancelBookingWorker: %v\n", err)
-		// Don't fail the request - payment failure was already processed successfully
+// Уведомить сервис, что платеж неуспешно проведен
+// (GET /api/payments/fail)
+func (s *HttpServer) NotifyPaymentFailed(w http.ResponseWriter, r *http.Request, params NotifyPaymentFailedParams) {
+	err := s.paymentSaga.Fail(r.Context(), s.riverClient, strconv.FormatInt(params.OrderId, 10))
+	if err != nil {
+		fmt.Println("ERROR: s.paymentSaga.Fail:", err)
+		status, message := paymentSagaResponse(err)
+		http.Error(w, message, status)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -556,69 +996,143 @@ func (s *HttpServer) NotifyPaymentFailed(w http.ResponseWriter, r *http.Request,
 // Принимать уведомления от платежного шлюза
 // (POST /api/payments/notifications)
 func (s *HttpServer) OnPaymentUpdates(w http.ResponseWriter, r *http.Request) {
-	b, err := io.ReadAll(r.Body)
-	slog.Info("payment notification", "err", err, "body", string(b))
-}
-
-// Уведомить сервис, что платеж успешно проведен
-// (GET /api/payments/success)
-func (s *HttpServer) NotifyPaymentCompleted(w http.ResponseWriter, r *http.Request, params NotifyPaymentCompletedParams) {
-	tx, err := s.db.BeginTx(r.Context(), nil)
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+		fmt.Printf("ERROR: failed to read payment notification: %v\n", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	defer tx.Rollback()
 
-	qtx := s.queries.WithTx(tx)
+	var notification PaymentNotificationPayload
+	decodeErr := json.Unmarshal(body, &notification)
 
-	// 1. Update booking_payments status to SUCCESS
-	err = qtx.UpdateBookingPaymentStatus(r.Context(), sqlc.UpdateBookingPaymentStatusParams{
-		Status:  stringPtr("SUCCESS"),
-		OrderID: fmt.Sprintf("%d", params.OrderId),
-	})
-	if err != nil {
-		fmt.Printf("ERROR: failed to update payment status: %v\n", err)
-		http.Error(w, "Failed to update payment status", http.StatusInternalServerError)
+	// 1. Verify the token over the raw body: every root-level field the gateway sent is signed
+	verified := false
+	if decodeErr == nil &&
+		notification.OrderId != nil &&
+		notification.TeamSlug != nil &&
+		*notification.TeamSlug == s.config.PaymentProvider.MerchantID {
+		verified, err = paymenttoken.NewSigner(s.config.PaymentProvider.MerchantPassword).VerifyJSON(body)
+		if err != nil {
+			fmt.Printf("ERROR: failed to verify payment notification: %v\n", err)
+		}
+	}
+
+	// 2. Store the raw notification for auditing, whatever comes next
+	if err := s.queries.InsertPaymentNotification(r.Context(), sqlc.InsertPaymentNotificationParams{
+		OrderID:    notification.OrderId,
+		PaymentID:  notification.PaymentId,
+		Status:     notification.Status,
+		Body:       string(body),
+		Verified:   verified,
+		ReceivedAt: time.Now().UTC(),
+	}); err != nil {
+		fmt.Printf("ERROR: failed to store payment notification: %v\n", err)
+		http.Error(w, "Failed to store payment notification", http.StatusInternalServerError)
 		return
 	}
 
-	// 2. Get booking by payment order ID
-	booking, err := qtx.GetBookingByPaymentOrderID(r.Context(), fmt.Sprintf("%d", params.OrderId))
-	if err != nil {
-		fmt.Printf("ERROR: failed to get booking by payment order ID: %v\n", err)
-		http.Error(w, "Booking not found", http.StatusNotFound)
+	if decodeErr != nil {
+		fmt.Printf("ERROR: failed to decode payment notification: %v\n", decodeErr)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 
-	// 3. Update booking status to CONFIRMED
-	err = qtx.UpdateBookingStatus(r.Context(), sqlc.UpdateBookingStatusParams{
-		Status:    "CONFIRMED",
-		BookingID: booking.ID,
-	})
+	if !verified {
+		fmt.Printf("ERROR: payment notification with invalid token, order %s\n", stringValue(notification.OrderId))
+		http.Error(w, "Invalid token", http.StatusForbidden)
+		return
+	}
+
+	// 3. Match the notification to our payment
+	payment, err := s.queries.GetBookingPaymentByOrderID(r.Context(), *notification.OrderId)
 	if err != nil {
-		fmt.Printf("ERROR: failed to update booking status: %v\n", err)
-		http.Error(w, "Failed to update booking status", http.StatusInternalServerError)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+		fmt.Printf("ERROR: failed to get booking payment: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	// Commit database changes first
-	if err = tx.Commit(); err != nil {
-		http.Error(w, "Could not commit transaction", http.StatusInternalServerError)
+	if notification.PaymentId != nil && *notification.PaymentId != payment.PaymentID {
+		fmt.Printf("ERROR: payment notification for order %s has payment ID %s, expected %s\n", payment.OrderID, *notification.PaymentId, payment.PaymentID)
+		http.Error(w, "Payment ID does not match order", http.StatusBadRequest)
 		return
 	}
 
-	// 4. Trigger ConfirmBookingWorker to handle EventProvider confirmation and seat updates
-	if _, err = s.riverClient.Insert(r.Context(), portriver.SelectSeatsArgs{
-		BookingID: booking.ID,
-	}, nil); err != nil {
-		fmt.Printf("ERROR: failed to queue ConfirmBookingWorker: %v\n", err)
-		// Don't fail the request - payment was already processed successfully
+	// 4. Run the same saga as the success/fail redirects
+	switch domain.GatewayPaymentOutcome(stringValue(notification.Status)) {
+	case domain.PaymentStatusSuccess, domain.PaymentStatusAuthorized:
+		err = s.paymentSaga.Complete(r.Context(), s.riverClient, payment.OrderID)
+	case domain.PaymentStatusFail:
+		err = s.paymentSaga.Fail(r.Context(), s.riverClient, payment.OrderID)
+	case domain.PaymentStatusRefunded:
+		// Возвраты инициирует сервис, уведомление только подтверждает итоговый статус
+		err = s.queries.UpdateBookingPaymentStatus(r.Context(), sqlc.UpdateBookingPaymentStatusParams{
+			Status:  stringPtr(domain.PaymentStatusRefunded),
+			OrderID: payment.OrderID,
+		})
+	default:
+		// Промежуточные статусы (NEW, FORM_SHOWED, AUTHORIZING...) ничего не меняют
+	}
+
+	if err != nil {
+		fmt.Println("ERROR: payment notification for order "+payment.OrderID+":", err)
+		status, message := paymentSagaResponse(err)
+		http.Error(w, message, status)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Уведомить сервис, что платеж успешно проведен
+// (GET /api/payments/success)
+func (s *HttpServer) NotifyPaymentCompleted(w http.ResponseWriter, r *http.Request, params NotifyPaymentCompletedParams) {
+	err := s.paymentSaga.Complete(r.Context(), s.riverClient, strconv.FormatInt(params.OrderId, 10))
+	if err != nil {
+		fmt.Println("ERROR: s.paymentSaga.Complete:", err)
+		status, message := paymentSagaResponse(err)
+		http.Error(w, message, status)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// paymentSagaResponse maps a PaymentSaga error onto the HTTP status and message to respond with.
+func paymentSagaResponse(err error) (int, string) {
+	switch {
+	case err == nil:
+		return http.StatusOK, ""
+	case errors.Is(err, portriver.ErrPaymentNotFound):
+		return http.StatusNotFound, "Booking not found"
+	case errors.Is(err, portriver.ErrPaymentCheckFailed):
+		return http.StatusBadGateway, "Failed to verify payment"
+	case errors.Is(err, portriver.ErrPaymentNotCompleted):
+		return http.StatusConflict, "Payment is not completed"
+	case errors.Is(err, portriver.ErrPaymentNeedsReview):
+		return http.StatusConflict, "Payment requires review"
+	case errors.Is(err, portriver.ErrBookingNotConfirmed):
+		return http.StatusConflict, "Booking cannot be confirmed"
+	case errors.Is(err, service.ErrBookingStatusChanged):
+		return http.StatusConflict, "Booking status changed, retry later"
+	case errors.Is(err, domain.ErrInvalidBookingTransition):
+		return http.StatusConflict, "Booking cannot be cancelled"
+	default:
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
 func stringPtr(s string) *string {
 	return &s
 }
@@ -665,11 +1179,12 @@ func (s *HttpServer) ListSeats(w http.ResponseWriter, r *http.Request, params Li
 	response := make(ListSeatsResponse, 0, len(seats))
 	for _, seat := range seats {
 		seatItem := ListSeatsResponseItem{
-			Id:     seat.ID,
-			Number: seat.Number,
-			Price:  seat.Price,
-			Row:    seat.Row,
-			Status: ListSeatsResponseItemStatus(seat.Status),
+			Id:       seat.ID,
+			Number:   seat.Number,
+			Price:    seat.Price.String(),
+			Currency: seat.Currency,
+			Row:      seat.Row,
+			Status:   ListSeatsResponseItemStatus(seat.Status),
 		}
 		response = append(response, seatItem)
 	}
@@ -696,6 +1211,18 @@ func (s *HttpServer) ReleaseSeat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Место неоплаченной брони сначала освобождается в заказе провайдера
+	if bookingSeat, err := s.queries.GetUserBookingSeat(r.Context(), sqlc.GetUserBookingSeatParams{
+		SeatID: req.SeatId,
+		UserID: session.UserID,
+	}); err == nil {
+		status, message := s.releaseSeatPlaces(r.Context(), session.UserID, bookingSeat.BookingID, []int64{req.SeatId})
+		if status != http.StatusOK {
+			http.Error(w, message, status)
+			return
+		}
+	}
+
 	tx, err := s.db.BeginTx(r.Context(), nil)
 	if err != nil {
 		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
@@ -705,26 +1232,56 @@ func (s *HttpServer) ReleaseSeat(w http.ResponseWriter, r *http.Request) {
 
 	qtx := s.queries.WithTx(tx)
 
-	rowsAffected, err := qtx.DeleteBookingSeat(r.Context(), sqlc.DeleteBookingSeatParams{
+	bookingSeat, err := qtx.GetUserBookingSeat(r.Context(), sqlc.GetUserBookingSeatParams{
 		SeatID: req.SeatId,
 		UserID: session.UserID,
 	})
 	if err != nil {
-		http.Error(w, "Could not release seat", 419)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Fobidden", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Could not release seat", http.StatusInternalServerError)
 		return
 	}
 
-	if rowsAffected == 0 {
-		http.Error(w, "Fobidden", http.StatusForbidden)
+	booking, err := qtx.GetBooking(r.Context(), bookingSeat.BookingID)
+	if err != nil {
+		http.Error(w, "Could not release seat", http.StatusInternalServerError)
 		return
 	}
 
-	err = qtx.UpdateSeatStatus(r.Context(), sqlc.UpdateSeatStatusParams{
-		Status: "FREE",
-		SeatID: req.SeatId,
-	})
-	if err != nil {
-		http.Error(w, "Could not update seat status", 419)
+	var refundID int64
+	switch booking.Status {
+	case domain.BookingStatusCreated:
+		// Место в неоплаченной брони освобождается сразу
+		if _, err := qtx.DeleteBookingSeatByBooking(r.Context(), sqlc.DeleteBookingSeatByBookingParams{
+			BookingID: booking.ID,
+			SeatID:    req.SeatId,
+		}); err != nil {
+			http.Error(w, "Could not release seat", http.StatusInternalServerError)
+			return
+		}
+
+		err = qtx.UpdateSeatStatus(r.Context(), sqlc.UpdateSeatStatusParams{
+			Status: "FREE",
+			SeatID: req.SeatId,
+		})
+		if err != nil {
+			http.Error(w, "Could not update seat status", http.StatusInternalServerError)
+			return
+		}
+	case domain.BookingStatusConfirmed:
+		// Место в оплаченной брони освобождается в EventProvider, деньги за него возвращаются
+		var status int
+		var message string
+		refundID, status, message = s.removeConfirmedSeat(r.Context(), qtx, booking, bookingSeat)
+		if status != http.StatusOK {
+			http.Error(w, message, status)
+			return
+		}
+	default:
+		http.Error(w, "Seat can not be released from a booking in status "+string(booking.Status), http.StatusConflict)
 		return
 	}
 
@@ -733,9 +1290,97 @@ func (s *HttpServer) ReleaseSeat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if refundID != 0 {
+		if _, err := s.riverClient.Insert(r.Context(), portriver.RefundSeatArgs{RefundID: refundID}, nil); err != nil {
+			fmt.Println("ERROR: Failed to queue seat refund:", err)
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
 
+// removeConfirmedSeat takes the seat out of a paid booking and records a pending refund
+// for its price. Only seats the event provider has not sold can be removed.
+// The seat stays SOLD until RefundSeatWorker frees it.
+func (s *HttpServer) removeConfirmedSeat(
+	ctx context.Context,
+	qtx *sqlc.Queries,
+	booking sqlc.Booking,
+	bookingSeat sqlc.GetUserBookingSeatRow,
+) (int64, int, string) {
+	if booking.ParentBookingID != nil {
+		return 0, http.StatusConflict, "Seats of a transferred booking can not be released"
+	}
+
+	// Пока провайдер не подтвердил заказ, сага сверяет с ним число мест брони
+	bookingOrder, err := qtx.GetBookingOrder(ctx, booking.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, http.StatusInternalServerError, "Could not get booking order"
+	}
+	if err == sql.ErrNoRows || bookingOrder.Status == nil || *bookingOrder.Status != "CONFIRMED" {
+		return 0, http.StatusConflict, "Tickets are not issued by the event provider yet"
+	}
+
+	// Провайдер освобождает места только в начатом заказе: проданное место из подтвержденного
+	// заказа вернуть нельзя, поэтому бронь не меняется
+	seat, err := qtx.GetSeatByID(ctx, bookingSeat.SeatID)
+	if err != nil {
+		return 0, http.StatusInternalServerError, "Could not get seat"
+	}
+	if seat.ExternalID != nil {
+		return 0, http.StatusConflict, "Tickets issued by the event provider can not be returned"
+	}
+
+	seatIDs, err := qtx.GetBookingSeats(ctx, booking.ID)
+	if err != nil {
+		return 0, http.StatusInternalServerError, "Could not get booking seats"
+	}
+	if len(seatIDs) < 2 {
+		return 0, http.StatusConflict, "Last seat of a booking can not be released, cancel the booking instead"
+	}
+
+	payment, err := qtx.GetPaidBookingPayment(ctx, booking.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, http.StatusConflict, "Payment is not completed"
+		}
+		return 0, http.StatusInternalServerError, "Could not get booking payment"
+	}
+	if payment.Status == nil || *payment.Status != domain.PaymentStatusSuccess {
+		return 0, http.StatusConflict, "Payment is not completed"
+	}
+
+	refunded, err := qtx.GetBookingPaymentRefundedAmount(ctx, payment.ID)
+	if err != nil {
+		return 0, http.StatusInternalServerError, "Could not get refunded amount"
+	}
+	if refunded+int64(bookingSeat.Price) > payment.Amount {
+		return 0, http.StatusConflict, "Refund exceeds the paid amount"
+	}
+
+	if _, err := qtx.DeleteBookingSeatByBooking(ctx, sqlc.DeleteBookingSeatByBookingParams{
+		BookingID: booking.ID,
+		SeatID:    bookingSeat.SeatID,
+	}); err != nil {
+		return 0, http.StatusInternalServerError, "Could not release seat"
+	}
+
+	refundID, err := qtx.InsertBookingRefund(ctx, sqlc.InsertBookingRefundParams{
+		BookingPaymentID: payment.ID,
+		BookingID:        booking.ID,
+		SeatID:           &bookingSeat.SeatID,
+		Amount:           int64(bookingSeat.Price),
+		Currency:         payment.Currency,
+		Status:           domain.RefundStatusPending,
+		CreatedAt:        time.Now(),
+	})
+	if err != nil {
+		return 0, http.StatusInternalServerError, "Could not record refund"
+	}
+
+	return refundID, http.StatusOK, ""
+}
+
 // Выбрать место для брони
 // (PATCH /api/seats/select)
 func (s *HttpServer) SelectSeat(w http.ResponseWriter, r *http.Request) {
@@ -751,6 +1396,14 @@ func (s *HttpServer) SelectSeat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 1. Select the place in the EventProvider order before the seat is claimed
+	held, _, status, message := s.holdSeatPlaces(r.Context(), session.UserID, req.BookingId, []int64{req.SeatId})
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+	defer held.release(r.Context())
+
 	tx, err := s.db.BeginTx(r.Context(), nil)
 	if err != nil {
 		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
@@ -760,22 +1413,46 @@ func (s *HttpServer) SelectSeat(w http.ResponseWriter, r *http.Request) {
 
 	qtx := s.queries.WithTx(tx)
 
+	// 2. Claim the seat: FREE, same event, booking owned by the user and still CREATED
+	claimed, err := qtx.ClaimSeatForBooking(r.Context(), sqlc.ClaimSeatForBookingParams{
+		SeatID:    req.SeatId,
+		BookingID: req.BookingId,
+		UserID:    session.UserID,
+	})
+	if err != nil {
+		fmt.Println("ERROR: qtx.ClaimSeatForBooking:", err)
+		http.Error(w, "Could not select seat", http.StatusInternalServerError)
+		return
+	}
+
+	// 3. Nothing claimed - find out why
+	if claimed == 0 {
+		status, message := selectSeatRejection(r.Context(), qtx, session.UserID, req)
+		http.Error(w, message, status)
+		return
+	}
+
+	// 4. A booking is charged in one currency, the seat must be priced in it
+	if status, message := checkSeatCurrency(r.Context(), qtx, req.BookingId, req.SeatId); status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	// 5. Attach the seat to the booking with its place
 	err = qtx.InsertBookingSeat(r.Context(), sqlc.InsertBookingSeatParams{
 		UserID:    session.UserID,
 		BookingID: req.BookingId,
 		SeatID:    req.SeatId,
 	})
 	if err != nil {
-		http.Error(w, "Could not select seat", 419)
+		fmt.Println("ERROR: qtx.InsertBookingSeat:", err)
+		http.Error(w, "Could not select seat", http.StatusInternalServerError)
 		return
 	}
 
-	err = qtx.UpdateSeatStatus(r.Context(), sqlc.UpdateSeatStatusParams{
-		Status: "RESERVED",
-		SeatID: req.SeatId,
-	})
-	if err != nil {
-		http.Error(w, "Could not update seat status", 419)
+	if err = held.mark(r.Context(), qtx, req.BookingId); err != nil {
+		fmt.Println("ERROR: held.mark:", err)
+		http.Error(w, "Could not select seat", http.StatusInternalServerError)
 		return
 	}
 
@@ -783,31 +1460,1050 @@ func (s *HttpServer) SelectSeat(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Could not commit transaction", http.StatusInternalServerError)
 		return
 	}
+	held.claimed = true
 
 	w.WriteHeader(http.StatusOK)
 }
 
-// Получить аналитику продаж для события
-// (GET /api/analytics)
-func (s *HttpServer) GetEventAnalytics(w http.ResponseWriter, r *http.Request, params GetEventAnalyticsParams) {
-	ctx := r.Context()
-
-	// Validate required parameter
-	if params.Id == 0 {
-		http.Error(w, "Missing required parameter: id", http.StatusBadRequest)
-		return
+// selectSeatRejection explains why ClaimSeatForBooking did not claim the seat.
+func selectSeatRejection(ctx context.Context, qtx *sqlc.Queries, userID int64, req SelectSeatRequest) (int, string) {
+	booking, status, message := getOpenBookingForSeats(ctx, qtx, userID, req.BookingId)
+	if status != http.StatusOK {
+		return status, message
 	}
 
-	eventID := params.Id
-
-	// Get analytics data from database
-	analytics, err := s.queries.GetEventAnalytics(ctx, eventID)
+	seat, err := qtx.GetSeatByID(ctx, req.SeatId)
 	if err != nil {
-		http.Error(w, "Failed to get event analytics", http.StatusInternalServerError)
-		return
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, "Seat not found"
+		}
+		fmt.Println("ERROR: qtx.GetSeatByID:", err)
+		return http.StatusInternalServerError, "Internal Server Error"
 	}
 
-	// Convert *float64 to int64, defaulting to 0 if null
+	if seat.EventID != booking.EventID {
+		return http.StatusUnprocessableEntity, "Seat belongs to another event"
+	}
+
+	return http.StatusConflict, "Seat is not available"
+}
+
+// Выбрать несколько мест для брони
+// (PATCH /api/seats/select/bulk)
+func (s *HttpServer) SelectSeats(w http.ResponseWriter, r *http.Request) {
+	session, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req SelectSeatsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if status, message := validateSeatIDs(req.SeatIds); status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	// 1. Select the places in the EventProvider order before the seats are claimed
+	held, seatID, status, message := s.holdSeatPlaces(r.Context(), session.UserID, req.BookingId, req.SeatIds)
+	if status == http.StatusConflict {
+		writeSeatsBulkResponse(w, status, []SeatConflict{{SeatId: seatID, Reason: "NOT_AVAILABLE"}})
+		return
+	}
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+	defer held.release(r.Context())
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := s.queries.WithTx(tx)
+
+	// 2. Claim every seat: FREE, same event, booking owned by the user and still CREATED.
+	// Условное обновление сразу берет блокировку записи, поэтому параллельный запрос
+	// увидит эти места занятыми
+	claimed := make(map[int64]bool, len(req.SeatIds))
+	for _, seatID := range req.SeatIds {
+		rows, err := qtx.ClaimSeatForBooking(r.Context(), sqlc.ClaimSeatForBookingParams{
+			SeatID:    seatID,
+			BookingID: req.BookingId,
+			UserID:    session.UserID,
+		})
+		if err != nil {
+			slog.Error("failed to claim seat", "seat_id", seatID, "booking_id", req.BookingId, "error", err)
+			http.Error(w, "Could not select seats", http.StatusInternalServerError)
+			return
+		}
+		claimed[seatID] = rows > 0
+	}
+
+	// 3. Booking must belong to the user and still accept seats
+	booking, status, message := getOpenBookingForSeats(r.Context(), qtx, session.UserID, req.BookingId)
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	// 4. Explain the seats that were not claimed and check the currency of the claimed ones
+	seats, err := qtx.GetSeatsByIDs(r.Context(), req.SeatIds)
+	if err != nil {
+		slog.Error("failed to get seats", "booking_id", booking.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	seatsByID := make(map[int64]sqlc.Seat, len(seats))
+	for _, seat := range seats {
+		seatsByID[seat.ID] = seat
+	}
+
+	currency, err := bookingCurrency(r.Context(), qtx, booking)
+	if err != nil {
+		slog.Error("failed to get booking currency", "booking_id", booking.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	conflicts := make([]SeatConflict, 0)
+	for _, seatID := range req.SeatIds {
+		seat, ok := seatsByID[seatID]
+		switch {
+		case !ok:
+			conflicts = append(conflicts, SeatConflict{SeatId: seatID, Reason: "NOT_FOUND"})
+		case seat.EventID != booking.EventID:
+			conflicts = append(conflicts, SeatConflict{SeatId: seatID, Reason: "OTHER_EVENT"})
+		case !claimed[seatID]:
+			conflicts = append(conflicts, SeatConflict{SeatId: seatID, Reason: "NOT_AVAILABLE"})
+		case seat.Currency != currency:
+			conflicts = append(conflicts, SeatConflict{SeatId: seatID, Reason: "OTHER_CURRENCY"})
+		}
+	}
+
+	// Откат транзакции возвращает уже захваченные места
+	if len(conflicts) > 0 {
+		writeSeatsBulkResponse(w, http.StatusConflict, conflicts)
+		return
+	}
+
+	// 5. Attach the seats to the booking with their places
+	for _, seatID := range req.SeatIds {
+		err = qtx.InsertBookingSeat(r.Context(), sqlc.InsertBookingSeatParams{
+			UserID:    session.UserID,
+			BookingID: booking.ID,
+			SeatID:    seatID,
+		})
+		if err != nil {
+			slog.Error("failed to attach seat", "seat_id", seatID, "booking_id", booking.ID, "error", err)
+			http.Error(w, "Could not select seats", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err = held.mark(r.Context(), qtx, booking.ID); err != nil {
+		slog.Error("failed to mark places selected", "booking_id", booking.ID, "error", err)
+		http.Error(w, "Could not select seats", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Could not commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	held.claimed = true
+
+	writeSeatsBulkResponse(w, http.StatusOK, conflicts)
+}
+
+// Подобрать лучшие места подряд
+// (PATCH /api/seats/select/best)
+func (s *HttpServer) SelectBestSeats(w http.ResponseWriter, r *http.Request) {
+	session, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req SelectBestSeatsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if req.Count < 1 || req.Count > maxBestSeats {
+		http.Error(w, fmt.Sprintf("count must be between 1 and %d", maxBestSeats), http.StatusBadRequest)
+		return
+	}
+
+	if req.RowFrom != nil && req.RowTo != nil && *req.RowFrom > *req.RowTo {
+		http.Error(w, "row_from must not be greater than row_to", http.StatusBadRequest)
+		return
+	}
+
+	var price *domain.Money
+	if req.Price != nil {
+		parsed, err := domain.ParseMoney(*req.Price)
+		if err != nil {
+			http.Error(w, "price must be an amount like 40000.00", http.StatusBadRequest)
+			return
+		}
+		price = &parsed
+	}
+
+	// 1. Booking must belong to the user and still accept seats
+	booking, status, message := getOpenBookingForSeats(r.Context(), s.queries, session.UserID, req.BookingId)
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	// 2. Find the cheapest block of adjacent FREE seats priced in the booking currency
+	currency, err := bookingCurrency(r.Context(), s.queries, booking)
+	if err != nil {
+		fmt.Println("ERROR: bookingCurrency:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	seats, err := s.queries.FindBestAvailableSeats(r.Context(), sqlc.FindBestAvailableSeatsParams{
+		EventID:  booking.EventID,
+		Currency: currency,
+		Price:    price,
+		RowFrom:  req.RowFrom,
+		RowTo:    req.RowTo,
+		Count:    req.Count,
+	})
+	if err != nil {
+		fmt.Println("ERROR: s.queries.FindBestAvailableSeats:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if int64(len(seats)) < req.Count {
+		http.Error(w, "Not enough adjacent free seats", http.StatusConflict)
+		return
+	}
+
+	seatIDs := make([]int64, 0, len(seats))
+	for _, seat := range seats {
+		seatIDs = append(seatIDs, seat.ID)
+	}
+
+	// 3. Select the places in the EventProvider order before the seats are claimed
+	held, _, status, message := s.holdSeatPlaces(r.Context(), session.UserID, booking.ID, seatIDs)
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+	defer held.release(r.Context())
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := s.queries.WithTx(tx)
+
+	// 4. Reserve only if all of them are still FREE
+	reserved, err := qtx.ReserveFreeSeatsByIDs(r.Context(), seatIDs)
+	if err != nil {
+		fmt.Println("ERROR: qtx.ReserveFreeSeatsByIDs:", err)
+		http.Error(w, "Could not update seat status", http.StatusInternalServerError)
+		return
+	}
+
+	if reserved != int64(len(seatIDs)) {
+		http.Error(w, "Seats were taken concurrently, retry", http.StatusConflict)
+		return
+	}
+
+	// Бронь могли закрыть, пока выбирались места
+	if _, status, message := getOpenBookingForSeats(r.Context(), qtx, session.UserID, booking.ID); status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	// 5. Attach the seats to the booking with their places
+	response := SelectBestSeatsResponse{
+		Seats: make([]GetBookingResponseSeat, 0, len(seats)),
+	}
+	for _, seat := range seats {
+		err = qtx.InsertBookingSeat(r.Context(), sqlc.InsertBookingSeatParams{
+			UserID:    session.UserID,
+			BookingID: booking.ID,
+			SeatID:    seat.ID,
+		})
+		if err != nil {
+			fmt.Println("ERROR: qtx.InsertBookingSeat:", err)
+			http.Error(w, "Could not select seats", http.StatusInternalServerError)
+			return
+		}
+
+		response.Seats = append(response.Seats, GetBookingResponseSeat{
+			Id:       seat.ID,
+			Row:      seat.Row,
+			Number:   seat.Number,
+			Price:    seat.Price.String(),
+			Currency: seat.Currency,
+		})
+	}
+
+	if err = held.mark(r.Context(), qtx, booking.ID); err != nil {
+		fmt.Println("ERROR: held.mark:", err)
+		http.Error(w, "Could not select seats", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Could not commit transaction", http.StatusInternalServerError)
+		return
+	}
+	held.claimed = true
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Убрать несколько мест из брони
+// (PATCH /api/seats/release/bulk)
+func (s *HttpServer) ReleaseSeats(w http.ResponseWriter, r *http.Request) {
+	session, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req ReleaseSeatsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if status, message := validateSeatIDs(req.SeatIds); status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	// Места неоплаченной брони сначала освобождаются в заказе провайдера
+	if status, message := s.releaseSeatPlaces(r.Context(), session.UserID, req.BookingId, req.SeatIds); status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := s.queries.WithTx(tx)
+
+	// 1. Booking must belong to the user and still accept changes
+	booking, status, message := getOpenBookingForSeats(r.Context(), qtx, session.UserID, req.BookingId)
+	if status != http.StatusOK {
+		http.Error(w, message, status)
+		return
+	}
+
+	// 2. Every seat must be part of the booking
+	bookingSeatIDs, err := qtx.GetBookingSeats(r.Context(), booking.ID)
+	if err != nil {
+		fmt.Println("ERROR: qtx.GetBookingSeats:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	inBooking := make(map[int64]bool, len(bookingSeatIDs))
+	for _, seatID := range bookingSeatIDs {
+		inBooking[seatID] = true
+	}
+
+	conflicts := make([]SeatConflict, 0)
+	for _, seatID := range req.SeatIds {
+		if !inBooking[seatID] {
+			conflicts = append(conflicts, SeatConflict{SeatId: seatID, Reason: "NOT_IN_BOOKING"})
+		}
+	}
+
+	if len(conflicts) > 0 {
+		writeSeatsBulkResponse(w, http.StatusConflict, conflicts)
+		return
+	}
+
+	// 3. Detach the seats and make them FREE
+	if _, err = qtx.DeleteBookingSeatsByIDs(r.Context(), sqlc.DeleteBookingSeatsByIDsParams{
+		BookingID: booking.ID,
+		SeatIds:   req.SeatIds,
+	}); err != nil {
+		fmt.Println("ERROR: qtx.DeleteBookingSeatsByIDs:", err)
+		http.Error(w, "Could not release seats", http.StatusInternalServerError)
+		return
+	}
+
+	err = qtx.UpdateSeatsStatusByIDs(r.Context(), sqlc.UpdateSeatsStatusByIDsParams{
+		Status:  "FREE",
+		SeatIds: req.SeatIds,
+	})
+	if err != nil {
+		slog.Error("failed to free seats", "booking_id", req.BookingId, "error", err)
+		http.Error(w, "Could not update seat status", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Could not commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	writeSeatsBulkResponse(w, http.StatusOK, conflicts)
+}
+
+// validateSeatIDs rejects empty lists and repeated seat ids in bulk requests.
+func validateSeatIDs(seatIDs []int64) (int, string) {
+	if len(seatIDs) == 0 {
+		return http.StatusBadRequest, "seat_ids must not be empty"
+	}
+
+	seen := make(map[int64]bool, len(seatIDs))
+	for _, seatID := range seatIDs {
+		if seen[seatID] {
+			return http.StatusBadRequest, fmt.Sprintf("seat %d is listed more than once", seatID)
+		}
+		seen[seatID] = true
+	}
+
+	return http.StatusOK, ""
+}
+
+// getOpenBookingForSeats returns the user's booking if seats can still be added or removed.
+func getOpenBookingForSeats(ctx context.Context, qtx *sqlc.Queries, userID int64, bookingID int64) (sqlc.Booking, int, string) {
+	booking, err := qtx.GetBooking(ctx, bookingID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return booking, http.StatusNotFound, "Booking not found"
+		}
+		fmt.Println("ERROR: qtx.GetBooking:", err)
+		return booking, http.StatusInternalServerError, "Internal Server Error"
+	}
+
+	if booking.UserID != userID {
+		return booking, http.StatusNotFound, "Booking not found"
+	}
+
+	if booking.Status != domain.BookingStatusCreated {
+		return booking, http.StatusConflict, "Booking is not open for seat selection"
+	}
+
+	return booking, http.StatusOK, ""
+}
+
+var errMixedCurrencies = errors.New("booking seats are priced in different currencies")
+
+// bookingTotal sums the seats of the booking. A booking is charged in a single currency,
+// seats priced in several currencies are reported as errMixedCurrencies.
+func bookingTotal(ctx context.Context, qtx *sqlc.Queries, bookingID int64) (domain.Money, string, error) {
+	totals, err := qtx.GetBookingTotals(ctx, bookingID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	switch len(totals) {
+	case 0:
+		return 0, "", nil
+	case 1:
+		return domain.Money(totals[0].Total), totals[0].Currency, nil
+	default:
+		return 0, "", errMixedCurrencies
+	}
+}
+
+// bookingCurrency returns the currency of the seats already in the booking,
+// for a booking without seats - the currency of its event.
+func bookingCurrency(ctx context.Context, qtx *sqlc.Queries, booking sqlc.Booking) (string, error) {
+	_, currency, err := bookingTotal(ctx, qtx, booking.ID)
+	if err != nil || currency != "" {
+		return currency, err
+	}
+
+	event, err := qtx.GetEvent(ctx, booking.EventID)
+	if err != nil {
+		return "", err
+	}
+
+	return event.Currency, nil
+}
+
+// checkSeatCurrency refuses a seat priced in another currency than the booking.
+func checkSeatCurrency(ctx context.Context, qtx *sqlc.Queries, bookingID int64, seatID int64) (int, string) {
+	booking, err := qtx.GetBooking(ctx, bookingID)
+	if err != nil {
+		fmt.Println("ERROR: qtx.GetBooking:", err)
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
+
+	seat, err := qtx.GetSeatByID(ctx, seatID)
+	if err != nil {
+		fmt.Println("ERROR: qtx.GetSeatByID:", err)
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
+
+	currency, err := bookingCurrency(ctx, qtx, booking)
+	if err != nil {
+		fmt.Println("ERROR: bookingCurrency:", err)
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
+
+	if seat.Currency != currency {
+		return http.StatusConflict, fmt.Sprintf("Seat is priced in %s, booking in %s", seat.Currency, currency)
+	}
+
+	return http.StatusOK, ""
+}
+
+// reservingRoute returns the provider of the event if it selects places as they are added to a booking, nil otherwise.
+func (s *HttpServer) reservingRoute(ctx context.Context, eventID int64) (*ticketing.Route, error) {
+	if !s.ticketProviders.ReserveOnSelect() {
+		return nil, nil
+	}
+
+	route, err := s.ticketProviders.ForEvent(ctx, eventID)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, ticketing.ErrUnknownProvider) {
+		// Несуществующее событие отклоняется дальше, а места события без провайдера не выбираются
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !route.ReserveOnSelect {
+		return nil, nil
+	}
+
+	return route, nil
+}
+
+// heldPlaces are the provider places selected for seats before the seats are claimed.
+type heldPlaces struct {
+	route *ticketing.Route
+	// Место провайдера по seat_id
+	places map[int64]string
+	// Места захвачены и выбор мест записан в booking_seats
+	claimed bool
+}
+
+// holdSeatPlaces selects the places of the seats in the provider order of the user's booking
+// before the seats are claimed, so a committed claim always has a provider hold. A place taken
+// at the provider makes its seat unavailable. It returns the seat that failed.
+// Seats the claim will reject anyway are skipped.
+func (s *HttpServer) holdSeatPlaces(ctx context.Context, userID int64, bookingID int64, seatIDs []int64) (*heldPlaces, int64, int, string) {
+	held := &heldPlaces{places: make(map[int64]string, len(seatIDs))}
+
+	if !s.ticketProviders.ReserveOnSelect() {
+		return held, 0, http.StatusOK, ""
+	}
+
+	// Чужую или закрытую бронь отклонит захват мест
+	booking, err := s.queries.GetBooking(ctx, bookingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return held, 0, http.StatusOK, ""
+	}
+	if err != nil {
+		fmt.Println("ERROR: s.queries.GetBooking:", err)
+		return nil, 0, http.StatusInternalServerError, "Internal Server Error"
+	}
+	if booking.UserID != userID || booking.Status != domain.BookingStatusCreated {
+		return held, 0, http.StatusOK, ""
+	}
+
+	route, err := s.reservingRoute(ctx, booking.EventID)
+	if err != nil {
+		fmt.Println("ERROR: s.reservingRoute:", err)
+		return nil, 0, http.StatusInternalServerError, "Internal Server Error"
+	}
+	if route == nil {
+		return held, 0, http.StatusOK, ""
+	}
+	held.route = route
+
+	bookingOrder, err := s.queries.GetBookingOrder(ctx, bookingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Бронь создана до включения режима - места выберет SelectSeatsWorker после оплаты
+		return held, 0, http.StatusOK, ""
+	}
+	if err != nil {
+		fmt.Println("ERROR: s.queries.GetBookingOrder:", err)
+		return nil, 0, http.StatusInternalServerError, "Internal Server Error"
+	}
+
+	seats, err := s.queries.GetSeatsByIDs(ctx, seatIDs)
+	if err != nil {
+		fmt.Println("ERROR: s.queries.GetSeatsByIDs:", err)
+		return nil, 0, http.StatusInternalServerError, "Internal Server Error"
+	}
+
+	for _, seat := range seats {
+		if seat.ExternalID == nil || seat.EventID != booking.EventID || seat.Status != "FREE" {
+			continue
+		}
+
+		placeID := *seat.ExternalID
+
+		err := route.SelectPlace(ctx, bookingOrder.OrderID, placeID)
+		if err == nil {
+			held.places[seat.ID] = placeID
+			continue
+		}
+
+		if errors.Is(err, ticketing.ErrPlaceTaken) {
+			held.release(ctx)
+
+			// Место держит другой дистрибьютор: оно недоступно, пока сверка мест не увидит его свободным
+			_, err = s.queries.UpdateUnbookedSeatStatus(ctx, sqlc.UpdateUnbookedSeatStatusParams{
+				Status:   "RESERVED",
+				SeatID:   seat.ID,
+				StatusEq: "FREE",
+			})
+			if err != nil {
+				fmt.Println("ERROR: s.queries.UpdateUnbookedSeatStatus:", err)
+			}
+
+			return nil, seat.ID, http.StatusConflict, "Seat is taken at the event provider"
+		}
+
+		// Ответ мог потеряться уже после выбора места - его тоже нужно освободить
+		held.places[seat.ID] = placeID
+		held.release(ctx)
+
+		fmt.Println("ERROR: route.SelectPlace:", err)
+		return nil, seat.ID, http.StatusBadGateway, "Event provider is unavailable"
+	}
+
+	return held, 0, http.StatusOK, ""
+}
+
+// mark records the held places of the just claimed seats in booking_seats.
+// It must run in the transaction that claims the seats.
+func (h *heldPlaces) mark(ctx context.Context, qtx *sqlc.Queries, bookingID int64) error {
+	selectedAt := time.Now().UTC()
+	for seatID := range h.places {
+		err := qtx.MarkBookingSeatPlaceSelected(ctx, sqlc.MarkBookingSeatPlaceSelectedParams{
+			PlaceSelectedAt: &selectedAt,
+			BookingID:       bookingID,
+			SeatID:          seatID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to mark seat %d selected: %w", seatID, err)
+		}
+	}
+
+	return nil
+}
+
+// release frees the held places unless the seats were claimed.
+func (h *heldPlaces) release(ctx context.Context) {
+	if h.claimed {
+		return
+	}
+
+	// Запрос мог быть отменен клиентом, а место все равно нужно вернуть
+	ctx = context.WithoutCancel(ctx)
+	for _, placeID := range h.places {
+		// Место, не выбранное ни в одном заказе, уже свободно
+		err := h.route.ReleasePlace(ctx, placeID)
+		if err != nil && !errors.Is(err, ticketing.ErrPlaceNotFound) && !errors.Is(err, ticketing.ErrPlaceNotSelected) {
+			fmt.Println("ERROR: route.ReleasePlace:", err)
+		}
+	}
+	h.places = nil
+}
+
+// releaseSeatPlaces releases the places of seats in the user's unpaid booking from its provider
+// order before the seats are detached. Nothing is released unless all the seats are in the booking,
+// the handler rejects such a request anyway. A seat left in the booking is selected again by SelectSeatsWorker.
+func (s *HttpServer) releaseSeatPlaces(ctx context.Context, userID int64, bookingID int64, seatIDs []int64) (int, string) {
+	if !s.ticketProviders.ReserveOnSelect() {
+		return http.StatusOK, ""
+	}
+
+	booking, err := s.queries.GetBooking(ctx, bookingID)
+	if err != nil || booking.UserID != userID || booking.Status != domain.BookingStatusCreated {
+		return http.StatusOK, ""
+	}
+
+	route, err := s.reservingRoute(ctx, booking.EventID)
+	if err != nil {
+		fmt.Println("ERROR: s.reservingRoute:", err)
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
+	if route == nil {
+		return http.StatusOK, ""
+	}
+
+	bookingSeats, err := s.queries.GetBookingSeatPlaces(ctx, bookingID)
+	if err != nil {
+		fmt.Println("ERROR: s.queries.GetBookingSeatPlaces:", err)
+		return http.StatusInternalServerError, "Internal Server Error"
+	}
+
+	release := make(map[int64]bool, len(seatIDs))
+	for _, seatID := range seatIDs {
+		release[seatID] = true
+	}
+
+	seats := make([]sqlc.GetBookingSeatPlacesRow, 0, len(seatIDs))
+	for _, seat := range bookingSeats {
+		if release[seat.SeatID] {
+			seats = append(seats, seat)
+		}
+	}
+	if len(seats) != len(seatIDs) {
+		return http.StatusOK, ""
+	}
+
+	for _, seat := range seats {
+		if seat.ExternalID == nil || seat.PlaceSelectedAt == nil {
+			continue
+		}
+
+		// Место, не выбранное ни в одном заказе, уже свободно
+		err := route.ReleasePlace(ctx, *seat.ExternalID)
+		if err != nil && !errors.Is(err, ticketing.ErrPlaceNotFound) && !errors.Is(err, ticketing.ErrPlaceNotSelected) {
+			fmt.Println("ERROR: route.ReleasePlace:", err)
+			return http.StatusBadGateway, "Event provider is unavailable"
+		}
+
+		err = s.queries.MarkBookingSeatPlaceSelected(ctx, sqlc.MarkBookingSeatPlaceSelectedParams{
+			PlaceSelectedAt: nil,
+			BookingID:       bookingID,
+			SeatID:          seat.SeatID,
+		})
+		if err != nil {
+			fmt.Println("ERROR: s.queries.MarkBookingSeatPlaceSelected:", err)
+			return http.StatusInternalServerError, "Internal Server Error"
+		}
+	}
+
+	return http.StatusOK, ""
+}
+
+func writeSeatsBulkResponse(w http.ResponseWriter, status int, conflicts []SeatConflict) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(SeatsBulkResponse{Conflicts: conflicts}); err != nil {
+		fmt.Println("ERROR: json.Encode:", err)
+	}
+}
+
+// Получить заявки пользователя в листе ожидания
+// (GET /api/waitlist)
+func (s *HttpServer) ListWaitlist(w http.ResponseWriter, r *http.Request) {
+	session, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		fmt.Println("ERROR: middleware.GetUserFromContext: false")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	entries, err := s.queries.GetWaitlistEntriesByUserID(r.Context(), session.UserID)
+	if err != nil {
+		fmt.Println("ERROR: s.queries.GetWaitlistEntriesByUserID:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make(ListWaitlistResponse, 0, len(entries))
+	for _, entry := range entries {
+		item := ListWaitlistResponseItem{
+			Id:             entry.ID,
+			EventId:        entry.EventID,
+			SeatsCount:     entry.SeatsCount,
+			Status:         string(entry.Status),
+			BookingId:      entry.BookingID,
+			OfferExpiresAt: entry.OfferExpiresAt,
+		}
+
+		if entry.Status == domain.WaitlistStatusWaiting {
+			position, err := s.queries.GetWaitlistPosition(r.Context(), sqlc.GetWaitlistPositionParams{
+				EventID: entry.EventID,
+				EntryID: entry.ID,
+			})
+			if err != nil {
+				fmt.Println("ERROR: s.queries.GetWaitlistPosition:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			item.Position = &position
+		}
+
+		response = append(response, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Встать в лист ожидания на событие
+// (POST /api/waitlist)
+func (s *HttpServer) JoinWaitlist(w http.ResponseWriter, r *http.Request) {
+	session, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		fmt.Println("ERROR: middleware.GetUserFromContext: false")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var req JoinWaitlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if req.SeatsCount < 1 || req.SeatsCount > maxBestSeats {
+		http.Error(w, fmt.Sprintf("seats_count must be between 1 and %d", maxBestSeats), http.StatusBadRequest)
+		return
+	}
+
+	// 1. Event must exist
+	if _, err := s.queries.GetEvent(r.Context(), req.EventId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Event not found", http.StatusNotFound)
+			return
+		}
+		fmt.Println("ERROR: s.queries.GetEvent:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := s.queries.WithTx(tx)
+
+	// 2. One active entry per user and event, enforced by idx_waitlist_entries_active
+	entryID, err := qtx.InsertWaitlistEntry(r.Context(), sqlc.InsertWaitlistEntryParams{
+		EventID:    req.EventId,
+		UserID:     session.UserID,
+		SeatsCount: req.SeatsCount,
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			http.Error(w, "Already in the waitlist for this event", http.StatusConflict)
+			return
+		}
+		fmt.Println("ERROR: qtx.InsertWaitlistEntry:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	position, err := qtx.GetWaitlistPosition(r.Context(), sqlc.GetWaitlistPositionParams{
+		EventID: req.EventId,
+		EntryID: entryID,
+	})
+	if err != nil {
+		fmt.Println("ERROR: qtx.GetWaitlistPosition:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Could not commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	// 3. Seats may already be free
+	s.queueWaitlistOffer(r, req.EventId)
+
+	response := JoinWaitlistResponse{
+		Id:       entryID,
+		Position: position,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// Покинуть лист ожидания
+// (PATCH /api/waitlist/cancel)
+func (s *HttpServer) LeaveWaitlist(w http.ResponseWriter, r *http.Request) {
+	session, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		fmt.Println("ERROR: middleware.GetUserFromContext: false")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var req LeaveWaitlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "Could not start transaction", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := s.queries.WithTx(tx)
+
+	entry, err := qtx.GetWaitlistEntry(r.Context(), req.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+			return
+		}
+		fmt.Println("ERROR: qtx.GetWaitlistEntry:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if entry.UserID != session.UserID {
+		http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+		return
+	}
+
+	if entry.Status != domain.WaitlistStatusWaiting && entry.Status != domain.WaitlistStatusOffered {
+		http.Error(w, "Waitlist entry is already closed", http.StatusConflict)
+		return
+	}
+
+	// 1. Declining an offer cancels the booking that holds the seats and frees them
+	var offerBooking *sqlc.Booking
+	if entry.Status == domain.WaitlistStatusOffered && entry.BookingID != nil {
+		booking, err := qtx.GetBooking(r.Context(), *entry.BookingID)
+		if err != nil {
+			fmt.Println("ERROR: qtx.GetBooking:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		switch booking.Status {
+		case domain.BookingStatusCreated:
+			err = service.TransitionBooking(r.Context(), qtx, booking, domain.BookingStatusCancelled, domain.UserActor(session.UserID), "waitlist offer declined")
+			if err != nil {
+				if errors.Is(err, service.ErrBookingStatusChanged) || errors.Is(err, domain.ErrInvalidBookingTransition) {
+					http.Error(w, "Booking status changed, retry", http.StatusConflict)
+					return
+				}
+				fmt.Println("ERROR: service.TransitionBooking:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if _, err := service.ReleaseBookingSeats(r.Context(), qtx, booking.ID); err != nil {
+				fmt.Println("ERROR: service.ReleaseBookingSeats:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			offerBooking = &booking
+		case domain.BookingStatusCancelled:
+		default:
+			http.Error(w, "Offered booking is already being paid, cancel the booking instead", http.StatusConflict)
+			return
+		}
+	}
+
+	// 2. Close the entry
+	updated, err := qtx.UpdateWaitlistEntryStatus(r.Context(), sqlc.UpdateWaitlistEntryStatusParams{
+		Status:   domain.WaitlistStatusCancelled,
+		EntryID:  entry.ID,
+		StatusEq: entry.Status,
+	})
+	if err != nil {
+		fmt.Println("ERROR: qtx.UpdateWaitlistEntryStatus:", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if updated == 0 {
+		http.Error(w, "Waitlist entry changed, retry", http.StatusConflict)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		http.Error(w, "Could not commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	// 3. Next user in line gets the offered seats
+	if offerBooking != nil {
+		s.queueWaitlistOffer(r, offerBooking.EventID)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// queueWaitlistOffer offers freed seats of the event to the waitlist.
+// Failures are only logged: the seats stay FREE and are offered on the next release.
+func (s *HttpServer) queueWaitlistOffer(r *http.Request, eventID int64) {
+	if _, err := s.riverClient.Insert(r.Context(), portriver.OfferWaitlistSeatsArgs{
+		EventID: eventID,
+	}, nil); err != nil {
+		fmt.Printf("ERROR: failed to queue OfferWaitlistSeatsWorker: %v\n", err)
+	}
+}
+
+// queueOrderCancel cancels the provider order of a booking cancelled before payment.
+// Such an order exists only with RESERVE_ON_SELECT and holds the places selected for the booking,
+// CancelBookingWorker frees the seats after the order is cancelled.
+func (s *HttpServer) queueOrderCancel(r *http.Request, bookingID int64) {
+	if _, err := s.riverClient.Insert(r.Context(), portriver.CancelBookingArgs{
+		BookingID: bookingID,
+	}, nil); err != nil {
+		fmt.Printf("ERROR: failed to queue CancelBookingWorker: %v\n", err)
+	}
+}
+
+// Получить аналитику продаж для события
+// (GET /api/analytics)
+func (s *HttpServer) GetEventAnalytics(w http.ResponseWriter, r *http.Request, params GetEventAnalyticsParams) {
+	ctx := r.Context()
+
+	// Validate required parameter
+	if params.Id == 0 {
+		http.Error(w, "Missing required parameter: id", http.StatusBadRequest)
+		return
+	}
+
+	eventID := params.Id
+
+	// Get analytics data from database
+	analytics, err := s.queries.GetEventAnalytics(ctx, eventID)
+	if err != nil {
+		http.Error(w, "Failed to get event analytics", http.StatusInternalServerError)
+		return
+	}
+
+	// Convert *float64 to int64, defaulting to 0 if null
 	soldSeats := int64(0)
 	if analytics.SoldSeats != nil {
 		soldSeats = int64(*analytics.SoldSeats)
@@ -823,18 +2519,43 @@ func (s *HttpServer) GetEventAnalytics(w http.ResponseWriter, r *http.Request, p
 		freeSeats = int64(*analytics.FreeSeats)
 	}
 
-	// Convert total revenue to string with 2 decimal places
-	totalRevenue := fmt.Sprintf("%.2f", analytics.TotalRevenue)
+	// Revenue is summed in minor units per currency, rendered with 2 decimal places
+	revenues, err := s.queries.GetEventRevenueByCurrency(ctx, eventID)
+	if err != nil {
+		http.Error(w, "Failed to get event analytics", http.StatusInternalServerError)
+		return
+	}
+
+	eventCurrency := domain.DefaultCurrency
+	event, err := s.queries.GetEvent(ctx, eventID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Failed to get event analytics", http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		eventCurrency = event.Currency
+	}
+
+	// total_revenue keeps its meaning: revenue in the currency the event is sold in
+	totalRevenue := domain.Money(0).String()
+	revenueByCurrency := make(map[string]string, len(revenues))
+	for _, revenue := range revenues {
+		revenueByCurrency[revenue.Currency] = domain.Money(revenue.Revenue).String()
+		if revenue.Currency == eventCurrency {
+			totalRevenue = domain.Money(revenue.Revenue).String()
+		}
+	}
 
 	// Prepare response
 	response := map[string]any{
-		"event_id":       eventID,
-		"total_seats":    analytics.TotalSeats,
-		"sold_seats":     soldSeats,
-		"reserved_seats": reservedSeats,
-		"free_seats":     freeSeats,
-		"total_revenue":  totalRevenue,
-		"bookings_count": analytics.BookingsCount,
+		"event_id":            eventID,
+		"total_seats":         analytics.TotalSeats,
+		"sold_seats":          soldSeats,
+		"reserved_seats":      reservedSeats,
+		"free_seats":          freeSeats,
+		"total_revenue":       totalRevenue,
+		"revenue_by_currency": revenueByCurrency,
+		"bookings_count":      analytics.BookingsCount,
 	}
 
 	// Set content type and send response
@@ -0,0 +1,134 @@
+//go:build sqlite_fts5
+
+package ports_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"hackload/internal/domain"
+	"hackload/internal/portriver"
+	"hackload/internal/testenv"
+	"hackload/pkg/eventprovider"
+	"hackload/pkg/eventprovider/fakeprovider"
+
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+func newCancelBookingEnv(t *testing.T) *apiEnv {
+	t.Helper()
+
+	env := newAPIEnv(t)
+	river.AddWorker(env.deps.RiverWorkers, portriver.NewCancelBookingWorker(env.queries, env.deps.DB, env.registry))
+	river.AddWorker(env.deps.RiverWorkers, portriver.NewReleaseSeatsWorker(env.queries, env.deps.DB))
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.OfferWaitlistSeatsArgs]{})
+	env.serve(t)
+
+	return env
+}
+
+// startOrder selects the places of the seats in a provider order started for the booking.
+func (e *apiEnv) startOrder(t *testing.T, bookingID int64, seatIDs ...int64) uuid.UUID {
+	t.Helper()
+
+	order := e.provider.Provider.StartOrder()
+	for _, seatID := range seatIDs {
+		if err := e.provider.Provider.SelectPlace(e.places[seatID-1].Id, order.Id); err != nil {
+			t.Fatal(err)
+		}
+		e.exec(t, `UPDATE booking_seats SET place_selected_at = CURRENT_TIMESTAMP WHERE seat_id = ?`, seatID)
+	}
+	e.exec(t, `INSERT INTO booking_orders (booking_id, order_id, status) VALUES (?, ?, 'STARTED')`, bookingID, order.Id.String())
+
+	return order.Id
+}
+
+func TestCancelBookingFreesSeatsAfterOrderCancel(t *testing.T) {
+	env := newCancelBookingEnv(t)
+
+	bookingID := env.createBooking(t, domain.BookingStatusCreated, "RESERVED", 1, 2)
+	orderID := env.startOrder(t, bookingID, 1, 2)
+
+	if code, body := env.request(t, 1, http.MethodPatch, "/api/bookings/cancel", map[string]any{"booking_id": bookingID}); code != http.StatusOK {
+		t.Fatalf("cancel answered %d: %s", code, body)
+	}
+
+	cancelJobs := testenv.WaitJobs(t, env.deps.RiverClient, portriver.CancelBookingArgs{}.Kind())
+	if len(cancelJobs) != 1 || cancelJobs[0].State != rivertype.JobStateCompleted {
+		t.Fatalf("cancel jobs: %+v", cancelJobs)
+	}
+	releaseJobs := testenv.WaitJobs(t, env.deps.RiverClient, portriver.ReleaseSeatsArgs{}.Kind())
+	if len(releaseJobs) != 1 || releaseJobs[0].State != rivertype.JobStateCompleted {
+		t.Fatalf("release jobs: %+v", releaseJobs)
+	}
+
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusCancelled {
+		t.Errorf("booking is %s, want CANCELLED", status)
+	}
+	for _, seatID := range []int64{1, 2} {
+		if status := env.seatStatus(t, seatID); status != "FREE" {
+			t.Errorf("seat %d is %s, want FREE", seatID, status)
+		}
+		place, err := env.provider.Provider.GetPlace(env.places[seatID-1].Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !place.IsFree {
+			t.Errorf("provider place %d is not free", seatID)
+		}
+	}
+	if seats := env.bookingSeats(t, bookingID); len(seats) != 0 {
+		t.Errorf("booking seats are %v, want none", seats)
+	}
+
+	order, err := env.provider.Provider.GetOrder(orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != eventprovider.CANCELLED {
+		t.Errorf("provider order is %s, want CANCELLED", order.Status)
+	}
+}
+
+func TestCancelBookingKeepsSeatsWhileProviderHoldsPlaces(t *testing.T) {
+	env := newCancelBookingEnv(t)
+
+	bookingID := env.createBooking(t, domain.BookingStatusCreated, "RESERVED", 1, 2)
+	env.startOrder(t, bookingID, 1, 2)
+	env.provider.Provider.Fail(fakeprovider.Failure{Op: fakeprovider.OpCancelOrder, StatusCode: http.StatusInternalServerError, Times: -1})
+
+	if code, body := env.request(t, 1, http.MethodPatch, "/api/bookings/cancel", map[string]any{"booking_id": bookingID}); code != http.StatusOK {
+		t.Fatalf("cancel answered %d: %s", code, body)
+	}
+
+	// Пока провайдер не отменил заказ, места брони не достаются другим
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		jobs := testenv.Jobs(t, env.deps.RiverClient, portriver.CancelBookingArgs{}.Kind())
+		if len(jobs) == 1 && len(jobs[0].Errors) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cancel job did not fail at the provider: %+v", jobs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusCancelled {
+		t.Errorf("booking is %s, want CANCELLED", status)
+	}
+	for _, seatID := range []int64{1, 2} {
+		if status := env.seatStatus(t, seatID); status != "RESERVED" {
+			t.Errorf("seat %d is %s, want RESERVED", seatID, status)
+		}
+	}
+	if seats := env.bookingSeats(t, bookingID); len(seats) != 2 {
+		t.Errorf("booking seats are %v, want [1 2]", seats)
+	}
+	if jobs := testenv.Jobs(t, env.deps.RiverClient, portriver.ReleaseSeatsArgs{}.Kind()); len(jobs) != 0 {
+		t.Errorf("%d release jobs queued, want none", len(jobs))
+	}
+}
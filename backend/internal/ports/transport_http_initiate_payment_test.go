@@ -0,0 +1,65 @@
+//go:build sqlite_fts5
+
+package ports_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"hackload/internal/domain"
+	"hackload/internal/portriver"
+	"hackload/internal/testenv"
+	"hackload/pkg/paymentgateway/fakegateway"
+
+	"github.com/riverqueue/river"
+)
+
+func TestInitiatePaymentAbandonedAttemptExpiresBooking(t *testing.T) {
+	env := newAPIEnv(t)
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.ExpireBookingArgs]{})
+	env.serve(t)
+
+	bookingID := env.createBooking(t, domain.BookingStatusPaymentInitiated, "RESERVED", 1)
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	env.exec(t, `UPDATE bookings SET expires_at = ? WHERE id = ?`, expiresAt, bookingID)
+	paymentID := env.initPayment(t, bookingID, "order-1", 10000)
+
+	// Прежняя попытка бросается, а новая не начинается: бронь остается в CREATED
+	env.gateway.Gateway.Fail(fakegateway.Failure{Op: fakegateway.OpInit, StatusCode: http.StatusInternalServerError})
+
+	if code, body := env.request(t, 1, http.MethodPatch, "/api/bookings/initiatePayment", map[string]any{"booking_id": bookingID}); code == http.StatusOK {
+		t.Fatalf("initiate payment answered 200 with a failing gateway: %s", body)
+	}
+
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusCreated {
+		t.Fatalf("booking is %s, want CREATED", status)
+	}
+	payment, err := env.queries.GetBookingPaymentByOrderID(context.Background(), "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status == nil || *payment.Status != domain.PaymentStatusAbandoned {
+		t.Errorf("payment is %v, want ABANDONED", payment.Status)
+	}
+	if gatewayPayment, _ := env.gateway.Gateway.Payment(paymentID); gatewayPayment.Status != fakegateway.StatusCancelled {
+		t.Errorf("gateway payment is %s, want CANCELLED", gatewayPayment.Status)
+	}
+
+	// Места освободятся в срок, если новая попытка так и не начнется
+	var expiring []time.Time
+	for _, job := range testenv.Jobs(t, env.deps.RiverClient, portriver.ExpireBookingArgs{}.Kind()) {
+		var args portriver.ExpireBookingArgs
+		if err := json.Unmarshal(job.EncodedArgs, &args); err != nil {
+			t.Fatal(err)
+		}
+		if args.BookingID == bookingID && args.StatusEq == domain.BookingStatusCreated {
+			expiring = append(expiring, job.ScheduledAt)
+		}
+	}
+	if len(expiring) != 1 || !expiring[0].Equal(expiresAt) {
+		t.Errorf("CREATED expiry jobs scheduled at %v, want one at %v", expiring, expiresAt)
+	}
+}
@@ -0,0 +1,252 @@
+//go:build sqlite_fts5
+
+package ports_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hackload/internal/config"
+	"hackload/internal/dependencies"
+	"hackload/internal/domain"
+	"hackload/internal/paymenttoken"
+	"hackload/internal/portriver"
+	"hackload/internal/ports"
+	"hackload/internal/sqlc"
+	"hackload/internal/testenv"
+	"hackload/internal/ticketing"
+
+	"github.com/riverqueue/river"
+)
+
+const (
+	teamSlug         = "team"
+	merchantPassword = "secret"
+)
+
+// notificationEnv serves the booking API that the payment gateway notifies.
+type notificationEnv struct {
+	deps    *dependencies.Dependencies
+	queries *sqlc.Queries
+	gateway *testenv.Gateway
+	api     *httptest.Server
+}
+
+func newNotificationEnv(t *testing.T) *notificationEnv {
+	t.Helper()
+
+	deps := testenv.New(t)
+	env := &notificationEnv{deps: deps, queries: sqlc.New(deps.DB), gateway: testenv.NewGateway()}
+
+	// Задачи саги не выполняются: проверяется только, что они поставлены
+	river.AddWorker(deps.RiverWorkers, testenv.NoopWorker[portriver.SelectSeatsArgs]{})
+	river.AddWorker(deps.RiverWorkers, testenv.NoopWorker[portriver.RefundPaymentArgs]{})
+	riverClient := testenv.StartRiver(t, deps)
+
+	conf := &config.Config{}
+	conf.PaymentProvider.MerchantID = teamSlug
+	conf.PaymentProvider.MerchantPassword = merchantPassword
+
+	srv := ports.NewHttpServer(env.queries, deps.DB, riverClient, ticketing.NewRegistry(env.queries, nil), env.gateway, nil, conf)
+	env.api = httptest.NewServer(ports.Handler(srv))
+	t.Cleanup(env.api.Close)
+
+	env.exec(t, `INSERT INTO users (user_id, email, password_hash, first_name, surname, registered_at, is_active, last_logged_in)
+		VALUES (1, 'a@example.com', 'x', 'A', 'A', '2025-01-01', 1, '2025-01-01')`)
+	env.exec(t, `INSERT INTO events_archive (id, title, datetime_start) VALUES (1, 'Concert', '2025-01-01T20:00:00')`)
+	env.exec(t, `INSERT INTO seats (id, event_id, row, number, price, status) VALUES (1, 1, 1, 1, 10000, 'RESERVED')`)
+
+	return env
+}
+
+func (e *notificationEnv) exec(t *testing.T, query string, args ...any) {
+	t.Helper()
+	if _, err := e.deps.DB.Exec(query, args...); err != nil {
+		t.Fatalf("exec %q: %v", query, err)
+	}
+}
+
+// initPayment creates a PAYMENT_INITIATED booking of seat 1 paid with the order.
+// The gateway knows the payment as started and not yet paid.
+func (e *notificationEnv) initPayment(t *testing.T, orderID string) (int64, string) {
+	t.Helper()
+
+	bookingID, err := e.queries.CreateBooking(context.Background(), sqlc.CreateBookingParams{UserID: 1, EventID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.exec(t, `UPDATE bookings SET status = 'PAYMENT_INITIATED' WHERE id = ?`, bookingID)
+	e.exec(t, `INSERT INTO booking_seats (booking_id, seat_id, user_id) VALUES (?, 1, 1)`, bookingID)
+
+	paymentID := "payment-" + orderID
+	e.exec(t, `INSERT INTO booking_payments (booking_id, order_id, payment_id, status, amount, currency, team_slug)
+		VALUES (?, ?, ?, 'INIT', 10000, 'KZT', ?)`,
+		bookingID, orderID, paymentID, teamSlug)
+	e.gateway.SetPayment(paymentID, testenv.GatewayPayment{Status: "NEW", Amount: 10000, Currency: "KZT"})
+
+	return bookingID, paymentID
+}
+
+// notify posts the notification signed with password, as the gateway does.
+func (e *notificationEnv) notify(t *testing.T, notification map[string]any, password string) int {
+	t.Helper()
+
+	token, err := paymenttoken.NewSigner(password).Sign(notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notification["token"] = token
+
+	body, err := json.Marshal(notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Post(e.api.URL+"/api/payments/notifications", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+// storedNotifications returns whether each stored notification of the order was verified, oldest first.
+func (e *notificationEnv) storedNotifications(t *testing.T, orderID string) []bool {
+	t.Helper()
+
+	rows, err := e.deps.DB.Query(`SELECT verified FROM payment_notifications WHERE order_id = ? ORDER BY id`, orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var verified []bool
+	for rows.Next() {
+		var v bool
+		if err := rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		verified = append(verified, v)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return verified
+}
+
+func (e *notificationEnv) bookingStatus(t *testing.T, bookingID int64) domain.BookingStatus {
+	t.Helper()
+
+	booking, err := e.queries.GetBooking(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return booking.Status
+}
+
+func (e *notificationEnv) jobs(t *testing.T, kind string) int {
+	t.Helper()
+	return len(testenv.Jobs(t, e.deps.RiverClient, kind))
+}
+
+func confirmedNotification(orderID, paymentID string) map[string]any {
+	return map[string]any{
+		"teamSlug":  teamSlug,
+		"orderId":   orderID,
+		"paymentId": paymentID,
+		"status":    "CONFIRMED",
+		"amount":    10000,
+		"currency":  "KZT",
+	}
+}
+
+func TestOnPaymentUpdatesConfirmsPaidBooking(t *testing.T) {
+	env := newNotificationEnv(t)
+
+	bookingID, paymentID := env.initPayment(t, "order-1")
+	env.gateway.SetPayment(paymentID, testenv.GatewayPayment{Status: "CONFIRMED", Amount: 10000, Currency: "KZT"})
+
+	if code := env.notify(t, confirmedNotification("order-1", paymentID), merchantPassword); code != http.StatusOK {
+		t.Fatalf("notification answered %d, want 200", code)
+	}
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusConfirmed {
+		t.Errorf("booking is %s, want CONFIRMED", status)
+	}
+
+	// Повторная доставка того же уведомления не запускает сагу снова
+	if code := env.notify(t, confirmedNotification("order-1", paymentID), merchantPassword); code != http.StatusOK {
+		t.Errorf("repeated notification answered %d, want 200", code)
+	}
+
+	if n := env.jobs(t, portriver.SelectSeatsArgs{}.Kind()); n != 1 {
+		t.Errorf("%d select seats jobs queued, want 1", n)
+	}
+	if verified := env.storedNotifications(t, "order-1"); len(verified) != 2 || !verified[0] || !verified[1] {
+		t.Errorf("stored notifications verified: %v, want two verified", verified)
+	}
+}
+
+func TestOnPaymentUpdatesRequiresPaymentAtGateway(t *testing.T) {
+	env := newNotificationEnv(t)
+
+	// Уведомление подписано верно, но шлюз не подтверждает оплату
+	bookingID, paymentID := env.initPayment(t, "order-1")
+
+	if code := env.notify(t, confirmedNotification("order-1", paymentID), merchantPassword); code != http.StatusConflict {
+		t.Errorf("notification answered %d, want 409", code)
+	}
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusPaymentInitiated {
+		t.Errorf("booking is %s, want PAYMENT_INITIATED", status)
+	}
+	if n := env.jobs(t, portriver.SelectSeatsArgs{}.Kind()); n != 0 {
+		t.Errorf("%d select seats jobs queued, want 0", n)
+	}
+}
+
+func TestOnPaymentUpdatesRejectsInvalidToken(t *testing.T) {
+	env := newNotificationEnv(t)
+
+	bookingID, paymentID := env.initPayment(t, "order-1")
+
+	if code := env.notify(t, confirmedNotification("order-1", paymentID), "wrong password"); code != http.StatusForbidden {
+		t.Errorf("notification answered %d, want 403", code)
+	}
+
+	// Уведомление сохраняется, но ничего не меняет
+	if verified := env.storedNotifications(t, "order-1"); len(verified) != 1 || verified[0] {
+		t.Errorf("stored notifications verified: %v, want one unverified", verified)
+	}
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusPaymentInitiated {
+		t.Errorf("booking is %s, want PAYMENT_INITIATED", status)
+	}
+	if n := env.jobs(t, portriver.SelectSeatsArgs{}.Kind()); n != 0 {
+		t.Errorf("%d select seats jobs queued, want none", n)
+	}
+}
+
+func TestOnPaymentUpdatesRejectsForeignPaymentID(t *testing.T) {
+	env := newNotificationEnv(t)
+
+	bookingID, _ := env.initPayment(t, "order-1")
+
+	if code := env.notify(t, confirmedNotification("order-1", "payment-other"), merchantPassword); code != http.StatusBadRequest {
+		t.Errorf("notification answered %d, want 400", code)
+	}
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusPaymentInitiated {
+		t.Errorf("booking is %s, want PAYMENT_INITIATED", status)
+	}
+}
+
+func TestOnPaymentUpdatesUnknownOrder(t *testing.T) {
+	env := newNotificationEnv(t)
+
+	if code := env.notify(t, confirmedNotification("order-404", "payment-404"), merchantPassword); code != http.StatusNotFound {
+		t.Errorf("notification answered %d, want 404", code)
+	}
+}
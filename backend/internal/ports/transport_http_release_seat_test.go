@@ -0,0 +1,128 @@
+//go:build sqlite_fts5
+
+package ports_test
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"hackload/internal/domain"
+	"hackload/internal/portriver"
+	"hackload/internal/testenv"
+	"hackload/pkg/paymentgateway/fakegateway"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+func newReleaseSeatEnv(t *testing.T) *apiEnv {
+	t.Helper()
+
+	env := newAPIEnv(t)
+	river.AddWorker(env.deps.RiverWorkers, portriver.NewRefundSeatWorker(env.queries, env.deps.DB, env.gatewayClient, env.conf))
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.OfferWaitlistSeatsArgs]{})
+	env.serve(t)
+
+	// Место 4 продается только в сервисе, у провайдера его нет
+	env.exec(t, `INSERT INTO seats (id, event_id, row, number, price, status) VALUES (4, 1, 2, 1, 10000, 'FREE')`)
+
+	return env
+}
+
+func (e *apiEnv) refundStatuses(t *testing.T, bookingID int64) []string {
+	t.Helper()
+
+	rows, err := e.deps.DB.Query(`SELECT status FROM booking_refunds WHERE booking_id = ? ORDER BY id`, bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var statuses []string
+	for rows.Next() {
+		var status string
+		if err := rows.Scan(&status); err != nil {
+			t.Fatal(err)
+		}
+		statuses = append(statuses, status)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return statuses
+}
+
+func TestReleaseSeatRefundsSeatOfConfirmedBooking(t *testing.T) {
+	env := newReleaseSeatEnv(t)
+
+	bookingID := env.createBooking(t, domain.BookingStatusConfirmed, "SOLD", 1, 4)
+	env.confirmOrder(t, bookingID, 1)
+	paymentID := env.payBooking(t, bookingID, "order-1", 20000)
+
+	if code, body := env.request(t, 1, http.MethodPatch, "/api/seats/release", map[string]any{"seat_id": 4}); code != http.StatusOK {
+		t.Fatalf("release answered %d: %s", code, body)
+	}
+
+	jobs := testenv.WaitJobs(t, env.deps.RiverClient, portriver.RefundSeatArgs{}.Kind())
+	if len(jobs) != 1 || jobs[0].State != rivertype.JobStateCompleted {
+		t.Fatalf("refund jobs: %+v", jobs)
+	}
+
+	if statuses := env.refundStatuses(t, bookingID); !slices.Equal(statuses, []string{domain.RefundStatusRefunded}) {
+		t.Errorf("refunds are %v, want one REFUNDED", statuses)
+	}
+	if status := env.seatStatus(t, 4); status != "FREE" {
+		t.Errorf("seat 4 is %s, want FREE", status)
+	}
+	if seats := env.bookingSeats(t, bookingID); !slices.Equal(seats, []int64{1}) {
+		t.Errorf("booking seats are %v, want [1]", seats)
+	}
+	if status := env.bookingStatus(t, bookingID); status != domain.BookingStatusConfirmed {
+		t.Errorf("booking is %s, want CONFIRMED", status)
+	}
+
+	payment, _ := env.gateway.Gateway.Payment(paymentID)
+	if payment.Status != fakegateway.StatusPartialRefunded || payment.RefundedAmount != 10000 {
+		t.Errorf("gateway payment is %s with %d refunded, want PARTIAL_REFUNDED with 10000", payment.Status, payment.RefundedAmount)
+	}
+}
+
+func TestReleaseSeatRejectsPlaceSoldByProvider(t *testing.T) {
+	env := newReleaseSeatEnv(t)
+
+	bookingID := env.createBooking(t, domain.BookingStatusConfirmed, "SOLD", 1, 2)
+	env.confirmOrder(t, bookingID, 1, 2)
+	paymentID := env.payBooking(t, bookingID, "order-1", 20000)
+
+	// Провайдер не освобождает места подтвержденного заказа, бронь остается как была
+	if code, body := env.request(t, 1, http.MethodPatch, "/api/seats/release", map[string]any{"seat_id": 2}); code != http.StatusConflict {
+		t.Fatalf("release answered %d, want 409: %s", code, body)
+	}
+
+	if seats := env.bookingSeats(t, bookingID); !slices.Equal(seats, []int64{1, 2}) {
+		t.Errorf("booking seats are %v, want [1 2]", seats)
+	}
+	if status := env.seatStatus(t, 2); status != "SOLD" {
+		t.Errorf("seat 2 is %s, want SOLD", status)
+	}
+	if statuses := env.refundStatuses(t, bookingID); len(statuses) != 0 {
+		t.Errorf("refunds are %v, want none", statuses)
+	}
+	if jobs := testenv.Jobs(t, env.deps.RiverClient, portriver.RefundSeatArgs{}.Kind()); len(jobs) != 0 {
+		t.Errorf("%d refund jobs queued, want none", len(jobs))
+	}
+
+	place, err := env.provider.Provider.GetPlace(env.places[1].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if place.IsFree {
+		t.Error("provider place 2 is free")
+	}
+	payment, _ := env.gateway.Gateway.Payment(paymentID)
+	if payment.RefundedAmount != 0 {
+		t.Errorf("gateway refunded %d, want nothing", payment.RefundedAmount)
+	}
+}
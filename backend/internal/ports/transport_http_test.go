@@ -0,0 +1,246 @@
+//go:build sqlite_fts5
+
+package ports_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"hackload/internal/config"
+	"hackload/internal/dependencies"
+	"hackload/internal/domain"
+	"hackload/internal/middleware"
+	"hackload/internal/paymenttoken"
+	"hackload/internal/ports"
+	"hackload/internal/service"
+	"hackload/internal/sqlc"
+	"hackload/internal/testenv"
+	"hackload/internal/ticketing"
+	"hackload/pkg/eventprovider"
+	"hackload/pkg/eventprovider/fakeprovider"
+	"hackload/pkg/paymentgateway"
+	"hackload/pkg/paymentgateway/fakegateway"
+)
+
+// apiEnv serves the booking API to users 1 and 2 with a fake ticket provider and payment gateway.
+// Seats 1-3 of event 1 are the provider places 1-3, all FREE.
+type apiEnv struct {
+	deps    *dependencies.Dependencies
+	queries *sqlc.Queries
+	conf    *config.Config
+
+	provider      *fakeprovider.Server
+	places        []eventprovider.Place
+	registry      *ticketing.Registry
+	gateway       *fakegateway.Server
+	gatewayClient paymentgateway.ClientInterface
+
+	api *httptest.Server
+}
+
+func newAPIEnv(t *testing.T) *apiEnv {
+	t.Helper()
+
+	deps := testenv.New(t)
+	env := &apiEnv{
+		deps:    deps,
+		queries: sqlc.New(deps.DB),
+		conf:    &config.Config{},
+	}
+	env.conf.PaymentProvider.MerchantID = teamSlug
+	env.conf.PaymentProvider.MerchantPassword = merchantPassword
+
+	env.provider = fakeprovider.NewServer(fakeprovider.WithPlaces(3))
+	t.Cleanup(env.provider.Close)
+	providerClient, err := env.provider.ProviderClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.places, err = env.provider.Provider.ListPlaces(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.registry = ticketing.NewRegistry(env.queries, &ticketing.Route{Provider: ticketing.NewEventProvider(providerClient)})
+
+	env.gateway = fakegateway.NewServer(fakegateway.WithTeam(teamSlug, merchantPassword))
+	t.Cleanup(env.gateway.Close)
+	env.gatewayClient, err = env.gateway.PaymentClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.exec(t, `INSERT INTO users (user_id, email, password_hash, password_plain, first_name, surname, registered_at, is_active, last_logged_in)
+		VALUES (1, 'user1@example.com', 'x', 'pw', 'A', 'A', '2025-01-01', 1, '2025-01-01'),
+		       (2, 'user2@example.com', 'x', 'pw', 'B', 'B', '2025-01-01', 1, '2025-01-01')`)
+	env.exec(t, `INSERT INTO events_archive (id, title, datetime_start, provider) VALUES (1, 'Concert', '2025-01-01T20:00:00', 'Билеттер')`)
+	for i, place := range env.places {
+		env.exec(t, `INSERT INTO seats (id, event_id, external_id, row, number, price, status) VALUES (?, 1, ?, 1, ?, 10000, 'FREE')`,
+			i+1, place.Id.String(), i+1)
+	}
+
+	return env
+}
+
+// serve starts River with the workers added to deps.RiverWorkers so far and the API behind authentication.
+func (e *apiEnv) serve(t *testing.T) {
+	t.Helper()
+
+	riverClient := testenv.StartRiver(t, e.deps)
+	srv := ports.NewHttpServer(e.queries, e.deps.DB, riverClient, e.registry, e.gatewayClient, nil, e.conf)
+	e.api = httptest.NewServer(middleware.AuthenticationMiddleware(service.NewAuthenticationService(e.queries))(ports.Handler(srv)))
+	t.Cleanup(e.api.Close)
+}
+
+func (e *apiEnv) exec(t *testing.T, query string, args ...any) {
+	t.Helper()
+	if _, err := e.deps.DB.Exec(query, args...); err != nil {
+		t.Fatalf("exec %q: %v", query, err)
+	}
+}
+
+// request sends body as JSON on behalf of the user and returns the response status and body.
+func (e *apiEnv) request(t *testing.T, userID int64, method, path string, body any) (int, []byte) {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(method, e.api.URL+path, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials := fmt.Sprintf("user%d@example.com:pw", userID)
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, respBody
+}
+
+// createBooking creates a booking of user 1 in status holding seats with the given seat status.
+func (e *apiEnv) createBooking(t *testing.T, status domain.BookingStatus, seatStatus string, seatIDs ...int64) int64 {
+	t.Helper()
+
+	bookingID, err := e.queries.CreateBooking(context.Background(), sqlc.CreateBookingParams{UserID: 1, EventID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.exec(t, `UPDATE bookings SET status = ? WHERE id = ?`, status, bookingID)
+
+	for _, seatID := range seatIDs {
+		e.exec(t, `INSERT INTO booking_seats (booking_id, seat_id, user_id) VALUES (?, ?, 1)`, bookingID, seatID)
+		e.exec(t, `UPDATE seats SET status = ? WHERE id = ?`, seatStatus, seatID)
+	}
+
+	return bookingID
+}
+
+// confirmOrder sells the places of the seats in a confirmed provider order and records it for the booking.
+func (e *apiEnv) confirmOrder(t *testing.T, bookingID int64, seatIDs ...int64) {
+	t.Helper()
+
+	order := e.provider.Provider.StartOrder()
+	for _, seatID := range seatIDs {
+		if err := e.provider.Provider.SelectPlace(e.places[seatID-1].Id, order.Id); err != nil {
+			t.Fatal(err)
+		}
+		e.exec(t, `UPDATE booking_seats SET place_selected_at = CURRENT_TIMESTAMP WHERE seat_id = ?`, seatID)
+	}
+	if err := e.provider.Provider.SubmitOrder(order.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.provider.Provider.ConfirmOrder(order.Id); err != nil {
+		t.Fatal(err)
+	}
+	e.exec(t, `INSERT INTO booking_orders (booking_id, order_id, status) VALUES (?, ?, 'CONFIRMED')`, bookingID, order.Id.String())
+}
+
+// initPayment starts a payment of amount at the gateway and records it as the INIT attempt of the booking.
+func (e *apiEnv) initPayment(t *testing.T, bookingID int64, orderID string, amount int64) string {
+	t.Helper()
+
+	req := paymentgateway.PaymentInitRequestDto{Amount: float64(amount), OrderId: orderID, TeamSlug: teamSlug}
+	token, err := paymenttoken.NewSigner(merchantPassword).Sign(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Token = token
+
+	resp, err := e.gatewayClient.PostApiV1PaymentInitInit(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var init paymentgateway.PaymentInitResponseDto
+	if err := json.NewDecoder(resp.Body).Decode(&init); err != nil || init.PaymentId == nil {
+		t.Fatalf("failed to init payment: status %d, %v", resp.StatusCode, err)
+	}
+
+	e.exec(t, `INSERT INTO booking_payments (booking_id, order_id, payment_id, status, amount, currency, team_slug, created_at)
+		VALUES (?, ?, ?, 'INIT', ?, 'KZT', ?, CURRENT_TIMESTAMP)`,
+		bookingID, orderID, *init.PaymentId, amount, teamSlug)
+
+	return *init.PaymentId
+}
+
+// payBooking pays amount for the booking at the gateway and records the SUCCESS payment.
+func (e *apiEnv) payBooking(t *testing.T, bookingID int64, orderID string, amount int64) string {
+	t.Helper()
+
+	paymentID := e.initPayment(t, bookingID, orderID, amount)
+	if err := e.gateway.Gateway.Pay(paymentID); err != nil {
+		t.Fatal(err)
+	}
+	e.exec(t, `UPDATE booking_payments SET status = 'SUCCESS' WHERE payment_id = ?`, paymentID)
+
+	return paymentID
+}
+
+func (e *apiEnv) bookingStatus(t *testing.T, bookingID int64) domain.BookingStatus {
+	t.Helper()
+
+	booking, err := e.queries.GetBooking(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return booking.Status
+}
+
+func (e *apiEnv) bookingSeats(t *testing.T, bookingID int64) []int64 {
+	t.Helper()
+
+	seatIDs, err := e.queries.GetBookingSeats(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seatIDs
+}
+
+func (e *apiEnv) seatStatus(t *testing.T, seatID int64) string {
+	t.Helper()
+
+	seat, err := e.queries.GetSeatByID(context.Background(), seatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seat.Status
+}
@@ -0,0 +1,195 @@
+//go:build sqlite_fts5
+
+package ports_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"hackload/internal/domain"
+	"hackload/internal/portriver"
+	"hackload/internal/ports"
+	"hackload/internal/testenv"
+
+	"github.com/riverqueue/river"
+)
+
+// newTransferEnv serves the API with a CONFIRMED booking of user 1 for seats 1 and 2, issued by the provider.
+func newTransferEnv(t *testing.T) (*apiEnv, int64) {
+	t.Helper()
+
+	env := newTransferAPIEnv(t)
+	bookingID := env.createBooking(t, domain.BookingStatusConfirmed, "SOLD", 1, 2)
+	env.confirmOrder(t, bookingID, 1, 2)
+
+	return env, bookingID
+}
+
+func newTransferAPIEnv(t *testing.T) *apiEnv {
+	t.Helper()
+
+	// Передача не ставит задач, но River не запускается без воркеров
+	env := newAPIEnv(t)
+	river.AddWorker(env.deps.RiverWorkers, testenv.NoopWorker[portriver.ExpireBookingArgs]{})
+	env.serve(t)
+
+	return env
+}
+
+func (e *apiEnv) transfer(t *testing.T, body map[string]any) (int, ports.TransferBookingResponse) {
+	t.Helper()
+
+	code, respBody := e.request(t, 1, http.MethodPatch, "/api/bookings/transfer", body)
+	var resp ports.TransferBookingResponse
+	if code == http.StatusOK {
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			t.Fatalf("failed to decode %s: %v", respBody, err)
+		}
+	}
+	return code, resp
+}
+
+// seatOwners returns the owners of the booking seats recorded in booking_seats.
+func (e *apiEnv) seatOwners(t *testing.T, bookingID int64) []int64 {
+	t.Helper()
+
+	rows, err := e.deps.DB.Query(`SELECT user_id FROM booking_seats WHERE booking_id = ? ORDER BY seat_id`, bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var owners []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			t.Fatal(err)
+		}
+		owners = append(owners, userID)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return owners
+}
+
+func TestTransferBookingHandsOverWholeBooking(t *testing.T) {
+	env, bookingID := newTransferEnv(t)
+
+	code, resp := env.transfer(t, map[string]any{"booking_id": bookingID, "email": "user2@example.com"})
+	if code != http.StatusOK {
+		t.Fatalf("transfer answered %d", code)
+	}
+	if resp.BookingId != bookingID {
+		t.Errorf("recipient booking is %d, want %d", resp.BookingId, bookingID)
+	}
+
+	booking, err := env.queries.GetBooking(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking.UserID != 2 || booking.Status != domain.BookingStatusConfirmed {
+		t.Errorf("booking is %s of user %d, want CONFIRMED of user 2", booking.Status, booking.UserID)
+	}
+	if owners := env.seatOwners(t, bookingID); !slices.Equal(owners, []int64{2, 2}) {
+		t.Errorf("seat owners are %v, want [2 2]", owners)
+	}
+	for _, seatID := range []int64{1, 2} {
+		if status := env.seatStatus(t, seatID); status != "SOLD" {
+			t.Errorf("seat %d is %s, want SOLD", seatID, status)
+		}
+	}
+
+	// Прежний владелец больше не распоряжается бронью
+	if code, _ := env.transfer(t, map[string]any{"booking_id": bookingID, "email": "user2@example.com"}); code != http.StatusForbidden {
+		t.Errorf("second transfer answered %d, want 403", code)
+	}
+}
+
+func TestTransferBookingSplitsSeats(t *testing.T) {
+	env, bookingID := newTransferEnv(t)
+
+	code, resp := env.transfer(t, map[string]any{"booking_id": bookingID, "email": "user2@example.com", "seat_ids": []int64{2}})
+	if code != http.StatusOK {
+		t.Fatalf("transfer answered %d", code)
+	}
+	if resp.BookingId == bookingID {
+		t.Fatal("seats were transferred with the whole booking")
+	}
+
+	parent, err := env.queries.GetBooking(context.Background(), bookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent.UserID != 1 || parent.Status != domain.BookingStatusConfirmed {
+		t.Errorf("original booking is %s of user %d, want CONFIRMED of user 1", parent.Status, parent.UserID)
+	}
+	if seats := env.bookingSeats(t, bookingID); !slices.Equal(seats, []int64{1}) {
+		t.Errorf("original booking seats are %v, want [1]", seats)
+	}
+
+	child, err := env.queries.GetBooking(context.Background(), resp.BookingId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.UserID != 2 || child.Status != domain.BookingStatusConfirmed || child.EventID != 1 {
+		t.Errorf("recipient booking is %s of user %d for event %d, want CONFIRMED of user 2 for event 1", child.Status, child.UserID, child.EventID)
+	}
+	if child.ParentBookingID == nil || *child.ParentBookingID != bookingID {
+		t.Errorf("recipient booking parent is %v, want %d", child.ParentBookingID, bookingID)
+	}
+	if seats := env.bookingSeats(t, resp.BookingId); !slices.Equal(seats, []int64{2}) {
+		t.Errorf("recipient booking seats are %v, want [2]", seats)
+	}
+	if owners := env.seatOwners(t, resp.BookingId); !slices.Equal(owners, []int64{2}) {
+		t.Errorf("recipient seat owners are %v, want [2]", owners)
+	}
+}
+
+func TestTransferBookingRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		status domain.BookingStatus
+		email  string
+		want   int
+	}{
+		{
+			name:   "unknown recipient",
+			status: domain.BookingStatusConfirmed,
+			email:  "nobody@example.com",
+			want:   http.StatusNotFound,
+		},
+		{
+			name:   "booking not confirmed",
+			status: domain.BookingStatusPaymentInitiated,
+			email:  "user2@example.com",
+			want:   http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTransferAPIEnv(t)
+			bookingID := env.createBooking(t, tt.status, "RESERVED", 1, 2)
+
+			if code, _ := env.transfer(t, map[string]any{"booking_id": bookingID, "email": tt.email}); code != tt.want {
+				t.Fatalf("transfer answered %d, want %d", code, tt.want)
+			}
+
+			booking, err := env.queries.GetBooking(context.Background(), bookingID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if booking.UserID != 1 || booking.Status != tt.status {
+				t.Errorf("booking is %s of user %d, want %s of user 1", booking.Status, booking.UserID, tt.status)
+			}
+			if owners := env.seatOwners(t, bookingID); !slices.Equal(owners, []int64{1, 1}) {
+				t.Errorf("seat owners are %v, want [1 1]", owners)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"hackload/internal/domain"
+	"hackload/internal/sqlc"
+)
+
+var ErrBookingStatusChanged = errors.New("booking status changed concurrently")
+
+// TransitionBooking moves the booking to status to if the domain state machine allows it
+// and records the transition in booking_status_history.
+// q is expected to be bound to the caller's transaction.
+func TransitionBooking(
+	ctx context.Context,
+	q *sqlc.Queries,
+	booking sqlc.Booking,
+	to domain.BookingStatus,
+	actor string,
+	reason string,
+) error {
+	if err := booking.Status.ValidateTransition(to); err != nil {
+		return err
+	}
+
+	rowsAffected, err := q.UpdateBookingStatus(ctx, sqlc.UpdateBookingStatusParams{
+		Status:    to,
+		BookingID: booking.ID,
+		StatusEq:  booking.Status,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update booking %d status: %w", booking.ID, err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrBookingStatusChanged
+	}
+
+	from := booking.Status
+	if err := q.InsertBookingStatusHistory(ctx, sqlc.InsertBookingStatusHistoryParams{
+		BookingID:  booking.ID,
+		FromStatus: &from,
+		ToStatus:   to,
+		Actor:      actor,
+		Reason:     &reason,
+		CreatedAt:  time.Now().UTC(),
+	}); err != nil {
+		return fmt.Errorf("failed to insert booking %d status history: %w", booking.ID, err)
+	}
+
+	return nil
+}
+
+// ReleaseBookingSeats detaches all seats from the booking and makes them FREE.
+// q is expected to be bound to the caller's transaction.
+func ReleaseBookingSeats(ctx context.Context, q *sqlc.Queries, bookingID int64) (int64, error) {
+	// 1. Get all seat IDs for this booking
+	seatIDs, err := q.GetBookingSeats(ctx, bookingID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get booking seats for booking %d: %w", bookingID, err)
+	}
+
+	// 2. Delete booking_seats records
+	rowsAffected, err := q.DeleteBookingSeats(ctx, bookingID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete booking seats for booking %d: %w", bookingID, err)
+	}
+
+	// 3. Update seats status to FREE (only if we actually deleted booking seats)
+	if rowsAffected > 0 && len(seatIDs) > 0 {
+		err = q.UpdateSeatsStatusByIDs(ctx, sqlc.UpdateSeatsStatusByIDsParams{
+			Status:  "FREE",
+			SeatIds: seatIDs,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to update seats status to FREE for booking %d: %w", bookingID, err)
+		}
+	}
+
+	return rowsAffected, nil
+}
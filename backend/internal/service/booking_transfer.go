@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"hackload/internal/domain"
+	"hackload/internal/sqlc"
+)
+
+var (
+	ErrSeatsNotInBooking = errors.New("seats do not belong to booking")
+	// Провайдер еще не подтвердил заказ брони: сага может поменять ее места или провалить ее
+	ErrBookingNotIssued = errors.New("booking is not issued by the ticket provider yet")
+	ErrRefundPending    = errors.New("booking has a pending seat refund")
+)
+
+// TransferBooking hands a CONFIRMED booking over to another user.
+// With empty seatIDs the whole booking changes owner, otherwise the given seats are split
+// into a new CONFIRMED booking of the recipient linked to the original via parent_booking_id.
+// It returns the recipient's booking ID.
+// q is expected to be bound to the caller's transaction.
+func TransferBooking(
+	ctx context.Context,
+	q *sqlc.Queries,
+	booking sqlc.Booking,
+	toUserID int64,
+	seatIDs []int64,
+) (int64, error) {
+	if booking.Status != domain.BookingStatusConfirmed {
+		return 0, fmt.Errorf("%w: booking %d is %s", domain.ErrInvalidBookingTransition, booking.ID, booking.Status)
+	}
+
+	if err := checkBookingIssued(ctx, q, booking); err != nil {
+		return 0, err
+	}
+
+	bookingSeats, err := q.GetBookingSeats(ctx, booking.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get booking seats for booking %d: %w", booking.ID, err)
+	}
+
+	owned := make(map[int64]bool, len(bookingSeats))
+	for _, seatID := range bookingSeats {
+		owned[seatID] = true
+	}
+	for _, seatID := range seatIDs {
+		if !owned[seatID] {
+			return 0, fmt.Errorf("%w: seat %d, booking %d", ErrSeatsNotInBooking, seatID, booking.ID)
+		}
+	}
+
+	// Передача всех мест равносильна передаче всей брони
+	partial := len(seatIDs) > 0 && len(seatIDs) < len(bookingSeats)
+
+	// Владелец меняется только у подтвержденной брони текущего владельца,
+	// поэтому параллельная отмена или возврат не пересекаются с передачей.
+	// При частичной передаче бронь остается у владельца, но проверка та же.
+	newOwnerID := toUserID
+	if partial {
+		newOwnerID = booking.UserID
+	}
+	rowsAffected, err := q.TransferBooking(ctx, sqlc.TransferBookingParams{
+		ToUserID:   newOwnerID,
+		BookingID:  booking.ID,
+		FromUserID: booking.UserID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to transfer booking %d: %w", booking.ID, err)
+	}
+	if rowsAffected == 0 {
+		return 0, ErrBookingStatusChanged
+	}
+
+	now := time.Now().UTC()
+	status := domain.BookingStatusConfirmed
+	actor := domain.UserActor(booking.UserID)
+
+	if !partial {
+		if err := q.UpdateBookingSeatsUserID(ctx, sqlc.UpdateBookingSeatsUserIDParams{
+			UserID:    toUserID,
+			BookingID: booking.ID,
+		}); err != nil {
+			return 0, fmt.Errorf("failed to update booking seats owner for booking %d: %w", booking.ID, err)
+		}
+
+		reason := "transferred to " + domain.UserActor(toUserID)
+		if err := q.InsertBookingStatusHistory(ctx, sqlc.InsertBookingStatusHistoryParams{
+			BookingID:  booking.ID,
+			FromStatus: &status,
+			ToStatus:   status,
+			Actor:      actor,
+			Reason:     &reason,
+			CreatedAt:  now,
+		}); err != nil {
+			return 0, fmt.Errorf("failed to insert booking %d status history: %w", booking.ID, err)
+		}
+
+		return booking.ID, nil
+	}
+
+	splitBookingID, err := q.InsertSplitBooking(ctx, sqlc.InsertSplitBookingParams{
+		UserID:          toUserID,
+		EventID:         booking.EventID,
+		ParentBookingID: &booking.ID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create split booking for booking %d: %w", booking.ID, err)
+	}
+
+	moved, err := q.MoveBookingSeats(ctx, sqlc.MoveBookingSeatsParams{
+		ToBookingID:   splitBookingID,
+		UserID:        toUserID,
+		FromBookingID: booking.ID,
+		SeatIds:       seatIDs,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to move seats from booking %d to %d: %w", booking.ID, splitBookingID, err)
+	}
+	if moved != int64(len(seatIDs)) {
+		return 0, ErrBookingStatusChanged
+	}
+
+	reason := fmt.Sprintf("%d seats transferred to %s as booking %d", moved, domain.UserActor(toUserID), splitBookingID)
+	if err := q.InsertBookingStatusHistory(ctx, sqlc.InsertBookingStatusHistoryParams{
+		BookingID:  booking.ID,
+		FromStatus: &status,
+		ToStatus:   status,
+		Actor:      actor,
+		Reason:     &reason,
+		CreatedAt:  now,
+	}); err != nil {
+		return 0, fmt.Errorf("failed to insert booking %d status history: %w", booking.ID, err)
+	}
+
+	splitReason := "split from booking " + strconv.FormatInt(booking.ID, 10)
+	if err := q.InsertBookingStatusHistory(ctx, sqlc.InsertBookingStatusHistoryParams{
+		BookingID:  splitBookingID,
+		FromStatus: nil,
+		ToStatus:   status,
+		Actor:      actor,
+		Reason:     &splitReason,
+		CreatedAt:  now,
+	}); err != nil {
+		return 0, fmt.Errorf("failed to insert booking %d status history: %w", splitBookingID, err)
+	}
+
+	return splitBookingID, nil
+}
+
+// checkBookingIssued makes sure the provider confirmed the order of the booking and no seat refund
+// is in progress. A booking split by an earlier transfer is checked against the booking it was split from.
+func checkBookingIssued(ctx context.Context, q *sqlc.Queries, booking sqlc.Booking) error {
+	root := booking
+	for root.ParentBookingID != nil {
+		parent, err := q.GetBooking(ctx, *root.ParentBookingID)
+		if err != nil {
+			return fmt.Errorf("failed to get parent booking %d: %w", *root.ParentBookingID, err)
+		}
+		root = parent
+	}
+
+	bookingOrder, err := q.GetBookingOrder(ctx, root.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: booking %d has no order", ErrBookingNotIssued, booking.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get booking order for booking %d: %w", root.ID, err)
+	}
+	if bookingOrder.Status == nil || *bookingOrder.Status != "CONFIRMED" {
+		return fmt.Errorf("%w: booking %d", ErrBookingNotIssued, booking.ID)
+	}
+
+	pendingRefunds, err := q.CountPendingBookingRefunds(ctx, root.ID)
+	if err != nil {
+		return fmt.Errorf("failed to count pending refunds for booking %d: %w", root.ID, err)
+	}
+	if pendingRefunds > 0 {
+		return fmt.Errorf("%w: booking %d", ErrRefundPending, booking.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"hackload/internal/paymenttoken"
+	"hackload/internal/sqlc"
+	"hackload/pkg/paymentgateway"
+)
+
+// CancelPayment cancels the payment at the payment gateway. For a payment that was
+// never completed it is a cancellation, for a completed one it is a full refund.
+func CancelPayment(
+	ctx context.Context,
+	paymentGateway paymentgateway.ClientInterface,
+	payment sqlc.BookingPayment,
+	merchantPassword string,
+) error {
+	req := paymentgateway.PaymentCancelRequestDto{
+		PaymentId: payment.PaymentID,
+		TeamSlug:  payment.TeamSlug,
+	}
+	token, err := paymenttoken.NewSigner(merchantPassword).Sign(req)
+	if err != nil {
+		return fmt.Errorf("failed to sign cancel of payment %s: %w", payment.PaymentID, err)
+	}
+	req.Token = token
+
+	resp, err := paymentGateway.PostApiV1PaymentCancelCancel(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to cancel payment %s: %w", payment.PaymentID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("failed to cancel payment %s, status: %d", payment.PaymentID, resp.StatusCode)
+	}
+
+	return nil
+}
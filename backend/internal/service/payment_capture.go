@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"hackload/internal/paymenttoken"
+	"hackload/internal/sqlc"
+	"hackload/pkg/paymentgateway"
+)
+
+// CapturePayment charges the full amount of an authorized two-stage payment.
+func CapturePayment(
+	ctx context.Context,
+	paymentGateway paymentgateway.ClientInterface,
+	payment sqlc.BookingPayment,
+	merchantPassword string,
+) error {
+	amount := float64(payment.Amount)
+	req := paymentgateway.PaymentConfirmRequestDto{
+		PaymentId: payment.PaymentID,
+		Amount:    &amount,
+		TeamSlug:  payment.TeamSlug,
+	}
+	token, err := paymenttoken.NewSigner(merchantPassword).Sign(req)
+	if err != nil {
+		return fmt.Errorf("failed to sign capture of payment %s: %w", payment.PaymentID, err)
+	}
+	req.Token = token
+
+	resp, err := paymentGateway.PostApiV1PaymentConfirmConfirm(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to capture payment %s: %w", payment.PaymentID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("failed to capture payment %s, status: %d", payment.PaymentID, resp.StatusCode)
+	}
+
+	return nil
+}
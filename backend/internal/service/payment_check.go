@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"hackload/internal/paymenttoken"
+	"hackload/internal/sqlc"
+	"hackload/pkg/paymentgateway"
+)
+
+var ErrPaymentNotFoundAtGateway = errors.New("payment not found at payment gateway")
+
+// PaymentCheck is the state of a payment as reported by the payment gateway.
+type PaymentCheck struct {
+	Status   string
+	Amount   int64 // Amount in cents
+	Currency string
+}
+
+// CheckPayment asks the payment gateway for the current state of the payment.
+func CheckPayment(
+	ctx context.Context,
+	paymentGateway paymentgateway.ClientInterface,
+	payment sqlc.BookingPayment,
+	merchantPassword string,
+) (PaymentCheck, error) {
+	req := paymentgateway.PaymentCheckRequestDto{
+		PaymentId: &payment.PaymentID,
+		OrderId:   &payment.OrderID,
+		TeamSlug:  payment.TeamSlug,
+	}
+	token, err := paymenttoken.NewSigner(merchantPassword).Sign(req)
+	if err != nil {
+		return PaymentCheck{}, fmt.Errorf("failed to sign check of payment %s: %w", payment.PaymentID, err)
+	}
+	req.Token = token
+
+	resp, err := paymentGateway.PostApiV1PaymentCheckCheck(ctx, req)
+	if err != nil {
+		return PaymentCheck{}, fmt.Errorf("failed to check payment %s: %w", payment.PaymentID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return PaymentCheck{}, fmt.Errorf("%w: %s", ErrPaymentNotFoundAtGateway, payment.PaymentID)
+	}
+
+	if resp.StatusCode > 299 {
+		return PaymentCheck{}, fmt.Errorf("failed to check payment %s, status: %d", payment.PaymentID, resp.StatusCode)
+	}
+
+	var checkResp paymentgateway.PaymentCheckResponseDto
+	if err := json.NewDecoder(resp.Body).Decode(&checkResp); err != nil {
+		return PaymentCheck{}, fmt.Errorf("failed to decode check response for payment %s: %w", payment.PaymentID, err)
+	}
+
+	if checkResp.Payments != nil {
+		for _, p := range *checkResp.Payments {
+			if p.PaymentId == nil || *p.PaymentId != payment.PaymentID {
+				continue
+			}
+			if p.Status == nil || p.Amount == nil || p.Currency == nil {
+				return PaymentCheck{}, fmt.Errorf("incomplete check response for payment %s", payment.PaymentID)
+			}
+			return PaymentCheck{
+				Status:   *p.Status,
+				Amount:   int64(math.Round(*p.Amount)),
+				Currency: *p.Currency,
+			}, nil
+		}
+	}
+
+	return PaymentCheck{}, fmt.Errorf("%w: %s", ErrPaymentNotFoundAtGateway, payment.PaymentID)
+}
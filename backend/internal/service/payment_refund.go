@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"hackload/internal/paymenttoken"
+	"hackload/internal/sqlc"
+	"hackload/pkg/paymentgateway"
+)
+
+// RefundSeat returns the price of one seat of a completed payment. The gateway cancel
+// is partial: Amount is the refunded part and the seat is the single cancel item.
+func RefundSeat(
+	ctx context.Context,
+	paymentGateway paymentgateway.ClientInterface,
+	payment sqlc.BookingPayment,
+	refund sqlc.BookingRefund,
+	merchantPassword string,
+) error {
+	amount := float64(refund.Amount)
+	quantity := float64(1)
+	reason := "seat removed from booking"
+	itemID := strconv.FormatInt(refund.ID, 10)
+	if refund.SeatID != nil {
+		itemID = strconv.FormatInt(*refund.SeatID, 10)
+	}
+
+	req := paymentgateway.PaymentCancelRequestDto{
+		PaymentId: payment.PaymentID,
+		TeamSlug:  payment.TeamSlug,
+		Amount:    &amount,
+		Reason:    &reason,
+		Items: &[]paymentgateway.CancelItemDto{{
+			ItemId:   itemID,
+			Amount:   &amount,
+			Quantity: &quantity,
+			Reason:   &reason,
+		}},
+	}
+	// Amount и Reason подписываются, позиции (Items) нет
+	token, err := paymenttoken.NewSigner(merchantPassword).Sign(req)
+	if err != nil {
+		return fmt.Errorf("failed to sign refund of payment %s: %w", payment.PaymentID, err)
+	}
+	req.Token = token
+
+	resp, err := paymentGateway.PostApiV1PaymentCancelCancel(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to refund payment %s: %w", payment.PaymentID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("failed to refund payment %s, status: %d", payment.PaymentID, resp.StatusCode)
+	}
+
+	return nil
+}
@@ -8,8 +8,9 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"hackload/internal/domain"
 	"hackload/internal/sqlc"
-	"hackload/pkg/eventprovider"
+	"hackload/internal/ticketing"
 
 	sq "github.com/Masterminds/squirrel"
 )
@@ -19,26 +20,47 @@ type ResetService interface {
 }
 
 type resetService struct {
-	queries                    *sqlc.Queries
-	db                         *sql.DB
-	eventProviderWithResponses *eventprovider.ClientWithResponses
+	queries         *sqlc.Queries
+	db              *sql.DB
+	ticketProviders *ticketing.Registry
 }
 
 func NewResetService(
 	queries *sqlc.Queries,
 	db *sql.DB,
-	eventProviderWithResponses *eventprovider.ClientWithResponses,
+	ticketProviders *ticketing.Registry,
 ) ResetService {
 	return &resetService{
-		queries:                    queries,
-		db:                         db,
-		eventProviderWithResponses: eventProviderWithResponses,
+		queries:         queries,
+		db:              db,
+		ticketProviders: ticketProviders,
 	}
 }
 
+// Reset clears the bookings and reloads the places of every ticket provider into its event.
 func (s *resetService) Reset(ctx context.Context) error {
 	slog.Info("starting preloader process")
 
+	// 1. Check that each provider is loaded into an event routed to it.
+	// Иначе ни брони, ни сверка склада не найдут провайдера его мест
+	var routes []*ticketing.Route
+	for _, route := range s.ticketProviders.Routes() {
+		if route.EventID == 0 {
+			slog.Warn("ticket provider has no event to load places into", "provider", route.Name)
+			continue
+		}
+
+		eventRoute, err := s.ticketProviders.ForEvent(ctx, route.EventID)
+		if err != nil {
+			return err
+		}
+		if eventRoute != route {
+			return fmt.Errorf("event %d is not routed to ticket provider %q", route.EventID, route.Name)
+		}
+
+		routes = append(routes, route)
+	}
+
 	// Start database transaction
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -49,14 +71,38 @@ func (s *resetService) Reset(ctx context.Context) error {
 
 	txQueries := s.queries.WithTx(tx)
 
-	// 1. Clear existing data
+	// 2. Clear existing data
 	if err := s.clearExistingData(ctx, txQueries); err != nil {
 		return err
 	}
 
-	// 2. Setup channels for producer-consumer pattern
+	// 3. Load the places of every provider
+	var totalInserted int64
+	for _, route := range routes {
+		inserted, err := s.loadPlaces(ctx, tx, route)
+		if err != nil {
+			return err
+		}
+		totalInserted += inserted
+	}
+
+	// 4. Commit transaction
+	if err := tx.Commit(); err != nil {
+		slog.Error("unable to commit transaction", "error", err)
+		return err
+	}
+
+	slog.Info("preloader process completed successfully", "seats_inserted", totalInserted)
+	return nil
+}
+
+// loadPlaces inserts the places of the provider as the seats of its event.
+func (s *resetService) loadPlaces(ctx context.Context, tx *sql.Tx, route *ticketing.Route) (int64, error) {
+	slog.Info("loading places", "provider", route.Name, "event_id", route.EventID)
+
+	// 1. Setup channels for producer-consumer pattern
 	type placeChunk struct {
-		places []eventprovider.Place
+		places []ticketing.Place
 		page   int
 	}
 
@@ -71,7 +117,7 @@ func (s *resetService) Reset(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	// 3. Start database inserter workers
+	// 2. Start database inserter workers
 	const numInserters = 3
 	var insertWg sync.WaitGroup
 
@@ -81,7 +127,7 @@ func (s *resetService) Reset(ctx context.Context) error {
 			defer insertWg.Done()
 
 			for chunk := range placeChan {
-				if err := s.insertPlaceChunk(ctx, tx, chunk.places, workerID); err != nil {
+				if err := s.insertPlaceChunk(ctx, tx, route.EventID, chunk.places, workerID); err != nil {
 					slog.Error("insert worker failed", "worker", workerID, "error", err)
 					select {
 					case errChan <- err:
@@ -104,7 +150,7 @@ func (s *resetService) Reset(ctx context.Context) error {
 		}(i)
 	}
 
-	// 4. Start fetcher workers
+	// 3. Start fetcher workers
 	const numFetchers = 5
 	const totalPages = 100
 
@@ -133,7 +179,7 @@ func (s *resetService) Reset(ctx context.Context) error {
 				default:
 				}
 
-				places, err := s.fetchPage(ctx, page, workerID)
+				places, err := s.fetchPage(ctx, route, page, workerID)
 				if err != nil {
 					slog.Error("fetcher worker failed", "worker", workerID, "page", page, "error", err)
 					select {
@@ -156,7 +202,7 @@ func (s *resetService) Reset(ctx context.Context) error {
 		}(i)
 	}
 
-	// 5. Monitor completion in separate goroutine
+	// 4. Monitor completion in separate goroutine
 	go func() {
 		fetchWg.Wait()
 		fetchComplete.Store(true)
@@ -168,34 +214,53 @@ func (s *resetService) Reset(ctx context.Context) error {
 		close(doneChan)
 	}()
 
-	// 6. Wait for completion or error
+	// 5. Wait for completion or error
 	select {
 	case err := <-errChan:
 		cancel() // Ensure all workers stop
-		return fmt.Errorf("operation failed: %w", err)
+		return 0, fmt.Errorf("operation failed: %w", err)
 	case <-doneChan:
 		// All workers completed successfully
 	}
 
-	// 7. Commit transaction
-	if err := tx.Commit(); err != nil {
-		slog.Error("unable to commit transaction", "error", err)
-		return err
-	}
-
-	slog.Info("preloader process completed successfully", "seats_inserted", totalInserted.Load())
-	return nil
+	slog.Info("loaded places", "provider", route.Name, "event_id", route.EventID, "seats_inserted", totalInserted.Load())
+	return totalInserted.Load(), nil
 }
 
 func (s *resetService) clearExistingData(ctx context.Context, txQueries *sqlc.Queries) error {
 	slog.Info("clearing existing data")
 
 	// Delete in order to respect foreign key constraints
+	if _, err := txQueries.DeleteAllIdempotencyKeys(ctx); err != nil {
+		slog.Error("unable to delete idempotency keys", "error", err)
+		return err
+	}
+
+	if _, err := txQueries.DeleteAllWaitlistEntries(ctx); err != nil {
+		slog.Error("unable to delete waitlist entries", "error", err)
+		return err
+	}
+
+	if _, err := txQueries.DeleteAllBookingStatusHistory(ctx); err != nil {
+		slog.Error("unable to delete booking status history", "error", err)
+		return err
+	}
+
 	if _, err := txQueries.DeleteAllBookingOrders(ctx); err != nil {
 		slog.Error("unable to delete booking orders", "error", err)
 		return err
 	}
 
+	if _, err := txQueries.DeleteAllPaymentNotifications(ctx); err != nil {
+		slog.Error("unable to delete payment notifications", "error", err)
+		return err
+	}
+
+	if _, err := txQueries.DeleteAllBookingRefunds(ctx); err != nil {
+		slog.Error("unable to delete booking refunds", "error", err)
+		return err
+	}
+
 	if _, err := txQueries.DeleteAllBookingPayments(ctx); err != nil {
 		slog.Error("unable to delete booking payments", "error", err)
 		return err
@@ -220,40 +285,31 @@ func (s *resetService) clearExistingData(ctx context.Context, txQueries *sqlc.Qu
 	return nil
 }
 
-func (s *resetService) fetchPage(ctx context.Context, page int, workerID int) ([]eventprovider.Place, error) {
+func (s *resetService) fetchPage(ctx context.Context, route *ticketing.Route, page int, workerID int) ([]ticketing.Place, error) {
 	pageSize := 1000
 
-	slog.Info("fetching page", "worker", workerID, "page", page)
+	slog.Info("fetching page", "worker", workerID, "provider", route.Name, "page", page)
 
-	placesResp, err := s.eventProviderWithResponses.ListPlacesWithResponse(ctx, &eventprovider.ListPlacesParams{
-		Page:     &page,
-		PageSize: &pageSize,
-	})
+	places, err := route.ListPlaces(ctx, page, pageSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch page %d: %w", page, err)
+		return nil, fmt.Errorf("failed to fetch page %d of ticket provider %q: %w", page, route.Name, err)
 	}
 
-	if placesResp.StatusCode() != 200 {
-		return nil, fmt.Errorf("bad response status %d for page %d", placesResp.StatusCode(), page)
-	}
-
-	if placesResp.JSON200 == nil {
-		return nil, fmt.Errorf("no data in response for page %d", page)
-	}
-
-	places := *placesResp.JSON200
-	slog.Info("fetched page successfully", "worker", workerID, "page", page, "count", len(places))
+	slog.Info("fetched page successfully", "worker", workerID, "provider", route.Name, "page", page, "count", len(places))
 
 	return places, nil
 }
 
-func (s *resetService) insertPlaceChunk(ctx context.Context, tx *sql.Tx, places []eventprovider.Place, workerID int) error {
+func (s *resetService) insertPlaceChunk(ctx context.Context, tx *sql.Tx, eventID int64, places []ticketing.Place, workerID int) error {
 	if len(places) == 0 {
 		return nil
 	}
 
 	// Build batch insert query
-	insertQuery := sq.Insert("seats").Columns("event_id", "external_id", "row", "number", "price", "status")
+	insertQuery := sq.Insert("seats").Columns("event_id", "external_id", "row", "number", "price", "currency", "status")
+
+	// Seats are priced in the currency of their event
+	currency := sq.Expr("coalesce((select currency from events_archive where id = ?), ?)", eventID, domain.DefaultCurrency)
 
 	for _, place := range places {
 		status := "FREE"
@@ -262,9 +318,7 @@ func (s *resetService) insertPlaceChunk(ctx context.Context, tx *sql.Tx, places
 		}
 
 		price := calculateSeatPrice(place.Row, place.Seat)
-		externalID := place.Id.String()
-
-		insertQuery = insertQuery.Values(1, externalID, int64(place.Row), int64(place.Seat), price, status)
+		insertQuery = insertQuery.Values(eventID, place.ID, int64(place.Row), int64(place.Seat), price, currency, status)
 	}
 
 	// Execute batch insert
@@ -281,19 +335,19 @@ func (s *resetService) insertPlaceChunk(ctx context.Context, tx *sql.Tx, places
 	return nil
 }
 
-func calculateSeatPrice(row, seat int) string {
+func calculateSeatPrice(row, seat int) domain.Money {
 	// Calculate seat index based on row and seat number (each row has 1000 seats)
 	seatIndex := (row-1)*1000 + seat
 	switch {
 	case seatIndex <= 10000: // 1-10,000: Золотой круг
-		return "40000.00"
+		return 40000_00
 	case seatIndex <= 25000: // 10,001-25,000: Фан-зона
-		return "80000.00"
+		return 80000_00
 	case seatIndex <= 45000: // 25,001-45,000: Нижний ярус
-		return "120000.00"
+		return 120000_00
 	case seatIndex <= 70000: // 45,001-70,000: Средний ярус
-		return "160000.00"
+		return 160000_00
 	default: // 70,001+: Верхний ярус
-		return "200000.00"
+		return 200000_00
 	}
 }
@@ -0,0 +1,105 @@
+//go:build sqlite_fts5
+
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"hackload/internal/service"
+	"hackload/internal/sqlc"
+	"hackload/internal/testenv"
+	"hackload/internal/ticketing"
+	"hackload/pkg/eventprovider/fakeprovider"
+)
+
+// fakeRoute serves n places of a fake provider.
+func fakeRoute(t *testing.T, name string, eventID int64, n int) (*ticketing.Route, *fakeprovider.Server) {
+	t.Helper()
+
+	server := fakeprovider.NewServer(fakeprovider.WithPlaces(n))
+	t.Cleanup(server.Close)
+
+	client, err := server.ProviderClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &ticketing.Route{Provider: ticketing.NewEventProvider(client), Name: name, EventID: eventID}, server
+}
+
+func TestResetLoadsPlacesOfEveryProvider(t *testing.T) {
+	deps := testenv.New(t)
+	queries := sqlc.New(deps.DB)
+	ctx := context.Background()
+
+	if _, err := deps.DB.Exec(`INSERT INTO events_archive (id, title, datetime_start, provider)
+		VALUES (1, 'Concert', '2025-01-01T20:00:00', NULL), (2, 'Show', '2025-01-02T20:00:00', 'TicketRu')`); err != nil {
+		t.Fatal(err)
+	}
+
+	fallback, fallbackServer := fakeRoute(t, "", 1, 3)
+	ticketRu, ticketRuServer := fakeRoute(t, "TicketRu", 2, 2)
+	registry := ticketing.NewRegistry(queries, fallback, ticketRu)
+
+	if err := service.NewResetService(queries, deps.DB, registry).Reset(ctx); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+
+	for eventID, server := range map[int64]*fakeprovider.Server{1: fallbackServer, 2: ticketRuServer} {
+		places, err := server.Provider.ListPlaces(1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, place := range places {
+			var seatEventID int64
+			err := deps.DB.QueryRow(`SELECT event_id FROM seats WHERE external_id = ?`, place.Id.String()).Scan(&seatEventID)
+			if err != nil {
+				t.Fatalf("place %s of event %d is not loaded: %v", place.Id, eventID, err)
+			}
+			if seatEventID != eventID {
+				t.Errorf("place %s is loaded into event %d, want %d", place.Id, seatEventID, eventID)
+			}
+		}
+	}
+
+	var seats int
+	if err := deps.DB.QueryRow(`SELECT count(*) FROM seats`).Scan(&seats); err != nil {
+		t.Fatal(err)
+	}
+	if seats != 5 {
+		t.Errorf("%d seats loaded, want 5", seats)
+	}
+}
+
+func TestResetRejectsEventOfAnotherProvider(t *testing.T) {
+	deps := testenv.New(t)
+	queries := sqlc.New(deps.DB)
+
+	// Событие 1 продает TicketRu, а не провайдер по умолчанию
+	if _, err := deps.DB.Exec(`INSERT INTO events_archive (id, title, datetime_start, provider)
+		VALUES (1, 'Concert', '2025-01-01T20:00:00', 'TicketRu')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := deps.DB.Exec(`INSERT INTO seats (event_id, row, number, price, status) VALUES (1, 1, 1, 10000, 'FREE')`); err != nil {
+		t.Fatal(err)
+	}
+
+	fallback, _ := fakeRoute(t, "", 1, 3)
+	ticketRu, _ := fakeRoute(t, "TicketRu", 0, 2)
+	registry := ticketing.NewRegistry(queries, fallback, ticketRu)
+
+	if err := service.NewResetService(queries, deps.DB, registry).Reset(context.Background()); err == nil {
+		t.Fatal("Reset succeeded, want an error")
+	}
+
+	// Ничего не удалено
+	var seats int
+	if err := deps.DB.QueryRow(`SELECT count(*) FROM seats`).Scan(&seats); err != nil {
+		t.Fatal(err)
+	}
+	if seats != 1 {
+		t.Errorf("%d seats left, want 1", seats)
+	}
+}
@@ -2,6 +2,7 @@
 select
   b.id,
   b.event_id,
+  b.expires_at,
   CASE 
     WHEN COUNT(bs.seat_id) = 0 THEN cast(json_array() as text)
     ELSE cast(
@@ -13,19 +14,13 @@ from bookings b
 left join booking_seats as bs on bs.booking_id = b.id
 where 1=1
   and sqlc.arg(user_id) = b.user_id
-group by b.id, b.event_id;
+group by b.id, b.event_id, b.expires_at;
 
 -- name: CreateBooking :one
-INSERT INTO bookings (user_id, event_id, status)
-VALUES (sqlc.arg(user_id), sqlc.arg(event_id), 'CREATED')
+INSERT INTO bookings (user_id, event_id, status, expires_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(event_id), 'CREATED', sqlc.arg(expires_at))
 RETURNING id;
 
--- name: CancelBooking :execresult
-UPDATE bookings 
-SET status = 'CANCELLED'
-WHERE id = sqlc.arg(booking_id) 
-  AND user_id = sqlc.arg(user_id)
-  AND status IN ('CREATED', 'PAYMENT_INITIATED');
 -- name: InsertBookingSeat :exec
 insert into booking_seats (user_id, booking_id, seat_id)
 values (sqlc.arg(user_id), sqlc.arg(booking_id), sqlc.arg(seat_id))
@@ -37,6 +32,23 @@ where seat_id = sqlc.arg(seat_id)
   and user_id = sqlc.arg(user_id)
 ;
 
+-- name: GetUserBookingSeat :one
+SELECT
+    bs.booking_id,
+    bs.seat_id,
+    s.price
+FROM booking_seats bs
+JOIN seats s ON s.id = bs.seat_id
+WHERE bs.seat_id = sqlc.arg(seat_id)
+  AND bs.user_id = sqlc.arg(user_id)
+;
+
+-- name: DeleteBookingSeatByBooking :execrows
+delete from booking_seats
+where booking_id = sqlc.arg(booking_id)
+  and seat_id = sqlc.arg(seat_id)
+;
+
 -- name: GetBooking :one
 select * from bookings 
 where id = sqlc.arg(booking_id)
@@ -58,9 +70,34 @@ delete from booking_seats
 where booking_id = sqlc.arg(booking_id)
 ;
 
--- name: UpdateBookingStatus :exec
+-- name: DeleteBookingSeatsByIDs :execrows
+delete from booking_seats
+where booking_id = sqlc.arg(booking_id)
+  and seat_id IN (sqlc.slice(seat_ids))
+;
+
+-- name: UpdateBookingStatus :execrows
 UPDATE bookings 
 SET status = sqlc.arg(status)
+WHERE id = sqlc.arg(booking_id)
+  AND status = sqlc.arg(status_eq)
+;
+
+-- name: InsertBookingStatusHistory :exec
+INSERT INTO booking_status_history (booking_id, from_status, to_status, actor, reason, created_at)
+VALUES (sqlc.arg(booking_id), sqlc.narg(from_status), sqlc.arg(to_status), sqlc.arg(actor), sqlc.narg(reason), sqlc.arg(created_at))
+;
+
+-- name: GetLatestBookingStatusHistory :one
+SELECT from_status, to_status, actor, reason FROM booking_status_history
+WHERE booking_id = sqlc.arg(booking_id)
+ORDER BY id DESC
+LIMIT 1
+;
+
+-- name: UpdateBookingExpiresAt :exec
+UPDATE bookings
+SET expires_at = sqlc.arg(expires_at)
 WHERE id = sqlc.arg(booking_id)
 ;
 
@@ -92,23 +129,135 @@ SELECT * FROM booking_orders
 WHERE booking_id = sqlc.arg(booking_id)
 ;
 
+-- name: UpdateBookingOrder :exec
+UPDATE booking_orders
+SET order_id = sqlc.arg(order_id),
+    status = sqlc.arg(status)
+WHERE booking_id = sqlc.arg(booking_id)
+;
+
+-- name: GetBookingSeatPlaces :many
+SELECT
+    bs.seat_id,
+    s.external_id,
+    bs.place_selected_at
+FROM booking_seats bs
+JOIN seats s ON s.id = bs.seat_id
+WHERE bs.booking_id = sqlc.arg(booking_id)
+ORDER BY bs.seat_id
+;
+
+-- name: MarkBookingSeatPlaceSelected :exec
+UPDATE booking_seats
+SET place_selected_at = sqlc.arg(place_selected_at)
+WHERE booking_id = sqlc.arg(booking_id)
+  AND seat_id = sqlc.arg(seat_id)
+;
+
+-- name: ResetBookingSeatPlacesSelected :exec
+UPDATE booking_seats
+SET place_selected_at = NULL
+WHERE booking_id = sqlc.arg(booking_id)
+;
+
 -- name: InsertBookingPayment :exec
-INSERT INTO booking_payments (booking_id, order_id, payment_id, status, amount, currency, team_slug)
-VALUES (sqlc.arg(booking_id), sqlc.arg(order_id), sqlc.arg(payment_id), sqlc.arg(status), sqlc.arg(amount), sqlc.arg(currency), sqlc.arg(team_slug))
+INSERT INTO booking_payments (booking_id, order_id, payment_id, status, amount, currency, team_slug, payment_url, created_at, two_stage)
+VALUES (sqlc.arg(booking_id), sqlc.arg(order_id), sqlc.arg(payment_id), sqlc.arg(status), sqlc.arg(amount), sqlc.arg(currency), sqlc.arg(team_slug), sqlc.narg(payment_url), sqlc.arg(created_at), sqlc.arg(two_stage))
 ;
 
--- name: GetBookingTotal :one
-SELECT COALESCE(SUM(CAST(s.price AS REAL) * 100), 0) as total
+-- name: GetBookingTotals :many
+SELECT s.currency, CAST(COALESCE(SUM(s.price), 0) AS INTEGER) as total
 FROM booking_seats bs
 JOIN seats s ON bs.seat_id = s.id
 WHERE bs.booking_id = sqlc.arg(booking_id)
+GROUP BY s.currency
+ORDER BY s.currency
+;
+
+-- name: GetLatestBookingPayment :one
+SELECT * FROM booking_payments
+WHERE booking_id = sqlc.arg(booking_id)
+ORDER BY id DESC
+LIMIT 1
+;
+
+-- name: GetPaidBookingPayment :one
+SELECT * FROM booking_payments
+WHERE booking_id = sqlc.arg(booking_id)
+  AND status IN ('AUTHORIZED', 'SUCCESS')
+ORDER BY id DESC
+LIMIT 1
+;
+
+-- name: GetBookingDetails :one
+select
+  b.id,
+  b.event_id,
+  b.status,
+  b.expires_at,
+  bp.order_id as payment_order_id,
+  bp.status as payment_status,
+  bp.payment_url,
+  bo.status as order_status
+from bookings b
+left join booking_payments bp on bp.booking_id = b.id
+left join booking_orders bo on bo.booking_id = b.id
+where b.id = sqlc.arg(booking_id)
+  and b.user_id = sqlc.arg(user_id)
+order by bp.id desc, bo.id desc
+limit 1
 ;
 
--- name: GetBookingPaymentByBookingID :one
-SELECT * FROM booking_payments 
+-- name: GetBookingDetailsSeats :many
+select s.id, s.row, s.number, s.price, s.currency
+from booking_seats bs
+join seats s on s.id = bs.seat_id
+where bs.booking_id = sqlc.arg(booking_id)
+order by s.row, s.number
+;
+
+-- name: TransferBooking :execrows
+UPDATE bookings
+SET user_id = sqlc.arg(to_user_id)
+WHERE id = sqlc.arg(booking_id)
+  AND user_id = sqlc.arg(from_user_id)
+  AND status = 'CONFIRMED'
+;
+
+-- name: UpdateBookingSeatsUserID :exec
+UPDATE booking_seats
+SET user_id = sqlc.arg(user_id)
 WHERE booking_id = sqlc.arg(booking_id)
 ;
 
+-- name: InsertSplitBooking :one
+INSERT INTO bookings (user_id, event_id, status, parent_booking_id)
+VALUES (sqlc.arg(user_id), sqlc.arg(event_id), 'CONFIRMED', sqlc.arg(parent_booking_id))
+RETURNING id
+;
+
+-- name: MoveBookingSeats :execrows
+UPDATE booking_seats
+SET booking_id = sqlc.arg(to_booking_id),
+    user_id = sqlc.arg(user_id)
+WHERE booking_id = sqlc.arg(from_booking_id)
+  AND seat_id IN (sqlc.slice(seat_ids))
+;
+
+-- name: CountSplitBookings :one
+SELECT COUNT(*) FROM bookings
+WHERE parent_booking_id = sqlc.arg(booking_id)
+;
+
+-- name: GetSplitBookings :many
+SELECT * FROM bookings
+WHERE parent_booking_id = sqlc.arg(booking_id)
+ORDER BY id
+;
+
+-- name: DeleteAllBookingStatusHistory :execresult
+DELETE FROM booking_status_history;
+
 -- name: DeleteAllBookingOrders :execresult
 DELETE FROM booking_orders;
 
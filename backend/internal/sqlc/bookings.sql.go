@@ -8,46 +8,46 @@ package sqlc
 import (
 	"context"
 	"database/sql"
+	"strings"
+	"time"
+
+	"hackload/internal/domain"
 )
 
-const cancelBooking = `-- name: CancelBooking :execresult
-UPDATE bookings 
-SET status = 'CANCELLED'
-WHERE id = ?1 
-  AND user_id = ?2
-  AND status IN ('CREATED', 'PAYMENT_INITIATED')
-`
+const countSplitBookings = `-- name: CountSplitBookings :one
+;
 
-type CancelBookingParams struct {
-	BookingID int64
-	UserID    int64
-}
+SELECT COUNT(*) FROM bookings
+WHERE parent_booking_id = ?1
+`
 
-func (q *Queries) CancelBooking(ctx context.Context, arg CancelBookingParams) (sql.Result, error) {
-	return q.db.ExecContext(ctx, cancelBooking, arg.BookingID, arg.UserID)
+func (q *Queries) CountSplitBookings(ctx context.Context, bookingID *int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countSplitBookings, bookingID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
 }
 
 const createBooking = `-- name: CreateBooking :one
-INSERT INTO bookings (user_id, event_id, status)
-VALUES (?1, ?2, 'CREATED')
+INSERT INTO bookings (user_id, event_id, status, expires_at)
+VALUES (?1, ?2, 'CREATED', ?3)
 RETURNING id
 `
 
 type CreateBookingParams struct {
-	UserID  int64
-	EventID int64
+	UserID    int64
+	EventID   int64
+	ExpiresAt *time.Time
 }
 
 func (q *Queries) CreateBooking(ctx context.Context, arg CreateBookingParams) (int64, error) {
-	row := q.db.QueryRowContext(ctx, createBooking, arg.UserID, arg.EventID)
+	row := q.db.QueryRowContext(ctx, createBooking, arg.UserID, arg.EventID, arg.ExpiresAt)
 	var id int64
 	err := row.Scan(&id)
 	return id, err
 }
 
 const deleteAllBookingOrders = `-- name: DeleteAllBookingOrders :execresult
-;
-
 DELETE FROM booking_orders
 `
 
@@ -71,6 +71,16 @@ func (q *Queries) DeleteAllBookingSeats(ctx context.Context) (sql.Result, error)
 	return q.db.ExecContext(ctx, deleteAllBookingSeats)
 }
 
+const deleteAllBookingStatusHistory = `-- name: DeleteAllBookingStatusHistory :execresult
+;
+
+DELETE FROM booking_status_history
+`
+
+func (q *Queries) DeleteAllBookingStatusHistory(ctx context.Context) (sql.Result, error) {
+	return q.db.ExecContext(ctx, deleteAllBookingStatusHistory)
+}
+
 const deleteAllBookings = `-- name: DeleteAllBookings :execresult
 DELETE FROM bookings
 `
@@ -100,6 +110,27 @@ func (q *Queries) DeleteBookingSeat(ctx context.Context, arg DeleteBookingSeatPa
 	return result.RowsAffected()
 }
 
+const deleteBookingSeatByBooking = `-- name: DeleteBookingSeatByBooking :execrows
+;
+
+delete from booking_seats
+where booking_id = ?1
+  and seat_id = ?2
+`
+
+type DeleteBookingSeatByBookingParams struct {
+	BookingID int64
+	SeatID    int64
+}
+
+func (q *Queries) DeleteBookingSeatByBooking(ctx context.Context, arg DeleteBookingSeatByBookingParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteBookingSeatByBooking, arg.BookingID, arg.SeatID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteBookingSeats = `-- name: DeleteBookingSeats :execrows
 ;
 
@@ -115,10 +146,42 @@ func (q *Queries) DeleteBookingSeats(ctx context.Context, bookingID int64) (int6
 	return result.RowsAffected()
 }
 
+const deleteBookingSeatsByIDs = `-- name: DeleteBookingSeatsByIDs :execrows
+;
+
+delete from booking_seats
+where booking_id = ?1
+  and seat_id IN (/*SLICE:seat_ids*/?)
+`
+
+type DeleteBookingSeatsByIDsParams struct {
+	BookingID int64
+	SeatIds   []int64
+}
+
+func (q *Queries) DeleteBookingSeatsByIDs(ctx context.Context, arg DeleteBookingSeatsByIDsParams) (int64, error) {
+	query := deleteBookingSeatsByIDs
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.BookingID)
+	if len(arg.SeatIds) > 0 {
+		for _, v := range arg.SeatIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:seat_ids*/?", strings.Repeat(",?", len(arg.SeatIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:seat_ids*/?", "NULL", 1)
+	}
+	result, err := q.db.ExecContext(ctx, query, queryParams...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getBooking = `-- name: GetBooking :one
 ;
 
-select id, user_id, event_id, status from bookings 
+select id, user_id, event_id, status, expires_at, parent_booking_id from bookings 
 where id = ?1
 `
 
@@ -130,6 +193,8 @@ func (q *Queries) GetBooking(ctx context.Context, bookingID int64) (Booking, err
 		&i.UserID,
 		&i.EventID,
 		&i.Status,
+		&i.ExpiresAt,
+		&i.ParentBookingID,
 	)
 	return i, err
 }
@@ -137,7 +202,7 @@ func (q *Queries) GetBooking(ctx context.Context, bookingID int64) (Booking, err
 const getBookingByIDAndUserID = `-- name: GetBookingByIDAndUserID :one
 ;
 
-select id, user_id, event_id, status from bookings 
+select id, user_id, event_id, status, expires_at, parent_booking_id from bookings 
 where id = ?1
   and user_id = ?2
 `
@@ -155,6 +220,8 @@ func (q *Queries) GetBookingByIDAndUserID(ctx context.Context, arg GetBookingByI
 		&i.UserID,
 		&i.EventID,
 		&i.Status,
+		&i.ExpiresAt,
+		&i.ParentBookingID,
 	)
 	return i, err
 }
@@ -162,7 +229,7 @@ func (q *Queries) GetBookingByIDAndUserID(ctx context.Context, arg GetBookingByI
 const getBookingByPaymentOrderID = `-- name: GetBookingByPaymentOrderID :one
 ;
 
-SELECT b.id, b.user_id, b.event_id, b.status FROM bookings b
+SELECT b.id, b.user_id, b.event_id, b.status, b.expires_at, b.parent_booking_id FROM bookings b
 JOIN booking_payments bp ON b.id = bp.booking_id
 WHERE bp.order_id = ?1
 `
@@ -175,52 +242,173 @@ func (q *Queries) GetBookingByPaymentOrderID(ctx context.Context, orderID string
 		&i.UserID,
 		&i.EventID,
 		&i.Status,
+		&i.ExpiresAt,
+		&i.ParentBookingID,
 	)
 	return i, err
 }
 
-const getBookingOrder = `-- name: GetBookingOrder :one
+const getBookingDetails = `-- name: GetBookingDetails :one
 ;
 
-SELECT id, booking_id, order_id, status FROM booking_orders 
-WHERE booking_id = ?1
+select
+  b.id,
+  b.event_id,
+  b.status,
+  b.expires_at,
+  bp.order_id as payment_order_id,
+  bp.status as payment_status,
+  bp.payment_url,
+  bo.status as order_status
+from bookings b
+left join booking_payments bp on bp.booking_id = b.id
+left join booking_orders bo on bo.booking_id = b.id
+where b.id = ?1
+  and b.user_id = ?2
+order by bp.id desc, bo.id desc
+limit 1
 `
 
-func (q *Queries) GetBookingOrder(ctx context.Context, bookingID int64) (BookingOrder, error) {
-	row := q.db.QueryRowContext(ctx, getBookingOrder, bookingID)
-	var i BookingOrder
+type GetBookingDetailsParams struct {
+	BookingID int64
+	UserID    int64
+}
+
+type GetBookingDetailsRow struct {
+	ID             int64
+	EventID        int64
+	Status         domain.BookingStatus
+	ExpiresAt      *time.Time
+	PaymentOrderID *string
+	PaymentStatus  *string
+	PaymentUrl     *string
+	OrderStatus    *string
+}
+
+func (q *Queries) GetBookingDetails(ctx context.Context, arg GetBookingDetailsParams) (GetBookingDetailsRow, error) {
+	row := q.db.QueryRowContext(ctx, getBookingDetails, arg.BookingID, arg.UserID)
+	var i GetBookingDetailsRow
 	err := row.Scan(
 		&i.ID,
-		&i.BookingID,
-		&i.OrderID,
+		&i.EventID,
 		&i.Status,
+		&i.ExpiresAt,
+		&i.PaymentOrderID,
+		&i.PaymentStatus,
+		&i.PaymentUrl,
+		&i.OrderStatus,
 	)
 	return i, err
 }
 
-const getBookingPaymentByBookingID = `-- name: GetBookingPaymentByBookingID :one
+const getBookingDetailsSeats = `-- name: GetBookingDetailsSeats :many
 ;
 
-SELECT id, booking_id, order_id, status, payment_id, amount, currency, team_slug FROM booking_payments 
+select s.id, s.row, s.number, s.price, s.currency
+from booking_seats bs
+join seats s on s.id = bs.seat_id
+where bs.booking_id = ?1
+order by s.row, s.number
+`
+
+type GetBookingDetailsSeatsRow struct {
+	ID       int64
+	Row      int64
+	Number   int64
+	Price    domain.Money
+	Currency string
+}
+
+func (q *Queries) GetBookingDetailsSeats(ctx context.Context, bookingID int64) ([]GetBookingDetailsSeatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getBookingDetailsSeats, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetBookingDetailsSeatsRow
+	for rows.Next() {
+		var i GetBookingDetailsSeatsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Row,
+			&i.Number,
+			&i.Price,
+			&i.Currency,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getBookingOrder = `-- name: GetBookingOrder :one
+;
+
+SELECT id, booking_id, order_id, status FROM booking_orders 
 WHERE booking_id = ?1
 `
 
-func (q *Queries) GetBookingPaymentByBookingID(ctx context.Context, bookingID int64) (BookingPayment, error) {
-	row := q.db.QueryRowContext(ctx, getBookingPaymentByBookingID, bookingID)
-	var i BookingPayment
+func (q *Queries) GetBookingOrder(ctx context.Context, bookingID int64) (BookingOrder, error) {
+	row := q.db.QueryRowContext(ctx, getBookingOrder, bookingID)
+	var i BookingOrder
 	err := row.Scan(
 		&i.ID,
 		&i.BookingID,
 		&i.OrderID,
 		&i.Status,
-		&i.PaymentID,
-		&i.Amount,
-		&i.Currency,
-		&i.TeamSlug,
 	)
 	return i, err
 }
 
+const getBookingSeatPlaces = `-- name: GetBookingSeatPlaces :many
+;
+
+SELECT
+    bs.seat_id,
+    s.external_id,
+    bs.place_selected_at
+FROM booking_seats bs
+JOIN seats s ON s.id = bs.seat_id
+WHERE bs.booking_id = ?1
+ORDER BY bs.seat_id
+`
+
+type GetBookingSeatPlacesRow struct {
+	SeatID          int64
+	ExternalID      *string
+	PlaceSelectedAt *time.Time
+}
+
+func (q *Queries) GetBookingSeatPlaces(ctx context.Context, bookingID int64) ([]GetBookingSeatPlacesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getBookingSeatPlaces, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetBookingSeatPlacesRow
+	for rows.Next() {
+		var i GetBookingSeatPlacesRow
+		if err := rows.Scan(&i.SeatID, &i.ExternalID, &i.PlaceSelectedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getBookingSeats = `-- name: GetBookingSeats :many
 ;
 
@@ -251,26 +439,50 @@ func (q *Queries) GetBookingSeats(ctx context.Context, bookingID int64) ([]int64
 	return items, nil
 }
 
-const getBookingTotal = `-- name: GetBookingTotal :one
+const getBookingTotals = `-- name: GetBookingTotals :many
 ;
 
-SELECT COALESCE(SUM(CAST(s.price AS REAL) * 100), 0) as total
+SELECT s.currency, CAST(COALESCE(SUM(s.price), 0) AS INTEGER) as total
 FROM booking_seats bs
 JOIN seats s ON bs.seat_id = s.id
 WHERE bs.booking_id = ?1
+GROUP BY s.currency
+ORDER BY s.currency
 `
 
-func (q *Queries) GetBookingTotal(ctx context.Context, bookingID int64) (interface{}, error) {
-	row := q.db.QueryRowContext(ctx, getBookingTotal, bookingID)
-	var total interface{}
-	err := row.Scan(&total)
-	return total, err
+type GetBookingTotalsRow struct {
+	Currency string
+	Total    int64
+}
+
+func (q *Queries) GetBookingTotals(ctx context.Context, bookingID int64) ([]GetBookingTotalsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getBookingTotals, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetBookingTotalsRow
+	for rows.Next() {
+		var i GetBookingTotalsRow
+		if err := rows.Scan(&i.Currency, &i.Total); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const getBookings = `-- name: GetBookings :many
 select
   b.id,
   b.event_id,
+  b.expires_at,
   CASE 
     WHEN COUNT(bs.seat_id) = 0 THEN cast(json_array() as text)
     ELSE cast(
@@ -282,13 +494,14 @@ from bookings b
 left join booking_seats as bs on bs.booking_id = b.id
 where 1=1
   and ?1 = b.user_id
-group by b.id, b.event_id
+group by b.id, b.event_id, b.expires_at
 `
 
 type GetBookingsRow struct {
-	ID      int64
-	EventID int64
-	Seats   string
+	ID        int64
+	EventID   int64
+	ExpiresAt *time.Time
+	Seats     string
 }
 
 func (q *Queries) GetBookings(ctx context.Context, userID int64) ([]GetBookingsRow, error) {
@@ -300,7 +513,137 @@ func (q *Queries) GetBookings(ctx context.Context, userID int64) ([]GetBookingsR
 	var items []GetBookingsRow
 	for rows.Next() {
 		var i GetBookingsRow
-		if err := rows.Scan(&i.ID, &i.EventID, &i.Seats); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.ExpiresAt,
+			&i.Seats,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLatestBookingPayment = `-- name: GetLatestBookingPayment :one
+;
+
+SELECT id, booking_id, order_id, status, payment_id, amount, currency, team_slug, payment_url, review_reason, created_at, two_stage FROM booking_payments
+WHERE booking_id = ?1
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestBookingPayment(ctx context.Context, bookingID int64) (BookingPayment, error) {
+	row := q.db.QueryRowContext(ctx, getLatestBookingPayment, bookingID)
+	var i BookingPayment
+	err := row.Scan(
+		&i.ID,
+		&i.BookingID,
+		&i.OrderID,
+		&i.Status,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.TeamSlug,
+		&i.PaymentUrl,
+		&i.ReviewReason,
+		&i.CreatedAt,
+		&i.TwoStage,
+	)
+	return i, err
+}
+
+const getLatestBookingStatusHistory = `-- name: GetLatestBookingStatusHistory :one
+;
+
+SELECT from_status, to_status, actor, reason FROM booking_status_history
+WHERE booking_id = ?1
+ORDER BY id DESC
+LIMIT 1
+`
+
+type GetLatestBookingStatusHistoryRow struct {
+	FromStatus *domain.BookingStatus
+	ToStatus   domain.BookingStatus
+	Actor      string
+	Reason     *string
+}
+
+func (q *Queries) GetLatestBookingStatusHistory(ctx context.Context, bookingID int64) (GetLatestBookingStatusHistoryRow, error) {
+	row := q.db.QueryRowContext(ctx, getLatestBookingStatusHistory, bookingID)
+	var i GetLatestBookingStatusHistoryRow
+	err := row.Scan(
+		&i.FromStatus,
+		&i.ToStatus,
+		&i.Actor,
+		&i.Reason,
+	)
+	return i, err
+}
+
+const getPaidBookingPayment = `-- name: GetPaidBookingPayment :one
+;
+
+SELECT id, booking_id, order_id, status, payment_id, amount, currency, team_slug, payment_url, review_reason, created_at, two_stage FROM booking_payments
+WHERE booking_id = ?1
+  AND status IN ('AUTHORIZED', 'SUCCESS')
+ORDER BY id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetPaidBookingPayment(ctx context.Context, bookingID int64) (BookingPayment, error) {
+	row := q.db.QueryRowContext(ctx, getPaidBookingPayment, bookingID)
+	var i BookingPayment
+	err := row.Scan(
+		&i.ID,
+		&i.BookingID,
+		&i.OrderID,
+		&i.Status,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.TeamSlug,
+		&i.PaymentUrl,
+		&i.ReviewReason,
+		&i.CreatedAt,
+		&i.TwoStage,
+	)
+	return i, err
+}
+
+const getSplitBookings = `-- name: GetSplitBookings :many
+;
+
+SELECT id, user_id, event_id, status, expires_at, parent_booking_id FROM bookings
+WHERE parent_booking_id = ?1
+ORDER BY id
+`
+
+func (q *Queries) GetSplitBookings(ctx context.Context, bookingID *int64) ([]Booking, error) {
+	rows, err := q.db.QueryContext(ctx, getSplitBookings, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Booking
+	for rows.Next() {
+		var i Booking
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.EventID,
+			&i.Status,
+			&i.ExpiresAt,
+			&i.ParentBookingID,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -314,6 +657,37 @@ func (q *Queries) GetBookings(ctx context.Context, userID int64) ([]GetBookingsR
 	return items, nil
 }
 
+const getUserBookingSeat = `-- name: GetUserBookingSeat :one
+;
+
+SELECT
+    bs.booking_id,
+    bs.seat_id,
+    s.price
+FROM booking_seats bs
+JOIN seats s ON s.id = bs.seat_id
+WHERE bs.seat_id = ?1
+  AND bs.user_id = ?2
+`
+
+type GetUserBookingSeatParams struct {
+	SeatID int64
+	UserID int64
+}
+
+type GetUserBookingSeatRow struct {
+	BookingID int64
+	SeatID    int64
+	Price     domain.Money
+}
+
+func (q *Queries) GetUserBookingSeat(ctx context.Context, arg GetUserBookingSeatParams) (GetUserBookingSeatRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserBookingSeat, arg.SeatID, arg.UserID)
+	var i GetUserBookingSeatRow
+	err := row.Scan(&i.BookingID, &i.SeatID, &i.Price)
+	return i, err
+}
+
 const insertBookingOrder = `-- name: InsertBookingOrder :exec
 ;
 
@@ -335,18 +709,21 @@ func (q *Queries) InsertBookingOrder(ctx context.Context, arg InsertBookingOrder
 const insertBookingPayment = `-- name: InsertBookingPayment :exec
 ;
 
-INSERT INTO booking_payments (booking_id, order_id, payment_id, status, amount, currency, team_slug)
-VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)
+INSERT INTO booking_payments (booking_id, order_id, payment_id, status, amount, currency, team_slug, payment_url, created_at, two_stage)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10)
 `
 
 type InsertBookingPaymentParams struct {
-	BookingID int64
-	OrderID   string
-	PaymentID string
-	Status    *string
-	Amount    int64
-	Currency  string
-	TeamSlug  string
+	BookingID  int64
+	OrderID    string
+	PaymentID  string
+	Status     *string
+	Amount     int64
+	Currency   string
+	TeamSlug   string
+	PaymentUrl *string
+	CreatedAt  *time.Time
+	TwoStage   bool
 }
 
 func (q *Queries) InsertBookingPayment(ctx context.Context, arg InsertBookingPaymentParams) error {
@@ -358,6 +735,9 @@ func (q *Queries) InsertBookingPayment(ctx context.Context, arg InsertBookingPay
 		arg.Amount,
 		arg.Currency,
 		arg.TeamSlug,
+		arg.PaymentUrl,
+		arg.CreatedAt,
+		arg.TwoStage,
 	)
 	return err
 }
@@ -378,6 +758,188 @@ func (q *Queries) InsertBookingSeat(ctx context.Context, arg InsertBookingSeatPa
 	return err
 }
 
+const insertBookingStatusHistory = `-- name: InsertBookingStatusHistory :exec
+;
+
+INSERT INTO booking_status_history (booking_id, from_status, to_status, actor, reason, created_at)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+`
+
+type InsertBookingStatusHistoryParams struct {
+	BookingID  int64
+	FromStatus *domain.BookingStatus
+	ToStatus   domain.BookingStatus
+	Actor      string
+	Reason     *string
+	CreatedAt  time.Time
+}
+
+func (q *Queries) InsertBookingStatusHistory(ctx context.Context, arg InsertBookingStatusHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, insertBookingStatusHistory,
+		arg.BookingID,
+		arg.FromStatus,
+		arg.ToStatus,
+		arg.Actor,
+		arg.Reason,
+		arg.CreatedAt,
+	)
+	return err
+}
+
+const insertSplitBooking = `-- name: InsertSplitBooking :one
+;
+
+INSERT INTO bookings (user_id, event_id, status, parent_booking_id)
+VALUES (?1, ?2, 'CONFIRMED', ?3)
+RETURNING id
+`
+
+type InsertSplitBookingParams struct {
+	UserID          int64
+	EventID         int64
+	ParentBookingID *int64
+}
+
+func (q *Queries) InsertSplitBooking(ctx context.Context, arg InsertSplitBookingParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, insertSplitBooking, arg.UserID, arg.EventID, arg.ParentBookingID)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const markBookingSeatPlaceSelected = `-- name: MarkBookingSeatPlaceSelected :exec
+;
+
+UPDATE booking_seats
+SET place_selected_at = ?1
+WHERE booking_id = ?2
+  AND seat_id = ?3
+`
+
+type MarkBookingSeatPlaceSelectedParams struct {
+	PlaceSelectedAt *time.Time
+	BookingID       int64
+	SeatID          int64
+}
+
+func (q *Queries) MarkBookingSeatPlaceSelected(ctx context.Context, arg MarkBookingSeatPlaceSelectedParams) error {
+	_, err := q.db.ExecContext(ctx, markBookingSeatPlaceSelected, arg.PlaceSelectedAt, arg.BookingID, arg.SeatID)
+	return err
+}
+
+const moveBookingSeats = `-- name: MoveBookingSeats :execrows
+;
+
+UPDATE booking_seats
+SET booking_id = ?1,
+    user_id = ?2
+WHERE booking_id = ?3
+  AND seat_id IN (/*SLICE:seat_ids*/?)
+`
+
+type MoveBookingSeatsParams struct {
+	ToBookingID   int64
+	UserID        int64
+	FromBookingID int64
+	SeatIds       []int64
+}
+
+func (q *Queries) MoveBookingSeats(ctx context.Context, arg MoveBookingSeatsParams) (int64, error) {
+	query := moveBookingSeats
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.ToBookingID)
+	queryParams = append(queryParams, arg.UserID)
+	queryParams = append(queryParams, arg.FromBookingID)
+	if len(arg.SeatIds) > 0 {
+		for _, v := range arg.SeatIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:seat_ids*/?", strings.Repeat(",?", len(arg.SeatIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:seat_ids*/?", "NULL", 1)
+	}
+	result, err := q.db.ExecContext(ctx, query, queryParams...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resetBookingSeatPlacesSelected = `-- name: ResetBookingSeatPlacesSelected :exec
+;
+
+UPDATE booking_seats
+SET place_selected_at = NULL
+WHERE booking_id = ?1
+`
+
+func (q *Queries) ResetBookingSeatPlacesSelected(ctx context.Context, bookingID int64) error {
+	_, err := q.db.ExecContext(ctx, resetBookingSeatPlacesSelected, bookingID)
+	return err
+}
+
+const transferBooking = `-- name: TransferBooking :execrows
+;
+
+UPDATE bookings
+SET user_id = ?1
+WHERE id = ?2
+  AND user_id = ?3
+  AND status = 'CONFIRMED'
+`
+
+type TransferBookingParams struct {
+	ToUserID   int64
+	BookingID  int64
+	FromUserID int64
+}
+
+func (q *Queries) TransferBooking(ctx context.Context, arg TransferBookingParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, transferBooking, arg.ToUserID, arg.BookingID, arg.FromUserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateBookingExpiresAt = `-- name: UpdateBookingExpiresAt :exec
+;
+
+UPDATE bookings
+SET expires_at = ?1
+WHERE id = ?2
+`
+
+type UpdateBookingExpiresAtParams struct {
+	ExpiresAt *time.Time
+	BookingID int64
+}
+
+func (q *Queries) UpdateBookingExpiresAt(ctx context.Context, arg UpdateBookingExpiresAtParams) error {
+	_, err := q.db.ExecContext(ctx, updateBookingExpiresAt, arg.ExpiresAt, arg.BookingID)
+	return err
+}
+
+const updateBookingOrder = `-- name: UpdateBookingOrder :exec
+;
+
+UPDATE booking_orders
+SET order_id = ?1,
+    status = ?2
+WHERE booking_id = ?3
+`
+
+type UpdateBookingOrderParams struct {
+	OrderID   string
+	Status    *string
+	BookingID int64
+}
+
+func (q *Queries) UpdateBookingOrder(ctx context.Context, arg UpdateBookingOrderParams) error {
+	_, err := q.db.ExecContext(ctx, updateBookingOrder, arg.OrderID, arg.Status, arg.BookingID)
+	return err
+}
+
 const updateBookingOrderStatus = `-- name: UpdateBookingOrderStatus :exec
 ;
 
@@ -414,20 +976,43 @@ func (q *Queries) UpdateBookingPaymentStatus(ctx context.Context, arg UpdateBook
 	return err
 }
 
-const updateBookingStatus = `-- name: UpdateBookingStatus :exec
+const updateBookingSeatsUserID = `-- name: UpdateBookingSeatsUserID :exec
+;
+
+UPDATE booking_seats
+SET user_id = ?1
+WHERE booking_id = ?2
+`
+
+type UpdateBookingSeatsUserIDParams struct {
+	UserID    int64
+	BookingID int64
+}
+
+func (q *Queries) UpdateBookingSeatsUserID(ctx context.Context, arg UpdateBookingSeatsUserIDParams) error {
+	_, err := q.db.ExecContext(ctx, updateBookingSeatsUserID, arg.UserID, arg.BookingID)
+	return err
+}
+
+const updateBookingStatus = `-- name: UpdateBookingStatus :execrows
 ;
 
 UPDATE bookings 
 SET status = ?1
 WHERE id = ?2
+  AND status = ?3
 `
 
 type UpdateBookingStatusParams struct {
-	Status    string
+	Status    domain.BookingStatus
 	BookingID int64
+	StatusEq  domain.BookingStatus
 }
 
-func (q *Queries) UpdateBookingStatus(ctx context.Context, arg UpdateBookingStatusParams) error {
-	_, err := q.db.ExecContext(ctx, updateBookingStatus, arg.Status, arg.BookingID)
-	return err
+func (q *Queries) UpdateBookingStatus(ctx context.Context, arg UpdateBookingStatusParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateBookingStatus, arg.Status, arg.BookingID, arg.StatusEq)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
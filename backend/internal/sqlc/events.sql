@@ -4,10 +4,6 @@ select
     SUM(CASE WHEN s.status = 'SOLD' THEN 1 ELSE 0 END) as sold_seats,
     SUM(CASE WHEN s.status = 'RESERVED' THEN 1 ELSE 0 END) as reserved_seats,
     SUM(CASE WHEN s.status = 'FREE' THEN 1 ELSE 0 END) as free_seats,
-    cast(
-        (COALESCE(SUM(CASE WHEN s.status = 'SOLD' THEN CAST(s.price AS REAL) ELSE 0 END), 0))
-        as real
-    ) as total_revenue,
     (
         select COUNT(DISTINCT b.id) 
         from bookings b 
@@ -16,3 +12,19 @@ select
 from seats s
 where s.event_id = sqlc.arg(event_id)
 ;
+
+-- name: GetEvent :one
+select id, title, provider, currency from events_archive
+where id = sqlc.arg(event_id)
+;
+
+-- name: GetEventRevenueByCurrency :many
+select
+    s.currency,
+    cast(COALESCE(SUM(s.price), 0) as integer) as revenue
+from seats s
+where s.event_id = sqlc.arg(event_id)
+  and s.status = 'SOLD'
+group by s.currency
+order by s.currency
+;
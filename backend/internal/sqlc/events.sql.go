@@ -9,16 +9,38 @@ import (
 	"context"
 )
 
+const getEvent = `-- name: GetEvent :one
+;
+
+select id, title, provider, currency from events_archive
+where id = ?1
+`
+
+type GetEventRow struct {
+	ID       int64
+	Title    *string
+	Provider *string
+	Currency string
+}
+
+func (q *Queries) GetEvent(ctx context.Context, eventID int64) (GetEventRow, error) {
+	row := q.db.QueryRowContext(ctx, getEvent, eventID)
+	var i GetEventRow
+	err := row.Scan(
+		&i.ID,
+		&i.Title,
+		&i.Provider,
+		&i.Currency,
+	)
+	return i, err
+}
+
 const getEventAnalytics = `-- name: GetEventAnalytics :one
 select
     COUNT(*) as total_seats,
     SUM(CASE WHEN s.status = 'SOLD' THEN 1 ELSE 0 END) as sold_seats,
     SUM(CASE WHEN s.status = 'RESERVED' THEN 1 ELSE 0 END) as reserved_seats,
     SUM(CASE WHEN s.status = 'FREE' THEN 1 ELSE 0 END) as free_seats,
-    cast(
-        (COALESCE(SUM(CASE WHEN s.status = 'SOLD' THEN CAST(s.price AS REAL) ELSE 0 END), 0))
-        as real
-    ) as total_revenue,
     (
         select COUNT(DISTINCT b.id) 
         from bookings b 
@@ -33,7 +55,6 @@ type GetEventAnalyticsRow struct {
 	SoldSeats     *float64
 	ReservedSeats *float64
 	FreeSeats     *float64
-	TotalRevenue  float64
 	BookingsCount int64
 }
 
@@ -45,8 +66,48 @@ func (q *Queries) GetEventAnalytics(ctx context.Context, eventID int64) (GetEven
 		&i.SoldSeats,
 		&i.ReservedSeats,
 		&i.FreeSeats,
-		&i.TotalRevenue,
 		&i.BookingsCount,
 	)
 	return i, err
 }
+
+const getEventRevenueByCurrency = `-- name: GetEventRevenueByCurrency :many
+;
+
+select
+    s.currency,
+    cast(COALESCE(SUM(s.price), 0) as integer) as revenue
+from seats s
+where s.event_id = ?1
+  and s.status = 'SOLD'
+group by s.currency
+order by s.currency
+`
+
+type GetEventRevenueByCurrencyRow struct {
+	Currency string
+	Revenue  int64
+}
+
+func (q *Queries) GetEventRevenueByCurrency(ctx context.Context, eventID int64) ([]GetEventRevenueByCurrencyRow, error) {
+	rows, err := q.db.QueryContext(ctx, getEventRevenueByCurrency, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetEventRevenueByCurrencyRow
+	for rows.Next() {
+		var i GetEventRevenueByCurrencyRow
+		if err := rows.Scan(&i.Currency, &i.Revenue); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,39 @@
+-- name: ClaimIdempotencyKey :execrows
+INSERT INTO idempotency_keys (user_id, key, request_hash, created_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(key), sqlc.arg(request_hash), sqlc.arg(created_at))
+ON CONFLICT (user_id, key) DO NOTHING
+;
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE user_id = sqlc.arg(user_id) AND key = sqlc.arg(key)
+;
+
+-- name: SaveIdempotencyKeyResponse :exec
+UPDATE idempotency_keys
+SET status_code = sqlc.arg(status_code),
+    content_type = sqlc.narg(content_type),
+    location = sqlc.narg(location),
+    body = sqlc.arg(body)
+WHERE user_id = sqlc.arg(user_id) AND key = sqlc.arg(key)
+;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE user_id = sqlc.arg(user_id) AND key = sqlc.arg(key)
+;
+
+-- name: DeleteExpiredIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE user_id = sqlc.arg(user_id) AND key = sqlc.arg(key)
+  AND (created_at < sqlc.arg(expired_before)
+    OR (status_code IS NULL AND created_at < sqlc.arg(in_progress_expired_before)))
+;
+
+-- name: DeleteExpiredIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE created_at < sqlc.arg(expired_before)
+;
+
+-- name: DeleteAllIdempotencyKeys :execresult
+DELETE FROM idempotency_keys;
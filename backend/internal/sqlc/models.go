@@ -6,13 +6,17 @@ package sqlc
 
 import (
 	"time"
+
+	"hackload/internal/domain"
 )
 
 type Booking struct {
-	ID      int64
-	UserID  int64
-	EventID int64
-	Status  string
+	ID              int64
+	UserID          int64
+	EventID         int64
+	Status          domain.BookingStatus
+	ExpiresAt       *time.Time
+	ParentBookingID *int64
 }
 
 type BookingOrder struct {
@@ -23,14 +27,41 @@ type BookingOrder struct {
 }
 
 type BookingPayment struct {
-	ID        int64
-	BookingID int64
-	OrderID   string
-	Status    *string
-	PaymentID string
-	Amount    int64
-	Currency  string
-	TeamSlug  string
+	ID           int64
+	BookingID    int64
+	OrderID      string
+	Status       *string
+	PaymentID    string
+	Amount       int64
+	Currency     string
+	TeamSlug     string
+	PaymentUrl   *string
+	ReviewReason *string
+	CreatedAt    *time.Time
+	TwoStage     bool
+}
+
+type BookingRefund struct {
+	ID               int64
+	BookingPaymentID int64
+	BookingID        int64
+	SeatID           *int64
+	Amount           int64
+	Currency         string
+	Status           string
+	CreatedAt        time.Time
+	RefundedAt       *time.Time
+}
+
+type IdempotencyKey struct {
+	UserID      int64
+	Key         string
+	RequestHash string
+	StatusCode  *int64
+	ContentType *string
+	Location    *string
+	Body        []byte
+	CreatedAt   time.Time
 }
 
 type Seat struct {
@@ -39,8 +70,9 @@ type Seat struct {
 	ExternalID *string
 	Row        int64
 	Number     int64
-	Price      string
 	Status     string
+	Price      domain.Money
+	Currency   string
 }
 
 type User struct {
@@ -55,3 +87,14 @@ type User struct {
 	IsActive      bool
 	LastLoggedIn  time.Time
 }
+
+type WaitlistEntry struct {
+	ID             int64
+	EventID        int64
+	UserID         int64
+	SeatsCount     int64
+	Status         domain.WaitlistStatus
+	BookingID      *int64
+	OfferExpiresAt *time.Time
+	CreatedAt      time.Time
+}
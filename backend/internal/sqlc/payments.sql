@@ -0,0 +1,33 @@
+-- name: InsertPaymentNotification :exec
+INSERT INTO payment_notifications (order_id, payment_id, status, body, verified, received_at)
+VALUES (sqlc.narg(order_id), sqlc.narg(payment_id), sqlc.narg(status), sqlc.arg(body), sqlc.arg(verified), sqlc.arg(received_at))
+;
+
+-- name: GetBookingPaymentByOrderID :one
+SELECT * FROM booking_payments
+WHERE order_id = sqlc.arg(order_id)
+;
+
+-- name: GetBookingPaymentByID :one
+SELECT * FROM booking_payments
+WHERE id = sqlc.arg(booking_payment_id)
+;
+
+-- name: FlagBookingPaymentForReview :exec
+UPDATE booking_payments
+SET review_reason = sqlc.arg(review_reason)
+WHERE order_id = sqlc.arg(order_id)
+;
+
+-- name: GetStaleInitPayments :many
+SELECT * FROM booking_payments
+WHERE status = 'INIT'
+  AND (created_at IS NULL OR created_at < sqlc.arg(created_before))
+  AND review_reason IS NULL
+ORDER BY id
+LIMIT sqlc.arg(limit)
+;
+
+-- name: DeleteAllPaymentNotifications :execresult
+DELETE FROM payment_notifications
+;
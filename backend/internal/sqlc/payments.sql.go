@@ -0,0 +1,172 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: payments.sql
+
+package sqlc
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const deleteAllPaymentNotifications = `-- name: DeleteAllPaymentNotifications :execresult
+;
+
+DELETE FROM payment_notifications
+`
+
+func (q *Queries) DeleteAllPaymentNotifications(ctx context.Context) (sql.Result, error) {
+	return q.db.ExecContext(ctx, deleteAllPaymentNotifications)
+}
+
+const flagBookingPaymentForReview = `-- name: FlagBookingPaymentForReview :exec
+;
+
+UPDATE booking_payments
+SET review_reason = ?1
+WHERE order_id = ?2
+`
+
+type FlagBookingPaymentForReviewParams struct {
+	ReviewReason *string
+	OrderID      string
+}
+
+func (q *Queries) FlagBookingPaymentForReview(ctx context.Context, arg FlagBookingPaymentForReviewParams) error {
+	_, err := q.db.ExecContext(ctx, flagBookingPaymentForReview, arg.ReviewReason, arg.OrderID)
+	return err
+}
+
+const getBookingPaymentByID = `-- name: GetBookingPaymentByID :one
+;
+
+SELECT id, booking_id, order_id, status, payment_id, amount, currency, team_slug, payment_url, review_reason, created_at, two_stage FROM booking_payments
+WHERE id = ?1
+`
+
+func (q *Queries) GetBookingPaymentByID(ctx context.Context, bookingPaymentID int64) (BookingPayment, error) {
+	row := q.db.QueryRowContext(ctx, getBookingPaymentByID, bookingPaymentID)
+	var i BookingPayment
+	err := row.Scan(
+		&i.ID,
+		&i.BookingID,
+		&i.OrderID,
+		&i.Status,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.TeamSlug,
+		&i.PaymentUrl,
+		&i.ReviewReason,
+		&i.CreatedAt,
+		&i.TwoStage,
+	)
+	return i, err
+}
+
+const getBookingPaymentByOrderID = `-- name: GetBookingPaymentByOrderID :one
+;
+
+SELECT id, booking_id, order_id, status, payment_id, amount, currency, team_slug, payment_url, review_reason, created_at, two_stage FROM booking_payments
+WHERE order_id = ?1
+`
+
+func (q *Queries) GetBookingPaymentByOrderID(ctx context.Context, orderID string) (BookingPayment, error) {
+	row := q.db.QueryRowContext(ctx, getBookingPaymentByOrderID, orderID)
+	var i BookingPayment
+	err := row.Scan(
+		&i.ID,
+		&i.BookingID,
+		&i.OrderID,
+		&i.Status,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.TeamSlug,
+		&i.PaymentUrl,
+		&i.ReviewReason,
+		&i.CreatedAt,
+		&i.TwoStage,
+	)
+	return i, err
+}
+
+const getStaleInitPayments = `-- name: GetStaleInitPayments :many
+;
+
+SELECT id, booking_id, order_id, status, payment_id, amount, currency, team_slug, payment_url, review_reason, created_at, two_stage FROM booking_payments
+WHERE status = 'INIT'
+  AND (created_at IS NULL OR created_at < ?1)
+  AND review_reason IS NULL
+ORDER BY id
+LIMIT ?2
+`
+
+type GetStaleInitPaymentsParams struct {
+	CreatedBefore *time.Time
+	Limit         int64
+}
+
+func (q *Queries) GetStaleInitPayments(ctx context.Context, arg GetStaleInitPaymentsParams) ([]BookingPayment, error) {
+	rows, err := q.db.QueryContext(ctx, getStaleInitPayments, arg.CreatedBefore, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BookingPayment
+	for rows.Next() {
+		var i BookingPayment
+		if err := rows.Scan(
+			&i.ID,
+			&i.BookingID,
+			&i.OrderID,
+			&i.Status,
+			&i.PaymentID,
+			&i.Amount,
+			&i.Currency,
+			&i.TeamSlug,
+			&i.PaymentUrl,
+			&i.ReviewReason,
+			&i.CreatedAt,
+			&i.TwoStage,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertPaymentNotification = `-- name: InsertPaymentNotification :exec
+INSERT INTO payment_notifications (order_id, payment_id, status, body, verified, received_at)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+`
+
+type InsertPaymentNotificationParams struct {
+	OrderID    *string
+	PaymentID  *string
+	Status     *string
+	Body       string
+	Verified   bool
+	ReceivedAt time.Time
+}
+
+func (q *Queries) InsertPaymentNotification(ctx context.Context, arg InsertPaymentNotificationParams) error {
+	_, err := q.db.ExecContext(ctx, insertPaymentNotification,
+		arg.OrderID,
+		arg.PaymentID,
+		arg.Status,
+		arg.Body,
+		arg.Verified,
+		arg.ReceivedAt,
+	)
+	return err
+}
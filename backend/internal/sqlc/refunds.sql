@@ -0,0 +1,33 @@
+-- name: InsertBookingRefund :one
+INSERT INTO booking_refunds (booking_payment_id, booking_id, seat_id, amount, currency, status, created_at, refunded_at)
+VALUES (sqlc.arg(booking_payment_id), sqlc.arg(booking_id), sqlc.narg(seat_id), sqlc.arg(amount), sqlc.arg(currency), sqlc.arg(status), sqlc.arg(created_at), sqlc.narg(refunded_at))
+RETURNING id
+;
+
+-- name: GetBookingRefund :one
+SELECT * FROM booking_refunds
+WHERE id = sqlc.arg(refund_id)
+;
+
+-- name: UpdateBookingRefundStatus :exec
+UPDATE booking_refunds
+SET status = sqlc.arg(status),
+    refunded_at = sqlc.narg(refunded_at)
+WHERE id = sqlc.arg(refund_id)
+;
+
+-- name: GetBookingPaymentRefundedAmount :one
+SELECT CAST(COALESCE(SUM(amount), 0) AS INTEGER) AS refunded
+FROM booking_refunds
+WHERE booking_payment_id = sqlc.arg(booking_payment_id)
+;
+
+-- name: CountPendingBookingRefunds :one
+SELECT COUNT(*) FROM booking_refunds
+WHERE booking_id = sqlc.arg(booking_id)
+  AND status != 'REFUNDED'
+;
+
+-- name: DeleteAllBookingRefunds :execresult
+DELETE FROM booking_refunds
+;
@@ -0,0 +1,129 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: refunds.sql
+
+package sqlc
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const countPendingBookingRefunds = `-- name: CountPendingBookingRefunds :one
+;
+
+SELECT COUNT(*) FROM booking_refunds
+WHERE booking_id = ?1
+  AND status != 'REFUNDED'
+`
+
+func (q *Queries) CountPendingBookingRefunds(ctx context.Context, bookingID int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPendingBookingRefunds, bookingID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteAllBookingRefunds = `-- name: DeleteAllBookingRefunds :execresult
+;
+
+DELETE FROM booking_refunds
+`
+
+func (q *Queries) DeleteAllBookingRefunds(ctx context.Context) (sql.Result, error) {
+	return q.db.ExecContext(ctx, deleteAllBookingRefunds)
+}
+
+const getBookingPaymentRefundedAmount = `-- name: GetBookingPaymentRefundedAmount :one
+;
+
+SELECT CAST(COALESCE(SUM(amount), 0) AS INTEGER) AS refunded
+FROM booking_refunds
+WHERE booking_payment_id = ?1
+`
+
+func (q *Queries) GetBookingPaymentRefundedAmount(ctx context.Context, bookingPaymentID int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getBookingPaymentRefundedAmount, bookingPaymentID)
+	var refunded int64
+	err := row.Scan(&refunded)
+	return refunded, err
+}
+
+const getBookingRefund = `-- name: GetBookingRefund :one
+;
+
+SELECT id, booking_payment_id, booking_id, seat_id, amount, currency, status, created_at, refunded_at FROM booking_refunds
+WHERE id = ?1
+`
+
+func (q *Queries) GetBookingRefund(ctx context.Context, refundID int64) (BookingRefund, error) {
+	row := q.db.QueryRowContext(ctx, getBookingRefund, refundID)
+	var i BookingRefund
+	err := row.Scan(
+		&i.ID,
+		&i.BookingPaymentID,
+		&i.BookingID,
+		&i.SeatID,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.CreatedAt,
+		&i.RefundedAt,
+	)
+	return i, err
+}
+
+const insertBookingRefund = `-- name: InsertBookingRefund :one
+INSERT INTO booking_refunds (booking_payment_id, booking_id, seat_id, amount, currency, status, created_at, refunded_at)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8)
+RETURNING id
+`
+
+type InsertBookingRefundParams struct {
+	BookingPaymentID int64
+	BookingID        int64
+	SeatID           *int64
+	Amount           int64
+	Currency         string
+	Status           string
+	CreatedAt        time.Time
+	RefundedAt       *time.Time
+}
+
+func (q *Queries) InsertBookingRefund(ctx context.Context, arg InsertBookingRefundParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, insertBookingRefund,
+		arg.BookingPaymentID,
+		arg.BookingID,
+		arg.SeatID,
+		arg.Amount,
+		arg.Currency,
+		arg.Status,
+		arg.CreatedAt,
+		arg.RefundedAt,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const updateBookingRefundStatus = `-- name: UpdateBookingRefundStatus :exec
+;
+
+UPDATE booking_refunds
+SET status = ?1,
+    refunded_at = ?2
+WHERE id = ?3
+`
+
+type UpdateBookingRefundStatusParams struct {
+	Status     string
+	RefundedAt *time.Time
+	RefundID   int64
+}
+
+func (q *Queries) UpdateBookingRefundStatus(ctx context.Context, arg UpdateBookingRefundStatusParams) error {
+	_, err := q.db.ExecContext(ctx, updateBookingRefundStatus, arg.Status, arg.RefundedAt, arg.RefundID)
+	return err
+}
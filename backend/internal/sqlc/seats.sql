@@ -12,6 +12,10 @@ where 1=1
     cast(sqlc.narg('status') as text) is null
     or cast(sqlc.narg('status') as text) = s.status
   )
+  and (
+    cast(sqlc.narg('currency') as text) is null
+    or cast(sqlc.narg('currency') as text) = s.currency
+  )
 limit sqlc.arg(limit)
 offset sqlc.arg(offset)
 ;
@@ -28,6 +32,53 @@ set status = sqlc.arg(status)
 where id IN (sqlc.slice(seat_ids))
 ;
 
+-- name: ClaimSeatForBooking :execrows
+update seats
+set status = 'RESERVED'
+where seats.id = sqlc.arg(seat_id)
+  and seats.status = 'FREE'
+  and seats.event_id = (
+    select b.event_id from bookings b
+    where b.id = sqlc.arg(booking_id)
+      and b.user_id = sqlc.arg(user_id)
+      and b.status = 'CREATED'
+  )
+;
+
+-- name: GetSeatsByIDs :many
+select * from seats
+where id IN (sqlc.slice(seat_ids))
+;
+
+-- name: ReserveFreeSeatsByIDs :execrows
+update seats
+set status = 'RESERVED'
+where id IN (sqlc.slice(seat_ids))
+  and status = 'FREE'
+;
+
+-- name: GetSeatsByExternalIDs :many
+select
+  s.id,
+  s.event_id,
+  s.external_id,
+  s.status,
+  bs.booking_id
+from seats s
+left join booking_seats bs on bs.seat_id = s.id
+where s.external_id IN (sqlc.slice(external_ids))
+;
+
+-- name: UpdateUnbookedSeatStatus :execrows
+update seats
+set status = sqlc.arg(status)
+where id = sqlc.arg(seat_id)
+  and status = sqlc.arg(status_eq)
+  and not exists (
+    select 1 from booking_seats bs where bs.seat_id = seats.id
+  )
+;
+
 -- name: GetSeatByID :one
 select * from seats
 where id = sqlc.arg(seat_id)
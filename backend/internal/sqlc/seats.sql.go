@@ -9,8 +9,39 @@ import (
 	"context"
 	"database/sql"
 	"strings"
+
+	"hackload/internal/domain"
 )
 
+const claimSeatForBooking = `-- name: ClaimSeatForBooking :execrows
+;
+
+update seats
+set status = 'RESERVED'
+where seats.id = ?1
+  and seats.status = 'FREE'
+  and seats.event_id = (
+    select b.event_id from bookings b
+    where b.id = ?2
+      and b.user_id = ?3
+      and b.status = 'CREATED'
+  )
+`
+
+type ClaimSeatForBookingParams struct {
+	SeatID    int64
+	BookingID int64
+	UserID    int64
+}
+
+func (q *Queries) ClaimSeatForBooking(ctx context.Context, arg ClaimSeatForBookingParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimSeatForBooking, arg.SeatID, arg.BookingID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteAllSeats = `-- name: DeleteAllSeats :execresult
 ;
 
@@ -24,7 +55,7 @@ func (q *Queries) DeleteAllSeats(ctx context.Context) (sql.Result, error) {
 const getSeatByID = `-- name: GetSeatByID :one
 ;
 
-select id, event_id, external_id, "row", number, price, status from seats
+select id, event_id, external_id, "row", number, status, price, currency from seats
 where id = ?1
 `
 
@@ -37,15 +68,16 @@ func (q *Queries) GetSeatByID(ctx context.Context, seatID int64) (Seat, error) {
 		&i.ExternalID,
 		&i.Row,
 		&i.Number,
-		&i.Price,
 		&i.Status,
+		&i.Price,
+		&i.Currency,
 	)
 	return i, err
 }
 
 const getSeats = `-- name: GetSeats :many
 select
-  id, event_id, external_id, "row", number, price, status
+  id, event_id, external_id, "row", number, status, price, currency
 from seats s
 where 1=1
   and ?1 = s.event_id
@@ -57,16 +89,21 @@ where 1=1
     cast(?3 as text) is null
     or cast(?3 as text) = s.status
   )
-limit ?5
-offset ?4
+  and (
+    cast(?4 as text) is null
+    or cast(?4 as text) = s.currency
+  )
+limit ?6
+offset ?5
 `
 
 type GetSeatsParams struct {
-	EventID int64
-	Row     *int64
-	Status  *string
-	Offset  int64
-	Limit   int64
+	EventID  int64
+	Row      *int64
+	Status   *string
+	Currency *string
+	Offset   int64
+	Limit    int64
 }
 
 func (q *Queries) GetSeats(ctx context.Context, arg GetSeatsParams) ([]Seat, error) {
@@ -74,6 +111,7 @@ func (q *Queries) GetSeats(ctx context.Context, arg GetSeatsParams) ([]Seat, err
 		arg.EventID,
 		arg.Row,
 		arg.Status,
+		arg.Currency,
 		arg.Offset,
 		arg.Limit,
 	)
@@ -90,8 +128,119 @@ func (q *Queries) GetSeats(ctx context.Context, arg GetSeatsParams) ([]Seat, err
 			&i.ExternalID,
 			&i.Row,
 			&i.Number,
+			&i.Status,
 			&i.Price,
+			&i.Currency,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSeatsByExternalIDs = `-- name: GetSeatsByExternalIDs :many
+;
+
+select
+  s.id,
+  s.event_id,
+  s.external_id,
+  s.status,
+  bs.booking_id
+from seats s
+left join booking_seats bs on bs.seat_id = s.id
+where s.external_id IN (/*SLICE:external_ids*/?)
+`
+
+type GetSeatsByExternalIDsRow struct {
+	ID         int64
+	EventID    int64
+	ExternalID *string
+	Status     string
+	BookingID  *int64
+}
+
+func (q *Queries) GetSeatsByExternalIDs(ctx context.Context, externalIds []*string) ([]GetSeatsByExternalIDsRow, error) {
+	query := getSeatsByExternalIDs
+	var queryParams []interface{}
+	if len(externalIds) > 0 {
+		for _, v := range externalIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:external_ids*/?", strings.Repeat(",?", len(externalIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:external_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetSeatsByExternalIDsRow
+	for rows.Next() {
+		var i GetSeatsByExternalIDsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.ExternalID,
+			&i.Status,
+			&i.BookingID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSeatsByIDs = `-- name: GetSeatsByIDs :many
+;
+
+select id, event_id, external_id, "row", number, status, price, currency from seats
+where id IN (/*SLICE:seat_ids*/?)
+`
+
+func (q *Queries) GetSeatsByIDs(ctx context.Context, seatIds []int64) ([]Seat, error) {
+	query := getSeatsByIDs
+	var queryParams []interface{}
+	if len(seatIds) > 0 {
+		for _, v := range seatIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:seat_ids*/?", strings.Repeat(",?", len(seatIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:seat_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Seat
+	for rows.Next() {
+		var i Seat
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.ExternalID,
+			&i.Row,
+			&i.Number,
 			&i.Status,
+			&i.Price,
+			&i.Currency,
 		); err != nil {
 			return nil, err
 		}
@@ -116,7 +265,7 @@ type InsertSeatParams struct {
 	ExternalID *string
 	Row        int64
 	Number     int64
-	Price      string
+	Price      domain.Money
 	Status     string
 }
 
@@ -132,6 +281,33 @@ func (q *Queries) InsertSeat(ctx context.Context, arg InsertSeatParams) error {
 	return err
 }
 
+const reserveFreeSeatsByIDs = `-- name: ReserveFreeSeatsByIDs :execrows
+;
+
+update seats
+set status = 'RESERVED'
+where id IN (/*SLICE:seat_ids*/?)
+  and status = 'FREE'
+`
+
+func (q *Queries) ReserveFreeSeatsByIDs(ctx context.Context, seatIds []int64) (int64, error) {
+	query := reserveFreeSeatsByIDs
+	var queryParams []interface{}
+	if len(seatIds) > 0 {
+		for _, v := range seatIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:seat_ids*/?", strings.Repeat(",?", len(seatIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:seat_ids*/?", "NULL", 1)
+	}
+	result, err := q.db.ExecContext(ctx, query, queryParams...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateSeatStatus = `-- name: UpdateSeatStatus :exec
 ;
 
@@ -178,3 +354,29 @@ func (q *Queries) UpdateSeatsStatusByIDs(ctx context.Context, arg UpdateSeatsSta
 	_, err := q.db.ExecContext(ctx, query, queryParams...)
 	return err
 }
+
+const updateUnbookedSeatStatus = `-- name: UpdateUnbookedSeatStatus :execrows
+;
+
+update seats
+set status = ?1
+where id = ?2
+  and status = ?3
+  and not exists (
+    select 1 from booking_seats bs where bs.seat_id = seats.id
+  )
+`
+
+type UpdateUnbookedSeatStatusParams struct {
+	Status   string
+	SeatID   int64
+	StatusEq string
+}
+
+func (q *Queries) UpdateUnbookedSeatStatus(ctx context.Context, arg UpdateUnbookedSeatStatusParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUnbookedSeatStatus, arg.Status, arg.SeatID, arg.StatusEq)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,104 @@
+package sqlc
+
+import (
+	"context"
+
+	"hackload/internal/domain"
+)
+
+// sqlc cannot parse window functions in the sqlite engine, so this query is written by hand.
+//
+// Ищет count подряд свободных мест в одном ряду с наименьшей суммарной ценой, при равной цене - ближе к началу зала.
+// У подряд идущих мест разность номера и порядкового номера в ряду одинакова (grp).
+// Окно из count мест начинается с каждого места блока, где после него еще хватает мест (end_number),
+// его цена - разность нарастающих сумм цен (price_to) на концах окна.
+// Фильтр event_id + status = 'FREE' + сортировка row, number попадают в idx_seats_event_status_free.
+const findBestAvailableSeats = `
+with free as (
+  select
+    s.id,
+    s.row,
+    s.number,
+    s.price,
+    s.currency,
+    s.number - row_number() over (partition by s.row order by s.number) as grp
+  from seats s
+  where s.event_id = ?1
+    and s.status = 'FREE'
+    and s.currency = ?6
+    and (cast(?2 as integer) is null or s.price = cast(?2 as integer))
+    and (cast(?3 as integer) is null or s.row >= cast(?3 as integer))
+    and (cast(?4 as integer) is null or s.row <= cast(?4 as integer))
+),
+runs as (
+  select row, grp, number, price,
+    sum(price) over (partition by row, grp order by number) as price_to
+  from free
+),
+windows as (
+  select row, number as start_number,
+    lead(number, ?5 - 1) over w as end_number,
+    lead(price_to, ?5 - 1) over w - price_to + price as total_price
+  from runs
+  window w as (partition by row, grp order by number)
+),
+best as (
+  select row, start_number, end_number
+  from windows
+  where end_number is not null
+  order by total_price, row, start_number
+  limit 1
+)
+select f.id, f.row, f.number, f.price, f.currency
+from free f
+join best b on b.row = f.row and f.number between b.start_number and b.end_number
+order by f.number
+`
+
+type FindBestAvailableSeatsParams struct {
+	EventID  int64
+	Price    *domain.Money
+	RowFrom  *int64
+	RowTo    *int64
+	Count    int64
+	Currency string
+}
+
+type FindBestAvailableSeatsRow struct {
+	ID       int64
+	Row      int64
+	Number   int64
+	Price    domain.Money
+	Currency string
+}
+
+// FindBestAvailableSeats returns the cheapest Count adjacent FREE seats in one row, or nothing if there is no such block.
+func (q *Queries) FindBestAvailableSeats(ctx context.Context, arg FindBestAvailableSeatsParams) ([]FindBestAvailableSeatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, findBestAvailableSeats,
+		arg.EventID,
+		arg.Price,
+		arg.RowFrom,
+		arg.RowTo,
+		arg.Count,
+		arg.Currency,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FindBestAvailableSeatsRow
+	for rows.Next() {
+		var i FindBestAvailableSeatsRow
+		if err := rows.Scan(&i.ID, &i.Row, &i.Number, &i.Price, &i.Currency); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
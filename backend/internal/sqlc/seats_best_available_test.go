@@ -0,0 +1,93 @@
+//go:build sqlite_fts5
+
+package sqlc_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"hackload/internal/sqlc"
+	"hackload/internal/testenv"
+)
+
+func TestFindBestAvailableSeats(t *testing.T) {
+	deps := testenv.New(t)
+	queries := sqlc.New(deps.DB)
+
+	// Ряд 1: 1-3 по 300, 4 занято, 5-7 по 100, 8 по 500.
+	// Ряд 2: 1-4 по 100, 5 по 50 - самое дешевое окно из двух мест, 4-5.
+	// Ряд 3: 1-2 по 75 - окно той же цены, что 4-5 в ряду 2, но в дальнем ряду
+	seats := []struct {
+		row, number, price int64
+		status             string
+	}{
+		{1, 1, 30000, "FREE"}, {1, 2, 30000, "FREE"}, {1, 3, 30000, "FREE"}, {1, 4, 10000, "SOLD"},
+		{1, 5, 10000, "FREE"}, {1, 6, 10000, "FREE"}, {1, 7, 10000, "FREE"}, {1, 8, 50000, "FREE"},
+		{2, 1, 10000, "FREE"}, {2, 2, 10000, "FREE"}, {2, 3, 10000, "FREE"}, {2, 4, 10000, "FREE"}, {2, 5, 5000, "FREE"},
+		{3, 1, 7500, "FREE"}, {3, 2, 7500, "FREE"},
+	}
+	if _, err := deps.DB.Exec(`INSERT INTO events_archive (id, title, datetime_start) VALUES (1, 'Concert', '2025-01-01T20:00:00')`); err != nil {
+		t.Fatal(err)
+	}
+	for _, seat := range seats {
+		if _, err := deps.DB.Exec(`INSERT INTO seats (event_id, row, number, price, status) VALUES (1, ?, ?, ?, ?)`,
+			seat.row, seat.number, seat.price, seat.status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	int64Ptr := func(v int64) *int64 { return &v }
+
+	type place struct{ row, number int64 }
+	tests := []struct {
+		name   string
+		params sqlc.FindBestAvailableSeatsParams
+		want   []place
+	}{
+		{
+			name:   "cheapest window inside a block, nearer row on a tie",
+			params: sqlc.FindBestAvailableSeatsParams{Count: 2},
+			want:   []place{{2, 4}, {2, 5}},
+		},
+		{
+			name:   "cheapest block of three",
+			params: sqlc.FindBestAvailableSeatsParams{Count: 3},
+			want:   []place{{2, 3}, {2, 4}, {2, 5}},
+		},
+		{
+			name:   "row range",
+			params: sqlc.FindBestAvailableSeatsParams{Count: 2, RowFrom: int64Ptr(3)},
+			want:   []place{{3, 1}, {3, 2}},
+		},
+		{
+			name:   "taken seat splits the row",
+			params: sqlc.FindBestAvailableSeatsParams{Count: 4, RowTo: int64Ptr(1)},
+			want:   []place{{1, 5}, {1, 6}, {1, 7}, {1, 8}},
+		},
+		{
+			name:   "no block long enough",
+			params: sqlc.FindBestAvailableSeatsParams{Count: 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params.EventID = 1
+			tt.params.Currency = "KZT"
+
+			rows, err := queries.FindBestAvailableSeats(context.Background(), tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []place
+			for _, row := range rows {
+				got = append(got, place{row.Row, row.Number})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+-- name: InsertWaitlistEntry :one
+INSERT INTO waitlist_entries (event_id, user_id, seats_count, status, created_at)
+VALUES (sqlc.arg(event_id), sqlc.arg(user_id), sqlc.arg(seats_count), 'WAITING', sqlc.arg(created_at))
+RETURNING id
+;
+
+-- name: GetWaitlistEntry :one
+SELECT * FROM waitlist_entries
+WHERE id = sqlc.arg(entry_id)
+;
+
+-- name: GetWaitlistEntriesByUserID :many
+SELECT * FROM waitlist_entries
+WHERE user_id = sqlc.arg(user_id)
+ORDER BY id DESC
+;
+
+-- name: GetNextWaitingWaitlistEntry :one
+SELECT * FROM waitlist_entries
+WHERE event_id = sqlc.arg(event_id)
+  AND status = 'WAITING'
+ORDER BY id
+LIMIT 1
+;
+
+-- name: CountWaitingWaitlistEntries :one
+SELECT COUNT(*) FROM waitlist_entries
+WHERE event_id = sqlc.arg(event_id)
+  AND status = 'WAITING'
+;
+
+-- name: GetWaitlistPosition :one
+SELECT COUNT(*) FROM waitlist_entries
+WHERE event_id = sqlc.arg(event_id)
+  AND status = 'WAITING'
+  AND id <= sqlc.arg(entry_id)
+;
+
+-- name: OfferWaitlistEntry :execrows
+UPDATE waitlist_entries
+SET status = 'OFFERED',
+    booking_id = sqlc.arg(booking_id),
+    offer_expires_at = sqlc.arg(offer_expires_at)
+WHERE id = sqlc.arg(entry_id)
+  AND status = 'WAITING'
+;
+
+-- name: UpdateWaitlistEntryStatus :execrows
+UPDATE waitlist_entries
+SET status = sqlc.arg(status)
+WHERE id = sqlc.arg(entry_id)
+  AND status = sqlc.arg(status_eq)
+;
+
+-- name: DeleteAllWaitlistEntries :execresult
+DELETE FROM waitlist_entries;
@@ -0,0 +1,222 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: waitlist.sql
+
+package sqlc
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"hackload/internal/domain"
+)
+
+const countWaitingWaitlistEntries = `-- name: CountWaitingWaitlistEntries :one
+;
+
+SELECT COUNT(*) FROM waitlist_entries
+WHERE event_id = ?1
+  AND status = 'WAITING'
+`
+
+func (q *Queries) CountWaitingWaitlistEntries(ctx context.Context, eventID int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countWaitingWaitlistEntries, eventID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteAllWaitlistEntries = `-- name: DeleteAllWaitlistEntries :execresult
+;
+
+DELETE FROM waitlist_entries
+`
+
+func (q *Queries) DeleteAllWaitlistEntries(ctx context.Context) (sql.Result, error) {
+	return q.db.ExecContext(ctx, deleteAllWaitlistEntries)
+}
+
+const getNextWaitingWaitlistEntry = `-- name: GetNextWaitingWaitlistEntry :one
+;
+
+SELECT id, event_id, user_id, seats_count, status, booking_id, offer_expires_at, created_at FROM waitlist_entries
+WHERE event_id = ?1
+  AND status = 'WAITING'
+ORDER BY id
+LIMIT 1
+`
+
+func (q *Queries) GetNextWaitingWaitlistEntry(ctx context.Context, eventID int64) (WaitlistEntry, error) {
+	row := q.db.QueryRowContext(ctx, getNextWaitingWaitlistEntry, eventID)
+	var i WaitlistEntry
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.UserID,
+		&i.SeatsCount,
+		&i.Status,
+		&i.BookingID,
+		&i.OfferExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getWaitlistEntriesByUserID = `-- name: GetWaitlistEntriesByUserID :many
+;
+
+SELECT id, event_id, user_id, seats_count, status, booking_id, offer_expires_at, created_at FROM waitlist_entries
+WHERE user_id = ?1
+ORDER BY id DESC
+`
+
+func (q *Queries) GetWaitlistEntriesByUserID(ctx context.Context, userID int64) ([]WaitlistEntry, error) {
+	rows, err := q.db.QueryContext(ctx, getWaitlistEntriesByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WaitlistEntry
+	for rows.Next() {
+		var i WaitlistEntry
+		if err := rows.Scan(
+			&i.ID,
+			&i.EventID,
+			&i.UserID,
+			&i.SeatsCount,
+			&i.Status,
+			&i.BookingID,
+			&i.OfferExpiresAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWaitlistEntry = `-- name: GetWaitlistEntry :one
+;
+
+SELECT id, event_id, user_id, seats_count, status, booking_id, offer_expires_at, created_at FROM waitlist_entries
+WHERE id = ?1
+`
+
+func (q *Queries) GetWaitlistEntry(ctx context.Context, entryID int64) (WaitlistEntry, error) {
+	row := q.db.QueryRowContext(ctx, getWaitlistEntry, entryID)
+	var i WaitlistEntry
+	err := row.Scan(
+		&i.ID,
+		&i.EventID,
+		&i.UserID,
+		&i.SeatsCount,
+		&i.Status,
+		&i.BookingID,
+		&i.OfferExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getWaitlistPosition = `-- name: GetWaitlistPosition :one
+;
+
+SELECT COUNT(*) FROM waitlist_entries
+WHERE event_id = ?1
+  AND status = 'WAITING'
+  AND id <= ?2
+`
+
+type GetWaitlistPositionParams struct {
+	EventID int64
+	EntryID int64
+}
+
+func (q *Queries) GetWaitlistPosition(ctx context.Context, arg GetWaitlistPositionParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getWaitlistPosition, arg.EventID, arg.EntryID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const insertWaitlistEntry = `-- name: InsertWaitlistEntry :one
+INSERT INTO waitlist_entries (event_id, user_id, seats_count, status, created_at)
+VALUES (?1, ?2, ?3, 'WAITING', ?4)
+RETURNING id
+`
+
+type InsertWaitlistEntryParams struct {
+	EventID    int64
+	UserID     int64
+	SeatsCount int64
+	CreatedAt  time.Time
+}
+
+func (q *Queries) InsertWaitlistEntry(ctx context.Context, arg InsertWaitlistEntryParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, insertWaitlistEntry,
+		arg.EventID,
+		arg.UserID,
+		arg.SeatsCount,
+		arg.CreatedAt,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const offerWaitlistEntry = `-- name: OfferWaitlistEntry :execrows
+;
+
+UPDATE waitlist_entries
+SET status = 'OFFERED',
+    booking_id = ?1,
+    offer_expires_at = ?2
+WHERE id = ?3
+  AND status = 'WAITING'
+`
+
+type OfferWaitlistEntryParams struct {
+	BookingID      *int64
+	OfferExpiresAt *time.Time
+	EntryID        int64
+}
+
+func (q *Queries) OfferWaitlistEntry(ctx context.Context, arg OfferWaitlistEntryParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, offerWaitlistEntry, arg.BookingID, arg.OfferExpiresAt, arg.EntryID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateWaitlistEntryStatus = `-- name: UpdateWaitlistEntryStatus :execrows
+;
+
+UPDATE waitlist_entries
+SET status = ?1
+WHERE id = ?2
+  AND status = ?3
+`
+
+type UpdateWaitlistEntryStatusParams struct {
+	Status   domain.WaitlistStatus
+	EntryID  int64
+	StatusEq domain.WaitlistStatus
+}
+
+func (q *Queries) UpdateWaitlistEntryStatus(ctx context.Context, arg UpdateWaitlistEntryStatusParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateWaitlistEntryStatus, arg.Status, arg.EntryID, arg.StatusEq)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,78 @@
+package testenv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"hackload/pkg/paymentgateway"
+)
+
+// GatewayPayment is the state of a payment as the Gateway reports it.
+type GatewayPayment struct {
+	Status   string
+	Amount   int64
+	Currency string
+}
+
+// Gateway answers payment checks from the payments set with SetPayment.
+// Other gateway calls are not implemented and panic.
+type Gateway struct {
+	paymentgateway.ClientInterface
+
+	mu       sync.Mutex
+	payments map[string]GatewayPayment
+}
+
+func NewGateway() *Gateway {
+	return &Gateway{payments: make(map[string]GatewayPayment)}
+}
+
+func (g *Gateway) SetPayment(paymentID string, payment GatewayPayment) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.payments[paymentID] = payment
+}
+
+func (g *Gateway) PostApiV1PaymentCheckCheck(
+	ctx context.Context,
+	body paymentgateway.PostApiV1PaymentCheckCheckJSONRequestBody,
+	reqEditors ...paymentgateway.RequestEditorFn,
+) (*http.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if body.PaymentId == nil {
+		return jsonResponse(http.StatusBadRequest, map[string]any{"success": false})
+	}
+	payment, ok := g.payments[*body.PaymentId]
+	if !ok {
+		return jsonResponse(http.StatusNotFound, map[string]any{"success": false})
+	}
+
+	amount := float64(payment.Amount)
+	return jsonResponse(http.StatusOK, paymentgateway.PaymentCheckResponseDto{
+		Payments: &[]paymentgateway.PaymentStatusDto{{
+			PaymentId: body.PaymentId,
+			OrderId:   body.OrderId,
+			Status:    &payment.Status,
+			Amount:    &amount,
+			Currency:  &payment.Currency,
+		}},
+	})
+}
+
+func jsonResponse(status int, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	}, nil
+}
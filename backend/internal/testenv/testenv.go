@@ -0,0 +1,164 @@
+// Package testenv sets up the databases and the River client shared by DB-backed tests.
+//
+// The migrations create an FTS5 table, so such tests are built with the same tag as the service:
+//
+//	go test -tags sqlite_fts5 ./...
+package testenv
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"hackload/cmd/setup"
+	"hackload/internal/config"
+	"hackload/internal/dependencies"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// Сколько ждать, пока River выполнит задачу
+const jobTimeout = 10 * time.Second
+
+// New migrates a fresh service database and River database in a temporary directory.
+// Workers are added to deps.RiverWorkers before StartRiver.
+func New(t testing.TB) *dependencies.Dependencies {
+	t.Helper()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	conf := &config.Config{SQLite3Path: filepath.Join(dir, "db.sqlite3")}
+	conf.River.SQLite3Path = filepath.Join(dir, "river.sqlite3")
+
+	if err := setup.Setup(ctx, conf, &config.Args{MigrationsDir: migrationsDir()}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	deps, err := dependencies.NewDependencies(ctx,
+		dependencies.WithDB(conf),
+		dependencies.WithRiverQueue(conf),
+	)
+	if err != nil {
+		t.Fatalf("failed to open databases: %v", err)
+	}
+	t.Cleanup(deps.Close)
+
+	return deps
+}
+
+// StartRiver starts a River client for deps.RiverWorkers that polls often enough for tests
+// and stores it in deps.RiverClient.
+func StartRiver(t testing.TB, deps *dependencies.Dependencies) *river.Client[*sql.Tx] {
+	t.Helper()
+
+	client, err := river.NewClient(deps.RiverDriver, &river.Config{
+		Queues: map[string]river.QueueConfig{
+			river.QueueDefault: {MaxWorkers: 2},
+		},
+		Workers:           deps.RiverWorkers,
+		FetchCooldown:     10 * time.Millisecond,
+		FetchPollInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to create River client: %v", err)
+	}
+
+	if err := client.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start River client: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+		defer cancel()
+		client.Stop(ctx)
+	})
+
+	deps.RiverClient = client
+	return client
+}
+
+// WorkJob inserts the job with a single attempt and waits until River has worked it.
+// It returns the job as River left it: completed, discarded or cancelled.
+func WorkJob(t testing.TB, client *river.Client[*sql.Tx], args river.JobArgs) *rivertype.JobRow {
+	t.Helper()
+
+	events, cancel := client.Subscribe(
+		river.EventKindJobCompleted,
+		river.EventKindJobFailed,
+		river.EventKindJobCancelled,
+	)
+	defer cancel()
+
+	result, err := client.Insert(context.Background(), args, &river.InsertOpts{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("failed to insert %s job: %v", args.Kind(), err)
+	}
+
+	timeout := time.After(jobTimeout)
+	for {
+		select {
+		case event := <-events:
+			if event.Job.ID == result.Job.ID {
+				return event.Job
+			}
+		case <-timeout:
+			t.Fatalf("%s job was not worked in %s", args.Kind(), jobTimeout)
+			return nil
+		}
+	}
+}
+
+// Jobs returns the jobs of the kind inserted so far, in any state.
+func Jobs(t testing.TB, client *river.Client[*sql.Tx], kind string) []*rivertype.JobRow {
+	t.Helper()
+
+	result, err := client.JobList(context.Background(), river.NewJobListParams().Kinds(kind))
+	if err != nil {
+		t.Fatalf("failed to list %s jobs: %v", kind, err)
+	}
+
+	return result.Jobs
+}
+
+// WaitJobs waits until River has finished every job of the kind inserted so far and returns them.
+func WaitJobs(t testing.TB, client *river.Client[*sql.Tx], kind string) []*rivertype.JobRow {
+	t.Helper()
+
+	deadline := time.Now().Add(jobTimeout)
+	for {
+		jobs := Jobs(t, client, kind)
+
+		var pending []rivertype.JobState
+		for _, job := range jobs {
+			if job.FinalizedAt == nil {
+				pending = append(pending, job.State)
+			}
+		}
+		if len(pending) == 0 {
+			return jobs
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("%s jobs were not worked in %s: %v", kind, jobTimeout, pending)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// NoopWorker completes jobs of kind T without doing anything. It stands in for the workers
+// a test does not exercise, so that the jobs queued for them can be inserted and inspected.
+type NoopWorker[T river.JobArgs] struct {
+	river.WorkerDefaults[T]
+}
+
+func (NoopWorker[T]) Work(context.Context, *river.Job[T]) error { return nil }
+
+// migrationsDir returns backend/migrations wherever the test runs from.
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "migrations")
+}
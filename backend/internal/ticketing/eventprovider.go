@@ -0,0 +1,255 @@
+package ticketing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"hackload/pkg/eventprovider"
+
+	"github.com/google/uuid"
+)
+
+// AdapterEventProvider is the adapter of providers speaking the EventProvider API (pkg/eventprovider).
+const AdapterEventProvider = "eventprovider"
+
+// errPlaceAlreadySelected is the error code of a place selected in another order.
+const errPlaceAlreadySelected = "PlaceAlreadySelectedException"
+
+type eventProviderAdapter struct {
+	client eventprovider.ClientInterface
+}
+
+// NewEventProvider adapts an EventProvider client. Its order and place IDs are UUIDs.
+func NewEventProvider(client eventprovider.ClientInterface) Provider {
+	return &eventProviderAdapter{client: client}
+}
+
+func (p *eventProviderAdapter) StartOrder(ctx context.Context) (string, error) {
+	resp, err := p.client.StartOrder(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to start order: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("failed to start order, status: %d", resp.StatusCode)
+	}
+
+	var orderCreated eventprovider.OrderCreatedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&orderCreated); err != nil {
+		return "", fmt.Errorf("failed to decode order response: %w", err)
+	}
+
+	return orderCreated.OrderId.String(), nil
+}
+
+func (p *eventProviderAdapter) GetOrder(ctx context.Context, orderID string) (Order, error) {
+	id, err := parseID("order", orderID)
+	if err != nil {
+		return Order{}, err
+	}
+
+	resp, err := p.client.GetOrder(ctx, id)
+	if err != nil {
+		return Order{}, fmt.Errorf("failed to get order %s: %w", orderID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Order{}, fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+	}
+
+	if resp.StatusCode > 299 {
+		return Order{}, fmt.Errorf("failed to get order %s, status: %d", orderID, resp.StatusCode)
+	}
+
+	var order eventprovider.Order
+	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+		return Order{}, fmt.Errorf("failed to decode order response: %w", err)
+	}
+
+	return Order{
+		ID:          order.Id.String(),
+		Status:      OrderStatus(order.Status),
+		PlacesCount: order.PlacesCount,
+	}, nil
+}
+
+func (p *eventProviderAdapter) SubmitOrder(ctx context.Context, orderID string) error {
+	return p.orderAction(ctx, "submit", orderID, p.client.SubmitOrder)
+}
+
+func (p *eventProviderAdapter) ConfirmOrder(ctx context.Context, orderID string) error {
+	return p.orderAction(ctx, "confirm", orderID, p.client.ConfirmOrder)
+}
+
+func (p *eventProviderAdapter) CancelOrder(ctx context.Context, orderID string) error {
+	return p.orderAction(ctx, "cancel", orderID, p.client.CancelOrder)
+}
+
+func (p *eventProviderAdapter) orderAction(
+	ctx context.Context,
+	action string,
+	orderID string,
+	call func(context.Context, eventprovider.OrderId, ...eventprovider.RequestEditorFn) (*http.Response, error),
+) error {
+	id, err := parseID("order", orderID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := call(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to %s order %s: %w", action, orderID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+	}
+
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("failed to %s order %s, status: %d", action, orderID, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (p *eventProviderAdapter) GetPlace(ctx context.Context, placeID string) (Place, error) {
+	id, err := parseID("place", placeID)
+	if err != nil {
+		return Place{}, err
+	}
+
+	resp, err := p.client.GetPlace(ctx, id)
+	if err != nil {
+		return Place{}, fmt.Errorf("failed to get place %s: %w", placeID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Place{}, fmt.Errorf("%w: %s", ErrPlaceNotFound, placeID)
+	}
+
+	if resp.StatusCode > 299 {
+		return Place{}, fmt.Errorf("failed to get place %s, status: %d", placeID, resp.StatusCode)
+	}
+
+	var place eventprovider.Place
+	if err := json.NewDecoder(resp.Body).Decode(&place); err != nil {
+		return Place{}, fmt.Errorf("failed to decode place response: %w", err)
+	}
+
+	return toPlace(place), nil
+}
+
+func (p *eventProviderAdapter) SelectPlace(ctx context.Context, orderID, placeID string) error {
+	order, err := parseID("order", orderID)
+	if err != nil {
+		return err
+	}
+	place, err := parseID("place", placeID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.SelectPlace(ctx, place, eventprovider.SelectPlaceRequest{
+		OrderId: order,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to select place %s: %w", placeID, err)
+	}
+	defer resp.Body.Close()
+
+	// 409 also rejects a place for an order that is no longer STARTED,
+	// only the error code tells a place selected in another order apart
+	if resp.StatusCode == http.StatusConflict {
+		var errorResponse eventprovider.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
+			return fmt.Errorf("failed to select place %s, status: %d: %w", placeID, resp.StatusCode, err)
+		}
+		if errorResponse.Error == errPlaceAlreadySelected {
+			return fmt.Errorf("%w: %s", ErrPlaceTaken, placeID)
+		}
+		return fmt.Errorf("failed to select place %s, status: %d, error: %s", placeID, resp.StatusCode, errorResponse.Error)
+	}
+
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("failed to select place %s, status: %d", placeID, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (p *eventProviderAdapter) ReleasePlace(ctx context.Context, placeID string) error {
+	id, err := parseID("place", placeID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.ReleasePlace(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to release place %s: %w", placeID, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrPlaceNotFound, placeID)
+	case http.StatusConflict:
+		return fmt.Errorf("%w: %s", ErrPlaceNotSelected, placeID)
+	}
+
+	if resp.StatusCode > 299 {
+		return fmt.Errorf("failed to release place %s, status: %d", placeID, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (p *eventProviderAdapter) ListPlaces(ctx context.Context, page, pageSize int) ([]Place, error) {
+	resp, err := p.client.ListPlaces(ctx, &eventprovider.ListPlacesParams{
+		Page:     &page,
+		PageSize: &pageSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list places, page %d: %w", page, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to list places, page %d, status: %d", page, resp.StatusCode)
+	}
+
+	var places []eventprovider.Place
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return nil, fmt.Errorf("failed to decode listPlaces response, page %d: %w", page, err)
+	}
+
+	result := make([]Place, 0, len(places))
+	for _, place := range places {
+		result = append(result, toPlace(place))
+	}
+
+	return result, nil
+}
+
+func toPlace(place eventprovider.Place) Place {
+	return Place{
+		ID:     place.Id.String(),
+		Row:    place.Row,
+		Seat:   place.Seat,
+		IsFree: place.IsFree,
+	}
+}
+
+func parseID(kind, id string) (uuid.UUID, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid %s id %q: %w", kind, id, err)
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,69 @@
+package ticketing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"hackload/internal/ticketing"
+	"hackload/pkg/eventprovider"
+	"hackload/pkg/eventprovider/fakeprovider"
+)
+
+func newEventProvider(t *testing.T) (*fakeprovider.Server, ticketing.Provider, []eventprovider.Place) {
+	t.Helper()
+
+	server := fakeprovider.NewServer(fakeprovider.WithPlaces(2))
+	t.Cleanup(server.Close)
+	client, err := server.ProviderClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	places, err := server.Provider.ListPlaces(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, ticketing.NewEventProvider(client), places
+}
+
+func TestEventProviderSelectPlaceTakenByAnotherOrder(t *testing.T) {
+	server, provider, places := newEventProvider(t)
+
+	other := server.Provider.StartOrder()
+	if err := server.Provider.SelectPlace(places[0].Id, other.Id); err != nil {
+		t.Fatal(err)
+	}
+	order := server.Provider.StartOrder()
+
+	err := provider.SelectPlace(context.Background(), order.Id.String(), places[0].Id.String())
+	if !errors.Is(err, ticketing.ErrPlaceTaken) {
+		t.Fatalf("select answered %v, want ErrPlaceTaken", err)
+	}
+}
+
+func TestEventProviderSelectPlaceForFinishedOrder(t *testing.T) {
+	server, provider, places := newEventProvider(t)
+
+	order := server.Provider.StartOrder()
+	if err := server.Provider.SelectPlace(places[0].Id, order.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Provider.SubmitOrder(order.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	// Провайдер отвечает тем же 409, но свободное место не занято другим заказом
+	err := provider.SelectPlace(context.Background(), order.Id.String(), places[1].Id.String())
+	if err == nil || errors.Is(err, ticketing.ErrPlaceTaken) {
+		t.Fatalf("select answered %v, want an error other than ErrPlaceTaken", err)
+	}
+
+	place, err := server.Provider.GetPlace(places[1].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !place.IsFree {
+		t.Error("place 2 is not free")
+	}
+}
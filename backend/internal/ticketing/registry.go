@@ -0,0 +1,135 @@
+package ticketing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"hackload/internal/config"
+	"hackload/internal/sqlc"
+	"hackload/pkg/eventprovider"
+)
+
+var ErrUnknownProvider = errors.New("no ticket provider for the event")
+
+// Route is a provider together with its settings.
+type Route struct {
+	Provider
+
+	// Значение events_archive.provider, пусто у провайдера по умолчанию
+	Name string
+	// Места выбираются у провайдера сразу при добавлении в бронь
+	ReserveOnSelect bool
+	// Событие, в которое сброс загружает места провайдера, 0 - не загружать
+	EventID int64
+}
+
+// Registry routes each event to the provider from events_archive.provider.
+type Registry struct {
+	queries  *sqlc.Queries
+	fallback *Route
+	routes   []*Route
+	byName   map[string]*Route
+}
+
+// NewRegistry routes the events of the named providers to their routes and all the other events
+// to fallback. Without fallback such events fail with ErrUnknownProvider.
+func NewRegistry(queries *sqlc.Queries, fallback *Route, routes ...*Route) *Registry {
+	r := &Registry{
+		queries:  queries,
+		fallback: fallback,
+		byName:   make(map[string]*Route, len(routes)),
+	}
+	if fallback != nil {
+		r.routes = append(r.routes, fallback)
+	}
+	for _, route := range routes {
+		r.routes = append(r.routes, route)
+		r.byName[route.Name] = route
+	}
+
+	return r
+}
+
+// NewRegistryFromConfig creates the adapters of EVENT_PROVIDERS with EVENT_PROVIDER_ADDR as the fallback.
+func NewRegistryFromConfig(queries *sqlc.Queries, conf *config.Config) (*Registry, error) {
+	var fallback *Route
+	if conf.EventProvider.Addr != "" {
+		provider, err := newProvider(AdapterEventProvider, conf.EventProvider.Addr)
+		if err != nil {
+			return nil, err
+		}
+
+		fallback = &Route{
+			Provider:        provider,
+			ReserveOnSelect: conf.EventProvider.ReserveOnSelect,
+			EventID:         conf.EventProvider.EventID,
+		}
+	}
+
+	routes := make([]*Route, 0, len(conf.EventProviders))
+	for _, providerConf := range conf.EventProviders {
+		provider, err := newProvider(providerConf.Adapter, providerConf.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("event provider %s: %w", providerConf.Name, err)
+		}
+
+		routes = append(routes, &Route{
+			Provider:        provider,
+			Name:            providerConf.Name,
+			ReserveOnSelect: providerConf.ReserveOnSelect,
+			EventID:         providerConf.EventID,
+		})
+	}
+
+	return NewRegistry(queries, fallback, routes...), nil
+}
+
+func newProvider(adapter, addr string) (Provider, error) {
+	switch adapter {
+	case AdapterEventProvider:
+		client, err := eventprovider.NewClient(addr)
+		if err != nil {
+			return nil, err
+		}
+		return NewEventProvider(client), nil
+	default:
+		return nil, fmt.Errorf("unknown ticket provider adapter: %s", adapter)
+	}
+}
+
+// ForEvent returns the route of the event's provider.
+func (r *Registry) ForEvent(ctx context.Context, eventID int64) (*Route, error) {
+	event, err := r.queries.GetEvent(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event %d: %w", eventID, err)
+	}
+
+	if event.Provider != nil {
+		if route, ok := r.byName[*event.Provider]; ok {
+			return route, nil
+		}
+	}
+
+	if r.fallback == nil {
+		return nil, fmt.Errorf("%w: event %d", ErrUnknownProvider, eventID)
+	}
+
+	return r.fallback, nil
+}
+
+// Routes returns every configured route, the fallback first.
+func (r *Registry) Routes() []*Route {
+	return r.routes
+}
+
+// ReserveOnSelect reports whether any route selects places as they are added to a booking.
+func (r *Registry) ReserveOnSelect() bool {
+	for _, route := range r.routes {
+		if route.ReserveOnSelect {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,58 @@
+// Package ticketing hides the API of a ticket provider behind Provider,
+// so that bookings of every event are handled the same way whoever sells its places.
+package ticketing
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrOrderNotFound = errors.New("order not found at the ticket provider")
+	ErrPlaceNotFound = errors.New("place not found at the ticket provider")
+	// Место выбрано в другом заказе, в том числе другим дистрибьютором
+	ErrPlaceTaken = errors.New("place is taken at the ticket provider")
+	// Место не выбрано ни в одном заказе
+	ErrPlaceNotSelected = errors.New("place is not selected at the ticket provider")
+)
+
+// OrderStatus is the state of a provider order: places are selected in a STARTED order,
+// a SUBMITTED order waits for confirmation, and a CONFIRMED order has sold its places.
+type OrderStatus string
+
+const (
+	OrderStarted   OrderStatus = "STARTED"
+	OrderSubmitted OrderStatus = "SUBMITTED"
+	OrderConfirmed OrderStatus = "CONFIRMED"
+	OrderCancelled OrderStatus = "CANCELLED"
+)
+
+type Order struct {
+	ID          string
+	Status      OrderStatus
+	PlacesCount int
+}
+
+type Place struct {
+	ID     string
+	Row    int
+	Seat   int
+	IsFree bool
+}
+
+// Provider is the API of a ticket provider. Order and place IDs are the provider's own:
+// they are stored in booking_orders.order_id and seats.external_id.
+type Provider interface {
+	StartOrder(ctx context.Context) (string, error)
+	GetOrder(ctx context.Context, orderID string) (Order, error)
+	SubmitOrder(ctx context.Context, orderID string) error
+	ConfirmOrder(ctx context.Context, orderID string) error
+	CancelOrder(ctx context.Context, orderID string) error
+
+	GetPlace(ctx context.Context, placeID string) (Place, error)
+	SelectPlace(ctx context.Context, orderID, placeID string) error
+	ReleasePlace(ctx context.Context, placeID string) error
+	// ListPlaces returns the inventory page by page, starting from page 1.
+	// A page shorter than pageSize is the last one
+	ListPlaces(ctx context.Context, page, pageSize int) ([]Place, error)
+}
@@ -0,0 +1,4 @@
+-- Remove hold expiration from bookings
+DROP INDEX IF EXISTS idx_bookings_expires_at;
+
+ALTER TABLE bookings DROP COLUMN expires_at;
@@ -0,0 +1,4 @@
+-- Add hold expiration to bookings
+ALTER TABLE bookings ADD COLUMN expires_at timestamp;
+
+CREATE INDEX idx_bookings_expires_at ON bookings(expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,17 @@
+create table "booking_status_history" (
+    "id" integer primary key autoincrement,
+    "booking_id" integer not null references "bookings"("id"),
+
+    -- статус до перехода, null при создании брони
+    "from_status" text,
+
+    -- статус: CREATED, PAYMENT_INITIATED, CONFIRMED, CANCELLED
+    "to_status" text not null,
+
+    -- инициатор: user:<user_id>, payment_gateway, system
+    "actor" text not null,
+    "reason" text,
+    "created_at" timestamp not null
+);
+
+CREATE INDEX idx_booking_status_history_booking ON booking_status_history(booking_id);
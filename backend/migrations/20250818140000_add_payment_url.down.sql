@@ -0,0 +1 @@
+ALTER TABLE booking_payments DROP COLUMN payment_url;
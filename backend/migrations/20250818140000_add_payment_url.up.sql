@@ -0,0 +1,2 @@
+-- Store the gateway payment page URL so the booking can be resumed
+ALTER TABLE booking_payments ADD COLUMN payment_url text;
@@ -0,0 +1,21 @@
+create table "idempotency_keys" (
+    "user_id" integer not null references "users"("user_id"),
+
+    -- значение заголовка Idempotency-Key
+    "key" text not null,
+
+    -- sha256 от метода, пути и тела запроса
+    "request_hash" text not null,
+
+    -- ответ на первый запрос, null пока запрос выполняется
+    "status_code" integer,
+    "content_type" text,
+    "location" text,
+    "body" blob,
+
+    "created_at" timestamp not null,
+
+    primary key ("user_id", "key")
+);
+
+CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys(created_at);
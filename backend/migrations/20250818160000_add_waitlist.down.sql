@@ -0,0 +1 @@
+drop table "waitlist_entries";
@@ -0,0 +1,24 @@
+create table "waitlist_entries" (
+    "id" integer primary key autoincrement,
+    "event_id" integer not null references "events_archive"("id"),
+    "user_id" integer not null references "users"("user_id"),
+
+    -- сколько мест хочет пользователь
+    "seats_count" integer not null,
+
+    -- статус: WAITING, OFFERED, FULFILLED, EXPIRED, CANCELLED
+    "status" text not null,
+
+    -- бронь с предложенными местами, заполняется при переходе в OFFERED
+    "booking_id" integer references "bookings"("id"),
+    "offer_expires_at" timestamp,
+
+    "created_at" timestamp not null
+);
+
+CREATE INDEX idx_waitlist_entries_event_status ON waitlist_entries(event_id, status, id);
+CREATE INDEX idx_waitlist_entries_user ON waitlist_entries(user_id);
+
+-- одна активная заявка пользователя на событие
+CREATE UNIQUE INDEX idx_waitlist_entries_active ON waitlist_entries(event_id, user_id)
+  WHERE status IN ('WAITING', 'OFFERED');
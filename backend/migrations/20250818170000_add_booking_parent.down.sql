@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_bookings_parent;
+ALTER TABLE bookings DROP COLUMN parent_booking_id;
@@ -0,0 +1,4 @@
+-- бронь, от которой отделена эта при передаче части мест другому пользователю
+ALTER TABLE bookings ADD COLUMN parent_booking_id integer references bookings(id);
+
+CREATE INDEX idx_bookings_parent ON bookings(parent_booking_id) WHERE parent_booking_id IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_payment_notifications_order;
+drop table "payment_notifications";
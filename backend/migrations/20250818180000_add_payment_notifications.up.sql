@@ -0,0 +1,20 @@
+create table "payment_notifications" (
+    "id" integer primary key autoincrement,
+
+    -- идентификаторы платежа из уведомления, могут отсутствовать в некорректном уведомлении
+    "order_id" text,
+    "payment_id" text,
+
+    -- статус платежа в платежном шлюзе
+    "status" text,
+
+    -- тело уведомления как оно пришло
+    "body" text not null,
+
+    -- токен уведомления совпал с рассчитанным по паролю мерчанта
+    "verified" boolean not null,
+
+    "received_at" timestamp not null
+);
+
+CREATE INDEX idx_payment_notifications_order ON payment_notifications(order_id);
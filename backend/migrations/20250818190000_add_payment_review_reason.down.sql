@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_booking_payments_review;
+
+ALTER TABLE booking_payments DROP COLUMN review_reason;
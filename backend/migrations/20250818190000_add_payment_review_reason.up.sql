@@ -0,0 +1,4 @@
+-- причина ручной проверки платежа, например расхождение суммы с платежным шлюзом
+ALTER TABLE booking_payments ADD COLUMN review_reason text;
+
+CREATE INDEX idx_booking_payments_review ON booking_payments(review_reason) WHERE review_reason IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_booking_payments_status_created;
+
+ALTER TABLE booking_payments DROP COLUMN created_at;
@@ -0,0 +1,4 @@
+-- время создания платежа, для сверки незавершенных платежей с платежным шлюзом
+ALTER TABLE booking_payments ADD COLUMN created_at timestamp;
+
+CREATE INDEX idx_booking_payments_status_created ON booking_payments(status, created_at);
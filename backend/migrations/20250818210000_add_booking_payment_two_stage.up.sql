@@ -0,0 +1,2 @@
+-- двухстадийный платеж: при оплате деньги только блокируются и списываются после подтверждения заказа провайдером
+ALTER TABLE booking_payments ADD COLUMN two_stage boolean not null default false;
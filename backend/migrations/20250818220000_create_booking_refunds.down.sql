@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_booking_refunds_booking;
+DROP INDEX IF EXISTS idx_booking_refunds_payment;
+drop table "booking_refunds";
@@ -0,0 +1,20 @@
+create table "booking_refunds" (
+    "id" integer primary key autoincrement,
+    "booking_payment_id" integer not null references "booking_payments"("id"),
+    "booking_id" integer not null references "bookings"("id"),
+
+    -- место, убранное из оплаченной брони; null при возврате всего остатка платежа
+    "seat_id" integer references "seats"("id"),
+
+    "amount" integer not null, -- Amount in cents
+    "currency" text not null,
+
+    -- статус: PENDING, PLACE_RELEASED, REFUNDED
+    "status" text not null,
+
+    "created_at" timestamp not null,
+    "refunded_at" timestamp
+);
+
+CREATE INDEX idx_booking_refunds_payment ON booking_refunds(booking_payment_id);
+CREATE INDEX idx_booking_refunds_booking ON booking_refunds(booking_id);
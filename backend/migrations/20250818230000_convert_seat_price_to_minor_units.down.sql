@@ -0,0 +1,9 @@
+ALTER TABLE seats DROP COLUMN currency;
+
+ALTER TABLE seats ADD COLUMN price_text text not null default '0.00';
+
+UPDATE seats SET price_text = printf('%d.%02d', price / 100, price % 100);
+
+ALTER TABLE seats DROP COLUMN price;
+
+ALTER TABLE seats RENAME COLUMN price_text TO price;
@@ -0,0 +1,11 @@
+-- цена места в минимальных единицах валюты: '40000.00' -> 4000000
+ALTER TABLE seats ADD COLUMN price_minor integer not null default 0;
+
+UPDATE seats SET price_minor = CAST(ROUND(CAST(price AS REAL) * 100) AS INTEGER);
+
+ALTER TABLE seats DROP COLUMN price;
+
+ALTER TABLE seats RENAME COLUMN price_minor TO price;
+
+-- валюта цены места
+ALTER TABLE seats ADD COLUMN currency text not null default 'KZT';
@@ -0,0 +1 @@
+ALTER TABLE events_archive DROP COLUMN currency;
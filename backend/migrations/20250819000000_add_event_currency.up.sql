@@ -0,0 +1,9 @@
+-- валюта, в которой продаются места события
+ALTER TABLE events_archive ADD COLUMN currency text not null default 'KZT';
+
+UPDATE seats SET currency = (
+    select e.currency from events_archive e where e.id = seats.event_id
+)
+WHERE EXISTS (
+    select 1 from events_archive e where e.id = seats.event_id
+);
@@ -0,0 +1,3 @@
+DROP INDEX idx_booking_orders_booking_unique;
+
+ALTER TABLE booking_seats DROP COLUMN place_selected_at;
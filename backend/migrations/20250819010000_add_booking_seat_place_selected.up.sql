@@ -0,0 +1,13 @@
+-- момент, когда место выбрано в заказе EventProvider; повторная попытка
+-- SelectSeatsWorker продолжает с первого невыбранного места
+ALTER TABLE booking_seats ADD COLUMN place_selected_at timestamp;
+
+-- повторные попытки SelectSeatsWorker добавляли брони новые заказы;
+-- остается последний, с ним работал ConfirmOrderWorker
+DELETE FROM booking_orders
+WHERE id NOT IN (
+    select max(bo.id) from booking_orders bo group by bo.booking_id
+);
+
+-- заказ EventProvider у брони один: повторные попытки переиспользуют его
+CREATE UNIQUE INDEX idx_booking_orders_booking_unique ON booking_orders(booking_id);
@@ -0,0 +1,80 @@
+package fakeprovider
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Эндпоинты управления фейком, которых нет у настоящего провайдера. Через них сценарии
+// отказов задаются по HTTP, когда провайдер запущен отдельной командой.
+func (p *Provider) controlRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /_fake/failures", p.handleScriptFailure)
+	mux.HandleFunc("POST /_fake/seed", p.handleSeed)
+	mux.HandleFunc("POST /_fake/reset", p.handleReset)
+}
+
+type failureRequest struct {
+	Op                   Operation `json:"op"`
+	ID                   string    `json:"id"`
+	StatusCode           int       `json:"status_code"`
+	Delay                string    `json:"delay"` // например "2s"
+	AfterwardsStatusCode int       `json:"afterwards_status_code"`
+	Times                int       `json:"times"`
+}
+
+// (POST /_fake/failures)
+func (p *Provider) handleScriptFailure(w http.ResponseWriter, r *http.Request) {
+	var req failureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Op {
+	case OpStartOrder, OpGetOrder, OpSubmitOrder, OpConfirmOrder, OpCancelOrder,
+		OpListPlaces, OpGetPlace, OpSelectPlace, OpReleasePlace, OpCreatePlace:
+	default:
+		http.Error(w, "Unknown operation", http.StatusBadRequest)
+		return
+	}
+
+	var delay time.Duration
+	if req.Delay != "" {
+		var err error
+		if delay, err = time.ParseDuration(req.Delay); err != nil {
+			http.Error(w, "Invalid delay", http.StatusBadRequest)
+			return
+		}
+	}
+
+	p.Fail(Failure{
+		Op:                   req.Op,
+		ID:                   req.ID,
+		StatusCode:           req.StatusCode,
+		Delay:                delay,
+		AfterwardsStatusCode: req.AfterwardsStatusCode,
+		Times:                req.Times,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// (POST /_fake/seed)
+func (p *Provider) handleSeed(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Places int `json:"places"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Places < 1 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	p.Seed(req.Places)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// (POST /_fake/reset)
+func (p *Provider) handleReset(w http.ResponseWriter, r *http.Request) {
+	p.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,67 @@
+package fakeprovider
+
+import "time"
+
+// Operation is what a scripted Failure applies to, named after the operationId in openapi.yaml.
+type Operation string
+
+const (
+	OpStartOrder   Operation = "startOrder"
+	OpGetOrder     Operation = "getOrder"
+	OpSubmitOrder  Operation = "submitOrder"
+	OpConfirmOrder Operation = "confirmOrder"
+	OpCancelOrder  Operation = "cancelOrder"
+	OpListPlaces   Operation = "listPlaces"
+	OpGetPlace     Operation = "getPlace"
+	OpSelectPlace  Operation = "selectPlace"
+	OpReleasePlace Operation = "releasePlace"
+	OpCreatePlace  Operation = "createPlace"
+)
+
+// Failure is a scripted fault. It applies to the next Times requests of Op, only for the
+// order or place ID in the path if it is set.
+//
+// The provider waits Delay and then answers StatusCode without touching its state.
+// A Failure with a Delay and no StatusCode only slows the request down, one with neither
+// answers 500. AfterwardsStatusCode instead lets the request through and replaces only the
+// response, as when the provider did the work but the answer was lost.
+type Failure struct {
+	Op         Operation
+	ID         string
+	StatusCode int
+	Delay      time.Duration
+	// Запрос выполняется, но ответ подменяется: например, место выбрано, а клиент получил 500
+	AfterwardsStatusCode int
+	// Сколько раз сработать: 0 - один раз, меньше нуля - всегда
+	Times int
+}
+
+// Fail scripts a failure. Failures are matched in the order they were added.
+func (p *Provider) Fail(f Failure) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if f.Times == 0 {
+		f.Times = 1
+	}
+	p.failures = append(p.failures, &f)
+}
+
+// takeFailure returns the first failure matching the request and uses it up. Must be called with p.mu held.
+func (p *Provider) takeFailure(op Operation, id string) *Failure {
+	for i, f := range p.failures {
+		if f.Op != op || (f.ID != "" && f.ID != id) {
+			continue
+		}
+
+		taken := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				p.failures = append(p.failures[:i], p.failures[i+1:]...)
+			}
+		}
+		return &taken
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package fakeprovider
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hackload/pkg/eventprovider"
+
+	"github.com/google/uuid"
+)
+
+var _ http.Handler = (*Provider)(nil)
+
+func (p *Provider) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/partners/v1/orders", p.handle(OpStartOrder, p.handleStartOrder))
+	mux.HandleFunc("GET /api/partners/v1/orders/{id}", p.handle(OpGetOrder, p.handleGetOrder))
+	mux.HandleFunc("PATCH /api/partners/v1/orders/{id}/submit", p.handle(OpSubmitOrder, p.handleOrderAction(p.SubmitOrder)))
+	mux.HandleFunc("PATCH /api/partners/v1/orders/{id}/confirm", p.handle(OpConfirmOrder, p.handleOrderAction(p.ConfirmOrder)))
+	mux.HandleFunc("PATCH /api/partners/v1/orders/{id}/cancel", p.handle(OpCancelOrder, p.handleOrderAction(p.CancelOrder)))
+	mux.HandleFunc("GET /api/partners/v1/places", p.handle(OpListPlaces, p.handleListPlaces))
+	mux.HandleFunc("GET /api/partners/v1/places/{id}", p.handle(OpGetPlace, p.handleGetPlace))
+	mux.HandleFunc("PATCH /api/partners/v1/places/{id}/select", p.handle(OpSelectPlace, p.handleSelectPlace))
+	mux.HandleFunc("PATCH /api/partners/v1/places/{id}/release", p.handle(OpReleasePlace, p.handleReleasePlace))
+	mux.HandleFunc("POST /api/admin/v1/places", p.handle(OpCreatePlace, p.handleCreatePlace))
+	p.controlRoutes(mux)
+	return mux
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+// handle injects latency, random errors and scripted failures around a contract endpoint.
+func (p *Provider) handle(op Operation, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		delay := p.latency
+		if p.jitter > 0 {
+			delay += time.Duration(p.rng.Int64N(int64(p.jitter)))
+		}
+		randomError := p.errorRate > 0 && p.rng.Float64() < p.errorRate
+		f := p.takeFailure(op, r.PathValue("id"))
+		p.mu.Unlock()
+
+		time.Sleep(delay)
+
+		if randomError {
+			writeError(w, &Error{http.StatusInternalServerError, "InternalServerError", "Injected random failure"})
+			return
+		}
+
+		if f == nil {
+			next(w, r)
+			return
+		}
+
+		time.Sleep(f.Delay)
+
+		if f.AfterwardsStatusCode != 0 {
+			next(discardWriter{header: http.Header{}}, r)
+			writeError(w, &Error{f.AfterwardsStatusCode, "InternalServerError", "Scripted " + string(op) + " failure after the request was applied"})
+			return
+		}
+
+		if f.StatusCode == 0 && f.Delay > 0 {
+			next(w, r)
+			return
+		}
+
+		status := f.StatusCode
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		writeError(w, &Error{status, "InternalServerError", "Scripted " + string(op) + " failure"})
+	}
+}
+
+// (POST /api/partners/v1/orders)
+func (p *Provider) handleStartOrder(w http.ResponseWriter, r *http.Request) {
+	o := p.StartOrder()
+	writeJSON(w, http.StatusCreated, eventprovider.OrderCreatedResponse{OrderId: o.Id})
+}
+
+// (GET /api/partners/v1/orders/{id})
+func (p *Provider) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	orderID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	o, err := p.GetOrder(orderID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+// (PATCH /api/partners/v1/orders/{id}/submit|confirm|cancel)
+func (p *Provider) handleOrderAction(action func(orderID uuid.UUID) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID, ok := pathID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := action(orderID); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// (GET /api/partners/v1/places)
+func (p *Provider) handleListPlaces(w http.ResponseWriter, r *http.Request) {
+	page, pageSize := 1, 20
+
+	query := r.URL.Query()
+	for name, value := range map[string]*int{"page": &page, "pageSize": &pageSize} {
+		if !query.Has(name) {
+			continue
+		}
+		n, err := strconv.Atoi(query.Get(name))
+		if err != nil {
+			writeError(w, ErrValidation)
+			return
+		}
+		*value = n
+	}
+
+	places, err := p.ListPlaces(page, pageSize)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, places)
+}
+
+// (GET /api/partners/v1/places/{id})
+func (p *Provider) handleGetPlace(w http.ResponseWriter, r *http.Request) {
+	placeID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	place, err := p.GetPlace(placeID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, place)
+}
+
+// (PATCH /api/partners/v1/places/{id}/select)
+func (p *Provider) handleSelectPlace(w http.ResponseWriter, r *http.Request) {
+	placeID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req eventprovider.SelectPlaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OrderId == uuid.Nil {
+		writeError(w, ErrValidation)
+		return
+	}
+
+	if err := p.SelectPlace(placeID, req.OrderId); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// (PATCH /api/partners/v1/places/{id}/release)
+func (p *Provider) handleReleasePlace(w http.ResponseWriter, r *http.Request) {
+	placeID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := p.ReleasePlace(placeID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// (POST /api/admin/v1/places)
+func (p *Provider) handleCreatePlace(w http.ResponseWriter, r *http.Request) {
+	var req eventprovider.CreatePlaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, ErrValidation)
+		return
+	}
+
+	place, err := p.CreatePlace(req.Row, req.Seat)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, place)
+}
+
+// pathID parses the order or place id. Ids that are not UUIDs cannot exist, so they are 404.
+func pathID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, &Error{http.StatusNotFound, "NotFound", "The requested resource was not found"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var providerErr *Error
+	if !errors.As(err, &providerErr) {
+		providerErr = &Error{http.StatusInternalServerError, "InternalServerError", err.Error()}
+	}
+	writeJSON(w, providerErr.Status, eventprovider.ErrorResponse{
+		Error:   providerErr.Code,
+		Message: providerErr.Message,
+	})
+}
+
+// discardWriter swallows the response of a request whose answer is replaced by a scripted failure.
+type discardWriter struct {
+	header http.Header
+}
+
+func (d discardWriter) Header() http.Header         { return d.header }
+func (d discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d discardWriter) WriteHeader(int)             {}
@@ -0,0 +1,422 @@
+// Package fakeprovider is an in-memory ticketing provider that speaks the eventprovider
+// OpenAPI contract: orders, places, select/release, submit/confirm/cancel and the admin
+// createPlace.
+//
+// The order and place rules of the real provider are enforced and broken ones are
+// reported with the same error codes (OrderNotStartedException, PlaceAlreadySelectedException...),
+// so the booking saga can run end to end offline. Latency and failures are injected in the
+// HTTP layer, the methods of Provider itself always answer right away.
+package fakeprovider
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"hackload/pkg/eventprovider"
+
+	"github.com/google/uuid"
+)
+
+// Error is a rule violation reported by the provider, with the HTTP status and error code of the real one.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+var (
+	ErrOrderNotFound = &Error{http.StatusNotFound, "NotFound", "Order not found"}
+	ErrPlaceNotFound = &Error{http.StatusNotFound, "NotFound", "Place not found"}
+
+	ErrOrderNotStarted            = &Error{http.StatusConflict, "OrderNotStartedException", "Cannot submit order that has not been started"}
+	ErrOrderNotSubmitted          = &Error{http.StatusConflict, "OrderNotSubmittedException", "Cannot confirm order that has not been submitted"}
+	ErrOrderAlreadyCancelled      = &Error{http.StatusConflict, "OrderAlreadyCancelledException", "Order is already cancelled"}
+	ErrNoPlacesAdded              = &Error{http.StatusConflict, "NoPlacesAddedException", "Cannot submit order without any places"}
+	ErrConfirmedOrderNotCancelled = &Error{http.StatusConflict, "ConfirmedOrderCanNotBeCancelledException", "Cannot cancel confirmed order"}
+	ErrPlaceAlreadySelected       = &Error{http.StatusConflict, "PlaceAlreadySelectedException", "Place is already reserved for another order"}
+	ErrPlaceCanNotBeAdded         = &Error{http.StatusConflict, "PlaceCanNotBeAddedToOrderException", "Place cannot be added to the specified order"}
+	ErrPlaceNotAdded              = &Error{http.StatusConflict, "PlaceNotAddedException", "Cannot release place that was not added to order"}
+	ErrPlaceOfFinishedOrder       = &Error{http.StatusForbidden, "PlaceSelectedForAnotherOrderException", "Cannot release place of an order that is no longer started"}
+
+	ErrValidation = &Error{http.StatusUnprocessableEntity, "ValidationError", "Request format is invalid"}
+)
+
+// orderTransitions lists the statuses each order status may move to.
+var orderTransitions = map[eventprovider.OrderStatus][]eventprovider.OrderStatus{
+	eventprovider.STARTED:   {eventprovider.SUBMITTED, eventprovider.CANCELLED},
+	eventprovider.SUBMITTED: {eventprovider.CONFIRMED, eventprovider.CANCELLED},
+	eventprovider.CONFIRMED: {},
+	eventprovider.CANCELLED: {},
+}
+
+type order struct {
+	id        uuid.UUID
+	status    eventprovider.OrderStatus
+	startedAt time.Time
+	updatedAt time.Time
+	places    []uuid.UUID
+}
+
+type place struct {
+	id      uuid.UUID
+	row     int
+	seat    int
+	orderID uuid.UUID // uuid.Nil - место свободно
+}
+
+// Provider is the fake ticketing provider. The zero value is not usable, create one with New.
+type Provider struct {
+	mu     sync.Mutex
+	orders map[uuid.UUID]*order
+	places map[uuid.UUID]*place
+	// Места в порядке создания, в нем же их отдает ListPlaces
+	placeList []*place
+	failures  []*Failure
+
+	ids       io.Reader
+	rng       *mathrand.Rand
+	rowSize   int
+	latency   time.Duration
+	jitter    time.Duration
+	errorRate float64
+	now       func() time.Time
+	mux       *http.ServeMux
+}
+
+type Option func(p *Provider)
+
+// WithPlaces seeds the venue with n places, see Seed.
+func WithPlaces(n int) Option {
+	return func(p *Provider) {
+		p.Seed(n)
+	}
+}
+
+// WithRowSize sets how many seats a row holds when seeding. The booking service prices seats
+// assuming 1000 seats per row, which is the default.
+func WithRowSize(seats int) Option {
+	return func(p *Provider) {
+		p.rowSize = seats
+	}
+}
+
+// WithRandSeed makes place and order ids, latency jitter and random failures reproducible.
+// Must come before WithPlaces to affect the seeded place ids.
+func WithRandSeed(seed uint64) Option {
+	return func(p *Provider) {
+		p.rng = mathrand.New(mathrand.NewPCG(seed, seed))
+		p.ids = rngReader{p.rng}
+	}
+}
+
+// WithLatency delays every HTTP request by latency plus a random part up to jitter.
+func WithLatency(latency, jitter time.Duration) Option {
+	return func(p *Provider) {
+		p.latency = latency
+		p.jitter = jitter
+	}
+}
+
+// WithErrorRate answers the given share of HTTP requests (0..1) with 500 InternalServerError.
+func WithErrorRate(rate float64) Option {
+	return func(p *Provider) {
+		p.errorRate = rate
+	}
+}
+
+// WithClock replaces time.Now for started_at/updated_at of orders.
+func WithClock(now func() time.Time) Option {
+	return func(p *Provider) {
+		p.now = now
+	}
+}
+
+func New(opts ...Option) *Provider {
+	p := &Provider{
+		orders:  make(map[uuid.UUID]*order),
+		places:  make(map[uuid.UUID]*place),
+		ids:     rand.Reader,
+		rng:     mathrand.New(mathrand.NewPCG(mathrand.Uint64(), mathrand.Uint64())),
+		rowSize: 1000,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.mux = p.routes()
+	return p
+}
+
+// Seed adds n free places, filling rows of the configured size after the existing places.
+func (p *Provider) Seed(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	next := len(p.placeList)
+	for i := next; i < next+n; i++ {
+		p.addPlace(i/p.rowSize+1, i%p.rowSize+1)
+	}
+}
+
+// Reset forgets all orders and scripted failures and frees every place.
+func (p *Provider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.orders = make(map[uuid.UUID]*order)
+	p.failures = nil
+	for _, pl := range p.placeList {
+		pl.orderID = uuid.Nil
+	}
+}
+
+// (POST /api/partners/v1/orders)
+func (p *Provider) StartOrder() eventprovider.Order {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	o := &order{
+		id:        p.newID(),
+		status:    eventprovider.STARTED,
+		startedAt: now,
+		updatedAt: now,
+	}
+	p.orders[o.id] = o
+	return o.dto()
+}
+
+// (GET /api/partners/v1/orders/{id})
+func (p *Provider) GetOrder(orderID uuid.UUID) (eventprovider.Order, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o, ok := p.orders[orderID]
+	if !ok {
+		return eventprovider.Order{}, ErrOrderNotFound
+	}
+	return o.dto(), nil
+}
+
+// (PATCH /api/partners/v1/orders/{id}/submit)
+func (p *Provider) SubmitOrder(orderID uuid.UUID) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o, ok := p.orders[orderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	switch {
+	case o.status == eventprovider.CANCELLED:
+		return ErrOrderAlreadyCancelled
+	case o.status != eventprovider.STARTED:
+		return ErrOrderNotStarted
+	case len(o.places) == 0:
+		return ErrNoPlacesAdded
+	}
+	return p.transition(o, eventprovider.SUBMITTED)
+}
+
+// (PATCH /api/partners/v1/orders/{id}/confirm)
+func (p *Provider) ConfirmOrder(orderID uuid.UUID) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o, ok := p.orders[orderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	switch o.status {
+	case eventprovider.CANCELLED:
+		return ErrOrderAlreadyCancelled
+	case eventprovider.SUBMITTED:
+		return p.transition(o, eventprovider.CONFIRMED)
+	default:
+		return ErrOrderNotSubmitted
+	}
+}
+
+// (PATCH /api/partners/v1/orders/{id}/cancel)
+// Cancelling frees the places of the order. Cancelling a cancelled order is a no-op.
+func (p *Provider) CancelOrder(orderID uuid.UUID) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o, ok := p.orders[orderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	switch o.status {
+	case eventprovider.CANCELLED:
+		return nil
+	case eventprovider.CONFIRMED:
+		return ErrConfirmedOrderNotCancelled
+	}
+
+	for _, placeID := range o.places {
+		p.places[placeID].orderID = uuid.Nil
+	}
+	o.places = nil
+	return p.transition(o, eventprovider.CANCELLED)
+}
+
+// (GET /api/partners/v1/places)
+// All places are listed, free or not, in the order they were created.
+func (p *Provider) ListPlaces(page, pageSize int) ([]eventprovider.Place, error) {
+	if page < 1 || pageSize < 1 || pageSize > 1000 {
+		return nil, ErrValidation
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	from := min((page-1)*pageSize, len(p.placeList))
+	to := min(from+pageSize, len(p.placeList))
+
+	places := make([]eventprovider.Place, 0, to-from)
+	for _, pl := range p.placeList[from:to] {
+		places = append(places, pl.dto())
+	}
+	return places, nil
+}
+
+// (GET /api/partners/v1/places/{id})
+func (p *Provider) GetPlace(placeID uuid.UUID) (eventprovider.Place, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pl, ok := p.places[placeID]
+	if !ok {
+		return eventprovider.Place{}, ErrPlaceNotFound
+	}
+	return pl.dto(), nil
+}
+
+// (PATCH /api/partners/v1/places/{id}/select)
+// Places may only be added to a STARTED order. Selecting a place twice for the same order
+// is rejected like selecting a place of another order.
+func (p *Provider) SelectPlace(placeID, orderID uuid.UUID) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pl, ok := p.places[placeID]
+	if !ok {
+		return ErrPlaceNotFound
+	}
+	o, ok := p.orders[orderID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	if pl.orderID != uuid.Nil {
+		return ErrPlaceAlreadySelected
+	}
+	if o.status != eventprovider.STARTED {
+		return ErrPlaceCanNotBeAdded
+	}
+
+	pl.orderID = o.id
+	o.places = append(o.places, pl.id)
+	o.updatedAt = p.now()
+	return nil
+}
+
+// (PATCH /api/partners/v1/places/{id}/release)
+// Only places of a STARTED order can be released.
+func (p *Provider) ReleasePlace(placeID uuid.UUID) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pl, ok := p.places[placeID]
+	if !ok {
+		return ErrPlaceNotFound
+	}
+	if pl.orderID == uuid.Nil {
+		return ErrPlaceNotAdded
+	}
+	o := p.orders[pl.orderID]
+	if o.status != eventprovider.STARTED {
+		return ErrPlaceOfFinishedOrder
+	}
+
+	pl.orderID = uuid.Nil
+	o.places = slices.DeleteFunc(o.places, func(id uuid.UUID) bool { return id == pl.id })
+	o.updatedAt = p.now()
+	return nil
+}
+
+// (POST /api/admin/v1/places)
+func (p *Provider) CreatePlace(row, seat int) (eventprovider.Place, error) {
+	if row < 1 || seat < 1 {
+		return eventprovider.Place{}, ErrValidation
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.addPlace(row, seat).dto(), nil
+}
+
+// addPlace must be called with p.mu held.
+func (p *Provider) addPlace(row, seat int) *place {
+	pl := &place{id: p.newID(), row: row, seat: seat}
+	p.places[pl.id] = pl
+	p.placeList = append(p.placeList, pl)
+	return pl
+}
+
+// transition must be called with p.mu held.
+func (p *Provider) transition(o *order, status eventprovider.OrderStatus) error {
+	if !slices.Contains(orderTransitions[o.status], status) {
+		return fmt.Errorf("invalid order status transition: %s -> %s", o.status, status)
+	}
+	o.status = status
+	o.updatedAt = p.now()
+	return nil
+}
+
+// newID must be called with p.mu held.
+func (p *Provider) newID() uuid.UUID {
+	return uuid.Must(uuid.NewRandomFromReader(p.ids))
+}
+
+func (o *order) dto() eventprovider.Order {
+	return eventprovider.Order{
+		Id:          o.id,
+		Status:      o.status,
+		StartedAt:   o.startedAt.UnixMilli(),
+		UpdatedAt:   o.updatedAt.UnixMilli(),
+		PlacesCount: len(o.places),
+	}
+}
+
+func (pl *place) dto() eventprovider.Place {
+	return eventprovider.Place{
+		Id:     pl.id,
+		Row:    pl.row,
+		Seat:   pl.seat,
+		IsFree: pl.orderID == uuid.Nil,
+	}
+}
+
+// rngReader reads bytes from a seeded generator, for reproducible ids.
+type rngReader struct {
+	rng *mathrand.Rand
+}
+
+func (r rngReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = byte(r.rng.Uint32())
+	}
+	return len(b), nil
+}
@@ -0,0 +1,381 @@
+package fakeprovider_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"hackload/pkg/eventprovider"
+	"hackload/pkg/eventprovider/fakeprovider"
+
+	"github.com/google/uuid"
+)
+
+type providerEnv struct {
+	server *fakeprovider.Server
+	client *eventprovider.Client
+	places []eventprovider.Place
+}
+
+func newProviderEnv(t *testing.T, places int) *providerEnv {
+	t.Helper()
+
+	server := fakeprovider.NewServer(fakeprovider.WithPlaces(places))
+	t.Cleanup(server.Close)
+	client, err := server.ProviderClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := server.Provider.ListPlaces(1, places)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &providerEnv{server: server, client: client, places: list}
+}
+
+// result returns the status of the response and the error code of a failed one.
+func result(t *testing.T, resp *http.Response, err error) (int, string) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 {
+		return resp.StatusCode, ""
+	}
+	var errorResponse eventprovider.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
+		t.Fatalf("failed to decode %d response: %v", resp.StatusCode, err)
+	}
+	return resp.StatusCode, errorResponse.Error
+}
+
+func (e *providerEnv) selectPlace(t *testing.T, placeID, orderID uuid.UUID) (int, string) {
+	t.Helper()
+	resp, err := e.client.SelectPlace(context.Background(), placeID, eventprovider.SelectPlaceRequest{OrderId: orderID})
+	return result(t, resp, err)
+}
+
+func (e *providerEnv) orderAction(
+	t *testing.T,
+	action func(context.Context, uuid.UUID, ...eventprovider.RequestEditorFn) (*http.Response, error),
+	orderID uuid.UUID,
+) (int, string) {
+	t.Helper()
+	resp, err := action(context.Background(), orderID)
+	return result(t, resp, err)
+}
+
+func (e *providerEnv) releasePlace(t *testing.T, placeID uuid.UUID) (int, string) {
+	t.Helper()
+	resp, err := e.client.ReleasePlace(context.Background(), placeID)
+	return result(t, resp, err)
+}
+
+// order starts an order with the places selected and moves it to status.
+func (e *providerEnv) order(t *testing.T, status eventprovider.OrderStatus, places ...eventprovider.Place) uuid.UUID {
+	t.Helper()
+
+	provider := e.server.Provider
+	order := provider.StartOrder()
+	for _, place := range places {
+		if err := provider.SelectPlace(place.Id, order.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var err error
+	switch status {
+	case eventprovider.SUBMITTED:
+		err = provider.SubmitOrder(order.Id)
+	case eventprovider.CONFIRMED:
+		if err = provider.SubmitOrder(order.Id); err == nil {
+			err = provider.ConfirmOrder(order.Id)
+		}
+	case eventprovider.CANCELLED:
+		err = provider.CancelOrder(order.Id)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return order.Id
+}
+
+func (e *providerEnv) isFree(t *testing.T, place eventprovider.Place) bool {
+	t.Helper()
+
+	got, err := e.server.Provider.GetPlace(place.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got.IsFree
+}
+
+func TestSelectPlace(t *testing.T) {
+	tests := []struct {
+		name string
+		// Заказ, в котором выбирается место, и кто держит место до выбора
+		orderStatus eventprovider.OrderStatus
+		heldBy      string
+		unknown     string
+		wantStatus  int
+		wantCode    string
+	}{
+		{name: "free place", orderStatus: eventprovider.STARTED, wantStatus: http.StatusNoContent},
+		{name: "place of another order", orderStatus: eventprovider.STARTED, heldBy: "other",
+			wantStatus: http.StatusConflict, wantCode: "PlaceAlreadySelectedException"},
+		// Провайдер не различает, чьему заказу принадлежит занятое место
+		{name: "place of the same order", orderStatus: eventprovider.STARTED, heldBy: "same",
+			wantStatus: http.StatusConflict, wantCode: "PlaceAlreadySelectedException"},
+		{name: "submitted order", orderStatus: eventprovider.SUBMITTED,
+			wantStatus: http.StatusConflict, wantCode: "PlaceCanNotBeAddedToOrderException"},
+		{name: "cancelled order", orderStatus: eventprovider.CANCELLED,
+			wantStatus: http.StatusConflict, wantCode: "PlaceCanNotBeAddedToOrderException"},
+		{name: "unknown place", orderStatus: eventprovider.STARTED, unknown: "place",
+			wantStatus: http.StatusNotFound, wantCode: "NotFound"},
+		{name: "unknown order", orderStatus: eventprovider.STARTED, unknown: "order",
+			wantStatus: http.StatusNotFound, wantCode: "NotFound"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newProviderEnv(t, 2)
+			place := env.places[0]
+
+			// Заказ не может уйти из STARTED без мест, поэтому в нем всегда есть второе место
+			var orderID uuid.UUID
+			switch tt.heldBy {
+			case "same":
+				orderID = env.order(t, tt.orderStatus, env.places[1], place)
+			case "other":
+				env.order(t, eventprovider.STARTED, place)
+				orderID = env.order(t, tt.orderStatus, env.places[1])
+			default:
+				orderID = env.order(t, tt.orderStatus, env.places[1])
+			}
+
+			placeID := place.Id
+			switch tt.unknown {
+			case "place":
+				placeID = uuid.New()
+			case "order":
+				orderID = uuid.New()
+			}
+
+			status, code := env.selectPlace(t, placeID, orderID)
+			if status != tt.wantStatus || code != tt.wantCode {
+				t.Errorf("select answered %d %q, want %d %q", status, code, tt.wantStatus, tt.wantCode)
+			}
+
+			if wantFree := tt.heldBy == "" && tt.wantStatus != http.StatusNoContent; env.isFree(t, place) != wantFree {
+				t.Errorf("place is free: %t, want %t", !wantFree, wantFree)
+			}
+		})
+	}
+}
+
+func TestReleasePlace(t *testing.T) {
+	tests := []struct {
+		name string
+		// Статус заказа, в котором выбрано место; пусто - место свободно
+		orderStatus eventprovider.OrderStatus
+		unknown     bool
+		wantStatus  int
+		wantCode    string
+		wantFree    bool
+	}{
+		{name: "place of started order", orderStatus: eventprovider.STARTED, wantStatus: http.StatusNoContent, wantFree: true},
+		{name: "free place", wantStatus: http.StatusConflict, wantCode: "PlaceNotAddedException", wantFree: true},
+		{name: "place of submitted order", orderStatus: eventprovider.SUBMITTED,
+			wantStatus: http.StatusForbidden, wantCode: "PlaceSelectedForAnotherOrderException"},
+		{name: "place of confirmed order", orderStatus: eventprovider.CONFIRMED,
+			wantStatus: http.StatusForbidden, wantCode: "PlaceSelectedForAnotherOrderException"},
+		{name: "unknown place", unknown: true, wantStatus: http.StatusNotFound, wantCode: "NotFound", wantFree: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newProviderEnv(t, 1)
+			place := env.places[0]
+
+			if tt.orderStatus != "" {
+				env.order(t, tt.orderStatus, place)
+			}
+
+			placeID := place.Id
+			if tt.unknown {
+				placeID = uuid.New()
+			}
+
+			status, code := env.releasePlace(t, placeID)
+			if status != tt.wantStatus || code != tt.wantCode {
+				t.Errorf("release answered %d %q, want %d %q", status, code, tt.wantStatus, tt.wantCode)
+			}
+			if free := env.isFree(t, place); free != tt.wantFree {
+				t.Errorf("place is free: %t, want %t", free, tt.wantFree)
+			}
+		})
+	}
+}
+
+func TestOrderLifecycle(t *testing.T) {
+	env := newProviderEnv(t, 2)
+	ctx := context.Background()
+
+	resp, err := env.client.StartOrder(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created eventprovider.OrderCreatedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("start order answered %d", resp.StatusCode)
+	}
+	orderID := created.OrderId
+
+	// Пустой заказ не передается, а неотправленный не подтверждается
+	if status, code := env.orderAction(t, env.client.SubmitOrder, orderID); code != "NoPlacesAddedException" {
+		t.Errorf("submit of empty order answered %d %q", status, code)
+	}
+	if status, code := env.orderAction(t, env.client.ConfirmOrder, orderID); code != "OrderNotSubmittedException" {
+		t.Errorf("confirm of started order answered %d %q", status, code)
+	}
+
+	for _, place := range env.places {
+		if status, code := env.selectPlace(t, place.Id, orderID); status != http.StatusNoContent {
+			t.Fatalf("select answered %d %q", status, code)
+		}
+	}
+
+	if status, code := env.orderAction(t, env.client.SubmitOrder, orderID); status != http.StatusOK {
+		t.Fatalf("submit answered %d %q", status, code)
+	}
+	if status, code := env.orderAction(t, env.client.SubmitOrder, orderID); code != "OrderNotStartedException" {
+		t.Errorf("second submit answered %d %q", status, code)
+	}
+	if status, code := env.orderAction(t, env.client.ConfirmOrder, orderID); status != http.StatusOK {
+		t.Fatalf("confirm answered %d %q", status, code)
+	}
+
+	// Подтвержденный заказ не отменяется, места остаются проданными
+	if status, code := env.orderAction(t, env.client.CancelOrder, orderID); status != http.StatusConflict || code != "ConfirmedOrderCanNotBeCancelledException" {
+		t.Errorf("cancel of confirmed order answered %d %q", status, code)
+	}
+
+	order, err := env.server.Provider.GetOrder(orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != eventprovider.CONFIRMED || order.PlacesCount != 2 {
+		t.Errorf("order is %s with %d places, want CONFIRMED with 2", order.Status, order.PlacesCount)
+	}
+	for i, place := range env.places {
+		if env.isFree(t, place) {
+			t.Errorf("place %d of confirmed order is free", i+1)
+		}
+	}
+}
+
+func TestCancelOrderFreesPlaces(t *testing.T) {
+	env := newProviderEnv(t, 2)
+	orderID := env.order(t, eventprovider.SUBMITTED, env.places...)
+
+	if status, code := env.orderAction(t, env.client.CancelOrder, orderID); status != http.StatusOK {
+		t.Fatalf("cancel answered %d %q", status, code)
+	}
+	// Повторная отмена ничего не меняет
+	if status, code := env.orderAction(t, env.client.CancelOrder, orderID); status != http.StatusOK {
+		t.Errorf("second cancel answered %d %q", status, code)
+	}
+	if status, code := env.orderAction(t, env.client.ConfirmOrder, orderID); code != "OrderAlreadyCancelledException" {
+		t.Errorf("confirm of cancelled order answered %d %q", status, code)
+	}
+	if status, code := env.orderAction(t, env.client.CancelOrder, uuid.New()); status != http.StatusNotFound {
+		t.Errorf("cancel of unknown order answered %d %q", status, code)
+	}
+
+	order, err := env.server.Provider.GetOrder(orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != eventprovider.CANCELLED || order.PlacesCount != 0 {
+		t.Errorf("order is %s with %d places, want CANCELLED with 0", order.Status, order.PlacesCount)
+	}
+	for i, place := range env.places {
+		if !env.isFree(t, place) {
+			t.Errorf("place %d of cancelled order is not free", i+1)
+		}
+	}
+}
+
+func TestListPlacesPages(t *testing.T) {
+	env := newProviderEnv(t, 5)
+	env.order(t, eventprovider.STARTED, env.places[3])
+
+	var listed []eventprovider.Place
+	for page := 1; ; page++ {
+		places, err := env.server.Provider.ListPlaces(page, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(places) == 0 {
+			break
+		}
+		listed = append(listed, places...)
+	}
+
+	if len(listed) != 5 {
+		t.Fatalf("listed %d places, want 5", len(listed))
+	}
+	for i, place := range listed {
+		if place.Id != env.places[i].Id {
+			t.Errorf("place %d is %s, want %s", i+1, place.Id, env.places[i].Id)
+		}
+		if wantFree := i != 3; place.IsFree != wantFree {
+			t.Errorf("place %d is free: %t, want %t", i+1, place.IsFree, wantFree)
+		}
+	}
+
+	if _, err := env.server.Provider.ListPlaces(1, 1001); err != fakeprovider.ErrValidation {
+		t.Errorf("page of 1001 places returned %v, want ErrValidation", err)
+	}
+}
+
+func TestScriptedFailures(t *testing.T) {
+	env := newProviderEnv(t, 2)
+	orderID := env.order(t, eventprovider.STARTED)
+
+	// Сбой отвечает ошибкой и не выбирает место
+	env.server.Provider.Fail(fakeprovider.Failure{Op: fakeprovider.OpSelectPlace, ID: env.places[0].Id.String(), StatusCode: http.StatusServiceUnavailable})
+	if status, _ := env.selectPlace(t, env.places[0].Id, orderID); status != http.StatusServiceUnavailable {
+		t.Errorf("scripted select answered %d, want 503", status)
+	}
+	if !env.isFree(t, env.places[0]) {
+		t.Error("place 1 is selected despite the failure")
+	}
+
+	// Сбой по месту не задевает другие места и срабатывает один раз
+	if status, code := env.selectPlace(t, env.places[1].Id, orderID); status != http.StatusNoContent {
+		t.Errorf("select of another place answered %d %q", status, code)
+	}
+	if status, code := env.selectPlace(t, env.places[0].Id, orderID); status != http.StatusNoContent {
+		t.Errorf("select after the failure answered %d %q", status, code)
+	}
+
+	// Ответ потерян после выполнения запроса: место освобождено, хотя клиент получил 500
+	env.server.Provider.Fail(fakeprovider.Failure{Op: fakeprovider.OpReleasePlace, AfterwardsStatusCode: http.StatusInternalServerError})
+	if status, _ := env.releasePlace(t, env.places[0].Id); status != http.StatusInternalServerError {
+		t.Errorf("scripted release answered %d, want 500", status)
+	}
+	if !env.isFree(t, env.places[0]) {
+		t.Error("place 1 is not released behind the lost answer")
+	}
+}
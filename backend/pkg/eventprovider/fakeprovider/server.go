@@ -0,0 +1,29 @@
+package fakeprovider
+
+import (
+	"net/http/httptest"
+
+	"hackload/pkg/eventprovider"
+)
+
+// Server is a fake provider listening on a local httptest server, for integration tests.
+type Server struct {
+	*httptest.Server
+	Provider *Provider
+}
+
+// NewServer starts a fake provider. The caller should Close it when finished.
+func NewServer(opts ...Option) *Server {
+	p := New(opts...)
+	return &Server{Server: httptest.NewServer(p), Provider: p}
+}
+
+// ProviderClient returns a generated client for the server, as the booking service builds it.
+func (s *Server) ProviderClient() (*eventprovider.Client, error) {
+	return eventprovider.NewClient(s.URL)
+}
+
+// ProviderClientWithResponses is ProviderClient for code that parses typed responses, like ResetService.
+func (s *Server) ProviderClientWithResponses() (*eventprovider.ClientWithResponses, error) {
+	return eventprovider.NewClientWithResponses(s.URL)
+}
@@ -0,0 +1,125 @@
+package fakegateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Эндпоинты управления фейком, которых нет у настоящего шлюза. Через них сценарии
+// отказов задаются по HTTP, когда шлюз запущен отдельной командой.
+func (g *Gateway) controlRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /_fake/failures", g.handleScriptFailure)
+	mux.HandleFunc("POST /_fake/payments/{paymentId}/pay", g.handleControl(g.Pay))
+	mux.HandleFunc("POST /_fake/payments/{paymentId}/decline", g.handleControl(g.Decline))
+	mux.HandleFunc("POST /_fake/payments/{paymentId}/expire", g.handleControl(g.Expire))
+	mux.HandleFunc("GET /_fake/payments/{paymentId}", g.handlePayment)
+	mux.HandleFunc("GET /_fake/notifications", g.handleNotifications)
+	mux.HandleFunc("POST /_fake/reset", g.handleReset)
+}
+
+type failureRequest struct {
+	Op         Operation `json:"op"`
+	OrderID    string    `json:"order_id"`
+	StatusCode int       `json:"status_code"`
+	Delay      string    `json:"delay"` // например "2s"
+	Duplicate  bool      `json:"duplicate"`
+	Times      int       `json:"times"`
+}
+
+// (POST /_fake/failures)
+func (g *Gateway) handleScriptFailure(w http.ResponseWriter, r *http.Request) {
+	var req failureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Op {
+	case OpInit, OpCheck, OpConfirm, OpCancel, OpPay, OpNotify:
+	default:
+		http.Error(w, "Unknown operation", http.StatusBadRequest)
+		return
+	}
+
+	var delay time.Duration
+	if req.Delay != "" {
+		var err error
+		if delay, err = time.ParseDuration(req.Delay); err != nil {
+			http.Error(w, "Invalid delay", http.StatusBadRequest)
+			return
+		}
+	}
+
+	g.Fail(Failure{
+		Op:         req.Op,
+		OrderID:    req.OrderID,
+		StatusCode: req.StatusCode,
+		Delay:      delay,
+		Duplicate:  req.Duplicate,
+		Times:      req.Times,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *Gateway) handleControl(action func(paymentID string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := action(r.PathValue("paymentId")); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, ErrPaymentNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// (GET /_fake/payments/{paymentId})
+func (g *Gateway) handlePayment(w http.ResponseWriter, r *http.Request) {
+	p, ok := g.Payment(r.PathValue("paymentId"))
+	if !ok {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// (GET /_fake/notifications)
+func (g *Gateway) handleNotifications(w http.ResponseWriter, r *http.Request) {
+	type notification struct {
+		PaymentID  string    `json:"payment_id"`
+		OrderID    string    `json:"order_id"`
+		Status     string    `json:"status"`
+		StatusCode int       `json:"status_code,omitempty"`
+		Error      string    `json:"error,omitempty"`
+		Dropped    bool      `json:"dropped,omitempty"`
+		SentAt     time.Time `json:"sent_at"`
+	}
+
+	notifications := g.Notifications()
+	resp := make([]notification, 0, len(notifications))
+	for _, n := range notifications {
+		item := notification{
+			PaymentID:  n.PaymentID,
+			OrderID:    n.OrderID,
+			Status:     n.Status,
+			StatusCode: n.StatusCode,
+			Dropped:    n.Dropped,
+			SentAt:     n.SentAt,
+		}
+		if n.Err != nil {
+			item.Error = n.Err.Error()
+		}
+		resp = append(resp, item)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// (POST /_fake/reset)
+func (g *Gateway) handleReset(w http.ResponseWriter, r *http.Request) {
+	g.Reset()
+	w.WriteHeader(http.StatusNoContent)
+}
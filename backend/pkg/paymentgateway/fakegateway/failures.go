@@ -0,0 +1,65 @@
+package fakegateway
+
+import "time"
+
+// Operation is what a scripted Failure applies to.
+type Operation string
+
+const (
+	OpInit    Operation = "init"
+	OpCheck   Operation = "check"
+	OpConfirm Operation = "confirm"
+	OpCancel  Operation = "cancel"
+	// Банк отклоняет карту, даже если форма отправлена с успехом
+	OpPay Operation = "pay"
+	// Уведомление мерчанту теряется, задерживается или приходит дважды
+	OpNotify Operation = "notify"
+)
+
+// Failure is a scripted fault. It applies to the next Times requests of Op, only for
+// OrderID if it is set.
+//
+// For the API operations (init, check, confirm, cancel) the gateway waits Delay and then
+// answers StatusCode without touching the payment. A Failure with a Delay and no StatusCode
+// only slows the request down, one with neither answers 500.
+// For OpPay the payment is rejected. For OpNotify the notification is delivered after
+// Delay, twice if Duplicate is set, and dropped if neither is set.
+type Failure struct {
+	Op         Operation
+	OrderID    string
+	StatusCode int
+	Delay      time.Duration
+	Duplicate  bool
+	// Сколько раз сработать: 0 - один раз, меньше нуля - всегда
+	Times int
+}
+
+// Fail scripts a failure. Failures are matched in the order they were added.
+func (g *Gateway) Fail(f Failure) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if f.Times == 0 {
+		f.Times = 1
+	}
+	g.failures = append(g.failures, &f)
+}
+
+// takeFailure returns the first failure matching the request and uses it up. Must be called with g.mu held.
+func (g *Gateway) takeFailure(op Operation, orderID string) *Failure {
+	for i, f := range g.failures {
+		if f.Op != op || (f.OrderID != "" && f.OrderID != orderID) {
+			continue
+		}
+
+		taken := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				g.failures = append(g.failures[:i], g.failures[i+1:]...)
+			}
+		}
+		return &taken
+	}
+	return nil
+}
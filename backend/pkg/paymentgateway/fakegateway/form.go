@@ -0,0 +1,128 @@
+package fakegateway
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"hackload/internal/domain"
+)
+
+// DeclinedCard is the card number the payment form always declines.
+const DeclinedCard = "4000000000000002"
+
+var formTemplate = template.Must(template.New("form").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Fake payment gateway</title></head>
+<body>
+<h1>Order {{.OrderID}}</h1>
+<p>{{.Description}}</p>
+<p>Amount: <b>{{.Amount}} {{.Currency}}</b>{{if .TwoStage}} (two-stage){{end}}</p>
+<p>Status: {{.Status}}</p>
+{{if .Payable}}
+<form method="post" action="/api/v1/PaymentForm/submit">
+  <input type="hidden" name="PaymentId" value="{{.PaymentID}}">
+  <p><label>Card number <input name="CardNumber" value="4111111111111111"></label></p>
+  <p><label>Expiry <input name="ExpiryDate" value="12/30"></label> <label>CVV <input name="Cvv" value="123"></label></p>
+  <p>Card {{.DeclinedCard}} is always declined.</p>
+  <button type="submit" name="Action" value="pay">Pay</button>
+  <button type="submit" name="Action" value="decline">Decline</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+var resultTemplate = template.Must(template.New("result").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Fake payment gateway</title></head>
+<body>
+<h1>{{if .Success}}Payment completed{{else}}Payment failed{{end}}</h1>
+<p>Order {{.OrderID}}, status {{.Status}}</p>
+</body>
+</html>
+`))
+
+type formView struct {
+	Payment
+	Amount       string
+	Payable      bool
+	DeclinedCard string
+}
+
+// (GET /api/v1/PaymentForm/render/{paymentId})
+func (g *Gateway) handleFormRender(w http.ResponseWriter, r *http.Request) {
+	paymentID := r.PathValue("paymentId")
+
+	g.mu.Lock()
+	p, ok := g.payments[paymentID]
+	if !ok {
+		g.mu.Unlock()
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	g.expire(p)
+	if p.Status == StatusNew {
+		_ = g.transition(p, StatusFormShowed)
+	}
+	snapshot := *p
+	g.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = formTemplate.Execute(w, formView{
+		Payment:      snapshot,
+		Amount:       domain.Money(snapshot.Amount).String(),
+		Payable:      snapshot.Status == StatusFormShowed,
+		DeclinedCard: DeclinedCard,
+	})
+}
+
+// (POST /api/v1/PaymentForm/submit)
+func (g *Gateway) handleFormSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	paymentID := r.PostForm.Get("PaymentId")
+	approved := r.PostForm.Get("Action") != "decline" && r.PostForm.Get("CardNumber") != DeclinedCard
+
+	redirect, err := g.submit(paymentID, approved)
+	if err != nil {
+		status := http.StatusConflict
+		if errors.Is(err, ErrPaymentNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// Без адресов мерчанта пользователь попадает на страницу результата шлюза
+	if redirect == "" {
+		p, _ := g.Payment(paymentID)
+		success := p.Status == StatusConfirmed || p.Status == StatusAuthorized
+		redirect = "/api/v1/PaymentForm/result/" + url.PathEscape(paymentID) + "?success=" + strconv.FormatBool(success)
+	}
+
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// (GET /api/v1/PaymentForm/result/{paymentId})
+func (g *Gateway) handleFormResult(w http.ResponseWriter, r *http.Request) {
+	p, ok := g.Payment(r.PathValue("paymentId"))
+	if !ok {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = resultTemplate.Execute(w, struct {
+		Payment
+		Success bool
+	}{
+		Payment: p,
+		Success: p.Status == StatusConfirmed || p.Status == StatusAuthorized,
+	})
+}
@@ -0,0 +1,328 @@
+// Package fakegateway is an in-memory payment gateway that speaks the paymentgateway
+// OpenAPI contract: init, check, status, confirm, cancel, the payment form with its
+// redirects and the notifications sent to the merchant.
+//
+// Requests are signed and verified with paymenttoken, so a booking service talking to
+// the fake runs exactly the code it runs against the real gateway. Amounts are minor
+// units of the currency, as everywhere in this service.
+//
+// Besides the contract the fake serves /_fake/... endpoints to pay, decline or expire a
+// payment and to script failures over HTTP, see control.go.
+package fakegateway
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Статусы платежа в терминах платежного шлюза
+const (
+	StatusNew        = "NEW"
+	StatusFormShowed = "FORM_SHOWED"
+	// Двухстадийный платеж: деньги заблокированы, ждут PaymentConfirm
+	StatusAuthorized      = "AUTHORIZED"
+	StatusConfirmed       = "CONFIRMED"
+	StatusRejected        = "REJECTED"
+	StatusCancelled       = "CANCELLED"
+	StatusReversed        = "REVERSED"
+	StatusRefunded        = "REFUNDED"
+	StatusPartialRefunded = "PARTIAL_REFUNDED"
+	StatusExpired         = "DEADLINE_EXPIRED"
+)
+
+// paymentTransitions lists the statuses each status may move to.
+var paymentTransitions = map[string][]string{
+	StatusNew:             {StatusFormShowed, StatusAuthorized, StatusConfirmed, StatusRejected, StatusCancelled, StatusExpired},
+	StatusFormShowed:      {StatusAuthorized, StatusConfirmed, StatusRejected, StatusCancelled, StatusExpired},
+	StatusAuthorized:      {StatusConfirmed, StatusReversed},
+	StatusConfirmed:       {StatusRefunded, StatusPartialRefunded},
+	StatusPartialRefunded: {StatusRefunded, StatusPartialRefunded},
+	StatusRejected:        {},
+	StatusCancelled:       {},
+	StatusReversed:        {},
+	StatusRefunded:        {},
+	StatusExpired:         {},
+}
+
+var (
+	ErrPaymentNotFound   = errors.New("payment not found")
+	ErrInvalidTransition = errors.New("invalid payment status transition")
+)
+
+// Payment is a snapshot of a payment held by the fake gateway.
+type Payment struct {
+	PaymentID       string    `json:"payment_id"`
+	OrderID         string    `json:"order_id"`
+	TeamSlug        string    `json:"team_slug"`
+	Amount          int64     `json:"amount"` // Amount in minor units
+	ConfirmedAmount int64     `json:"confirmed_amount"`
+	RefundedAmount  int64     `json:"refunded_amount"`
+	Currency        string    `json:"currency"`
+	Description     string    `json:"description"`
+	TwoStage        bool      `json:"two_stage"`
+	Status          string    `json:"status"`
+	SuccessURL      string    `json:"success_url"`
+	FailURL         string    `json:"fail_url"`
+	NotificationURL string    `json:"notification_url"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+// Notification is a webhook sent (or deliberately not sent) to the merchant.
+type Notification struct {
+	PaymentID  string
+	OrderID    string
+	Status     string
+	URL        string
+	Body       []byte
+	StatusCode int // 0 if the request failed or was dropped
+	Err        error
+	Dropped    bool
+	SentAt     time.Time
+}
+
+// Gateway is the fake payment gateway. The zero value is not usable, create one with New.
+type Gateway struct {
+	mu            sync.Mutex
+	teams         map[string]string // teamSlug -> password
+	payments      map[string]*Payment
+	orders        map[string]string // teamSlug/orderId -> paymentId
+	failures      []*Failure
+	notifications []Notification
+	lastID        int64
+
+	baseURL    string
+	paymentTTL time.Duration
+	client     *http.Client
+	now        func() time.Time
+	logger     *slog.Logger
+	mux        *http.ServeMux
+}
+
+type Option func(g *Gateway)
+
+// WithTeam registers a merchant. Requests of unknown teams are rejected with 401.
+func WithTeam(teamSlug, password string) Option {
+	return func(g *Gateway) {
+		g.teams[teamSlug] = password
+	}
+}
+
+// WithBaseURL sets the public address of the gateway, used for the payment form URL.
+func WithBaseURL(baseURL string) Option {
+	return func(g *Gateway) {
+		g.baseURL = baseURL
+	}
+}
+
+// WithPaymentTTL sets how long a payment waits for the form to be submitted.
+func WithPaymentTTL(ttl time.Duration) Option {
+	return func(g *Gateway) {
+		g.paymentTTL = ttl
+	}
+}
+
+// WithHTTPClient sets the client used for notifications and followed redirects.
+func WithHTTPClient(client *http.Client) Option {
+	return func(g *Gateway) {
+		g.client = client
+	}
+}
+
+// WithClock replaces time.Now, e.g. to expire payments without waiting.
+func WithClock(now func() time.Time) Option {
+	return func(g *Gateway) {
+		g.now = now
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(g *Gateway) {
+		g.logger = logger
+	}
+}
+
+func New(opts ...Option) *Gateway {
+	g := &Gateway{
+		teams:      make(map[string]string),
+		payments:   make(map[string]*Payment),
+		orders:     make(map[string]string),
+		paymentTTL: 15 * time.Minute,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+		logger:     slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.mux = g.routes()
+	return g
+}
+
+// Payment returns a snapshot of the payment.
+func (g *Gateway) Payment(paymentID string) (Payment, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p, ok := g.payments[paymentID]
+	if !ok {
+		return Payment{}, false
+	}
+	g.expire(p)
+	return *p, true
+}
+
+// PaymentByOrder returns a snapshot of the payment created for the merchant's order.
+func (g *Gateway) PaymentByOrder(teamSlug, orderID string) (Payment, bool) {
+	g.mu.Lock()
+	paymentID, ok := g.orders[orderKey(teamSlug, orderID)]
+	g.mu.Unlock()
+	if !ok {
+		return Payment{}, false
+	}
+	return g.Payment(paymentID)
+}
+
+// Notifications returns the webhooks sent so far, oldest first.
+func (g *Gateway) Notifications() []Notification {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return slices.Clone(g.notifications)
+}
+
+// Pay completes the payment as if the user submitted the form with a valid card:
+// the payment is authorized (two-stage) or confirmed, the merchant is notified and
+// the success redirect is followed. It is the programmatic twin of the payment form.
+func (g *Gateway) Pay(paymentID string) error {
+	redirect, err := g.submit(paymentID, true)
+	if err != nil {
+		return err
+	}
+	return g.followRedirect(redirect)
+}
+
+// Decline rejects the payment as if the bank declined the card, notifies the merchant
+// and follows the fail redirect.
+func (g *Gateway) Decline(paymentID string) error {
+	redirect, err := g.submit(paymentID, false)
+	if err != nil {
+		return err
+	}
+	return g.followRedirect(redirect)
+}
+
+// Expire moves an unpaid payment to DEADLINE_EXPIRED right away and notifies the merchant.
+func (g *Gateway) Expire(paymentID string) error {
+	g.mu.Lock()
+	p, ok := g.payments[paymentID]
+	if !ok {
+		g.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrPaymentNotFound, paymentID)
+	}
+	if err := g.transition(p, StatusExpired); err != nil {
+		g.mu.Unlock()
+		return err
+	}
+	snapshot := *p
+	g.mu.Unlock()
+
+	g.notify(snapshot)
+	return nil
+}
+
+// Reset forgets all payments, notifications and scripted failures. Teams are kept.
+func (g *Gateway) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.payments = make(map[string]*Payment)
+	g.orders = make(map[string]string)
+	g.failures = nil
+	g.notifications = nil
+}
+
+// submit applies the outcome of the payment form and returns where to redirect the user.
+func (g *Gateway) submit(paymentID string, approved bool) (string, error) {
+	g.mu.Lock()
+	p, ok := g.payments[paymentID]
+	if !ok {
+		g.mu.Unlock()
+		return "", fmt.Errorf("%w: %s", ErrPaymentNotFound, paymentID)
+	}
+	g.expire(p)
+
+	// Сценарий отказа банка по заказу сильнее выбора пользователя
+	if approved && g.takeFailure(OpPay, p.OrderID) != nil {
+		approved = false
+	}
+
+	status := StatusRejected
+	if approved {
+		status = StatusConfirmed
+		if p.TwoStage {
+			status = StatusAuthorized
+		}
+	}
+	if err := g.transition(p, status); err != nil {
+		g.mu.Unlock()
+		return "", err
+	}
+	if status == StatusConfirmed {
+		p.ConfirmedAmount = p.Amount
+	}
+	snapshot := *p
+	g.mu.Unlock()
+
+	g.notify(snapshot)
+
+	if approved {
+		return snapshot.SuccessURL, nil
+	}
+	return snapshot.FailURL, nil
+}
+
+func (g *Gateway) followRedirect(redirect string) error {
+	if redirect == "" {
+		return nil
+	}
+	resp, err := g.client.Get(redirect)
+	if err != nil {
+		return fmt.Errorf("failed to follow redirect to %s: %w", redirect, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// transition moves the payment to status. Must be called with g.mu held.
+func (g *Gateway) transition(p *Payment, status string) error {
+	if !slices.Contains(paymentTransitions[p.Status], status) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, p.Status, status)
+	}
+	p.Status = status
+	p.UpdatedAt = g.now().UTC()
+	return nil
+}
+
+// expire moves an unpaid payment past its deadline to DEADLINE_EXPIRED. Must be called with g.mu held.
+// The merchant is not notified: the deadline is noticed lazily, on the next request for the payment.
+func (g *Gateway) expire(p *Payment) {
+	if (p.Status == StatusNew || p.Status == StatusFormShowed) && g.now().After(p.ExpiresAt) {
+		_ = g.transition(p, StatusExpired)
+	}
+}
+
+func (g *Gateway) nextPaymentID() string {
+	g.lastID++
+	return "pay_" + strconv.FormatInt(g.lastID, 10)
+}
+
+func orderKey(teamSlug, orderID string) string {
+	return teamSlug + "/" + orderID
+}
@@ -0,0 +1,436 @@
+package fakegateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hackload/internal/paymenttoken"
+	"hackload/pkg/paymentgateway"
+	"hackload/pkg/paymentgateway/fakegateway"
+)
+
+const (
+	teamSlug = "team"
+	password = "secret"
+)
+
+type gatewayEnv struct {
+	server *fakegateway.Server
+	client *paymentgateway.Client
+	signer *paymenttoken.Signer
+}
+
+func newGatewayEnv(t *testing.T, opts ...fakegateway.Option) *gatewayEnv {
+	t.Helper()
+
+	server := fakegateway.NewServer(append([]fakegateway.Option{fakegateway.WithTeam(teamSlug, password)}, opts...)...)
+	t.Cleanup(server.Close)
+	client, err := server.PaymentClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &gatewayEnv{server: server, client: client, signer: paymenttoken.NewSigner(password)}
+}
+
+func (e *gatewayEnv) sign(t *testing.T, dto any) string {
+	t.Helper()
+
+	token, err := e.signer.Sign(dto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// decode reads the response into dto and returns its status code.
+func decode(t *testing.T, resp *http.Response, dto any) int {
+	t.Helper()
+	defer resp.Body.Close()
+
+	if dto != nil {
+		if err := json.NewDecoder(resp.Body).Decode(dto); err != nil {
+			t.Fatalf("failed to decode %d response: %v", resp.StatusCode, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// initPayment starts a payment of amount for the order, one-stage or two-stage with payType "T".
+func (e *gatewayEnv) initPayment(t *testing.T, orderID string, amount int64, payType string, notificationURL string) (int, string) {
+	t.Helper()
+
+	req := paymentgateway.PaymentInitRequestDto{Amount: float64(amount), OrderId: orderID, TeamSlug: teamSlug, PayType: &payType}
+	if notificationURL != "" {
+		req.NotificationURL = &notificationURL
+	}
+	req.Token = e.sign(t, req)
+
+	resp, err := e.client.PostApiV1PaymentInitInit(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var init paymentgateway.PaymentInitResponseDto
+	code := decode(t, resp, &init)
+	if init.PaymentId == nil {
+		return code, ""
+	}
+	return code, *init.PaymentId
+}
+
+func (e *gatewayEnv) mustInitPayment(t *testing.T, orderID string, amount int64, payType string) string {
+	t.Helper()
+
+	code, paymentID := e.initPayment(t, orderID, amount, payType, "")
+	if code != http.StatusOK || paymentID == "" {
+		t.Fatalf("init answered %d", code)
+	}
+	return paymentID
+}
+
+func (e *gatewayEnv) confirm(t *testing.T, paymentID string, amount *float64) int {
+	t.Helper()
+
+	req := paymentgateway.PaymentConfirmRequestDto{PaymentId: paymentID, TeamSlug: teamSlug, Amount: amount}
+	req.Token = e.sign(t, req)
+
+	resp, err := e.client.PostApiV1PaymentConfirmConfirm(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decode(t, resp, nil)
+}
+
+func (e *gatewayEnv) cancel(t *testing.T, paymentID string, amount *float64) (int, paymentgateway.PaymentCancelResponseDto) {
+	t.Helper()
+
+	req := paymentgateway.PaymentCancelRequestDto{PaymentId: paymentID, TeamSlug: teamSlug, Amount: amount}
+	req.Token = e.sign(t, req)
+
+	resp, err := e.client.PostApiV1PaymentCancelCancel(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cancel paymentgateway.PaymentCancelResponseDto
+	return decode(t, resp, &cancel), cancel
+}
+
+func (e *gatewayEnv) check(t *testing.T, orderID string) int {
+	t.Helper()
+
+	req := paymentgateway.PaymentCheckRequestDto{OrderId: &orderID, TeamSlug: teamSlug}
+	req.Token = e.sign(t, req)
+
+	resp, err := e.client.PostApiV1PaymentCheckCheck(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decode(t, resp, nil)
+}
+
+func (e *gatewayEnv) payment(t *testing.T, paymentID string) fakegateway.Payment {
+	t.Helper()
+
+	payment, ok := e.server.Gateway.Payment(paymentID)
+	if !ok {
+		t.Fatalf("payment %s not found", paymentID)
+	}
+	return payment
+}
+
+func amount(v float64) *float64 {
+	return &v
+}
+
+func TestPayConfirmsOneStagePayment(t *testing.T) {
+	env := newGatewayEnv(t)
+	paymentID := env.mustInitPayment(t, "order-1", 10000, "O")
+
+	if status := env.payment(t, paymentID).Status; status != fakegateway.StatusNew {
+		t.Fatalf("new payment is %s, want NEW", status)
+	}
+	if err := env.server.Gateway.Pay(paymentID); err != nil {
+		t.Fatal(err)
+	}
+
+	payment := env.payment(t, paymentID)
+	if payment.Status != fakegateway.StatusConfirmed || payment.ConfirmedAmount != 10000 {
+		t.Errorf("payment is %s with %d confirmed, want CONFIRMED with 10000", payment.Status, payment.ConfirmedAmount)
+	}
+
+	// Оплаченный платеж нельзя оплатить или отклонить повторно
+	if err := env.server.Gateway.Pay(paymentID); !errors.Is(err, fakegateway.ErrInvalidTransition) {
+		t.Errorf("second pay returned %v, want ErrInvalidTransition", err)
+	}
+	if err := env.server.Gateway.Decline(paymentID); !errors.Is(err, fakegateway.ErrInvalidTransition) {
+		t.Errorf("decline returned %v, want ErrInvalidTransition", err)
+	}
+}
+
+func TestPayAuthorizesTwoStagePayment(t *testing.T) {
+	env := newGatewayEnv(t)
+	paymentID := env.mustInitPayment(t, "order-1", 10000, "T")
+
+	if err := env.server.Gateway.Pay(paymentID); err != nil {
+		t.Fatal(err)
+	}
+	if status := env.payment(t, paymentID).Status; status != fakegateway.StatusAuthorized {
+		t.Fatalf("paid payment is %s, want AUTHORIZED", status)
+	}
+
+	if code := env.confirm(t, paymentID, amount(20000)); code != http.StatusBadRequest {
+		t.Errorf("confirm of more than authorized answered %d, want 400", code)
+	}
+	if code := env.confirm(t, paymentID, amount(6000)); code != http.StatusOK {
+		t.Fatalf("confirm answered %d", code)
+	}
+
+	payment := env.payment(t, paymentID)
+	if payment.Status != fakegateway.StatusConfirmed || payment.ConfirmedAmount != 6000 {
+		t.Errorf("payment is %s with %d confirmed, want CONFIRMED with 6000", payment.Status, payment.ConfirmedAmount)
+	}
+	if code := env.confirm(t, paymentID, nil); code != http.StatusConflict {
+		t.Errorf("second confirm answered %d, want 409", code)
+	}
+}
+
+func TestCancelByPaymentStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		payType string
+		pay     bool
+		want    string
+	}{
+		{name: "unpaid", payType: "O", want: fakegateway.StatusCancelled},
+		{name: "authorized", payType: "T", pay: true, want: fakegateway.StatusReversed},
+		{name: "confirmed", payType: "O", pay: true, want: fakegateway.StatusRefunded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newGatewayEnv(t)
+			paymentID := env.mustInitPayment(t, "order-1", 10000, tt.payType)
+			if tt.pay {
+				if err := env.server.Gateway.Pay(paymentID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			code, cancel := env.cancel(t, paymentID, nil)
+			if code != http.StatusOK {
+				t.Fatalf("cancel answered %d", code)
+			}
+			if cancel.Status == nil || *cancel.Status != tt.want {
+				t.Errorf("cancel answered status %v, want %s", cancel.Status, tt.want)
+			}
+			if status := env.payment(t, paymentID).Status; status != tt.want {
+				t.Errorf("payment is %s, want %s", status, tt.want)
+			}
+
+			// Из конечного статуса платеж уже не уходит
+			if code, _ := env.cancel(t, paymentID, nil); code != http.StatusConflict {
+				t.Errorf("second cancel answered %d, want 409", code)
+			}
+		})
+	}
+}
+
+func TestCancelRefundsPartially(t *testing.T) {
+	env := newGatewayEnv(t)
+	paymentID := env.mustInitPayment(t, "order-1", 10000, "O")
+	if err := env.server.Gateway.Pay(paymentID); err != nil {
+		t.Fatal(err)
+	}
+
+	code, cancel := env.cancel(t, paymentID, amount(4000))
+	if code != http.StatusOK {
+		t.Fatalf("partial refund answered %d", code)
+	}
+	if cancel.CancellationType == nil || *cancel.CancellationType != "PARTIAL" {
+		t.Errorf("cancellation type is %v, want PARTIAL", cancel.CancellationType)
+	}
+	payment := env.payment(t, paymentID)
+	if payment.Status != fakegateway.StatusPartialRefunded || payment.RefundedAmount != 4000 {
+		t.Errorf("payment is %s with %d refunded, want PARTIAL_REFUNDED with 4000", payment.Status, payment.RefundedAmount)
+	}
+
+	if code, _ := env.cancel(t, paymentID, amount(7000)); code != http.StatusUnprocessableEntity {
+		t.Errorf("refund over the remaining amount answered %d, want 422", code)
+	}
+
+	if code, _ := env.cancel(t, paymentID, amount(6000)); code != http.StatusOK {
+		t.Fatalf("refund of the rest answered %d", code)
+	}
+	payment = env.payment(t, paymentID)
+	if payment.Status != fakegateway.StatusRefunded || payment.RefundedAmount != 10000 {
+		t.Errorf("payment is %s with %d refunded, want REFUNDED with 10000", payment.Status, payment.RefundedAmount)
+	}
+}
+
+func TestDeclineAndExpire(t *testing.T) {
+	env := newGatewayEnv(t)
+
+	declined := env.mustInitPayment(t, "order-1", 10000, "O")
+	if err := env.server.Gateway.Decline(declined); err != nil {
+		t.Fatal(err)
+	}
+	if status := env.payment(t, declined).Status; status != fakegateway.StatusRejected {
+		t.Errorf("declined payment is %s, want REJECTED", status)
+	}
+
+	expired := env.mustInitPayment(t, "order-2", 10000, "O")
+	if err := env.server.Gateway.Expire(expired); err != nil {
+		t.Fatal(err)
+	}
+	if status := env.payment(t, expired).Status; status != fakegateway.StatusExpired {
+		t.Errorf("expired payment is %s, want DEADLINE_EXPIRED", status)
+	}
+	if err := env.server.Gateway.Pay(expired); !errors.Is(err, fakegateway.ErrInvalidTransition) {
+		t.Errorf("pay of expired payment returned %v, want ErrInvalidTransition", err)
+	}
+
+	if err := env.server.Gateway.Pay("unknown"); !errors.Is(err, fakegateway.ErrPaymentNotFound) {
+		t.Errorf("pay of unknown payment returned %v, want ErrPaymentNotFound", err)
+	}
+}
+
+func TestPaymentExpiresAfterTTL(t *testing.T) {
+	var now atomic.Int64
+	now.Store(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Unix())
+	env := newGatewayEnv(t,
+		fakegateway.WithPaymentTTL(time.Minute),
+		fakegateway.WithClock(func() time.Time { return time.Unix(now.Load(), 0) }),
+	)
+	paymentID := env.mustInitPayment(t, "order-1", 10000, "O")
+
+	now.Add(61)
+
+	if status := env.payment(t, paymentID).Status; status != fakegateway.StatusExpired {
+		t.Errorf("payment past its TTL is %s, want DEADLINE_EXPIRED", status)
+	}
+}
+
+func TestInitRejectsDuplicateOrderAndForeignToken(t *testing.T) {
+	env := newGatewayEnv(t)
+	env.mustInitPayment(t, "order-1", 10000, "O")
+
+	if code, _ := env.initPayment(t, "order-1", 10000, "O", ""); code != http.StatusUnprocessableEntity {
+		t.Errorf("init of the same order answered %d, want 422", code)
+	}
+
+	req := paymentgateway.PaymentInitRequestDto{Amount: 10000, OrderId: "order-2", TeamSlug: teamSlug}
+	req.Token, _ = paymenttoken.NewSigner("wrong").Sign(req)
+	resp, err := env.client.PostApiV1PaymentInitInit(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := decode(t, resp, nil); code != http.StatusUnauthorized {
+		t.Errorf("init with a wrong token answered %d, want 401", code)
+	}
+	if _, ok := env.server.Gateway.PaymentByOrder(teamSlug, "order-2"); ok {
+		t.Error("payment created with a wrong token")
+	}
+}
+
+func TestScriptedFailures(t *testing.T) {
+	env := newGatewayEnv(t)
+
+	// Сбой отвечает заданным статусом и не создает платеж, следующий запрос проходит
+	env.server.Gateway.Fail(fakegateway.Failure{Op: fakegateway.OpInit, StatusCode: http.StatusServiceUnavailable})
+	if code, _ := env.initPayment(t, "order-1", 10000, "O", ""); code != http.StatusServiceUnavailable {
+		t.Errorf("scripted init answered %d, want 503", code)
+	}
+	if _, ok := env.server.Gateway.PaymentByOrder(teamSlug, "order-1"); ok {
+		t.Error("failed init created a payment")
+	}
+	paymentID := env.mustInitPayment(t, "order-1", 10000, "O")
+	env.mustInitPayment(t, "order-2", 10000, "O")
+
+	// Сбой по заказу не задевает другие заказы и срабатывает заданное число раз
+	env.server.Gateway.Fail(fakegateway.Failure{Op: fakegateway.OpCheck, OrderID: "order-1", Times: 2})
+	if code := env.check(t, "order-2"); code != http.StatusOK {
+		t.Errorf("check of another order answered %d, want 200", code)
+	}
+	for i := range 2 {
+		if code := env.check(t, "order-1"); code != http.StatusInternalServerError {
+			t.Errorf("scripted check %d answered %d, want 500", i+1, code)
+		}
+	}
+	if code := env.check(t, "order-1"); code != http.StatusOK {
+		t.Errorf("check after the failures answered %d, want 200", code)
+	}
+
+	// Сбой отмены не меняет платеж
+	env.server.Gateway.Fail(fakegateway.Failure{Op: fakegateway.OpCancel, StatusCode: http.StatusBadGateway})
+	if code, _ := env.cancel(t, paymentID, nil); code != http.StatusBadGateway {
+		t.Errorf("scripted cancel answered %d, want 502", code)
+	}
+	if status := env.payment(t, paymentID).Status; status != fakegateway.StatusNew {
+		t.Errorf("payment is %s after a failed cancel, want NEW", status)
+	}
+
+	// Банк отклоняет карту, даже если форма отправлена с успехом
+	env.server.Gateway.Fail(fakegateway.Failure{Op: fakegateway.OpPay, OrderID: "order-1"})
+	if err := env.server.Gateway.Pay(paymentID); err != nil {
+		t.Fatal(err)
+	}
+	if status := env.payment(t, paymentID).Status; status != fakegateway.StatusRejected {
+		t.Errorf("payment with a scripted pay failure is %s, want REJECTED", status)
+	}
+}
+
+func TestScriptedNotificationFailures(t *testing.T) {
+	var delivered atomic.Int64
+	merchant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered.Add(1)
+	}))
+	t.Cleanup(merchant.Close)
+
+	env := newGatewayEnv(t)
+	pay := func(orderID string) {
+		t.Helper()
+		code, paymentID := env.initPayment(t, orderID, 10000, "O", merchant.URL)
+		if code != http.StatusOK {
+			t.Fatalf("init answered %d", code)
+		}
+		if err := env.server.Gateway.Pay(paymentID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env.server.Gateway.Fail(fakegateway.Failure{Op: fakegateway.OpNotify, OrderID: "dropped"})
+	env.server.Gateway.Fail(fakegateway.Failure{Op: fakegateway.OpNotify, OrderID: "duplicated", Duplicate: true})
+	pay("dropped")
+	pay("duplicated")
+	pay("delivered")
+
+	counts := make(map[string]int)
+	for _, n := range env.server.Gateway.Notifications() {
+		if n.Dropped {
+			counts[n.OrderID+" dropped"]++
+			continue
+		}
+		if n.StatusCode != http.StatusOK || n.Status != fakegateway.StatusConfirmed {
+			t.Errorf("notification of %s: %s answered %d", n.OrderID, n.Status, n.StatusCode)
+		}
+		counts[n.OrderID]++
+	}
+
+	want := map[string]int{"dropped dropped": 1, "duplicated": 2, "delivered": 1}
+	for key, count := range want {
+		if counts[key] != count {
+			t.Errorf("notifications %q: %d, want %d", key, counts[key], count)
+		}
+	}
+	if delivered.Load() != 3 {
+		t.Errorf("merchant received %d notifications, want 3", delivered.Load())
+	}
+}
@@ -0,0 +1,453 @@
+package fakegateway
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"hackload/internal/paymenttoken"
+	"hackload/pkg/paymentgateway"
+)
+
+var _ http.Handler = (*Gateway)(nil)
+
+func (g *Gateway) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/PaymentInit/init", g.handleInit)
+	mux.HandleFunc("GET /api/v1/PaymentInit/session/{paymentId}", g.handleSession)
+	mux.HandleFunc("POST /api/v1/PaymentCheck/check", g.handleCheck)
+	mux.HandleFunc("GET /api/v1/PaymentCheck/status", g.handleStatus)
+	mux.HandleFunc("POST /api/v1/PaymentConfirm/confirm", g.handleConfirm)
+	mux.HandleFunc("POST /api/v1/PaymentCancel/cancel", g.handleCancel)
+	mux.HandleFunc("GET /api/v1/PaymentForm/render/{paymentId}", g.handleFormRender)
+	mux.HandleFunc("POST /api/v1/PaymentForm/submit", g.handleFormSubmit)
+	mux.HandleFunc("GET /api/v1/PaymentForm/result/{paymentId}", g.handleFormResult)
+	g.controlRoutes(mux)
+	return mux
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// (POST /api/v1/PaymentInit/init)
+func (g *Gateway) handleInit(w http.ResponseWriter, r *http.Request) {
+	var req paymentgateway.PaymentInitRequestDto
+	if !g.readSigned(w, r, &req) {
+		return
+	}
+
+	amount, ok := minorUnits(req.Amount)
+	if !ok || amount <= 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", "amount must be a positive number of minor units")
+		return
+	}
+	if req.OrderId == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_ORDER_ID", "orderId is required")
+		return
+	}
+
+	if g.fault(w, OpInit, req.OrderId) {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.orders[orderKey(req.TeamSlug, req.OrderId)]; ok {
+		writeError(w, http.StatusUnprocessableEntity, "DUPLICATE_ORDER", "payment for order "+req.OrderId+" already exists")
+		return
+	}
+
+	baseURL := g.baseURL
+	if baseURL == "" {
+		baseURL = "http://" + r.Host
+	}
+
+	now := g.now().UTC()
+	p := &Payment{
+		PaymentID:       g.nextPaymentID(),
+		OrderID:         req.OrderId,
+		TeamSlug:        req.TeamSlug,
+		Amount:          amount,
+		Currency:        stringValue(req.Currency, "KZT"),
+		Description:     stringValue(req.Description, ""),
+		TwoStage:        stringValue(req.PayType, "O") == "T",
+		Status:          StatusNew,
+		SuccessURL:      stringValue(req.SuccessURL, ""),
+		FailURL:         stringValue(req.FailURL, ""),
+		NotificationURL: stringValue(req.NotificationURL, ""),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		ExpiresAt:       now.Add(g.paymentTTL),
+	}
+	g.payments[p.PaymentID] = p
+	g.orders[orderKey(p.TeamSlug, p.OrderID)] = p.PaymentID
+
+	paymentURL := strings.TrimSuffix(baseURL, "/") + "/api/v1/PaymentForm/render/" + url.PathEscape(p.PaymentID)
+	writeJSON(w, http.StatusOK, paymentgateway.PaymentInitResponseDto{
+		Success:    ptr(true),
+		PaymentId:  &p.PaymentID,
+		OrderId:    &p.OrderID,
+		Status:     &p.Status,
+		Amount:     ptr(float64(p.Amount)),
+		Currency:   &p.Currency,
+		PaymentURL: &paymentURL,
+		CreatedAt:  &p.CreatedAt,
+		ExpiresAt:  &p.ExpiresAt,
+	})
+}
+
+// (GET /api/v1/PaymentInit/session/{paymentId})
+func (g *Gateway) handleSession(w http.ResponseWriter, r *http.Request) {
+	p, ok := g.Payment(r.PathValue("paymentId"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "PAYMENT_NOT_FOUND", "payment not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, statusDto(p))
+}
+
+// (POST /api/v1/PaymentCheck/check)
+func (g *Gateway) handleCheck(w http.ResponseWriter, r *http.Request) {
+	var req paymentgateway.PaymentCheckRequestDto
+	if !g.readSigned(w, r, &req) {
+		return
+	}
+	g.check(w, req.TeamSlug, stringValue(req.PaymentId, ""), stringValue(req.OrderId, ""))
+}
+
+// (GET /api/v1/PaymentCheck/status)
+func (g *Gateway) handleStatus(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// Подписываются все параметры запроса, кроме самого токена
+	params := make(map[string]string, len(query))
+	for key := range query {
+		if key != "token" {
+			params[key] = query.Get(key)
+		}
+	}
+
+	teamSlug := query.Get("teamSlug")
+	if !g.verify(w, teamSlug, func(s *paymenttoken.Signer) bool {
+		return s.Verify(params, query.Get("token"))
+	}) {
+		return
+	}
+	g.check(w, teamSlug, query.Get("paymentId"), query.Get("orderId"))
+}
+
+func (g *Gateway) check(w http.ResponseWriter, teamSlug, paymentID, orderID string) {
+	if paymentID == "" && orderID == "" {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "paymentId or orderId is required")
+		return
+	}
+
+	p, ok := g.find(teamSlug, paymentID, orderID)
+	if g.fault(w, OpCheck, p.OrderID) {
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "PAYMENT_NOT_FOUND", "payment not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, paymentgateway.PaymentCheckResponseDto{
+		Success:    ptr(true),
+		OrderId:    &p.OrderID,
+		Payments:   &[]paymentgateway.PaymentStatusDto{statusDto(p)},
+		TotalCount: ptr(int32(1)),
+	})
+}
+
+// (POST /api/v1/PaymentConfirm/confirm)
+func (g *Gateway) handleConfirm(w http.ResponseWriter, r *http.Request) {
+	var req paymentgateway.PaymentConfirmRequestDto
+	if !g.readSigned(w, r, &req) {
+		return
+	}
+
+	p, ok := g.find(req.TeamSlug, req.PaymentId, "")
+	if g.fault(w, OpConfirm, p.OrderID) {
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "PAYMENT_NOT_FOUND", "payment not found")
+		return
+	}
+
+	g.mu.Lock()
+	payment := g.payments[p.PaymentID]
+	g.expire(payment)
+
+	// Подтверждается вся заблокированная сумма или ее часть
+	amount := payment.Amount
+	if req.Amount != nil {
+		var ok bool
+		if amount, ok = minorUnits(*req.Amount); !ok || amount <= 0 || amount > payment.Amount {
+			g.mu.Unlock()
+			writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", "amount must be positive and not exceed the authorized amount")
+			return
+		}
+	}
+
+	if err := g.transition(payment, StatusConfirmed); err != nil {
+		g.mu.Unlock()
+		writeError(w, http.StatusConflict, "INVALID_STATUS", err.Error())
+		return
+	}
+	payment.ConfirmedAmount = amount
+	snapshot := *payment
+	g.mu.Unlock()
+
+	g.notify(snapshot)
+
+	writeJSON(w, http.StatusOK, paymentgateway.PaymentConfirmResponseDto{
+		Success:          ptr(true),
+		PaymentId:        &snapshot.PaymentID,
+		OrderId:          &snapshot.OrderID,
+		Status:           &snapshot.Status,
+		AuthorizedAmount: ptr(float64(snapshot.Amount)),
+		ConfirmedAmount:  ptr(float64(snapshot.ConfirmedAmount)),
+		RemainingAmount:  ptr(float64(0)),
+		Currency:         &snapshot.Currency,
+		ConfirmedAt:      &snapshot.UpdatedAt,
+	})
+}
+
+// (POST /api/v1/PaymentCancel/cancel)
+func (g *Gateway) handleCancel(w http.ResponseWriter, r *http.Request) {
+	var req paymentgateway.PaymentCancelRequestDto
+	if !g.readSigned(w, r, &req) {
+		return
+	}
+
+	p, ok := g.find(req.TeamSlug, req.PaymentId, "")
+	if g.fault(w, OpCancel, p.OrderID) {
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "PAYMENT_NOT_FOUND", "payment not found")
+		return
+	}
+
+	g.mu.Lock()
+	payment := g.payments[p.PaymentID]
+	g.expire(payment)
+
+	// Отмена до оплаты и снятие блокировки всегда полные, возврат может быть частичным
+	cancelled := payment.Amount
+	cancellationType := "FULL"
+	var status string
+	switch payment.Status {
+	case StatusNew, StatusFormShowed:
+		status = StatusCancelled
+	case StatusAuthorized:
+		status = StatusReversed
+	case StatusConfirmed, StatusPartialRefunded:
+		remaining := payment.ConfirmedAmount - payment.RefundedAmount
+		cancelled = remaining
+		if req.Amount != nil {
+			var ok bool
+			if cancelled, ok = minorUnits(*req.Amount); !ok || cancelled <= 0 {
+				g.mu.Unlock()
+				writeError(w, http.StatusBadRequest, "INVALID_AMOUNT", "amount must be a positive number of minor units")
+				return
+			}
+		}
+		if cancelled > remaining {
+			g.mu.Unlock()
+			writeError(w, http.StatusUnprocessableEntity, "AMOUNT_EXCEEDS_REMAINING", "refund exceeds the remaining amount")
+			return
+		}
+		status = StatusRefunded
+		if cancelled < remaining {
+			status = StatusPartialRefunded
+			cancellationType = "PARTIAL"
+		}
+	default:
+		status = payment.Status
+	}
+
+	if err := g.transition(payment, status); err != nil {
+		g.mu.Unlock()
+		writeError(w, http.StatusConflict, "INVALID_STATUS", err.Error())
+		return
+	}
+	if status == StatusRefunded || status == StatusPartialRefunded {
+		payment.RefundedAmount += cancelled
+	}
+	snapshot := *payment
+	g.mu.Unlock()
+
+	g.notify(snapshot)
+
+	writeJSON(w, http.StatusOK, paymentgateway.PaymentCancelResponseDto{
+		Success:          ptr(true),
+		PaymentId:        &snapshot.PaymentID,
+		OrderId:          &snapshot.OrderID,
+		Status:           &snapshot.Status,
+		CancellationType: &cancellationType,
+		CancelledAmount:  ptr(float64(cancelled)),
+		OriginalAmount:   ptr(float64(snapshot.Amount)),
+		RemainingAmount:  ptr(float64(snapshot.ConfirmedAmount - snapshot.RefundedAmount)),
+		Currency:         &snapshot.Currency,
+		CancelledAt:      &snapshot.UpdatedAt,
+	})
+}
+
+// readSigned decodes a signed JSON request into dto. It answers 400 for a malformed body
+// and 401 for an unknown team or a wrong token.
+func (g *Gateway) readSigned(w http.ResponseWriter, r *http.Request, dto any) bool {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "failed to read request body")
+		return false
+	}
+
+	var team struct {
+		TeamSlug string `json:"teamSlug"`
+	}
+	if err := json.Unmarshal(body, &team); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", "request body is not a JSON object")
+		return false
+	}
+
+	if !g.verify(w, team.TeamSlug, func(s *paymenttoken.Signer) bool {
+		ok, err := s.VerifyJSON(body)
+		return err == nil && ok
+	}) {
+		return false
+	}
+
+	if err := json.Unmarshal(body, dto); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		return false
+	}
+	return true
+}
+
+func (g *Gateway) verify(w http.ResponseWriter, teamSlug string, verify func(s *paymenttoken.Signer) bool) bool {
+	g.mu.Lock()
+	password, ok := g.teams[teamSlug]
+	g.mu.Unlock()
+
+	if !ok {
+		writeProblem(w, http.StatusUnauthorized, "Unknown team", "team "+teamSlug+" is not registered")
+		return false
+	}
+	if !verify(paymenttoken.NewSigner(password)) {
+		writeProblem(w, http.StatusUnauthorized, "Invalid token", "token does not match the request")
+		return false
+	}
+	return true
+}
+
+// find returns a snapshot of the team's payment by id or, if paymentID is empty, by order.
+func (g *Gateway) find(teamSlug, paymentID, orderID string) (Payment, bool) {
+	if paymentID == "" {
+		return g.PaymentByOrder(teamSlug, orderID)
+	}
+	p, ok := g.Payment(paymentID)
+	if !ok || p.TeamSlug != teamSlug {
+		return Payment{}, false
+	}
+	return p, true
+}
+
+// fault applies a scripted failure of op and reports whether the request was answered.
+func (g *Gateway) fault(w http.ResponseWriter, op Operation, orderID string) bool {
+	g.mu.Lock()
+	f := g.takeFailure(op, orderID)
+	g.mu.Unlock()
+
+	if f == nil {
+		return false
+	}
+
+	time.Sleep(f.Delay)
+	if f.StatusCode == 0 && f.Delay > 0 {
+		return false
+	}
+
+	status := f.StatusCode
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	writeError(w, status, "SCRIPTED_FAILURE", "scripted "+string(op)+" failure")
+	return true
+}
+
+func statusDto(p Payment) paymentgateway.PaymentStatusDto {
+	payType := "O"
+	if p.TwoStage {
+		payType = "T"
+	}
+	return paymentgateway.PaymentStatusDto{
+		PaymentId:   &p.PaymentID,
+		OrderId:     &p.OrderID,
+		Status:      &p.Status,
+		Amount:      ptr(float64(p.Amount)),
+		Currency:    &p.Currency,
+		Description: &p.Description,
+		PayType:     &payType,
+		CreatedAt:   &p.CreatedAt,
+		UpdatedAt:   &p.UpdatedAt,
+		ExpiresAt:   &p.ExpiresAt,
+		Amounts: &paymentgateway.PaymentAmountsDto{
+			OriginalAmount:  ptr(float64(p.Amount)),
+			ConfirmedAmount: ptr(float64(p.ConfirmedAmount)),
+			RefundedAmount:  ptr(float64(p.RefundedAmount)),
+			RemainingAmount: ptr(float64(p.ConfirmedAmount - p.RefundedAmount)),
+		},
+	}
+}
+
+// minorUnits converts a DTO amount, which must be a whole number of minor units.
+func minorUnits(amount float64) (int64, bool) {
+	if amount != math.Trunc(amount) || math.Abs(amount) > math.MaxInt64/2 {
+		return 0, false
+	}
+	return int64(amount), true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeError answers in the shape shared by all response DTOs: success, errorCode, message.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]any{
+		"success":   false,
+		"errorCode": code,
+		"message":   message,
+	})
+}
+
+func writeProblem(w http.ResponseWriter, status int, title, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(paymentgateway.ProblemDetails{
+		Status: ptr(int32(status)),
+		Title:  &title,
+		Detail: &detail,
+	})
+}
+
+func stringValue(s *string, fallback string) string {
+	if s == nil || *s == "" {
+		return fallback
+	}
+	return *s
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,108 @@
+package fakegateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"hackload/internal/paymenttoken"
+)
+
+// notificationPayload is the webhook body, signed like every gateway request.
+type notificationPayload struct {
+	TeamSlug  string    `json:"teamSlug"`
+	OrderID   string    `json:"orderId"`
+	PaymentID string    `json:"paymentId"`
+	Status    string    `json:"status"`
+	Amount    int64     `json:"amount"`
+	Currency  string    `json:"currency"`
+	Timestamp time.Time `json:"timestamp"`
+	Token     string    `json:"token"`
+}
+
+// notify sends the payment status to the merchant's NotificationURL. The delivery is
+// synchronous and never retried, scripted OpNotify failures delay, duplicate or drop it.
+func (g *Gateway) notify(p Payment) {
+	if p.NotificationURL == "" {
+		return
+	}
+
+	g.mu.Lock()
+	password := g.teams[p.TeamSlug]
+	failure := g.takeFailure(OpNotify, p.OrderID)
+	g.mu.Unlock()
+
+	payload := notificationPayload{
+		TeamSlug:  p.TeamSlug,
+		OrderID:   p.OrderID,
+		PaymentID: p.PaymentID,
+		Status:    p.Status,
+		Amount:    p.Amount,
+		Currency:  p.Currency,
+		Timestamp: g.now().UTC(),
+	}
+	token, err := paymenttoken.NewSigner(password).Sign(payload)
+	if err != nil {
+		g.logger.Error("failed to sign notification", "payment_id", p.PaymentID, "error", err)
+		return
+	}
+	payload.Token = token
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		g.logger.Error("failed to marshal notification", "payment_id", p.PaymentID, "error", err)
+		return
+	}
+
+	deliveries := 1
+	if failure != nil {
+		time.Sleep(failure.Delay)
+		switch {
+		case failure.Duplicate:
+			deliveries = 2
+		case failure.Delay == 0:
+			deliveries = 0
+		}
+	}
+
+	if deliveries == 0 {
+		g.record(Notification{
+			PaymentID: p.PaymentID,
+			OrderID:   p.OrderID,
+			Status:    p.Status,
+			URL:       p.NotificationURL,
+			Body:      body,
+			Dropped:   true,
+			SentAt:    g.now().UTC(),
+		})
+		return
+	}
+
+	for range deliveries {
+		n := Notification{
+			PaymentID: p.PaymentID,
+			OrderID:   p.OrderID,
+			Status:    p.Status,
+			URL:       p.NotificationURL,
+			Body:      body,
+			SentAt:    g.now().UTC(),
+		}
+
+		resp, err := g.client.Post(p.NotificationURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			n.Err = err
+			g.logger.Warn("failed to deliver notification", "payment_id", p.PaymentID, "url", p.NotificationURL, "error", err)
+		} else {
+			n.StatusCode = resp.StatusCode
+			resp.Body.Close()
+		}
+
+		g.record(n)
+	}
+}
+
+func (g *Gateway) record(n Notification) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.notifications = append(g.notifications, n)
+}
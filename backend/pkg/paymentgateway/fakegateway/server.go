@@ -0,0 +1,31 @@
+package fakegateway
+
+import (
+	"net/http/httptest"
+
+	"hackload/pkg/paymentgateway"
+)
+
+// Server is a fake gateway listening on a local httptest server, for integration tests.
+type Server struct {
+	*httptest.Server
+	Gateway *Gateway
+}
+
+// NewServer starts a fake gateway. Unless WithBaseURL is given, payment URLs point at the
+// test server. The caller should Close it when finished.
+func NewServer(opts ...Option) *Server {
+	g := New(opts...)
+	srv := httptest.NewServer(g)
+
+	if g.baseURL == "" {
+		g.baseURL = srv.URL
+	}
+
+	return &Server{Server: srv, Gateway: g}
+}
+
+// PaymentClient returns a generated client for the server, as the booking service builds it.
+func (s *Server) PaymentClient() (*paymentgateway.Client, error) {
+	return paymentgateway.NewClient(s.URL)
+}